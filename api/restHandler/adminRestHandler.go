@@ -0,0 +1,105 @@
+package restHandler
+
+import (
+	"encoding/json"
+	"errors"
+	"expvar"
+	"github.com/caarlos0/env"
+	"github.com/go-pg/pg"
+	muxContext "github.com/gorilla/context"
+	"github.com/gorilla/mux"
+	"github.com/iraunit/get-link-backend/pkg/services"
+	"github.com/iraunit/get-link-backend/util/bean"
+	"go.uber.org/zap"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+type AdminRestHandler interface {
+	GetOutboxMessages(w http.ResponseWriter, r *http.Request)
+	RetryOutboxMessage(w http.ResponseWriter, r *http.Request)
+	GetMetrics(w http.ResponseWriter, r *http.Request)
+}
+
+type AdminRestHandlerImpl struct {
+	logger        *zap.SugaredLogger
+	cfg           bean.AdminCfg
+	outboxService services.OutboxService
+}
+
+func NewAdminRestHandlerImpl(logger *zap.SugaredLogger, outboxService services.OutboxService) *AdminRestHandlerImpl {
+	cfg := bean.AdminCfg{}
+	if err := env.Parse(&cfg); err != nil {
+		logger.Fatal("Error loading Cfg from env", "Error", zap.Error(err))
+	}
+	return &AdminRestHandlerImpl{
+		logger:        logger,
+		cfg:           cfg,
+		outboxService: outboxService,
+	}
+}
+
+func (impl *AdminRestHandlerImpl) GetOutboxMessages(w http.ResponseWriter, r *http.Request) {
+	if !impl.isAdmin(w, r) {
+		return
+	}
+	limit, err := strconv.Atoi(r.URL.Query().Get("limit"))
+	if err != nil || limit <= 0 || limit > 500 {
+		limit = 100
+	}
+	messages, err := impl.outboxService.GetMessages(r.URL.Query().Get("status"), limit)
+	if err != nil {
+		impl.logger.Errorw("Error in getting outbox messages", "Error: ", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		_ = json.NewEncoder(w).Encode(bean.Response{StatusCode: 500, Error: "Error in getting outbox messages"})
+		return
+	}
+	_ = json.NewEncoder(w).Encode(bean.Response{StatusCode: 200, Result: messages})
+}
+
+func (impl *AdminRestHandlerImpl) RetryOutboxMessage(w http.ResponseWriter, r *http.Request) {
+	if !impl.isAdmin(w, r) {
+		return
+	}
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		_ = json.NewEncoder(w).Encode(bean.Response{StatusCode: 400, Error: "Invalid message id"})
+		return
+	}
+	err = impl.outboxService.Retry(id)
+	if err != nil {
+		if errors.Is(err, pg.ErrNoRows) {
+			w.WriteHeader(http.StatusNotFound)
+			_ = json.NewEncoder(w).Encode(bean.Response{StatusCode: 404, Error: "Message not found"})
+			return
+		}
+		impl.logger.Errorw("Error in retrying outbox message", "Error: ", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		_ = json.NewEncoder(w).Encode(bean.Response{StatusCode: 500, Error: "Error in retrying outbox message"})
+		return
+	}
+	_ = json.NewEncoder(w).Encode(bean.Response{StatusCode: 200, Result: "Message queued for retry"})
+}
+
+func (impl *AdminRestHandlerImpl) GetMetrics(w http.ResponseWriter, r *http.Request) {
+	if !impl.isAdmin(w, r) {
+		return
+	}
+	expvar.Handler().ServeHTTP(w, r)
+}
+
+func (impl *AdminRestHandlerImpl) isAdmin(w http.ResponseWriter, r *http.Request) bool {
+	userEmail := muxContext.Get(r, "email").(string)
+	isAdmin := slices.ContainsFunc(impl.cfg.AdminEmails, func(adminEmail string) bool {
+		return strings.EqualFold(adminEmail, userEmail)
+	})
+	if !isAdmin {
+		w.WriteHeader(http.StatusForbidden)
+		_ = json.NewEncoder(w).Encode(bean.Response{StatusCode: 403, Error: "Forbidden"})
+		return false
+	}
+	return true
+}
@@ -0,0 +1,93 @@
+package restHandler
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/caarlos0/env"
+	"github.com/go-pg/pg"
+	"github.com/golang-jwt/jwt/v5"
+	muxContext "github.com/gorilla/context"
+	"github.com/gorilla/mux"
+	"github.com/iraunit/get-link-backend/pkg/services"
+	"github.com/iraunit/get-link-backend/util/bean"
+	"go.uber.org/zap"
+	"net/http"
+	"net/url"
+)
+
+type ChannelRestHandler interface {
+	GetChannels(w http.ResponseWriter, r *http.Request)
+	UnlinkChannel(w http.ResponseWriter, r *http.Request)
+	VerifyChannelEmail(w http.ResponseWriter, r *http.Request)
+}
+
+type ChannelRestHandlerImpl struct {
+	logger         *zap.SugaredLogger
+	cfg            bean.TokenConfig
+	channelService services.ChannelService
+}
+
+func NewChannelRestHandlerImpl(logger *zap.SugaredLogger, channelService services.ChannelService) *ChannelRestHandlerImpl {
+	cfg := bean.TokenConfig{}
+	if err := env.Parse(&cfg); err != nil {
+		logger.Fatal("Error loading Cfg from env", "Error", zap.Error(err))
+	}
+	return &ChannelRestHandlerImpl{
+		logger:         logger,
+		cfg:            cfg,
+		channelService: channelService,
+	}
+}
+
+func (impl *ChannelRestHandlerImpl) GetChannels(w http.ResponseWriter, r *http.Request) {
+	userEmail := muxContext.Get(r, "email").(string)
+	accounts, err := impl.channelService.GetLinkedAccounts(userEmail)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		_ = json.NewEncoder(w).Encode(bean.Response{StatusCode: 500, Error: "Error in getting linked channels"})
+		return
+	}
+	if accounts == nil {
+		accounts = []bean.LinkedAccount{}
+	}
+	_ = json.NewEncoder(w).Encode(bean.Response{StatusCode: 200, Result: accounts})
+}
+
+func (impl *ChannelRestHandlerImpl) UnlinkChannel(w http.ResponseWriter, r *http.Request) {
+	userEmail := muxContext.Get(r, "email").(string)
+	err := impl.channelService.UnlinkAccount(userEmail, mux.Vars(r)["id"])
+	if err != nil {
+		if errors.Is(err, pg.ErrNoRows) {
+			w.WriteHeader(http.StatusNotFound)
+			_ = json.NewEncoder(w).Encode(bean.Response{StatusCode: 404, Error: "Linked channel not found"})
+			return
+		}
+		w.WriteHeader(http.StatusInternalServerError)
+		_ = json.NewEncoder(w).Encode(bean.Response{StatusCode: 500, Error: "Error in unlinking channel"})
+		return
+	}
+	_ = json.NewEncoder(w).Encode(bean.Response{StatusCode: 200, Result: "Channel unlinked"})
+}
+
+func (impl *ChannelRestHandlerImpl) VerifyChannelEmail(w http.ResponseWriter, r *http.Request) {
+	tokenStr, _ := url.QueryUnescape(r.URL.Query().Get("token"))
+
+	claims := bean.ChannelVerificationClaims{}
+	_, err := jwt.ParseWithClaims(tokenStr, &claims, func(t *jwt.Token) (interface{}, error) {
+		return []byte(impl.cfg.JwtKey), nil
+	})
+	if err != nil {
+		impl.logger.Errorw("Unauthorised Request. Invalid token.")
+		w.WriteHeader(http.StatusBadRequest)
+		_ = json.NewEncoder(w).Encode(bean.Response{StatusCode: 400, Error: "Error parsing token."})
+		return
+	}
+
+	err = impl.channelService.VerifyIdentity(&claims)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		_ = json.NewEncoder(w).Encode(bean.Response{StatusCode: 400, Error: "Error in verifying link"})
+		return
+	}
+	_ = json.NewEncoder(w).Encode(bean.Response{StatusCode: 200, Result: "Link verified successfully"})
+}
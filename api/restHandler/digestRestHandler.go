@@ -0,0 +1,180 @@
+package restHandler
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/caarlos0/env"
+	"github.com/go-pg/pg"
+	"github.com/golang-jwt/jwt/v5"
+	muxContext "github.com/gorilla/context"
+	"github.com/iraunit/get-link-backend/pkg/services"
+	"github.com/iraunit/get-link-backend/util"
+	"github.com/iraunit/get-link-backend/util/bean"
+	"go.uber.org/zap"
+	"html/template"
+	"net/http"
+	"net/url"
+)
+
+// digestActionPage asks to confirm a digest mail action. Links in mails get opened by scanners and previews, so only
+// the POST it submits, or a one-click POST from the mail client, changes anything.
+var digestActionPage = template.Must(template.New("digestAction").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="UTF-8">
+<meta name="viewport" content="width=device-width, initial-scale=1.0">
+<title>{{.Title}}</title>
+</head>
+<body style="font-family:Arial,Helvetica,sans-serif;color:#1f2933;text-align:center;padding:48px 16px;">
+<h2>{{.Title}}</h2>
+{{if .Button}}<form method="post"><button type="submit" style="background:#2563eb;color:#ffffff;padding:10px 18px;border:0;border-radius:6px;font-size:15px;cursor:pointer;">{{.Button}}</button></form>{{end}}
+</body>
+</html>`))
+
+type digestActionPageData struct {
+	Title  string
+	Button string
+}
+
+type DigestRestHandler interface {
+	GetDigest(w http.ResponseWriter, r *http.Request)
+	SaveDigest(w http.ResponseWriter, r *http.Request)
+	DeleteDigest(w http.ResponseWriter, r *http.Request)
+	ConfirmUnsubscribe(w http.ResponseWriter, r *http.Request)
+	Unsubscribe(w http.ResponseWriter, r *http.Request)
+	ConfirmMarkAllRead(w http.ResponseWriter, r *http.Request)
+	MarkAllRead(w http.ResponseWriter, r *http.Request)
+}
+
+type DigestRestHandlerImpl struct {
+	logger        *zap.SugaredLogger
+	digestService services.DigestService
+	cfg           bean.DigestCfg
+}
+
+func NewDigestRestHandlerImpl(logger *zap.SugaredLogger, digestService services.DigestService) *DigestRestHandlerImpl {
+	cfg := bean.DigestCfg{}
+	if err := env.Parse(&cfg); err != nil {
+		logger.Fatal("Error loading Cfg from env", "Error", zap.Error(err))
+	}
+	return &DigestRestHandlerImpl{
+		logger:        logger,
+		digestService: digestService,
+		cfg:           cfg,
+	}
+}
+
+func (impl *DigestRestHandlerImpl) GetDigest(w http.ResponseWriter, r *http.Request) {
+	userEmail := muxContext.Get(r, "email").(string)
+	subscription, err := impl.digestService.GetSubscription(userEmail)
+	if err != nil {
+		if errors.Is(err, pg.ErrNoRows) {
+			w.WriteHeader(http.StatusNotFound)
+			_ = json.NewEncoder(w).Encode(bean.Response{StatusCode: 404, Error: "You are not subscribed to the digest."})
+			return
+		}
+		impl.logger.Errorw("Error in getting digest subscription", "Error: ", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		_ = json.NewEncoder(w).Encode(bean.Response{StatusCode: 500, Error: "Error in getting digest subscription"})
+		return
+	}
+	_ = json.NewEncoder(w).Encode(bean.Response{StatusCode: 200, Result: subscription})
+}
+
+func (impl *DigestRestHandlerImpl) SaveDigest(w http.ResponseWriter, r *http.Request) {
+	userEmail := muxContext.Get(r, "email").(string)
+
+	var subscription bean.DigestSubscription
+	err := json.NewDecoder(r.Body).Decode(&subscription)
+	if err != nil {
+		impl.logger.Errorw("Error in decoding request body", "Error: ", err)
+		w.WriteHeader(http.StatusBadRequest)
+		_ = json.NewEncoder(w).Encode(bean.Response{StatusCode: 400, Error: "Error in decoding request body"})
+		return
+	}
+
+	err = impl.digestService.Subscribe(userEmail, &subscription)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		_ = json.NewEncoder(w).Encode(bean.Response{StatusCode: 400, Error: err.Error()})
+		return
+	}
+	_ = json.NewEncoder(w).Encode(bean.Response{StatusCode: 200, Result: "Digest subscription saved"})
+}
+
+func (impl *DigestRestHandlerImpl) DeleteDigest(w http.ResponseWriter, r *http.Request) {
+	userEmail := muxContext.Get(r, "email").(string)
+	err := impl.digestService.Unsubscribe(userEmail)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		_ = json.NewEncoder(w).Encode(bean.Response{StatusCode: 500, Error: "Error in deleting digest subscription"})
+		return
+	}
+	_ = json.NewEncoder(w).Encode(bean.Response{StatusCode: 200, Result: "Unsubscribed from digest"})
+}
+
+func (impl *DigestRestHandlerImpl) ConfirmUnsubscribe(w http.ResponseWriter, r *http.Request) {
+	if _, ok := impl.parseActionToken(w, r, util.DigestActionUnsubscribe); !ok {
+		return
+	}
+	impl.writePage(w, digestActionPageData{Title: "Unsubscribe from the digest?", Button: "Unsubscribe"})
+}
+
+func (impl *DigestRestHandlerImpl) Unsubscribe(w http.ResponseWriter, r *http.Request) {
+	claims, ok := impl.parseActionToken(w, r, util.DigestActionUnsubscribe)
+	if !ok {
+		return
+	}
+	err := impl.digestService.Unsubscribe(claims.Email)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		_ = json.NewEncoder(w).Encode(bean.Response{StatusCode: 500, Error: "Error in deleting digest subscription"})
+		return
+	}
+	impl.writePage(w, digestActionPageData{Title: "Unsubscribed from digest"})
+}
+
+func (impl *DigestRestHandlerImpl) ConfirmMarkAllRead(w http.ResponseWriter, r *http.Request) {
+	if _, ok := impl.parseActionToken(w, r, util.DigestActionMarkRead); !ok {
+		return
+	}
+	impl.writePage(w, digestActionPageData{Title: "Mark all links as read?", Button: "Mark all as read"})
+}
+
+func (impl *DigestRestHandlerImpl) MarkAllRead(w http.ResponseWriter, r *http.Request) {
+	claims, ok := impl.parseActionToken(w, r, util.DigestActionMarkRead)
+	if !ok {
+		return
+	}
+	err := impl.digestService.MarkAllRead(claims.Email)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		_ = json.NewEncoder(w).Encode(bean.Response{StatusCode: 500, Error: "Error in marking links as read"})
+		return
+	}
+	impl.writePage(w, digestActionPageData{Title: "All links marked as read"})
+}
+
+func (impl *DigestRestHandlerImpl) writePage(w http.ResponseWriter, data digestActionPageData) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := digestActionPage.Execute(w, data); err != nil {
+		impl.logger.Errorw("Error in rendering digest page", "Error", err)
+	}
+}
+
+func (impl *DigestRestHandlerImpl) parseActionToken(w http.ResponseWriter, r *http.Request, action string) (*bean.DigestClaims, bool) {
+	tokenStr, _ := url.QueryUnescape(r.URL.Query().Get("token"))
+
+	claims := bean.DigestClaims{}
+	_, err := jwt.ParseWithClaims(tokenStr, &claims, func(t *jwt.Token) (interface{}, error) {
+		return []byte(impl.cfg.JwtKey), nil
+	}, jwt.WithAudience(util.DigestAudience))
+
+	if err != nil || claims.Action != action {
+		impl.logger.Errorw("Unauthorised Request. Invalid token.")
+		w.WriteHeader(http.StatusBadRequest)
+		_ = json.NewEncoder(w).Encode(bean.Response{StatusCode: 400, Error: "Error parsing token."})
+		return nil, false
+	}
+	return &claims, true
+}
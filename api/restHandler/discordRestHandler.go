@@ -0,0 +1,97 @@
+package restHandler
+
+import (
+	"crypto/ed25519"
+	"encoding/hex"
+	"encoding/json"
+	"github.com/caarlos0/env"
+	"github.com/iraunit/get-link-backend/pkg/services"
+	"github.com/iraunit/get-link-backend/util"
+	"github.com/iraunit/get-link-backend/util/bean"
+	"go.uber.org/zap"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+type DiscordRestHandler interface {
+	HandleInteraction(w http.ResponseWriter, r *http.Request)
+}
+
+type DiscordRestHandlerImpl struct {
+	logger         *zap.SugaredLogger
+	cfg            bean.DiscordCfg
+	publicKey      ed25519.PublicKey
+	discordService services.DiscordService
+}
+
+func NewDiscordRestHandlerImpl(logger *zap.SugaredLogger, discordService services.DiscordService) *DiscordRestHandlerImpl {
+	cfg := bean.DiscordCfg{}
+	if err := env.Parse(&cfg); err != nil {
+		logger.Fatal("Error loading Cfg from env", "Error", zap.Error(err))
+	}
+	var publicKey ed25519.PublicKey
+	if cfg.PublicKey == "" {
+		logger.Infow("DISCORD_PUBLIC_KEY is not set, Discord requests are rejected")
+	} else if key, err := hex.DecodeString(cfg.PublicKey); err != nil || len(key) != ed25519.PublicKeySize {
+		logger.Fatal("DISCORD_PUBLIC_KEY must be a hex encoded Ed25519 public key")
+	} else {
+		publicKey = key
+	}
+	return &DiscordRestHandlerImpl{
+		logger:         logger,
+		cfg:            cfg,
+		publicKey:      publicKey,
+		discordService: discordService,
+	}
+}
+
+func (impl *DiscordRestHandlerImpl) HandleInteraction(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		impl.logger.Errorw("Error reading request body", "Error: ", err)
+		http.Error(w, "Error reading request body", http.StatusInternalServerError)
+		return
+	}
+	if !impl.verifySignature(w, r, body) {
+		return
+	}
+	var interaction bean.DiscordInteraction
+	if err = json.Unmarshal(body, &interaction); err != nil {
+		impl.logger.Errorw("Error in decoding discord interaction", "Error", err)
+		w.WriteHeader(http.StatusBadRequest)
+		_ = json.NewEncoder(w).Encode(bean.Response{StatusCode: 400, Error: "Error in decoding request body"})
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(impl.discordService.HandleInteraction(&interaction))
+}
+
+// verifySignature checks X-Signature-Ed25519 over timestamp+body. Discord disables endpoints that accept bad signatures.
+func (impl *DiscordRestHandlerImpl) verifySignature(w http.ResponseWriter, r *http.Request, body []byte) bool {
+	timestamp := r.Header.Get("X-Signature-Timestamp")
+	signature, err := hex.DecodeString(r.Header.Get("X-Signature-Ed25519"))
+	if err != nil || len(signature) == 0 || impl.publicKey == nil {
+		util.CountWebhook(util.DISCORD, util.WebhookRejectedUnsigned)
+		w.WriteHeader(http.StatusUnauthorized)
+		_ = json.NewEncoder(w).Encode(bean.Response{StatusCode: 401, Error: "Missing signature"})
+		return false
+	}
+	if !ed25519.Verify(impl.publicKey, append([]byte(timestamp), body...), signature) {
+		util.CountWebhook(util.DISCORD, util.WebhookRejectedSignature)
+		impl.logger.Errorw("Invalid discord signature", "RemoteAddr", r.RemoteAddr)
+		w.WriteHeader(http.StatusUnauthorized)
+		_ = json.NewEncoder(w).Encode(bean.Response{StatusCode: 401, Error: "Invalid signature"})
+		return false
+	}
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if age := time.Since(time.Unix(seconds, 0)); err != nil || age > impl.cfg.WebhookMaxAge || age < -impl.cfg.WebhookMaxAge {
+		util.CountWebhook(util.DISCORD, util.WebhookRejectedStale)
+		w.WriteHeader(http.StatusUnauthorized)
+		_ = json.NewEncoder(w).Encode(bean.Response{StatusCode: 401, Error: "Stale request"})
+		return false
+	}
+	util.CountWebhook(util.DISCORD, util.WebhookAccepted)
+	return true
+}
@@ -0,0 +1,90 @@
+package restHandler
+
+import (
+	"crypto/ed25519"
+	"encoding/hex"
+	"github.com/iraunit/get-link-backend/pkg/services"
+	"github.com/iraunit/get-link-backend/util/bean"
+	"go.uber.org/zap"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+type fakeDiscordService struct {
+	services.DiscordService
+	interactions []*bean.DiscordInteraction
+}
+
+func (service *fakeDiscordService) HandleInteraction(interaction *bean.DiscordInteraction) *bean.DiscordInteractionResponse {
+	service.interactions = append(service.interactions, interaction)
+	return &bean.DiscordInteractionResponse{Type: 1}
+}
+
+func signedDiscordRequest(body string, at time.Time, key ed25519.PrivateKey) *http.Request {
+	timestamp := strconv.FormatInt(at.Unix(), 10)
+	r := httptest.NewRequest(http.MethodPost, "/discord/interactions", strings.NewReader(body))
+	r.Header.Set("X-Signature-Timestamp", timestamp)
+	r.Header.Set("X-Signature-Ed25519", hex.EncodeToString(ed25519.Sign(key, []byte(timestamp+body))))
+	return r
+}
+
+func TestDiscordInteractionSignature(t *testing.T) {
+	publicKey, privateKey, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, otherKey, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const body = `{"type":1}`
+	tests := []struct {
+		name    string
+		request *http.Request
+		status  int
+		handled int
+	}{
+		{"valid", signedDiscordRequest(body, time.Now(), privateKey), http.StatusOK, 1},
+		{"wrong key", signedDiscordRequest(body, time.Now(), otherKey), http.StatusUnauthorized, 0},
+		{"stale", signedDiscordRequest(body, time.Now().Add(-time.Hour), privateKey), http.StatusUnauthorized, 0},
+		{"unsigned", httptest.NewRequest(http.MethodPost, "/discord/interactions", strings.NewReader(body)), http.StatusUnauthorized, 0},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			service := &fakeDiscordService{}
+			handler := &DiscordRestHandlerImpl{
+				logger:         zap.NewNop().Sugar(),
+				cfg:            bean.DiscordCfg{WebhookMaxAge: 5 * time.Minute},
+				publicKey:      publicKey,
+				discordService: service,
+			}
+			w := httptest.NewRecorder()
+			handler.HandleInteraction(w, test.request)
+			if w.Code != test.status || len(service.interactions) != test.handled {
+				t.Fatalf("got status %d and %d interactions, want %d and %d", w.Code, len(service.interactions), test.status, test.handled)
+			}
+		})
+	}
+}
+
+func TestDiscordInteractionRejectedWithoutPublicKey(t *testing.T) {
+	_, privateKey, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	service := &fakeDiscordService{}
+	handler := &DiscordRestHandlerImpl{
+		logger:         zap.NewNop().Sugar(),
+		cfg:            bean.DiscordCfg{WebhookMaxAge: 5 * time.Minute},
+		discordService: service,
+	}
+	w := httptest.NewRecorder()
+	handler.HandleInteraction(w, signedDiscordRequest(`{"type":1}`, time.Now(), privateKey))
+	if w.Code != http.StatusUnauthorized || len(service.interactions) != 0 {
+		t.Fatalf("got status %d and %d interactions, want 401 and none", w.Code, len(service.interactions))
+	}
+}
@@ -0,0 +1,68 @@
+package restHandler
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/go-pg/pg"
+	muxContext "github.com/gorilla/context"
+	"github.com/iraunit/get-link-backend/pkg/services"
+	"github.com/iraunit/get-link-backend/util/bean"
+	"go.uber.org/zap"
+	"net/http"
+)
+
+type FcmRestHandler interface {
+	RegisterToken(w http.ResponseWriter, r *http.Request)
+	UnregisterToken(w http.ResponseWriter, r *http.Request)
+}
+
+type FcmRestHandlerImpl struct {
+	logger     *zap.SugaredLogger
+	fcmService services.FcmService
+}
+
+func NewFcmRestHandlerImpl(logger *zap.SugaredLogger, fcmService services.FcmService) *FcmRestHandlerImpl {
+	return &FcmRestHandlerImpl{
+		logger:     logger,
+		fcmService: fcmService,
+	}
+}
+
+func (impl *FcmRestHandlerImpl) RegisterToken(w http.ResponseWriter, r *http.Request) {
+	userEmail := muxContext.Get(r, "email").(string)
+	uuid := muxContext.Get(r, "uuid").(string)
+
+	var request bean.FcmTokenRequest
+	err := json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		impl.logger.Errorw("Error in decoding request body", "Error: ", err)
+		w.WriteHeader(http.StatusBadRequest)
+		_ = json.NewEncoder(w).Encode(bean.Response{StatusCode: 400, Error: "Error in decoding request body"})
+		return
+	}
+
+	err = impl.fcmService.RegisterToken(userEmail, uuid, &request)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		_ = json.NewEncoder(w).Encode(bean.Response{StatusCode: 400, Error: err.Error()})
+		return
+	}
+	_ = json.NewEncoder(w).Encode(bean.Response{StatusCode: 200, Result: "Push notifications enabled"})
+}
+
+func (impl *FcmRestHandlerImpl) UnregisterToken(w http.ResponseWriter, r *http.Request) {
+	userEmail := muxContext.Get(r, "email").(string)
+	uuid := muxContext.Get(r, "uuid").(string)
+
+	err := impl.fcmService.UnregisterToken(userEmail, uuid)
+	if errors.Is(err, pg.ErrNoRows) {
+		w.WriteHeader(http.StatusNotFound)
+		_ = json.NewEncoder(w).Encode(bean.Response{StatusCode: 404, Error: "Token not found"})
+		return
+	} else if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		_ = json.NewEncoder(w).Encode(bean.Response{StatusCode: 500, Error: "Error in removing token"})
+		return
+	}
+	_ = json.NewEncoder(w).Encode(bean.Response{StatusCode: 200, Result: "Push notifications disabled"})
+}
@@ -6,6 +6,7 @@ import (
 	muxContext "github.com/gorilla/context"
 	"github.com/gorilla/mux"
 	"github.com/iraunit/get-link-backend/pkg/fileManager"
+	"github.com/iraunit/get-link-backend/pkg/messages"
 	"github.com/iraunit/get-link-backend/pkg/services"
 	"github.com/iraunit/get-link-backend/util"
 	"github.com/iraunit/get-link-backend/util/bean"
@@ -16,6 +17,7 @@ import (
 
 type FileHandler interface {
 	DownloadFile(w http.ResponseWriter, r *http.Request)
+	DownloadSharedFile(w http.ResponseWriter, r *http.Request)
 	UploadFile(w http.ResponseWriter, r *http.Request)
 	ListAllFiles(w http.ResponseWriter, r *http.Request)
 	DeleteFile(w http.ResponseWriter, r *http.Request)
@@ -25,19 +27,40 @@ type FileHandlerImpl struct {
 	logger        *zap.SugaredLogger
 	fileManager   fileManager.FileManager
 	fileService   services.FileService
+	notification  services.NotificationService
+	async         *util.Async
 	downloadQueue chan struct{}
 }
 
-func NewFileHandlerImpl(logger *zap.SugaredLogger, fileManager fileManager.FileManager, fileService services.FileService) *FileHandlerImpl {
+func NewFileHandlerImpl(logger *zap.SugaredLogger, async *util.Async, fileManager fileManager.FileManager, fileService services.FileService, notificationService services.NotificationService) *FileHandlerImpl {
 	return &FileHandlerImpl{
 		logger:        logger,
+		async:         async,
 		fileManager:   fileManager,
 		fileService:   fileService,
+		notification:  notificationService,
 		downloadQueue: make(chan struct{}, 1),
 	}
 }
 
 func (impl *FileHandlerImpl) DownloadFile(w http.ResponseWriter, r *http.Request) {
+	impl.download(w, r)
+}
+
+// DownloadSharedFile serves a file through its share link and tells the owner when someone downloads it.
+func (impl *FileHandlerImpl) DownloadSharedFile(w http.ResponseWriter, r *http.Request) {
+	if !impl.download(w, r) || r.Method != http.MethodGet {
+		return
+	}
+	email := muxContext.Get(r, "email").(string)
+	fileName := mux.Vars(r)["fileName"]
+	impl.async.Run(func() {
+		impl.notification.Notify(email, util.EventShareDownloaded, impl.notification.Message(email, "notification.share_downloaded", messages.Data{"FileName": fileName}))
+	})
+}
+
+// download writes the requested file of the user to w and reports whether it succeeded.
+func (impl *FileHandlerImpl) download(w http.ResponseWriter, r *http.Request) bool {
 	impl.downloadQueue <- struct{}{}
 	defer func() {
 		<-impl.downloadQueue
@@ -52,7 +75,7 @@ func (impl *FileHandlerImpl) DownloadFile(w http.ResponseWriter, r *http.Request
 		w.WriteHeader(http.StatusBadRequest)
 		_ = json.NewEncoder(w).Encode(bean.Response{StatusCode: 400, Error: "fileName or appName is missing"})
 		impl.logger.Errorw("fileName or appName is missing")
-		return
+		return false
 	}
 
 	err := impl.fileManager.DownloadDecryptedFile(w, fmt.Sprintf("%s/%s", impl.fileManager.GetPathToSaveFileFromApp(util.EncodeString(email), appName), fileName), email)
@@ -60,8 +83,9 @@ func (impl *FileHandlerImpl) DownloadFile(w http.ResponseWriter, r *http.Request
 		w.WriteHeader(http.StatusBadRequest)
 		_ = json.NewEncoder(w).Encode(bean.Response{StatusCode: 400, Error: err.Error()})
 		impl.logger.Errorw("Error in downloading file", "Error", err)
-		return
+		return false
 	}
+	return true
 }
 
 func (impl *FileHandlerImpl) UploadFile(w http.ResponseWriter, r *http.Request) {
@@ -89,7 +113,7 @@ func (impl *FileHandlerImpl) UploadFile(w http.ResponseWriter, r *http.Request)
 
 	impl.fileService.CleanGetLinkAppFiles(email)
 
-	err = impl.fileManager.SaveFileToPath(file, fmt.Sprintf("%s/%s.bin", impl.fileManager.GetPathToSaveFileFromApp(util.EncodeString(email), util.GETLINK), filename), email)
+	err = impl.fileManager.SaveFileToPath(file, fmt.Sprintf("%s/%s.bin", impl.fileManager.GetPathToSaveFileFromApp(util.EncodeString(email), util.GETLINK), filename), email, muxContext.Get(r, util.UUID).(string))
 	if err != nil {
 		w.WriteHeader(http.StatusBadRequest)
 		_ = json.NewEncoder(w).Encode(bean.Response{StatusCode: 400, Error: err.Error()})
@@ -112,7 +136,7 @@ func (impl *FileHandlerImpl) ListAllFiles(w http.ResponseWriter, r *http.Request
 	email := muxContext.Get(r, "email").(string)
 
 	var allFiles []bean.FileInfo
-	appNames := []string{util.WHATSAPP, util.GETLINK, util.TELEGRAM}
+	appNames := []string{util.WHATSAPP, util.GETLINK, util.TELEGRAM, util.EMAIL}
 
 	for _, appName := range appNames {
 		files, err := impl.fileManager.ListAllFilesFromApp(email, appName)
@@ -0,0 +1,149 @@
+package restHandler
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"github.com/caarlos0/env"
+	"github.com/go-pg/pg"
+	"github.com/golang-jwt/jwt/v5"
+	muxContext "github.com/gorilla/context"
+	"github.com/iraunit/get-link-backend/pkg/services"
+	"github.com/iraunit/get-link-backend/util/bean"
+	"go.uber.org/zap"
+	"net/http"
+	"net/url"
+)
+
+type InboundEmailRestHandler interface {
+	HandleInboundEmail(w http.ResponseWriter, r *http.Request)
+	VerifyInboundEmail(w http.ResponseWriter, r *http.Request)
+	GetInboundAddress(w http.ResponseWriter, r *http.Request)
+}
+
+type InboundEmailRestHandlerImpl struct {
+	logger              *zap.SugaredLogger
+	inboundEmailService services.InboundEmailService
+	cfg                 bean.InboundEmailCfg
+}
+
+func NewInboundEmailRestHandlerImpl(logger *zap.SugaredLogger, inboundEmailService services.InboundEmailService) *InboundEmailRestHandlerImpl {
+	cfg := bean.InboundEmailCfg{}
+	if err := env.Parse(&cfg); err != nil {
+		logger.Fatal("Error loading Cfg from env", "Error", zap.Error(err))
+	}
+	return &InboundEmailRestHandlerImpl{
+		logger:              logger,
+		inboundEmailService: inboundEmailService,
+		cfg:                 cfg,
+	}
+}
+
+// HandleInboundEmail accepts inbound-parse webhooks (SendGrid and Mailgun style multipart forms).
+func (impl *InboundEmailRestHandlerImpl) HandleInboundEmail(w http.ResponseWriter, r *http.Request) {
+	secret := r.URL.Query().Get("secret")
+	if impl.cfg.WebhookSecret == "" || subtle.ConstantTimeCompare([]byte(secret), []byte(impl.cfg.WebhookSecret)) != 1 {
+		impl.logger.Errorw("Unauthorised inbound email webhook request")
+		w.WriteHeader(http.StatusForbidden)
+		_ = json.NewEncoder(w).Encode(bean.Response{StatusCode: 403, Error: "Invalid webhook secret"})
+		return
+	}
+
+	err := r.ParseMultipartForm(32 << 20)
+	if err != nil {
+		impl.logger.Errorw("Error in parsing inbound email", "Error: ", err)
+		w.WriteHeader(http.StatusBadRequest)
+		_ = json.NewEncoder(w).Encode(bean.Response{StatusCode: 400, Error: "Failed to parse multipart form"})
+		return
+	}
+
+	email := &bean.InboundEmail{
+		From:    firstFormValue(r, "from", "sender"),
+		To:      firstFormValue(r, "to", "recipient"),
+		Subject: firstFormValue(r, "subject"),
+		Text:    firstFormValue(r, "text", "body-plain"),
+		SPF:     firstFormValue(r, "SPF", "X-Mailgun-Spf"),
+		DKIM:    firstFormValue(r, "dkim", "X-Mailgun-Dkim-Check-Result"),
+	}
+	// SendGrid sends the envelope as JSON, Mailgun as the sender field
+	envelope := struct {
+		From string `json:"from"`
+	}{}
+	if err := json.Unmarshal([]byte(r.FormValue("envelope")), &envelope); err == nil {
+		email.EnvelopeFrom = envelope.From
+	} else {
+		email.EnvelopeFrom = r.FormValue("sender")
+	}
+
+	for _, headers := range r.MultipartForm.File {
+		for _, header := range headers {
+			file, err := header.Open()
+			if err != nil {
+				impl.logger.Errorw("Error in opening email attachment", "FileName", header.Filename, "Error: ", err)
+				continue
+			}
+			email.Attachments = append(email.Attachments, bean.InboundEmailAttachment{FileName: header.Filename, Size: header.Size, Data: file})
+		}
+	}
+
+	err = impl.inboundEmailService.ReceiveEmail(email)
+	if err != nil {
+		if errors.Is(err, pg.ErrNoRows) || errors.Is(err, services.ErrInboundSenderNotVerified) || errors.Is(err, services.ErrInboundSenderNotAuthenticated) {
+			// acknowledge so the provider does not keep retrying mail we will never accept
+			impl.logger.Infow("Inbound email dropped", "From", email.From, "To", email.To, "Reason", err.Error())
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		impl.logger.Errorw("Error in handling inbound email", "From", email.From, "Error: ", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		_ = json.NewEncoder(w).Encode(bean.Response{StatusCode: 500, Error: "Error in handling inbound email"})
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+func (impl *InboundEmailRestHandlerImpl) VerifyInboundEmail(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	tokenStr, _ := url.QueryUnescape(query.Get("token"))
+
+	claims := bean.InboundEmailVerificationClaims{}
+	_, err := jwt.ParseWithClaims(tokenStr, &claims, func(t *jwt.Token) (interface{}, error) {
+		return []byte(impl.cfg.JwtKey), nil
+	})
+
+	if err != nil {
+		impl.logger.Errorw("Unauthorised Request. Invalid token.")
+		w.WriteHeader(http.StatusBadRequest)
+		_ = json.NewEncoder(w).Encode(bean.Response{StatusCode: 400, Error: "Error parsing token."})
+		return
+	}
+
+	err = impl.inboundEmailService.VerifyInboundSender(&claims)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		_ = json.NewEncoder(w).Encode(bean.Response{StatusCode: 400, Error: "Error in verifying sender"})
+		return
+	}
+	_ = json.NewEncoder(w).Encode(bean.Response{StatusCode: 200, Result: "Sender verified successfully"})
+}
+
+func (impl *InboundEmailRestHandlerImpl) GetInboundAddress(w http.ResponseWriter, r *http.Request) {
+	userEmail := muxContext.Get(r, "email").(string)
+	address, err := impl.inboundEmailService.GetInboundAddress(userEmail)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		_ = json.NewEncoder(w).Encode(bean.Response{StatusCode: 500, Error: "Error in getting inbound email address"})
+		return
+	}
+	_ = json.NewEncoder(w).Encode(bean.Response{StatusCode: 200, Result: address})
+}
+
+func firstFormValue(r *http.Request, keys ...string) string {
+	for _, key := range keys {
+		if value := r.FormValue(key); value != "" {
+			return value
+		}
+	}
+	return ""
+}
@@ -0,0 +1,61 @@
+package restHandler
+
+import (
+	"encoding/json"
+	"github.com/caarlos0/env"
+	"github.com/gorilla/mux"
+	"github.com/iraunit/get-link-backend/pkg/mailTemplates"
+	"github.com/iraunit/get-link-backend/pkg/services"
+	"github.com/iraunit/get-link-backend/util"
+	"github.com/iraunit/get-link-backend/util/bean"
+	"go.uber.org/zap"
+	"net/http"
+)
+
+type MailRestHandler interface {
+	PreviewMail(w http.ResponseWriter, r *http.Request)
+}
+
+type MailRestHandlerImpl struct {
+	logger  *zap.SugaredLogger
+	cfg     bean.MailConfig
+	tenants services.TenantService
+}
+
+func NewMailRestHandlerImpl(logger *zap.SugaredLogger, tenantService services.TenantService) *MailRestHandlerImpl {
+	cfg := bean.MailConfig{}
+	if err := env.Parse(&cfg); err != nil {
+		logger.Fatal("Error loading Cfg from env", "Error", zap.Error(err))
+	}
+	return &MailRestHandlerImpl{
+		logger:  logger,
+		cfg:     cfg,
+		tenants: tenantService,
+	}
+}
+
+func (impl *MailRestHandlerImpl) PreviewMail(w http.ResponseWriter, r *http.Request) {
+	if impl.cfg.Type != util.DEVELOPMENT {
+		w.WriteHeader(http.StatusNotFound)
+		_ = json.NewEncoder(w).Encode(bean.Response{StatusCode: 404, Error: "Mail preview is only available in development"})
+		return
+	}
+
+	templateName := mux.Vars(r)["template"]
+	tenant := impl.tenants.Get(r.URL.Query().Get("tenant"))
+	rendered, err := mailTemplates.Render(templateName, tenant, mailTemplates.SampleData(templateName))
+	if err != nil {
+		impl.logger.Errorw("Error in rendering mail preview", "Template", templateName, "Error", err)
+		w.WriteHeader(http.StatusBadRequest)
+		_ = json.NewEncoder(w).Encode(bean.Response{StatusCode: 400, Error: err.Error()})
+		return
+	}
+
+	if r.URL.Query().Get("format") == "text" {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		_, _ = w.Write([]byte("Subject: " + rendered.Subject + "\n\n" + rendered.Text))
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	_, _ = w.Write([]byte(rendered.HTML))
+}
@@ -0,0 +1,86 @@
+package restHandler
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/go-pg/pg"
+	muxContext "github.com/gorilla/context"
+	"github.com/gorilla/mux"
+	"github.com/iraunit/get-link-backend/pkg/services"
+	"github.com/iraunit/get-link-backend/util/bean"
+	"go.uber.org/zap"
+	"net/http"
+	"strconv"
+)
+
+type MirrorRestHandler interface {
+	GetRules(w http.ResponseWriter, r *http.Request)
+	AddRule(w http.ResponseWriter, r *http.Request)
+	DeleteRule(w http.ResponseWriter, r *http.Request)
+}
+
+type MirrorRestHandlerImpl struct {
+	logger        *zap.SugaredLogger
+	mirrorService services.MirrorService
+}
+
+func NewMirrorRestHandlerImpl(logger *zap.SugaredLogger, mirrorService services.MirrorService) *MirrorRestHandlerImpl {
+	return &MirrorRestHandlerImpl{
+		logger:        logger,
+		mirrorService: mirrorService,
+	}
+}
+
+func (impl *MirrorRestHandlerImpl) GetRules(w http.ResponseWriter, r *http.Request) {
+	userEmail := muxContext.Get(r, "email").(string)
+	rules, err := impl.mirrorService.GetRules(userEmail)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		_ = json.NewEncoder(w).Encode(bean.Response{StatusCode: 500, Error: "Error in getting mirror rules"})
+		return
+	}
+	_ = json.NewEncoder(w).Encode(bean.Response{StatusCode: 200, Result: rules})
+}
+
+func (impl *MirrorRestHandlerImpl) AddRule(w http.ResponseWriter, r *http.Request) {
+	userEmail := muxContext.Get(r, "email").(string)
+
+	var rule bean.MirrorRule
+	err := json.NewDecoder(r.Body).Decode(&rule)
+	if err != nil {
+		impl.logger.Errorw("Error in decoding request body", "Error: ", err)
+		w.WriteHeader(http.StatusBadRequest)
+		_ = json.NewEncoder(w).Encode(bean.Response{StatusCode: 400, Error: "Error in decoding request body"})
+		return
+	}
+
+	err = impl.mirrorService.AddRule(userEmail, &rule)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		_ = json.NewEncoder(w).Encode(bean.Response{StatusCode: 400, Error: err.Error()})
+		return
+	}
+	_ = json.NewEncoder(w).Encode(bean.Response{StatusCode: 200, Result: "Mirror rule saved"})
+}
+
+func (impl *MirrorRestHandlerImpl) DeleteRule(w http.ResponseWriter, r *http.Request) {
+	userEmail := muxContext.Get(r, "email").(string)
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		_ = json.NewEncoder(w).Encode(bean.Response{StatusCode: 400, Error: "Invalid rule id"})
+		return
+	}
+	err = impl.mirrorService.DeleteRule(userEmail, id)
+	if err != nil {
+		if errors.Is(err, pg.ErrNoRows) {
+			w.WriteHeader(http.StatusNotFound)
+			_ = json.NewEncoder(w).Encode(bean.Response{StatusCode: 404, Error: "Mirror rule not found"})
+			return
+		}
+		w.WriteHeader(http.StatusInternalServerError)
+		_ = json.NewEncoder(w).Encode(bean.Response{StatusCode: 500, Error: "Error in deleting mirror rule"})
+		return
+	}
+	_ = json.NewEncoder(w).Encode(bean.Response{StatusCode: 200, Result: "Mirror rule deleted"})
+}
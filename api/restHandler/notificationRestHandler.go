@@ -0,0 +1,61 @@
+package restHandler
+
+import (
+	"encoding/json"
+	muxContext "github.com/gorilla/context"
+	"github.com/iraunit/get-link-backend/pkg/services"
+	"github.com/iraunit/get-link-backend/util/bean"
+	"go.uber.org/zap"
+	"net/http"
+)
+
+type NotificationRestHandler interface {
+	GetPreferences(w http.ResponseWriter, r *http.Request)
+	SavePreferences(w http.ResponseWriter, r *http.Request)
+}
+
+type NotificationRestHandlerImpl struct {
+	logger              *zap.SugaredLogger
+	notificationService services.NotificationService
+}
+
+func NewNotificationRestHandlerImpl(logger *zap.SugaredLogger, notificationService services.NotificationService) *NotificationRestHandlerImpl {
+	return &NotificationRestHandlerImpl{
+		logger:              logger,
+		notificationService: notificationService,
+	}
+}
+
+func (impl *NotificationRestHandlerImpl) GetPreferences(w http.ResponseWriter, r *http.Request) {
+	userEmail := muxContext.Get(r, "email").(string)
+
+	preferences, err := impl.notificationService.GetPreferences(userEmail)
+	if err != nil {
+		impl.logger.Errorw("Error in getting notification preferences", "Error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		_ = json.NewEncoder(w).Encode(bean.Response{StatusCode: 500, Error: "Error in getting notification preferences"})
+		return
+	}
+	_ = json.NewEncoder(w).Encode(bean.Response{StatusCode: 200, Result: preferences})
+}
+
+func (impl *NotificationRestHandlerImpl) SavePreferences(w http.ResponseWriter, r *http.Request) {
+	userEmail := muxContext.Get(r, "email").(string)
+
+	var preferences bean.NotificationPreferences
+	err := json.NewDecoder(r.Body).Decode(&preferences)
+	if err != nil {
+		impl.logger.Errorw("Error in decoding request body", "Error: ", err)
+		w.WriteHeader(http.StatusBadRequest)
+		_ = json.NewEncoder(w).Encode(bean.Response{StatusCode: 400, Error: "Error in decoding request body"})
+		return
+	}
+
+	err = impl.notificationService.SavePreferences(userEmail, &preferences)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		_ = json.NewEncoder(w).Encode(bean.Response{StatusCode: 400, Error: err.Error()})
+		return
+	}
+	_ = json.NewEncoder(w).Encode(bean.Response{StatusCode: 200, Result: "Notification preferences saved"})
+}
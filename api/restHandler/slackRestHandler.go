@@ -0,0 +1,127 @@
+package restHandler
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"github.com/caarlos0/env"
+	"github.com/iraunit/get-link-backend/pkg/services"
+	"github.com/iraunit/get-link-backend/util"
+	"github.com/iraunit/get-link-backend/util/bean"
+	"go.uber.org/zap"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+type SlackRestHandler interface {
+	HandleEvents(w http.ResponseWriter, r *http.Request)
+	HandleCommand(w http.ResponseWriter, r *http.Request)
+}
+
+type SlackRestHandlerImpl struct {
+	logger       *zap.SugaredLogger
+	cfg          bean.SlackCfg
+	slackService services.SlackService
+}
+
+func NewSlackRestHandlerImpl(logger *zap.SugaredLogger, slackService services.SlackService) *SlackRestHandlerImpl {
+	cfg := bean.SlackCfg{}
+	if err := env.Parse(&cfg); err != nil {
+		logger.Fatal("Error loading Cfg from env", "Error", zap.Error(err))
+	}
+	if cfg.SigningSecret == "" {
+		logger.Infow("SLACK_SIGNING_SECRET is not set, Slack requests are rejected")
+	}
+	return &SlackRestHandlerImpl{
+		logger:       logger,
+		cfg:          cfg,
+		slackService: slackService,
+	}
+}
+
+func (impl *SlackRestHandlerImpl) HandleEvents(w http.ResponseWriter, r *http.Request) {
+	body, ok := impl.readSignedBody(w, r)
+	if !ok {
+		return
+	}
+	var envelope bean.SlackEventEnvelope
+	if err := json.Unmarshal(body, &envelope); err != nil {
+		impl.logger.Errorw("Error in decoding slack event", "Error", err)
+		w.WriteHeader(http.StatusBadRequest)
+		_ = json.NewEncoder(w).Encode(bean.Response{StatusCode: 400, Error: "Error in decoding request body"})
+		return
+	}
+	if envelope.Type == "url_verification" {
+		_ = json.NewEncoder(w).Encode(map[string]string{"challenge": envelope.Challenge})
+		return
+	}
+	// retries of events that were already delivered are skipped by event id in the service
+	if envelope.Type == "event_callback" {
+		impl.slackService.HandleEvent(&envelope)
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+func (impl *SlackRestHandlerImpl) HandleCommand(w http.ResponseWriter, r *http.Request) {
+	body, ok := impl.readSignedBody(w, r)
+	if !ok {
+		return
+	}
+	form, err := url.ParseQuery(string(body))
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		_ = json.NewEncoder(w).Encode(bean.Response{StatusCode: 400, Error: "Error in decoding request body"})
+		return
+	}
+	reply := impl.slackService.HandleCommand(&bean.SlackSlashCommand{
+		Command:   form.Get("command"),
+		Text:      form.Get("text"),
+		UserID:    form.Get("user_id"),
+		UserName:  form.Get("user_name"),
+		ChannelID: form.Get("channel_id"),
+	})
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(bean.SlackCommandResponse{ResponseType: "ephemeral", Text: reply})
+}
+
+// readSignedBody checks X-Slack-Signature, the HMAC-SHA256 of "v0:timestamp:body" keyed with the signing secret.
+func (impl *SlackRestHandlerImpl) readSignedBody(w http.ResponseWriter, r *http.Request) ([]byte, bool) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		impl.logger.Errorw("Error reading request body", "Error: ", err)
+		http.Error(w, "Error reading request body", http.StatusInternalServerError)
+		return nil, false
+	}
+	timestamp := r.Header.Get("X-Slack-Request-Timestamp")
+	signature := r.Header.Get("X-Slack-Signature")
+	if signature == "" || impl.cfg.SigningSecret == "" {
+		util.CountWebhook(util.SLACK, util.WebhookRejectedUnsigned)
+		w.WriteHeader(http.StatusUnauthorized)
+		_ = json.NewEncoder(w).Encode(bean.Response{StatusCode: 401, Error: "Missing signature"})
+		return nil, false
+	}
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if age := time.Since(time.Unix(seconds, 0)); err != nil || age > impl.cfg.WebhookMaxAge || age < -impl.cfg.WebhookMaxAge {
+		util.CountWebhook(util.SLACK, util.WebhookRejectedStale)
+		w.WriteHeader(http.StatusUnauthorized)
+		_ = json.NewEncoder(w).Encode(bean.Response{StatusCode: 401, Error: "Stale request"})
+		return nil, false
+	}
+	mac := hmac.New(sha256.New, []byte(impl.cfg.SigningSecret))
+	mac.Write([]byte("v0:" + timestamp + ":"))
+	mac.Write(body)
+	expected := "v0=" + hex.EncodeToString(mac.Sum(nil))
+	if !hmac.Equal([]byte(signature), []byte(expected)) {
+		util.CountWebhook(util.SLACK, util.WebhookRejectedSignature)
+		impl.logger.Errorw("Invalid slack signature", "RemoteAddr", r.RemoteAddr)
+		w.WriteHeader(http.StatusUnauthorized)
+		_ = json.NewEncoder(w).Encode(bean.Response{StatusCode: 401, Error: "Invalid signature"})
+		return nil, false
+	}
+	util.CountWebhook(util.SLACK, util.WebhookAccepted)
+	return body, true
+}
@@ -0,0 +1,101 @@
+package restHandler
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"github.com/iraunit/get-link-backend/pkg/services"
+	"github.com/iraunit/get-link-backend/util/bean"
+	"go.uber.org/zap"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testSlackSecret = "signing-secret"
+
+type fakeSlackService struct {
+	services.SlackService
+	events []*bean.SlackEventEnvelope
+}
+
+func (service *fakeSlackService) HandleEvent(envelope *bean.SlackEventEnvelope) {
+	service.events = append(service.events, envelope)
+}
+
+func newTestSlackHandler() (*SlackRestHandlerImpl, *fakeSlackService) {
+	service := &fakeSlackService{}
+	return &SlackRestHandlerImpl{
+		logger:       zap.NewNop().Sugar(),
+		cfg:          bean.SlackCfg{SigningSecret: testSlackSecret, WebhookMaxAge: 5 * time.Minute},
+		slackService: service,
+	}, service
+}
+
+func signedSlackRequest(body string, at time.Time, secret string) *http.Request {
+	timestamp := strconv.FormatInt(at.Unix(), 10)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte("v0:" + timestamp + ":" + body))
+	r := httptest.NewRequest(http.MethodPost, "/slack/events", strings.NewReader(body))
+	r.Header.Set("X-Slack-Request-Timestamp", timestamp)
+	r.Header.Set("X-Slack-Signature", "v0="+hex.EncodeToString(mac.Sum(nil)))
+	return r
+}
+
+func TestSlackEventsSignature(t *testing.T) {
+	const body = `{"type":"event_callback","event_id":"Ev1","event":{"type":"message","channel_type":"im","user":"U1","text":"hi"}}`
+	tests := []struct {
+		name    string
+		request *http.Request
+		status  int
+		events  int
+	}{
+		{"valid", signedSlackRequest(body, time.Now(), testSlackSecret), http.StatusOK, 1},
+		{"wrong secret", signedSlackRequest(body, time.Now(), "other"), http.StatusUnauthorized, 0},
+		{"stale", signedSlackRequest(body, time.Now().Add(-time.Hour), testSlackSecret), http.StatusUnauthorized, 0},
+		{"unsigned", httptest.NewRequest(http.MethodPost, "/slack/events", strings.NewReader(body)), http.StatusUnauthorized, 0},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			handler, service := newTestSlackHandler()
+			w := httptest.NewRecorder()
+			handler.HandleEvents(w, test.request)
+			if w.Code != test.status || len(service.events) != test.events {
+				t.Fatalf("got status %d and %d events, want %d and %d", w.Code, len(service.events), test.status, test.events)
+			}
+		})
+	}
+}
+
+func TestSlackEventsRejectedWithoutSigningSecret(t *testing.T) {
+	handler, service := newTestSlackHandler()
+	handler.cfg.SigningSecret = ""
+	w := httptest.NewRecorder()
+	handler.HandleEvents(w, signedSlackRequest(`{"type":"event_callback","event_id":"Ev1"}`, time.Now(), ""))
+	if w.Code != http.StatusUnauthorized || len(service.events) != 0 {
+		t.Fatalf("got status %d and %d events, want 401 and none", w.Code, len(service.events))
+	}
+}
+
+func TestSlackEventsRetryReachesService(t *testing.T) {
+	handler, service := newTestSlackHandler()
+	r := signedSlackRequest(`{"type":"event_callback","event_id":"Ev1"}`, time.Now(), testSlackSecret)
+	r.Header.Set("X-Slack-Retry-Num", "1")
+
+	handler.HandleEvents(httptest.NewRecorder(), r)
+	if len(service.events) != 1 || service.events[0].EventID != "Ev1" {
+		t.Fatalf("expected the retry to be handed to the service, got %+v", service.events)
+	}
+}
+
+func TestSlackUrlVerification(t *testing.T) {
+	handler, _ := newTestSlackHandler()
+	w := httptest.NewRecorder()
+	handler.HandleEvents(w, signedSlackRequest(`{"type":"url_verification","challenge":"abc"}`, time.Now(), testSlackSecret))
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"challenge":"abc"`) {
+		t.Fatalf("unexpected response %d %s", w.Code, w.Body.String())
+	}
+}
@@ -0,0 +1,137 @@
+package restHandler
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"github.com/caarlos0/env"
+	"github.com/gorilla/context"
+	"github.com/iraunit/get-link-backend/pkg/services"
+	"github.com/iraunit/get-link-backend/util"
+	"github.com/iraunit/get-link-backend/util/bean"
+	"go.uber.org/zap"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+)
+
+type SmsRestHandler interface {
+	HandleMessage(w http.ResponseWriter, r *http.Request)
+	SendSmsMessage(w http.ResponseWriter, r *http.Request)
+}
+
+type SmsRestHandlerImpl struct {
+	logger     *zap.SugaredLogger
+	cfg        bean.SmsCfg
+	smsService services.SmsService
+}
+
+func NewSmsRestHandlerImpl(logger *zap.SugaredLogger, smsService services.SmsService) *SmsRestHandlerImpl {
+	cfg := bean.SmsCfg{}
+	if err := env.Parse(&cfg); err != nil {
+		logger.Fatal("Error loading Cfg from env", "Error", zap.Error(err))
+	}
+	return &SmsRestHandlerImpl{
+		logger:     logger,
+		cfg:        cfg,
+		smsService: smsService,
+	}
+}
+
+func (impl *SmsRestHandlerImpl) HandleMessage(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		_ = json.NewEncoder(w).Encode(bean.Response{StatusCode: 400, Error: "Error in decoding request body"})
+		return
+	}
+	if !impl.verifySignature(w, r) {
+		return
+	}
+
+	message := &bean.SmsMessage{
+		MessageSid: r.PostForm.Get("MessageSid"),
+		From:       r.PostForm.Get("From"),
+		Body:       r.PostForm.Get("Body"),
+	}
+	numMedia, _ := strconv.Atoi(r.PostForm.Get("NumMedia"))
+	for i := 0; i < numMedia; i++ {
+		message.Media = append(message.Media, bean.SmsMedia{
+			Url:         r.PostForm.Get(fmt.Sprintf("MediaUrl%d", i)),
+			ContentType: r.PostForm.Get(fmt.Sprintf("MediaContentType%d", i)),
+		})
+	}
+	impl.logger.Infow("SMS Received", "MessageSid", message.MessageSid)
+	impl.smsService.HandleMessage(message)
+
+	// confirmations go out through the REST API, so the TwiML reply is empty
+	w.Header().Set("Content-Type", "text/xml")
+	_, _ = w.Write([]byte("<Response></Response>"))
+}
+
+// verifySignature checks X-Twilio-Signature, the base64 HMAC-SHA1 of the webhook url followed by every
+// POST parameter name and value sorted by name, keyed with the auth token.
+func (impl *SmsRestHandlerImpl) verifySignature(w http.ResponseWriter, r *http.Request) bool {
+	signature := r.Header.Get("X-Twilio-Signature")
+	if signature == "" || impl.cfg.AuthToken == "" {
+		util.CountWebhook(util.SMS, util.WebhookRejectedUnsigned)
+		impl.logger.Errorw("Unsigned sms webhook request", "RemoteAddr", r.RemoteAddr)
+		w.WriteHeader(http.StatusUnauthorized)
+		_ = json.NewEncoder(w).Encode(bean.Response{StatusCode: 401, Error: "Missing signature"})
+		return false
+	}
+	if !hmac.Equal([]byte(signature), []byte(twilioSignature(impl.cfg.AuthToken, impl.cfg.BaseUrl+r.URL.RequestURI(), r.PostForm))) {
+		util.CountWebhook(util.SMS, util.WebhookRejectedSignature)
+		impl.logger.Errorw("Invalid sms webhook signature", "RemoteAddr", r.RemoteAddr)
+		w.WriteHeader(http.StatusUnauthorized)
+		_ = json.NewEncoder(w).Encode(bean.Response{StatusCode: 401, Error: "Invalid signature"})
+		return false
+	}
+	util.CountWebhook(util.SMS, util.WebhookAccepted)
+	return true
+}
+
+func twilioSignature(authToken, webhookUrl string, form url.Values) string {
+	keys := make([]string, 0, len(form))
+	for key := range form {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	mac := hmac.New(sha1.New, []byte(authToken))
+	mac.Write([]byte(webhookUrl))
+	for _, key := range keys {
+		for _, value := range form[key] {
+			mac.Write([]byte(key + value))
+		}
+	}
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func (impl *SmsRestHandlerImpl) SendSmsMessage(w http.ResponseWriter, r *http.Request) {
+	userEmail := context.Get(r, "email").(string)
+	if !impl.smsService.GetIfUserIsPremium(userEmail) {
+		w.WriteHeader(http.StatusForbidden)
+		_ = json.NewEncoder(w).Encode(bean.Response{StatusCode: 403, Error: "You are not a premium user. Please upgrade your plan. Contact me on twitter (iraunit) or email me on contact.shyptsolution@gmail.com."})
+		return
+	}
+
+	msg := &Message{}
+	err := json.NewDecoder(r.Body).Decode(&msg)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		_ = json.NewEncoder(w).Encode(bean.Response{StatusCode: 400, Error: "Error in decoding request body"})
+		return
+	}
+
+	err = impl.smsService.SendMessageFromWeb(userEmail, msg.Message)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		_ = json.NewEncoder(w).Encode(bean.Response{StatusCode: 400, Error: err.Error()})
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+	_ = json.NewEncoder(w).Encode(bean.Response{StatusCode: 200, Result: "Message queued for delivery"})
+}
@@ -1,22 +1,25 @@
 package restHandler
 
 import (
+	"crypto/subtle"
 	"encoding/json"
 	"github.com/caarlos0/env"
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/gorilla/context"
-	"github.com/iraunit/get-link-backend/pkg/cryptography"
+	"github.com/gorilla/mux"
 	"github.com/iraunit/get-link-backend/pkg/services"
 	"github.com/iraunit/get-link-backend/util/bean"
 	"go.uber.org/zap"
 	"net/http"
 	"net/url"
-	"strconv"
 )
 
 type TelegramRestHandler interface {
 	VerifyTelegramEmail(w http.ResponseWriter, r *http.Request)
 	SendTelegramMessage(w http.ResponseWriter, r *http.Request)
+	HandleWebhook(w http.ResponseWriter, r *http.Request)
+	ConnectTelegram(w http.ResponseWriter, r *http.Request)
+	SendTelegramFile(w http.ResponseWriter, r *http.Request)
 }
 
 type TelegramRestHandlerImpl struct {
@@ -68,34 +71,65 @@ type Message struct {
 
 func (impl *TelegramRestHandlerImpl) SendTelegramMessage(w http.ResponseWriter, r *http.Request) {
 	userEmail := context.Get(r, "email").(string)
-	allEmails, err := impl.telegramService.GetUsersFromEmail(userEmail)
+
+	msg := &Message{}
+	err := json.NewDecoder(r.Body).Decode(&msg)
 	if err != nil {
 		w.WriteHeader(http.StatusBadRequest)
-		_ = json.NewEncoder(w).Encode(bean.Response{StatusCode: 400, Error: "Error in sending message"})
+		_ = json.NewEncoder(w).Encode(bean.Response{StatusCode: 400, Error: "Error in decoding request body"})
 		return
 	}
 
-	msg := &Message{}
-	err = json.NewDecoder(r.Body).Decode(&msg)
+	err = impl.telegramService.SendMessageToEmail(userEmail, msg.Message)
 	if err != nil {
 		w.WriteHeader(http.StatusBadRequest)
-		_ = json.NewEncoder(w).Encode(bean.Response{StatusCode: 400, Error: "Error in decoding request body"})
+		_ = json.NewEncoder(w).Encode(bean.Response{StatusCode: 400, Error: "Error in sending message"})
 		return
 	}
 
-	for _, email := range allEmails {
-		decryptedData, err := cryptography.DecryptData(userEmail, email.ChatId, impl.logger)
-		if err != nil {
-			impl.logger.Errorw("Error in decrypting data", "Error: ", err)
-			w.WriteHeader(http.StatusBadRequest)
-		}
-		chatId, err := strconv.ParseInt(decryptedData, 10, 64)
-		if err != nil {
-			impl.logger.Errorw("Error in converting string to int", "Error: ", err)
-			w.WriteHeader(http.StatusBadRequest)
-		}
-		impl.telegramService.SendTelegramMessage(chatId, msg.Message)
+	w.WriteHeader(http.StatusAccepted)
+	_ = json.NewEncoder(w).Encode(bean.Response{StatusCode: 200, Result: "Message queued for delivery"})
+}
+
+func (impl *TelegramRestHandlerImpl) HandleWebhook(w http.ResponseWriter, r *http.Request) {
+	secret := r.Header.Get("X-Telegram-Bot-Api-Secret-Token")
+	if impl.cfg.WebhookSecret == "" || subtle.ConstantTimeCompare([]byte(secret), []byte(impl.cfg.WebhookSecret)) != 1 {
+		impl.logger.Errorw("Unauthorised telegram webhook request")
+		w.WriteHeader(http.StatusUnauthorized)
+		_ = json.NewEncoder(w).Encode(bean.Response{StatusCode: 401, Error: "Invalid secret token"})
+		return
 	}
+	handler, ok := impl.telegramService.WebhookHandler(r.URL.Query().Get("bot"))
+	if !ok {
+		impl.logger.Errorw("Telegram webhook for unknown bot", "Bot", r.URL.Query().Get("bot"))
+		w.WriteHeader(http.StatusNotFound)
+		_ = json.NewEncoder(w).Encode(bean.Response{StatusCode: 404, Error: "Unknown bot"})
+		return
+	}
+	handler(w, r)
+}
 
-	_ = json.NewEncoder(w).Encode(bean.Response{StatusCode: 200, Result: "Message sent successfully"})
+func (impl *TelegramRestHandlerImpl) ConnectTelegram(w http.ResponseWriter, r *http.Request) {
+	userEmail := context.Get(r, "email").(string)
+	link, err := impl.telegramService.CreateConnectLink(userEmail, r.Header.Get("Origin"))
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		_ = json.NewEncoder(w).Encode(bean.Response{StatusCode: 500, Error: "Error in creating telegram connect link"})
+		return
+	}
+	_ = json.NewEncoder(w).Encode(bean.Response{StatusCode: 200, Result: link})
+}
+
+func (impl *TelegramRestHandlerImpl) SendTelegramFile(w http.ResponseWriter, r *http.Request) {
+	userEmail := context.Get(r, "email").(string)
+	vars := mux.Vars(r)
+
+	err := impl.telegramService.SendFileToEmail(userEmail, vars["appName"], vars["fileName"])
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		_ = json.NewEncoder(w).Encode(bean.Response{StatusCode: 400, Error: err.Error()})
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+	_ = json.NewEncoder(w).Encode(bean.Response{StatusCode: 200, Result: "File queued for delivery"})
 }
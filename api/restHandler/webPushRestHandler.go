@@ -0,0 +1,81 @@
+package restHandler
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/go-pg/pg"
+	muxContext "github.com/gorilla/context"
+	"github.com/iraunit/get-link-backend/pkg/services"
+	"github.com/iraunit/get-link-backend/util/bean"
+	"go.uber.org/zap"
+	"net/http"
+)
+
+type WebPushRestHandler interface {
+	GetPublicKey(w http.ResponseWriter, r *http.Request)
+	Subscribe(w http.ResponseWriter, r *http.Request)
+	Unsubscribe(w http.ResponseWriter, r *http.Request)
+}
+
+type WebPushRestHandlerImpl struct {
+	logger         *zap.SugaredLogger
+	webPushService services.WebPushService
+}
+
+func NewWebPushRestHandlerImpl(logger *zap.SugaredLogger, webPushService services.WebPushService) *WebPushRestHandlerImpl {
+	return &WebPushRestHandlerImpl{
+		logger:         logger,
+		webPushService: webPushService,
+	}
+}
+
+func (impl *WebPushRestHandlerImpl) GetPublicKey(w http.ResponseWriter, r *http.Request) {
+	_ = json.NewEncoder(w).Encode(bean.Response{StatusCode: 200, Result: impl.webPushService.GetPublicKey()})
+}
+
+func (impl *WebPushRestHandlerImpl) Subscribe(w http.ResponseWriter, r *http.Request) {
+	userEmail := muxContext.Get(r, "email").(string)
+	uuid := muxContext.Get(r, "uuid").(string)
+
+	var subscription bean.PushSubscriptionRequest
+	err := json.NewDecoder(r.Body).Decode(&subscription)
+	if err != nil {
+		impl.logger.Errorw("Error in decoding request body", "Error: ", err)
+		w.WriteHeader(http.StatusBadRequest)
+		_ = json.NewEncoder(w).Encode(bean.Response{StatusCode: 400, Error: "Error in decoding request body"})
+		return
+	}
+
+	err = impl.webPushService.Subscribe(userEmail, uuid, &subscription)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		_ = json.NewEncoder(w).Encode(bean.Response{StatusCode: 400, Error: err.Error()})
+		return
+	}
+	_ = json.NewEncoder(w).Encode(bean.Response{StatusCode: 200, Result: "Push notifications enabled"})
+}
+
+func (impl *WebPushRestHandlerImpl) Unsubscribe(w http.ResponseWriter, r *http.Request) {
+	userEmail := muxContext.Get(r, "email").(string)
+
+	var subscription bean.PushSubscriptionRequest
+	err := json.NewDecoder(r.Body).Decode(&subscription)
+	if err != nil {
+		impl.logger.Errorw("Error in decoding request body", "Error: ", err)
+		w.WriteHeader(http.StatusBadRequest)
+		_ = json.NewEncoder(w).Encode(bean.Response{StatusCode: 400, Error: "Error in decoding request body"})
+		return
+	}
+
+	err = impl.webPushService.Unsubscribe(userEmail, subscription.Endpoint)
+	if errors.Is(err, pg.ErrNoRows) {
+		w.WriteHeader(http.StatusNotFound)
+		_ = json.NewEncoder(w).Encode(bean.Response{StatusCode: 404, Error: "Subscription not found"})
+		return
+	} else if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		_ = json.NewEncoder(w).Encode(bean.Response{StatusCode: 500, Error: "Error in removing subscription"})
+		return
+	}
+	_ = json.NewEncoder(w).Encode(bean.Response{StatusCode: 200, Result: "Push notifications disabled"})
+}
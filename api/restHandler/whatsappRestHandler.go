@@ -1,32 +1,40 @@
 package restHandler
 
 import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
-	"errors"
-	"fmt"
 	"github.com/caarlos0/env"
-	"github.com/go-pg/pg"
 	"github.com/gorilla/context"
+	"github.com/gorilla/mux"
 	"github.com/iraunit/get-link-backend/pkg/services"
+	"github.com/iraunit/get-link-backend/util"
 	"github.com/iraunit/get-link-backend/util/bean"
 	"go.uber.org/zap"
 	"io"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 )
 
 type Whatsapp interface {
 	Verify(w http.ResponseWriter, r *http.Request)
 	HandleMessage(w http.ResponseWriter, r *http.Request)
 	SendWhatsappMessage(w http.ResponseWriter, r *http.Request)
+	SendWhatsappFile(w http.ResponseWriter, r *http.Request)
+	GetMessageStatus(w http.ResponseWriter, r *http.Request)
 }
 
 type WhatsappImpl struct {
 	logger   *zap.SugaredLogger
 	cfg      bean.WhatsAppConfig
 	wService services.WhatsappService
+	tenants  services.TenantService
 }
 
-func NewWhatsappImpl(logger *zap.SugaredLogger, wService services.WhatsappService) *WhatsappImpl {
+func NewWhatsappImpl(logger *zap.SugaredLogger, wService services.WhatsappService, tenantService services.TenantService) *WhatsappImpl {
 	cfg := bean.WhatsAppConfig{}
 	if err := env.Parse(&cfg); err != nil {
 		logger.Fatal("Error loading Cfg from env", "Error", zap.Error(err))
@@ -35,6 +43,7 @@ func NewWhatsappImpl(logger *zap.SugaredLogger, wService services.WhatsappServic
 		logger:   logger,
 		cfg:      cfg,
 		wService: wService,
+		tenants:  tenantService,
 	}
 }
 
@@ -67,6 +76,10 @@ func (impl *WhatsappImpl) HandleMessage(w http.ResponseWriter, r *http.Request)
 		http.Error(w, "Error reading request body", http.StatusInternalServerError)
 		return
 	}
+	signer, ok := impl.verifySignature(w, r, body)
+	if !ok {
+		return
+	}
 	err = json.Unmarshal(body, &message)
 	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
@@ -77,23 +90,117 @@ func (impl *WhatsappImpl) HandleMessage(w http.ResponseWriter, r *http.Request)
 
 	for i := 0; i < len(message.Entry); i++ {
 		for j := 0; j < len(message.Entry[i].Changes); j++ {
-			currentMessages := message.Entry[i].Changes[j].Value.Messages
-			for k := 0; k < len(currentMessages); k++ {
-				impl.logger.Infow("Message Received", "From", currentMessages[k].From)
-				err = impl.wService.ReceiveMessage(&currentMessages[k])
+			value := message.Entry[i].Changes[j].Value
+			// the signature only vouches for the numbers of the tenant whose app secret matched
+			if impl.phoneTenant(value.Metadata.PhoneNumberID).ID != signer.ID {
+				util.CountWebhook(util.WHATSAPP, util.WebhookRejectedTenant)
+				impl.logger.Errorw("Dropping whatsapp change for a number of another tenant", "PhoneID", value.Metadata.PhoneNumberID, "Tenant", signer.ID)
+				continue
+			}
+			for k := 0; k < len(value.Statuses); k++ {
+				if !impl.isFresh(value.Statuses[k].Timestamp) {
+					util.CountWebhook(util.WHATSAPP, util.WebhookRejectedStale)
+					impl.logger.Errorw("Dropping stale whatsapp status", "ID", value.Statuses[k].ID, "Timestamp", value.Statuses[k].Timestamp)
+					continue
+				}
+				err = impl.wService.QueueStatus(value.Statuses[k])
 				if err != nil {
-					if errors.Is(err, pg.ErrNoRows) {
-						_ = impl.wService.SendMessage(message.Entry[i].Changes[j].Value.Contacts[0].WaID, fmt.Sprintf("Hey %s!\nPlease set and verify your email first.\nSend \n> *`set email youremail`*\n here to get started. You can share your feedback or report an issue on codingkaro.in.\n\nRegards\nRaunit Verma\nShypt Solution", message.Entry[i].Changes[j].Value.Contacts[0].Profile.Name))
-					} else {
-						_ = impl.wService.SendMessage(message.Entry[i].Changes[j].Value.Contacts[0].WaID, fmt.Sprintf("Hey %s,\nSorry for the inconvenience. Get-Link is unable to process your request. Please try again later. Please share your feedback or report an issue on codingkaro.in\n\nRegards\nRaunit Verma\nShypt Solution", message.Entry[i].Changes[j].Value.Contacts[0].Profile.Name))
-						impl.logger.Errorw("Error in handling message", "Message:", currentMessages[k], "Error: ", err)
-					}
+					impl.logger.Errorw("Error in queueing whatsapp status", "ID", value.Statuses[k].ID, "Error: ", err)
+					w.WriteHeader(http.StatusServiceUnavailable)
+					_ = json.NewEncoder(w).Encode(bean.Response{StatusCode: 503, Error: "Busy, please retry"})
+					return
+				}
+			}
+			for k := 0; k < len(value.Messages); k++ {
+				if !impl.isFresh(value.Messages[k].Timestamp) {
+					util.CountWebhook(util.WHATSAPP, util.WebhookRejectedStale)
+					impl.logger.Errorw("Dropping stale whatsapp message", "ID", value.Messages[k].ID, "Timestamp", value.Messages[k].Timestamp)
+					continue
+				}
+				impl.logger.Infow("Message Received", "From", value.Messages[k].From)
+				err = impl.wService.QueueMessage(value.Messages[k], contactName(value.Contacts, value.Messages[k].From), value.Metadata.PhoneNumberID)
+				if err != nil {
+					impl.logger.Errorw("Error in queueing whatsapp message", "ID", value.Messages[k].ID, "Error: ", err)
+					w.WriteHeader(http.StatusServiceUnavailable)
+					_ = json.NewEncoder(w).Encode(bean.Response{StatusCode: 503, Error: "Busy, please retry"})
+					return
 				}
 			}
 		}
 	}
 
-	w.WriteHeader(http.StatusAccepted)
+	w.WriteHeader(http.StatusOK)
+}
+
+// contactName returns the sender's profile name, or "" so the reply template can greet them in its own language.
+func contactName(contacts []bean.WhatsAppBusinessContact, waID string) string {
+	for _, contact := range contacts {
+		if contact.WaID == waID {
+			return contact.Profile.Name
+		}
+	}
+	return ""
+}
+
+// verifySignature checks X-Hub-Signature-256, the HMAC-SHA256 of the raw body keyed with the app secret, and returns
+// the tenant whose secret signed it.
+func (impl *WhatsappImpl) verifySignature(w http.ResponseWriter, r *http.Request, body []byte) (*bean.Tenant, bool) {
+	signature, found := strings.CutPrefix(r.Header.Get("X-Hub-Signature-256"), "sha256=")
+	tenant := impl.signingTenant(body)
+	appSecret := tenant.WhatsappAppSecret
+	if !found || appSecret == "" {
+		util.CountWebhook(util.WHATSAPP, util.WebhookRejectedUnsigned)
+		impl.logger.Errorw("Unsigned whatsapp webhook request", "RemoteAddr", r.RemoteAddr)
+		w.WriteHeader(http.StatusUnauthorized)
+		_ = json.NewEncoder(w).Encode(bean.Response{StatusCode: 401, Error: "Missing signature"})
+		return nil, false
+	}
+	mac := hmac.New(sha256.New, []byte(appSecret))
+	mac.Write(body)
+	expected := hex.EncodeToString(mac.Sum(nil))
+	if !hmac.Equal([]byte(strings.ToLower(signature)), []byte(expected)) {
+		util.CountWebhook(util.WHATSAPP, util.WebhookRejectedSignature)
+		impl.logger.Errorw("Invalid whatsapp webhook signature", "RemoteAddr", r.RemoteAddr)
+		w.WriteHeader(http.StatusUnauthorized)
+		_ = json.NewEncoder(w).Encode(bean.Response{StatusCode: 401, Error: "Invalid signature"})
+		return nil, false
+	}
+	util.CountWebhook(util.WHATSAPP, util.WebhookAccepted)
+	return tenant, true
+}
+
+// signingTenant picks the tenant of the first business number in the unverified body, or the default tenant. The body
+// is only trusted for that tenant's numbers once the signature matches, see HandleMessage.
+func (impl *WhatsappImpl) signingTenant(body []byte) *bean.Tenant {
+	var message bean.WhatsAppBusinessMessage
+	if err := json.Unmarshal(body, &message); err == nil {
+		for _, entry := range message.Entry {
+			for _, change := range entry.Changes {
+				if tenant, ok := impl.tenants.ByWhatsappPhoneID(change.Value.Metadata.PhoneNumberID); ok {
+					return tenant
+				}
+			}
+		}
+	}
+	return impl.tenants.Default()
+}
+
+// phoneTenant returns the tenant owning a business number. Unknown numbers belong to the default tenant, as in
+// WhatsappService.QueueMessage.
+func (impl *WhatsappImpl) phoneTenant(phoneID string) *bean.Tenant {
+	if tenant, ok := impl.tenants.ByWhatsappPhoneID(phoneID); ok {
+		return tenant
+	}
+	return impl.tenants.Default()
+}
+
+func (impl *WhatsappImpl) isFresh(timestamp string) bool {
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return false
+	}
+	age := time.Since(time.Unix(seconds, 0))
+	return age <= impl.cfg.WebhookMaxAge && age >= -impl.cfg.WebhookMaxAge
 }
 
 func (impl *WhatsappImpl) SendWhatsappMessage(w http.ResponseWriter, r *http.Request) {
@@ -113,13 +220,43 @@ func (impl *WhatsappImpl) SendWhatsappMessage(w http.ResponseWriter, r *http.Req
 		return
 	}
 
-	err = impl.wService.SendMessageFromWeb(userEmail, msg.Message)
+	messageID, err := impl.wService.SendMessageFromWeb(userEmail, msg.Message)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		_ = json.NewEncoder(w).Encode(bean.Response{StatusCode: 400, Error: err.Error()})
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+	_ = json.NewEncoder(w).Encode(bean.Response{StatusCode: 200, Result: bean.WhatsappSentMessage{MessageID: messageID}})
+}
+
+func (impl *WhatsappImpl) SendWhatsappFile(w http.ResponseWriter, r *http.Request) {
+	userEmail := context.Get(r, "email").(string)
+	if !impl.wService.GetIfUserIsPremium(userEmail) {
+		w.WriteHeader(http.StatusForbidden)
+		_ = json.NewEncoder(w).Encode(bean.Response{StatusCode: 403, Error: "You are not a premium user. Please upgrade your plan. Contact me on twitter (iraunit) or email me on contact.shyptsolution@gmail.com."})
+		return
+	}
 
+	vars := mux.Vars(r)
+	err := impl.wService.SendFileFromWeb(userEmail, vars["appName"], vars["fileName"])
 	if err != nil {
 		w.WriteHeader(http.StatusBadRequest)
 		_ = json.NewEncoder(w).Encode(bean.Response{StatusCode: 400, Error: err.Error()})
 		return
 	}
+	w.WriteHeader(http.StatusAccepted)
+	_ = json.NewEncoder(w).Encode(bean.Response{StatusCode: 200, Result: "File queued for delivery"})
+}
 
-	_ = json.NewEncoder(w).Encode(bean.Response{StatusCode: 200, Result: "Message sent successfully"})
+func (impl *WhatsappImpl) GetMessageStatus(w http.ResponseWriter, r *http.Request) {
+	userEmail := context.Get(r, "email").(string)
+	statuses, err := impl.wService.GetMessageStatuses(userEmail, mux.Vars(r)["id"])
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		_ = json.NewEncoder(w).Encode(bean.Response{StatusCode: 400, Error: err.Error()})
+		return
+	}
+	_ = json.NewEncoder(w).Encode(bean.Response{StatusCode: 200, Result: statuses})
 }
@@ -0,0 +1,91 @@
+package restHandler
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"github.com/iraunit/get-link-backend/pkg/services"
+	"github.com/iraunit/get-link-backend/util/bean"
+	"go.uber.org/zap"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+type fakeTenantService struct {
+	services.TenantService
+	tenants []*bean.Tenant
+}
+
+func (service *fakeTenantService) Default() *bean.Tenant {
+	return service.tenants[0]
+}
+
+func (service *fakeTenantService) ByWhatsappPhoneID(phoneID string) (*bean.Tenant, bool) {
+	for _, tenant := range service.tenants {
+		if tenant.WhatsappPhoneID == phoneID {
+			return tenant, true
+		}
+	}
+	return nil, false
+}
+
+type fakeWhatsappService struct {
+	services.WhatsappService
+	phoneIDs []string
+}
+
+func (service *fakeWhatsappService) QueueMessage(message bean.WhatsAppBusinessMessageData, name string, phoneID string) error {
+	service.phoneIDs = append(service.phoneIDs, phoneID)
+	return nil
+}
+
+func newTestWhatsappHandler() (*WhatsappImpl, *fakeWhatsappService) {
+	service := &fakeWhatsappService{}
+	return &WhatsappImpl{
+		logger:   zap.NewNop().Sugar(),
+		cfg:      bean.WhatsAppConfig{WebhookMaxAge: 5 * time.Minute},
+		wService: service,
+		tenants: &fakeTenantService{tenants: []*bean.Tenant{
+			{ID: "default", WhatsappPhoneID: "111", WhatsappAppSecret: "default-secret"},
+			{ID: "acme", WhatsappPhoneID: "222", WhatsappAppSecret: "acme-secret"},
+		}},
+	}, service
+}
+
+func whatsappChange(phoneID string) string {
+	return fmt.Sprintf(`{"value":{"metadata":{"phone_number_id":"%s"},"messages":[{"from":"15550001","id":"wamid.%s","timestamp":"%d"}]}}`, phoneID, phoneID, time.Now().Unix())
+}
+
+func signedWhatsappRequest(body, secret string) *http.Request {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(body))
+	r := httptest.NewRequest(http.MethodPost, "/whatsapp-webhook", strings.NewReader(body))
+	r.Header.Set("X-Hub-Signature-256", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	return r
+}
+
+func TestWhatsappWebhookQueuesOnlyTheSigningTenantsNumbers(t *testing.T) {
+	handler, service := newTestWhatsappHandler()
+	body := fmt.Sprintf(`{"entry":[{"changes":[%s]},{"changes":[%s]}]}`, whatsappChange("222"), whatsappChange("111"))
+
+	w := httptest.NewRecorder()
+	handler.HandleMessage(w, signedWhatsappRequest(body, "acme-secret"))
+	if w.Code != http.StatusOK || len(service.phoneIDs) != 1 || service.phoneIDs[0] != "222" {
+		t.Fatalf("expected only the acme number to be queued, got status %d and %v", w.Code, service.phoneIDs)
+	}
+}
+
+func TestWhatsappWebhookRejectsAnotherTenantsSecret(t *testing.T) {
+	handler, service := newTestWhatsappHandler()
+	body := fmt.Sprintf(`{"entry":[{"changes":[%s]}]}`, whatsappChange("111"))
+
+	w := httptest.NewRecorder()
+	handler.HandleMessage(w, signedWhatsappRequest(body, "acme-secret"))
+	if w.Code != http.StatusUnauthorized || len(service.phoneIDs) != 0 {
+		t.Fatalf("expected the request to be rejected, got status %d and %v", w.Code, service.phoneIDs)
+	}
+}
@@ -2,6 +2,7 @@ package router
 
 import (
 	"encoding/json"
+	"errors"
 	"github.com/caarlos0/env"
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/gorilla/context"
@@ -9,6 +10,7 @@ import (
 	"github.com/iraunit/get-link-backend/util/bean"
 	"go.uber.org/zap"
 	"net/http"
+	"slices"
 	"strings"
 )
 
@@ -18,6 +20,23 @@ type Middleware interface {
 	HandlePanic(next http.Handler) http.Handler
 }
 
+// publicRoutes skip token auth; the value is stored as the request uuid for logging.
+var publicRoutes = map[string]string{
+	util.WhatsappWebhook:     util.WHATSAPP,
+	util.VerifyWhatsappEmail: "Verify Email",
+	util.VerifyTelegramEmail: "Verify Email",
+	util.InboundEmailWebhook: util.EMAIL,
+	util.VerifyInboundEmail:  "Verify Email",
+	util.DigestUnsubscribe:   "Digest",
+	util.DigestMarkRead:      "Digest",
+	util.TelegramWebhook:     util.TELEGRAM,
+	util.VerifyChannelEmail:  "Verify Email",
+	util.SlackEventsWebhook:  util.SLACK,
+	util.SlackCommandWebhook: util.SLACK,
+	util.DiscordWebhook:      util.DISCORD,
+	util.SmsWebhook:          util.SMS,
+}
+
 type MiddlewareImpl struct {
 	logger *zap.SugaredLogger
 	cfg    bean.MiddlewareCfg
@@ -37,12 +56,8 @@ func NewMiddlewareImpl(logger *zap.SugaredLogger) *MiddlewareImpl {
 
 func (impl *MiddlewareImpl) AuthMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if r.URL.Path == util.WhatsappWebhook || r.URL.Path == util.VerifyWhatsappEmail || r.URL.Path == util.VerifyTelegramEmail {
-			if r.URL.Path == util.VerifyWhatsappEmail || r.URL.Path == util.VerifyTelegramEmail {
-				context.Set(r, util.UUID, "Verify Email")
-			} else if r.URL.Path == util.WhatsappWebhook {
-				context.Set(r, util.UUID, util.WHATSAPP)
-			}
+		if uuid, ok := publicRoutes[r.URL.Path]; ok {
+			context.Set(r, util.UUID, uuid)
 			next.ServeHTTP(w, r)
 			return
 		} else if strings.Contains(r.URL.Path, "/download-shared-file/") {
@@ -72,6 +87,9 @@ func (impl *MiddlewareImpl) AuthMiddleware(next http.Handler) http.Handler {
 			_, err := jwt.ParseWithClaims(tokenStr, &claims, func(t *jwt.Token) (interface{}, error) {
 				return []byte(impl.cfg.JwtKey), nil
 			})
+			if err == nil && slices.Contains(claims.Audience, util.DigestAudience) {
+				err = errors.New("digest action token used as a session token")
+			}
 
 			if err != nil {
 				if tokenStr != "undefined" {
@@ -94,7 +112,7 @@ func (impl *MiddlewareImpl) AuthMiddleware(next http.Handler) http.Handler {
 
 func (impl *MiddlewareImpl) LoggerMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if r.URL.Path == util.WhatsappWebhook || r.URL.Path == util.VerifyWhatsappEmail {
+		if _, ok := publicRoutes[r.URL.Path]; ok {
 			impl.logger.Infow("req:", "Path:", r.URL.Path, "Method:", r.Method, "UUID:", context.Get(r, "uuid"))
 			next.ServeHTTP(w, r)
 			return
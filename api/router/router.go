@@ -6,22 +6,46 @@ import (
 )
 
 type MuxRouter struct {
-	Router      *mux.Router
-	middleware  Middleware
-	Links       restHandler.Links
-	Whatsapp    restHandler.Whatsapp
-	fileHandler restHandler.FileHandler
-	Telegram    restHandler.TelegramRestHandler
+	Router       *mux.Router
+	middleware   Middleware
+	Links        restHandler.Links
+	Whatsapp     restHandler.Whatsapp
+	fileHandler  restHandler.FileHandler
+	Telegram     restHandler.TelegramRestHandler
+	InboundMail  restHandler.InboundEmailRestHandler
+	Digest       restHandler.DigestRestHandler
+	Mail         restHandler.MailRestHandler
+	Admin        restHandler.AdminRestHandler
+	Mirror       restHandler.MirrorRestHandler
+	Channel      restHandler.ChannelRestHandler
+	Slack        restHandler.SlackRestHandler
+	Discord      restHandler.DiscordRestHandler
+	Sms          restHandler.SmsRestHandler
+	WebPush      restHandler.WebPushRestHandler
+	Fcm          restHandler.FcmRestHandler
+	Notification restHandler.NotificationRestHandler
 }
 
-func NewMuxRouter(middleware Middleware, links restHandler.Links, whatsapp restHandler.Whatsapp, fileHandler restHandler.FileHandler, telegram restHandler.TelegramRestHandler) *MuxRouter {
+func NewMuxRouter(middleware Middleware, links restHandler.Links, whatsapp restHandler.Whatsapp, fileHandler restHandler.FileHandler, telegram restHandler.TelegramRestHandler, inboundMail restHandler.InboundEmailRestHandler, digest restHandler.DigestRestHandler, mail restHandler.MailRestHandler, admin restHandler.AdminRestHandler, mirror restHandler.MirrorRestHandler, channel restHandler.ChannelRestHandler, slack restHandler.SlackRestHandler, discord restHandler.DiscordRestHandler, sms restHandler.SmsRestHandler, webPush restHandler.WebPushRestHandler, fcm restHandler.FcmRestHandler, notification restHandler.NotificationRestHandler) *MuxRouter {
 	return &MuxRouter{
-		Router:      mux.NewRouter(),
-		middleware:  middleware,
-		Links:       links,
-		Whatsapp:    whatsapp,
-		fileHandler: fileHandler,
-		Telegram:    telegram,
+		Router:       mux.NewRouter(),
+		middleware:   middleware,
+		Links:        links,
+		Whatsapp:     whatsapp,
+		fileHandler:  fileHandler,
+		Telegram:     telegram,
+		InboundMail:  inboundMail,
+		Digest:       digest,
+		Mail:         mail,
+		Admin:        admin,
+		Mirror:       mirror,
+		Channel:      channel,
+		Slack:        slack,
+		Discord:      discord,
+		Sms:          sms,
+		WebPush:      webPush,
+		Fcm:          fcm,
+		Notification: notification,
 	}
 }
 
@@ -38,12 +62,50 @@ func (r *MuxRouter) GetRouter() *mux.Router {
 	r.Router.HandleFunc("/whatsapp-webhook", r.Whatsapp.Verify).Methods("GET")
 	r.Router.HandleFunc("/whatsapp-webhook", r.Whatsapp.HandleMessage).Methods("POST")
 	r.Router.HandleFunc("/download-file/{appName}/{fileName}", r.fileHandler.DownloadFile).Methods("GET")
-	r.Router.HandleFunc("/download-shared-file/{appName}/{fileName}", r.fileHandler.DownloadFile).Methods("GET", "HEAD")
+	r.Router.HandleFunc("/download-shared-file/{appName}/{fileName}", r.fileHandler.DownloadSharedFile).Methods("GET", "HEAD")
 	r.Router.HandleFunc("/upload-file", r.fileHandler.UploadFile).Methods("POST")
 	r.Router.HandleFunc("/list-files", r.fileHandler.ListAllFiles).Methods("GET", "OPTIONS", "HEAD")
 	r.Router.HandleFunc("/delete-file/{appName}/{fileName}", r.fileHandler.DeleteFile).Methods("DELETE")
 	r.Router.HandleFunc("/verify-telegram-email", r.Telegram.VerifyTelegramEmail).Methods("GET")
 	r.Router.HandleFunc("/send-telegram-message", r.Telegram.SendTelegramMessage).Methods("POST")
+	r.Router.HandleFunc("/telegram-webhook", r.Telegram.HandleWebhook).Methods("POST")
+	r.Router.HandleFunc("/connect-telegram", r.Telegram.ConnectTelegram).Methods("GET")
+	r.Router.HandleFunc("/send-telegram-file/{appName}/{fileName}", r.Telegram.SendTelegramFile).Methods("POST")
 	r.Router.HandleFunc("/send-whatsapp-message", r.Whatsapp.SendWhatsappMessage).Methods("POST")
+	r.Router.HandleFunc("/send-whatsapp-file/{appName}/{fileName}", r.Whatsapp.SendWhatsappFile).Methods("POST")
+	r.Router.HandleFunc("/whatsapp-message-status", r.Whatsapp.GetMessageStatus).Methods("GET")
+	r.Router.HandleFunc("/whatsapp-message-status/{id}", r.Whatsapp.GetMessageStatus).Methods("GET")
+	r.Router.HandleFunc("/email-webhook", r.InboundMail.HandleInboundEmail).Methods("POST")
+	r.Router.HandleFunc("/verify-inbound-email", r.InboundMail.VerifyInboundEmail).Methods("GET")
+	r.Router.HandleFunc("/inbound-email-address", r.InboundMail.GetInboundAddress).Methods("GET")
+	r.Router.HandleFunc("/digest", r.Digest.GetDigest).Methods("GET")
+	r.Router.HandleFunc("/digest", r.Digest.SaveDigest).Methods("POST")
+	r.Router.HandleFunc("/digest", r.Digest.DeleteDigest).Methods("DELETE")
+	r.Router.HandleFunc("/digest/unsubscribe", r.Digest.ConfirmUnsubscribe).Methods("GET")
+	r.Router.HandleFunc("/digest/unsubscribe", r.Digest.Unsubscribe).Methods("POST")
+	r.Router.HandleFunc("/digest/mark-read", r.Digest.ConfirmMarkAllRead).Methods("GET")
+	r.Router.HandleFunc("/digest/mark-read", r.Digest.MarkAllRead).Methods("POST")
+	r.Router.HandleFunc("/mail-preview/{template}", r.Mail.PreviewMail).Methods("GET")
+	r.Router.HandleFunc("/admin/outbox", r.Admin.GetOutboxMessages).Methods("GET")
+	r.Router.HandleFunc("/admin/outbox/{id}/retry", r.Admin.RetryOutboxMessage).Methods("POST")
+	r.Router.HandleFunc("/admin/metrics", r.Admin.GetMetrics).Methods("GET")
+	r.Router.HandleFunc("/mirror-rules", r.Mirror.GetRules).Methods("GET")
+	r.Router.HandleFunc("/mirror-rules", r.Mirror.AddRule).Methods("POST")
+	r.Router.HandleFunc("/mirror-rules/{id}", r.Mirror.DeleteRule).Methods("DELETE")
+	r.Router.HandleFunc("/channels", r.Channel.GetChannels).Methods("GET")
+	r.Router.HandleFunc("/channels/{id}", r.Channel.UnlinkChannel).Methods("DELETE")
+	r.Router.HandleFunc("/verify-channel-email", r.Channel.VerifyChannelEmail).Methods("GET")
+	r.Router.HandleFunc("/slack/events", r.Slack.HandleEvents).Methods("POST")
+	r.Router.HandleFunc("/slack/commands", r.Slack.HandleCommand).Methods("POST")
+	r.Router.HandleFunc("/discord/interactions", r.Discord.HandleInteraction).Methods("POST")
+	r.Router.HandleFunc("/sms-webhook", r.Sms.HandleMessage).Methods("POST")
+	r.Router.HandleFunc("/send-sms-message", r.Sms.SendSmsMessage).Methods("POST")
+	r.Router.HandleFunc("/web-push/public-key", r.WebPush.GetPublicKey).Methods("GET")
+	r.Router.HandleFunc("/web-push/subscriptions", r.WebPush.Subscribe).Methods("POST")
+	r.Router.HandleFunc("/web-push/subscriptions", r.WebPush.Unsubscribe).Methods("DELETE")
+	r.Router.HandleFunc("/fcm-token", r.Fcm.RegisterToken).Methods("POST")
+	r.Router.HandleFunc("/fcm-token", r.Fcm.UnregisterToken).Methods("DELETE")
+	r.Router.HandleFunc("/notification-preferences", r.Notification.GetPreferences).Methods("GET")
+	r.Router.HandleFunc("/notification-preferences", r.Notification.SavePreferences).Methods("PUT")
 	return r.Router
 }
@@ -5,7 +5,7 @@ import (
 	"github.com/caarlos0/env"
 	"github.com/gorilla/handlers"
 	"github.com/iraunit/get-link-backend/api/router"
-	"github.com/iraunit/get-link-backend/util"
+	"github.com/iraunit/get-link-backend/pkg/services"
 	"github.com/iraunit/get-link-backend/util/bean"
 	"go.uber.org/zap"
 	"net/http"
@@ -14,12 +14,14 @@ import (
 type App struct {
 	MuxRouter *router.MuxRouter
 	Logger    *zap.SugaredLogger
+	Tenants   services.TenantService
 }
 
-func NewApp(logger *zap.SugaredLogger, muxRouter *router.MuxRouter) *App {
+func NewApp(logger *zap.SugaredLogger, muxRouter *router.MuxRouter, tenants services.TenantService) *App {
 	return &App{
 		MuxRouter: muxRouter,
 		Logger:    logger,
+		Tenants:   tenants,
 	}
 }
 
@@ -33,17 +35,7 @@ func (app *App) Start() {
 	app.Logger.Infow(fmt.Sprintf("Starting server on port %s", cfg.Port))
 	app.MuxRouter.GetRouter()
 
-	corsOrigins := handlers.AllowedOrigins([]string{
-		util.ChromeExtensionUrl,
-		"https://codingkaro.in",
-		"https://www.codingkaro.in",
-		"https://getlink.codingkaro.in",
-		"https://getlink.shyptsolution.com",
-		"http://codingkaro.in",
-		"http://getlink.codingkaro.in",
-		"https://shyptsolution.com",
-		"https://www.shyptsolution.com",
-	})
+	corsOrigins := handlers.AllowedOrigins(app.Tenants.AllowedOrigins())
 	corsHeaders := handlers.AllowedHeaders([]string{"Accept", "Content-Type", "Content-Length", "Accept-Encoding", "Access-Control-Allow-Origin"})
 	corsMethods := handlers.AllowedMethods([]string{"POST", "DELETE", "GET", "OPTIONS", "HEAD"})
 
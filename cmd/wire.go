@@ -8,6 +8,7 @@ import (
 	"github.com/iraunit/get-link-backend/api/restHandler"
 	"github.com/iraunit/get-link-backend/api/router"
 	"github.com/iraunit/get-link-backend/pkg/fileManager"
+	"github.com/iraunit/get-link-backend/pkg/messages"
 	"github.com/iraunit/get-link-backend/pkg/repository"
 	"github.com/iraunit/get-link-backend/pkg/restCalls"
 	"github.com/iraunit/get-link-backend/pkg/services"
@@ -38,6 +39,31 @@ func InitializeApp() *App {
 		services.NewFileServiceImpl, wire.Bind(new(services.FileService), new(*services.FileServiceImpl)),
 		restHandler.NewTelegramRestHandler, wire.Bind(new(restHandler.TelegramRestHandler), new(*restHandler.TelegramRestHandlerImpl)),
 		services.NewTelegramService, wire.Bind(new(services.TelegramService), new(*services.TelegramImpl)),
+		restHandler.NewInboundEmailRestHandlerImpl, wire.Bind(new(restHandler.InboundEmailRestHandler), new(*restHandler.InboundEmailRestHandlerImpl)),
+		services.NewInboundEmailServiceImpl, wire.Bind(new(services.InboundEmailService), new(*services.InboundEmailServiceImpl)),
+		restHandler.NewDigestRestHandlerImpl, wire.Bind(new(restHandler.DigestRestHandler), new(*restHandler.DigestRestHandlerImpl)),
+		services.NewDigestServiceImpl, wire.Bind(new(services.DigestService), new(*services.DigestServiceImpl)),
+		restHandler.NewMailRestHandlerImpl, wire.Bind(new(restHandler.MailRestHandler), new(*restHandler.MailRestHandlerImpl)),
+		services.NewOutboxServiceImpl, wire.Bind(new(services.OutboxService), new(*services.OutboxServiceImpl)),
+		restHandler.NewAdminRestHandlerImpl, wire.Bind(new(restHandler.AdminRestHandler), new(*restHandler.AdminRestHandlerImpl)),
+		services.NewMirrorServiceImpl, wire.Bind(new(services.MirrorService), new(*services.MirrorServiceImpl)),
+		restHandler.NewMirrorRestHandlerImpl, wire.Bind(new(restHandler.MirrorRestHandler), new(*restHandler.MirrorRestHandlerImpl)),
+		services.NewSlackServiceImpl, wire.Bind(new(services.SlackService), new(*services.SlackServiceImpl)),
+		restHandler.NewSlackRestHandlerImpl, wire.Bind(new(restHandler.SlackRestHandler), new(*restHandler.SlackRestHandlerImpl)),
+		services.NewDiscordServiceImpl, wire.Bind(new(services.DiscordService), new(*services.DiscordServiceImpl)),
+		restHandler.NewDiscordRestHandlerImpl, wire.Bind(new(restHandler.DiscordRestHandler), new(*restHandler.DiscordRestHandlerImpl)),
+		services.NewSmsServiceImpl, wire.Bind(new(services.SmsService), new(*services.SmsServiceImpl)),
+		restHandler.NewSmsRestHandlerImpl, wire.Bind(new(restHandler.SmsRestHandler), new(*restHandler.SmsRestHandlerImpl)),
+		services.NewWebPushServiceImpl, wire.Bind(new(services.WebPushService), new(*services.WebPushServiceImpl)),
+		restHandler.NewWebPushRestHandlerImpl, wire.Bind(new(restHandler.WebPushRestHandler), new(*restHandler.WebPushRestHandlerImpl)),
+		services.NewFcmServiceImpl, wire.Bind(new(services.FcmService), new(*services.FcmServiceImpl)),
+		restHandler.NewFcmRestHandlerImpl, wire.Bind(new(restHandler.FcmRestHandler), new(*restHandler.FcmRestHandlerImpl)),
+		services.NewNotificationServiceImpl, wire.Bind(new(services.NotificationService), new(*services.NotificationServiceImpl)),
+		restHandler.NewNotificationRestHandlerImpl, wire.Bind(new(restHandler.NotificationRestHandler), new(*restHandler.NotificationRestHandlerImpl)),
+		services.NewChannelServiceImpl, wire.Bind(new(services.ChannelService), new(*services.ChannelServiceImpl)),
+		restHandler.NewChannelRestHandlerImpl, wire.Bind(new(restHandler.ChannelRestHandler), new(*restHandler.ChannelRestHandlerImpl)),
+		messages.NewCatalogImpl, wire.Bind(new(messages.Catalog), new(*messages.CatalogImpl)),
+		services.NewTenantServiceImpl, wire.Bind(new(services.TenantService), new(*services.TenantServiceImpl)),
 	)
 	return &App{}
 }
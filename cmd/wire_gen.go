@@ -10,6 +10,7 @@ import (
 	"github.com/iraunit/get-link-backend/api/restHandler"
 	"github.com/iraunit/get-link-backend/api/router"
 	"github.com/iraunit/get-link-backend/pkg/fileManager"
+	"github.com/iraunit/get-link-backend/pkg/messages"
 	"github.com/iraunit/get-link-backend/pkg/repository"
 	"github.com/iraunit/get-link-backend/pkg/restCalls"
 	"github.com/iraunit/get-link-backend/pkg/services"
@@ -26,20 +27,45 @@ func InitializeApp() *App {
 	db := repository.NewPgDb(sugaredLogger)
 	v := repository.NewUsersMap()
 	impl := repository.NewRepositoryImpl(db, sugaredLogger, client)
-	linkServiceImpl := services.NewLinkServiceImpl(client, sugaredLogger, v, impl)
+	catalogImpl := messages.NewCatalogImpl(sugaredLogger)
+	notificationServiceImpl := services.NewNotificationServiceImpl(sugaredLogger, client, impl, catalogImpl)
+	linkServiceImpl := services.NewLinkServiceImpl(client, sugaredLogger, v, impl, notificationServiceImpl)
 	linksImpl := restHandler.NewLinksImpl(sugaredLogger, client, db, v, linkServiceImpl)
 	async := util.NewAsync(sugaredLogger)
 	tokenServiceImpl := tokenService.NewTokenServiceImpl(sugaredLogger)
 	fileManagerImpl := fileManager.NewFileManagerImpl(sugaredLogger, async, tokenServiceImpl)
 	restClientImpl := restCalls.NewRestClientImpl(sugaredLogger, async, fileManagerImpl)
-	mailServiceImpl := services.NewMailServiceImpl(sugaredLogger)
-	whatsappServiceImpl := services.NewWhatsappServiceImpl(sugaredLogger, restClientImpl, mailServiceImpl, tokenServiceImpl, impl, linkServiceImpl, fileManagerImpl)
-	whatsappImpl := restHandler.NewWhatsappImpl(sugaredLogger, whatsappServiceImpl)
+	outboxServiceImpl := services.NewOutboxServiceImpl(sugaredLogger, async, impl)
+	tenantServiceImpl := services.NewTenantServiceImpl(sugaredLogger, client, impl)
+	mailServiceImpl := services.NewMailServiceImpl(sugaredLogger, outboxServiceImpl, notificationServiceImpl, tenantServiceImpl)
+	whatsappServiceImpl := services.NewWhatsappServiceImpl(sugaredLogger, async, client, restClientImpl, mailServiceImpl, tokenServiceImpl, impl, linkServiceImpl, fileManagerImpl, outboxServiceImpl, notificationServiceImpl, catalogImpl, tenantServiceImpl)
+	whatsappImpl := restHandler.NewWhatsappImpl(sugaredLogger, whatsappServiceImpl, tenantServiceImpl)
 	fileServiceImpl := services.NewFileServiceImpl(sugaredLogger, impl, fileManagerImpl)
-	fileHandlerImpl := restHandler.NewFileHandlerImpl(sugaredLogger, fileManagerImpl, fileServiceImpl)
-	telegramImpl := services.NewTelegramService(sugaredLogger, async, mailServiceImpl, tokenServiceImpl, impl, linkServiceImpl, fileManagerImpl, restClientImpl)
+	fileHandlerImpl := restHandler.NewFileHandlerImpl(sugaredLogger, async, fileManagerImpl, fileServiceImpl, notificationServiceImpl)
+	telegramImpl := services.NewTelegramService(sugaredLogger, async, client, mailServiceImpl, tokenServiceImpl, impl, linkServiceImpl, fileManagerImpl, restClientImpl, outboxServiceImpl, notificationServiceImpl, catalogImpl, tenantServiceImpl)
 	telegramRestHandlerImpl := restHandler.NewTelegramRestHandler(sugaredLogger, telegramImpl)
-	muxRouter := router.NewMuxRouter(middlewareImpl, linksImpl, whatsappImpl, fileHandlerImpl, telegramRestHandlerImpl)
-	app := NewApp(sugaredLogger, muxRouter)
+	inboundEmailServiceImpl := services.NewInboundEmailServiceImpl(sugaredLogger, client, mailServiceImpl, tokenServiceImpl, impl, linkServiceImpl, fileManagerImpl)
+	inboundEmailRestHandlerImpl := restHandler.NewInboundEmailRestHandlerImpl(sugaredLogger, inboundEmailServiceImpl)
+	digestServiceImpl := services.NewDigestServiceImpl(sugaredLogger, async, client, impl, mailServiceImpl, tokenServiceImpl, fileManagerImpl)
+	digestRestHandlerImpl := restHandler.NewDigestRestHandlerImpl(sugaredLogger, digestServiceImpl)
+	mailRestHandlerImpl := restHandler.NewMailRestHandlerImpl(sugaredLogger, tenantServiceImpl)
+	adminRestHandlerImpl := restHandler.NewAdminRestHandlerImpl(sugaredLogger, outboxServiceImpl)
+	mirrorServiceImpl := services.NewMirrorServiceImpl(sugaredLogger, async, impl, linkServiceImpl, telegramImpl, whatsappServiceImpl)
+	mirrorRestHandlerImpl := restHandler.NewMirrorRestHandlerImpl(sugaredLogger, mirrorServiceImpl)
+	slackServiceImpl := services.NewSlackServiceImpl(sugaredLogger, async, client, impl, linkServiceImpl, fileManagerImpl, tokenServiceImpl, mailServiceImpl, outboxServiceImpl, notificationServiceImpl, catalogImpl, tenantServiceImpl)
+	discordServiceImpl := services.NewDiscordServiceImpl(sugaredLogger, async, impl, linkServiceImpl, fileManagerImpl, tokenServiceImpl, mailServiceImpl, outboxServiceImpl, notificationServiceImpl, catalogImpl, tenantServiceImpl)
+	smsServiceImpl := services.NewSmsServiceImpl(sugaredLogger, async, client, impl, linkServiceImpl, fileManagerImpl, tokenServiceImpl, mailServiceImpl, outboxServiceImpl, notificationServiceImpl, catalogImpl, tenantServiceImpl)
+	channelServiceImpl := services.NewChannelServiceImpl(sugaredLogger, telegramImpl, whatsappServiceImpl, slackServiceImpl, discordServiceImpl, smsServiceImpl)
+	channelRestHandlerImpl := restHandler.NewChannelRestHandlerImpl(sugaredLogger, channelServiceImpl)
+	slackRestHandlerImpl := restHandler.NewSlackRestHandlerImpl(sugaredLogger, slackServiceImpl)
+	discordRestHandlerImpl := restHandler.NewDiscordRestHandlerImpl(sugaredLogger, discordServiceImpl)
+	smsRestHandlerImpl := restHandler.NewSmsRestHandlerImpl(sugaredLogger, smsServiceImpl)
+	webPushServiceImpl := services.NewWebPushServiceImpl(sugaredLogger, async, impl, linkServiceImpl, fileManagerImpl, outboxServiceImpl, notificationServiceImpl)
+	webPushRestHandlerImpl := restHandler.NewWebPushRestHandlerImpl(sugaredLogger, webPushServiceImpl)
+	fcmServiceImpl := services.NewFcmServiceImpl(sugaredLogger, async, impl, linkServiceImpl, fileManagerImpl, outboxServiceImpl, notificationServiceImpl)
+	fcmRestHandlerImpl := restHandler.NewFcmRestHandlerImpl(sugaredLogger, fcmServiceImpl)
+	notificationRestHandlerImpl := restHandler.NewNotificationRestHandlerImpl(sugaredLogger, notificationServiceImpl)
+	muxRouter := router.NewMuxRouter(middlewareImpl, linksImpl, whatsappImpl, fileHandlerImpl, telegramRestHandlerImpl, inboundEmailRestHandlerImpl, digestRestHandlerImpl, mailRestHandlerImpl, adminRestHandlerImpl, mirrorRestHandlerImpl, channelRestHandlerImpl, slackRestHandlerImpl, discordRestHandlerImpl, smsRestHandlerImpl, webPushRestHandlerImpl, fcmRestHandlerImpl, notificationRestHandlerImpl)
+	app := NewApp(sugaredLogger, muxRouter, tenantServiceImpl)
 	return app
 }
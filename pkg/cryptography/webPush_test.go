@@ -0,0 +1,48 @@
+package cryptography
+
+import (
+	"bytes"
+	"crypto/ecdh"
+	"crypto/rand"
+	"encoding/base64"
+	"testing"
+)
+
+func decodeTestBase64(t *testing.T, value string) []byte {
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+// TestEncryptWebPushKnownAnswer checks the example of RFC 8291 Appendix A.
+func TestEncryptWebPushKnownAnswer(t *testing.T) {
+	asKey, err := ecdh.P256().NewPrivateKey(decodeTestBase64(t, "yfWPiYE-n46HLnH0KqZOF1fJJU3MYrct3AELtAQ-oRw"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	uaPublic := decodeTestBase64(t, "BCVxsr7N_eNgVRqvHtD0zTZsEc6-VV-JvLexhqUzORcxaOzi6-AYWXvTBHm4bjyPjs7Vd8pZGH6SRpkNtoIAiw4")
+	authSecret := decodeTestBase64(t, "BTBZMqHH6r4Tts7J_aSIgg")
+	salt := decodeTestBase64(t, "DGv6ra1nlYgDCS1FRnbzlw")
+	plaintext := decodeTestBase64(t, "V2hlbiBJIGdyb3cgdXAsIEkgd2FudCB0byBiZSBhIHdhdGVybWVsb24")
+	expected := decodeTestBase64(t, "DGv6ra1nlYgDCS1FRnbzlwAAEABBBP4z9KsN6nGRTbVYI_c7VJSPQTBtkgcy27mlmlMoZIIgDll6e3vCYLocInmYWAmS6TlzAC8wEqKK6PBru3jl7A_yl95bQpu6cVPTpK4Mqgkf1CXztLVBSt2Ks3oZwbuwXPXLWyouBWLVWGNWQexSgSxsj_Qulcy4a-fN")
+
+	got, err := encryptWebPush(asKey, salt, uaPublic, authSecret, plaintext)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, expected) {
+		t.Fatalf("got %s, want the RFC 8291 ciphertext", base64.RawURLEncoding.EncodeToString(got))
+	}
+}
+
+func TestEncryptWebPushRejectsLargeMessages(t *testing.T) {
+	uaKey, err := ecdh.P256().GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = EncryptWebPush(uaKey.PublicKey().Bytes(), make([]byte, 16), make([]byte, webPushRecordSize)); err == nil {
+		t.Fatal("expected a message over the record size to be rejected")
+	}
+}
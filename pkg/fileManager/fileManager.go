@@ -1,6 +1,8 @@
 package fileManager
 
 import (
+	"bytes"
+	"crypto/aes"
 	"fmt"
 	"github.com/iraunit/get-link-backend/pkg/cryptography"
 	"github.com/iraunit/get-link-backend/pkg/services/tokenService"
@@ -14,6 +16,7 @@ import (
 	"path"
 	"path/filepath"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -27,15 +30,24 @@ type FileManager interface {
 	GetSizeOfADirectory(path string) (int64, error)
 	DownloadDecryptedFile(w http.ResponseWriter, encryptedFilePath, email string) error
 	ListAllFilesFromApp(userEmail, appName string) ([]bean.FileInfo, error)
-	SaveFileToPath(data io.ReadCloser, path, userEmail string) error
+	SaveFileToPath(data io.ReadCloser, path, userEmail, sourceUUID string) error
 	DeleteAllFileOlderThanHours(path string, hours int)
 	DeleteAFileInAppFolder(fileName, email, app string) error
+	GetDecryptedFile(fileName, email, app string) ([]byte, error)
+	// GetFileSize returns the decrypted size of a stored file without reading it.
+	GetFileSize(fileName, email, app string) (int64, error)
+	RegisterSaveFileHook(hook SaveFileHook)
 }
 
+// SaveFileHook is called after a file has been stored in an app folder of userEmail. sourceUUID is the device or channel it came from.
+type SaveFileHook func(userEmail, appName, fileName, sourceUUID string)
+
 type FileManagerImpl struct {
 	logger       *zap.SugaredLogger
 	async        *util.Async
 	tokenService tokenService.TokenService
+	lock         *sync.Mutex
+	hooks        []SaveFileHook
 }
 
 func NewFileManagerImpl(logger *zap.SugaredLogger, async *util.Async, tokenService tokenService.TokenService) *FileManagerImpl {
@@ -43,6 +55,7 @@ func NewFileManagerImpl(logger *zap.SugaredLogger, async *util.Async, tokenServi
 		logger:       logger,
 		async:        async,
 		tokenService: tokenService,
+		lock:         &sync.Mutex{},
 	}
 }
 
@@ -199,7 +212,7 @@ func (impl *FileManagerImpl) ListAllFilesFromApp(email, appName string) ([]bean.
 	return allFiles, nil
 }
 
-func (impl *FileManagerImpl) SaveFileToPath(data io.ReadCloser, path, userEmail string) error {
+func (impl *FileManagerImpl) SaveFileToPath(data io.ReadCloser, path, userEmail, sourceUUID string) error {
 	dir := filepath.Dir(path)
 	err := os.MkdirAll(dir, os.ModePerm)
 	if err != nil {
@@ -232,9 +245,25 @@ func (impl *FileManagerImpl) SaveFileToPath(data io.ReadCloser, path, userEmail
 		impl.logger.Errorw("Error encrypting and saving to file", "Error", err)
 		return err
 	}
+	impl.runSaveFileHooks(userEmail, filepath.Base(dir), strings.TrimSuffix(filepath.Base(path), ".bin"), sourceUUID)
 	return nil
 }
 
+func (impl *FileManagerImpl) RegisterSaveFileHook(hook SaveFileHook) {
+	impl.lock.Lock()
+	defer impl.lock.Unlock()
+	impl.hooks = append(impl.hooks, hook)
+}
+
+func (impl *FileManagerImpl) runSaveFileHooks(userEmail, appName, fileName, sourceUUID string) {
+	impl.lock.Lock()
+	hooks := impl.hooks
+	impl.lock.Unlock()
+	for _, hook := range hooks {
+		hook(userEmail, appName, fileName, sourceUUID)
+	}
+}
+
 func (impl *FileManagerImpl) DeleteAllFileOlderThanHours(p string, hours int) {
 	impl.async.Run(func() {
 		files, err := os.ReadDir(p)
@@ -284,3 +313,35 @@ func (impl *FileManagerImpl) DeleteAFileInAppFolder(fileName, email, app string)
 	}
 	return nil
 }
+
+func (impl *FileManagerImpl) GetFileSize(fileName, email, app string) (int64, error) {
+	p := path.Join(fmt.Sprintf(util.PathToFiles, util.EncodeString(email), app), path.Base(fileName)+".bin")
+	info, err := os.Stat(p)
+	if err != nil {
+		return 0, err
+	}
+	// files are stored with their IV in front
+	return info.Size() - aes.BlockSize, nil
+}
+
+func (impl *FileManagerImpl) GetDecryptedFile(fileName, email, app string) ([]byte, error) {
+	p := path.Join(fmt.Sprintf(util.PathToFiles, util.EncodeString(email), app), path.Base(fileName)+".bin")
+	encryptedData, err := os.ReadFile(p)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := cryptography.CreateKey(email)
+	if err != nil {
+		impl.logger.Errorw("Error creating decryption key", "Error", err)
+		return nil, err
+	}
+
+	var data bytes.Buffer
+	err = cryptography.DecryptFileAndSend(&data, key, encryptedData, impl.logger)
+	if err != nil {
+		impl.logger.Errorw("Error decrypting data", "Error", err)
+		return nil, err
+	}
+	return data.Bytes(), nil
+}
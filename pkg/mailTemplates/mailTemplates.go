@@ -0,0 +1,118 @@
+package mailTemplates
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	"github.com/iraunit/get-link-backend/util"
+	"github.com/iraunit/get-link-backend/util/bean"
+	htmlTemplate "html/template"
+	"strings"
+	textTemplate "text/template"
+)
+
+//go:embed templates/*
+var templateFiles embed.FS
+
+type Rendered struct {
+	Subject string
+	Text    string
+	HTML    string
+}
+
+type mailTemplate struct {
+	text *textTemplate.Template
+	html *htmlTemplate.Template
+}
+
+var templates = map[string]*mailTemplate{}
+
+func init() {
+	funcs := brandFuncs(&bean.Tenant{})
+	for _, name := range Names() {
+		templates[name] = &mailTemplate{
+			text: textTemplate.Must(textTemplate.New(name).Funcs(funcs).ParseFS(templateFiles, "templates/signature.txt", fmt.Sprintf("templates/%s.txt", name))),
+			html: htmlTemplate.Must(htmlTemplate.New(name).Funcs(funcs).ParseFS(templateFiles, "templates/layout.html", fmt.Sprintf("templates/%s.html", name))),
+		}
+	}
+}
+
+// brandFuncs returns the tenant's name, falling back to Get-Link, signature lines and feedback url to the templates.
+func brandFuncs(tenant *bean.Tenant) map[string]interface{} {
+	return map[string]interface{}{
+		"brand": func() string {
+			if tenant.Name == "" {
+				return "Get-Link"
+			}
+			return tenant.Name
+		},
+		"signature":   func() []string { return tenant.Signature },
+		"feedbackUrl": func() string { return tenant.FeedbackUrl },
+	}
+}
+
+func Names() []string {
+	return []string{util.MailTemplateVerification, util.MailTemplateDigest, util.MailTemplateNotification}
+}
+
+// Render executes the template with the tenant's branding.
+func Render(name string, tenant *bean.Tenant, data interface{}) (*Rendered, error) {
+	base, ok := templates[name]
+	if !ok {
+		return nil, fmt.Errorf("mail template %s not found", name)
+	}
+	funcs := brandFuncs(tenant)
+	t := &mailTemplate{text: textTemplate.Must(base.text.Clone()).Funcs(funcs)}
+	html, err := base.html.Clone()
+	if err != nil {
+		return nil, err
+	}
+	t.html = html.Funcs(funcs)
+
+	var subject, text, htmlOut bytes.Buffer
+	if err = t.text.ExecuteTemplate(&subject, "subject", data); err != nil {
+		return nil, err
+	}
+	if err = t.text.ExecuteTemplate(&text, "body", data); err != nil {
+		return nil, err
+	}
+	if err = t.html.ExecuteTemplate(&htmlOut, "layout", data); err != nil {
+		return nil, err
+	}
+	return &Rendered{
+		Subject: strings.TrimSpace(subject.String()),
+		Text:    strings.TrimSpace(text.String()),
+		HTML:    htmlOut.String(),
+	}, nil
+}
+
+// SampleData returns placeholder data used by the developer preview endpoint.
+func SampleData(name string) interface{} {
+	switch name {
+	case util.MailTemplateVerification:
+		return bean.VerificationMailData{
+			Intro:      "Please click on the below link to verify your email.",
+			ActionUrl:  "https://getlink.codingkaro.in/verify-telegram-email?token=preview",
+			ActionText: "Verify email",
+		}
+	case util.MailTemplateDigest:
+		return bean.DigestMailData{
+			Frequency: util.DigestDaily,
+			Since:     "Mon, 02 Jan 2006 15:04:05 UTC",
+			Sections: []bean.DigestMailSection{
+				{Title: "WhatsApp", Links: []string{"https://codingkaro.in", "https://github.com/iraunit"}},
+				{Title: "Telegram", Files: []bean.DigestMailFile{{Name: "report.pdf", SizeKB: 120, Url: "https://getlink.codingkaro.in/download-shared-file/telegram/report.pdf"}}},
+			},
+			MarkReadUrl:    "https://getlink.codingkaro.in/digest/mark-read?token=preview",
+			UnsubscribeUrl: "https://getlink.codingkaro.in/digest/unsubscribe?token=preview",
+		}
+	case util.MailTemplateNotification:
+		return bean.NotificationMailData{
+			Title:      "New link received",
+			Message:    "A new link was added to your Get-Link inbox from WhatsApp.",
+			ActionUrl:  "https://getlink.codingkaro.in",
+			ActionText: "Open Get-Link",
+		}
+	}
+	return nil
+}
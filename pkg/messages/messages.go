@@ -0,0 +1,165 @@
+package messages
+
+import (
+	"bytes"
+	"embed"
+	"github.com/caarlos0/env"
+	"github.com/iraunit/get-link-backend/util/bean"
+	"go.uber.org/zap"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"text/template"
+)
+
+//go:embed locales/*.tmpl
+var localeFiles embed.FS
+
+const baseLocale = "en"
+
+// Data holds the variables of a message template, e.g. {{.Name}}.
+type Data map[string]interface{}
+
+type Catalog interface {
+	// Render executes the message key in locale, falling back to the base language and then the default locale.
+	Render(locale, key string, data Data) string
+	// Match returns the supported locale for a language tag like pt-BR, or "" if there is none.
+	Match(locale string) string
+	Locales() []string
+	// ForTenant returns the catalog with the tenant's brand and message overrides.
+	ForTenant(tenant *bean.Tenant) Catalog
+}
+
+type CatalogImpl struct {
+	logger    *zap.SugaredLogger
+	cfg       bean.MessagesCfg
+	templates map[string]*template.Template
+}
+
+func NewCatalogImpl(logger *zap.SugaredLogger) *CatalogImpl {
+	cfg := bean.MessagesCfg{}
+	if err := env.Parse(&cfg); err != nil {
+		logger.Fatal("Error loading Cfg from env", "Error", zap.Error(err))
+	}
+	impl := &CatalogImpl{
+		logger: logger,
+		cfg:    cfg,
+	}
+	var err error
+	impl.templates, err = impl.load(&bean.Tenant{}, cfg.Dir)
+	if err != nil {
+		logger.Fatal("Error loading message catalog", "Error", zap.Error(err))
+	}
+	impl.cfg.DefaultLocale = impl.Match(cfg.DefaultLocale)
+	if impl.cfg.DefaultLocale == "" {
+		logger.Fatalw("DEFAULT_LOCALE is not in the message catalog", "Locale", cfg.DefaultLocale, "Locales", impl.Locales())
+	}
+	return impl
+}
+
+func (impl *CatalogImpl) ForTenant(tenant *bean.Tenant) Catalog {
+	templates, err := impl.load(tenant, impl.cfg.Dir, tenant.MessagesDir)
+	if err != nil {
+		impl.logger.Fatalw("Error loading message catalog of tenant", "Tenant", tenant.ID, "Error", err)
+	}
+	return &CatalogImpl{logger: impl.logger, cfg: impl.cfg, templates: templates}
+}
+
+// load parses every locale on top of the English catalog, so a missing translation shows the English text.
+// Files in the override dirs are parsed last, in order, and replace the messages they define.
+// The brand, signature and feedbackUrl functions return the tenant's branding, which en.tmpl falls back from when empty.
+func (impl *CatalogImpl) load(tenant *bean.Tenant, dirs ...string) (map[string]*template.Template, error) {
+	sources := map[string][]string{}
+	embedded, err := fs.Glob(localeFiles, "locales/*.tmpl")
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range embedded {
+		data, err := localeFiles.ReadFile(name)
+		if err != nil {
+			return nil, err
+		}
+		locale := localeName(name)
+		sources[locale] = append(sources[locale], string(data))
+	}
+	for _, dir := range dirs {
+		if dir == "" {
+			continue
+		}
+		overrides, err := filepath.Glob(filepath.Join(dir, "*.tmpl"))
+		if err != nil {
+			return nil, err
+		}
+		for _, name := range overrides {
+			data, err := os.ReadFile(name)
+			if err != nil {
+				return nil, err
+			}
+			locale := localeName(name)
+			sources[locale] = append(sources[locale], string(data))
+			impl.logger.Infow("Loaded message overrides", "Locale", locale, "File", name)
+		}
+	}
+
+	templates := make(map[string]*template.Template)
+	funcs := template.FuncMap{
+		"brand":       func() string { return tenant.Name },
+		"signature":   func() string { return strings.Join(tenant.Signature, "\n") },
+		"feedbackUrl": func() string { return tenant.FeedbackUrl },
+	}
+	for locale := range sources {
+		chain := sources[baseLocale]
+		if locale != baseLocale {
+			// translations leave brand and signature to en.tmpl, so overriding them there rebrands every language
+			chain = append(append([]string{}, chain...), sources[locale]...)
+		}
+		t := template.New(locale).Option("missingkey=zero").Funcs(funcs)
+		for _, text := range chain {
+			if t, err = t.Parse(text); err != nil {
+				return nil, err
+			}
+		}
+		templates[locale] = t
+	}
+	return templates, nil
+}
+
+func (impl *CatalogImpl) Render(locale, key string, data Data) string {
+	t, ok := impl.templates[impl.Match(locale)]
+	if !ok {
+		t = impl.templates[impl.cfg.DefaultLocale]
+	}
+	var out bytes.Buffer
+	if err := t.ExecuteTemplate(&out, key, data); err != nil {
+		impl.logger.Errorw("Error in rendering message", "Locale", locale, "Key", key, "Error", err)
+		return key
+	}
+	return strings.TrimSpace(out.String())
+}
+
+func (impl *CatalogImpl) Match(locale string) string {
+	locale = strings.ToLower(strings.ReplaceAll(strings.TrimSpace(locale), "_", "-"))
+	if _, ok := impl.templates[locale]; ok {
+		return locale
+	}
+	language, _, _ := strings.Cut(locale, "-")
+	if _, ok := impl.templates[language]; ok {
+		return language
+	}
+	return ""
+}
+
+func (impl *CatalogImpl) Locales() []string {
+	var locales []string
+	for locale := range impl.templates {
+		locales = append(locales, locale)
+	}
+	slices.Sort(locales)
+	return locales
+}
+
+func localeName(file string) string {
+	return strings.ToLower(strings.TrimSuffix(filepath.Base(file), filepath.Ext(file)))
+}
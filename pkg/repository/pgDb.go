@@ -34,6 +34,18 @@ func NewPgDb(logger *zap.SugaredLogger) *pg.DB {
 		logger.Fatal("Error creating schema for users", zap.Error(err))
 	}
 
+	_, err = db.Exec(`ALTER TABLE "get_links" ADD COLUMN IF NOT EXISTS "created_at" TIMESTAMPTZ DEFAULT now();`)
+
+	if err != nil {
+		logger.Fatal("Error adding created_at to get_links", zap.Error(err))
+	}
+
+	_, err = db.Exec(`ALTER TABLE "get_links" ADD COLUMN IF NOT EXISTS "tags" TEXT[];`)
+
+	if err != nil {
+		logger.Fatal("Error adding tags to get_links", zap.Error(err))
+	}
+
 	_, err = db.Exec(`CREATE TABLE IF NOT EXISTS "whatsapp_emails" (
 		"email" VARCHAR (512) PRIMARY KEY,
 		"whatsapp_number" VARCHAR(512)
@@ -60,5 +72,194 @@ func NewPgDb(logger *zap.SugaredLogger) *pg.DB {
 		logger.Fatal("Error creating schema for telegram_email", zap.Error(err))
 	}
 
+	_, err = db.Exec(`CREATE TABLE IF NOT EXISTS "inbound_email_addresses" (
+		"code" VARCHAR(64) PRIMARY KEY,
+		"email" VARCHAR(512),
+		"owner" VARCHAR(512) UNIQUE
+	  );`)
+
+	if err != nil {
+		logger.Fatal("Error creating schema for inbound_email_addresses", zap.Error(err))
+	}
+
+	_, err = db.Exec(`CREATE TABLE IF NOT EXISTS "telegram_groups" (
+		"chat_id" VARCHAR(512),
+		"email" VARCHAR(512),
+		"created_at" TIMESTAMPTZ DEFAULT now(),
+		PRIMARY KEY ("chat_id", "email")
+	  );`)
+
+	if err != nil {
+		logger.Fatal("Error creating schema for telegram_groups", zap.Error(err))
+	}
+
+	_, err = db.Exec(`CREATE TABLE IF NOT EXISTS "mirror_rules" (
+		"id" SERIAL PRIMARY KEY,
+		"email" VARCHAR(512) NOT NULL,
+		"source" VARCHAR(512) NOT NULL,
+		"target" VARCHAR(32) NOT NULL,
+		"created_at" TIMESTAMPTZ DEFAULT now(),
+		UNIQUE ("email", "source", "target")
+	  );`)
+
+	if err != nil {
+		logger.Fatal("Error creating schema for mirror_rules", zap.Error(err))
+	}
+
+	_, err = db.Exec(`CREATE TABLE IF NOT EXISTS "inbound_email_senders" (
+		"email" VARCHAR(512) PRIMARY KEY,
+		"sender" VARCHAR(512)
+	  );`)
+
+	if err != nil {
+		logger.Fatal("Error creating schema for inbound_email_senders", zap.Error(err))
+	}
+
+	_, err = db.Exec(`CREATE TABLE IF NOT EXISTS "digest_subscriptions" (
+		"email" VARCHAR(512) PRIMARY KEY,
+		"frequency" VARCHAR(16) NOT NULL,
+		"hour" INTEGER NOT NULL DEFAULT 9,
+		"weekday" INTEGER NOT NULL DEFAULT 1,
+		"timezone" VARCHAR(64) NOT NULL DEFAULT 'UTC',
+		"last_sent_at" TIMESTAMPTZ,
+		"read_until" TIMESTAMPTZ,
+		"created_at" TIMESTAMPTZ DEFAULT now()
+	  );`)
+
+	if err != nil {
+		logger.Fatal("Error creating schema for digest_subscriptions", zap.Error(err))
+	}
+
+	_, err = db.Exec(`CREATE TABLE IF NOT EXISTS "outbox_messages" (
+		"id" SERIAL PRIMARY KEY,
+		"channel" VARCHAR(32) NOT NULL,
+		"recipient" VARCHAR(1024) NOT NULL,
+		"payload" TEXT NOT NULL,
+		"status" VARCHAR(16) NOT NULL,
+		"attempts" INTEGER NOT NULL DEFAULT 0,
+		"max_attempts" INTEGER NOT NULL,
+		"next_attempt_at" TIMESTAMPTZ NOT NULL,
+		"last_error" TEXT,
+		"created_at" TIMESTAMPTZ DEFAULT now(),
+		"updated_at" TIMESTAMPTZ DEFAULT now()
+	  );
+	  CREATE INDEX IF NOT EXISTS "outbox_messages_status_next_attempt_at" ON "outbox_messages" ("status", "next_attempt_at");`)
+
+	if err != nil {
+		logger.Fatal("Error creating schema for outbox_messages", zap.Error(err))
+	}
+
+	_, err = db.Exec(`CREATE TABLE IF NOT EXISTS "whatsapp_message_statuses" (
+		"message_id" VARCHAR(256) PRIMARY KEY,
+		"whatsapp_number" VARCHAR(512) NOT NULL,
+		"status" VARCHAR(16) NOT NULL,
+		"error_code" INTEGER,
+		"error_title" TEXT,
+		"created_at" TIMESTAMPTZ DEFAULT now(),
+		"updated_at" TIMESTAMPTZ DEFAULT now()
+	  );
+	  CREATE INDEX IF NOT EXISTS "whatsapp_message_statuses_number_updated_at" ON "whatsapp_message_statuses" ("whatsapp_number", "updated_at");
+	  CREATE INDEX IF NOT EXISTS "whatsapp_message_statuses_updated_at" ON "whatsapp_message_statuses" ("updated_at");`)
+
+	if err != nil {
+		logger.Fatal("Error creating schema for whatsapp_message_statuses", zap.Error(err))
+	}
+
+	_, err = db.Exec(`CREATE TABLE IF NOT EXISTS "channel_identities" (
+		"channel" VARCHAR(32) NOT NULL,
+		"identity" VARCHAR(512) NOT NULL,
+		"email" VARCHAR(512) NOT NULL,
+		"created_at" TIMESTAMPTZ DEFAULT now(),
+		PRIMARY KEY ("channel", "identity", "email")
+	  );`)
+
+	if err != nil {
+		logger.Fatal("Error creating schema for channel_identities", zap.Error(err))
+	}
+
+	_, err = db.Exec(`CREATE TABLE IF NOT EXISTS "vapid_keys" (
+		"id" INTEGER PRIMARY KEY,
+		"public_key" VARCHAR(128) NOT NULL,
+		"private_key" VARCHAR(512) NOT NULL,
+		"created_at" TIMESTAMPTZ DEFAULT now()
+	  );`)
+
+	if err != nil {
+		logger.Fatal("Error creating schema for vapid_keys", zap.Error(err))
+	}
+
+	_, err = db.Exec(`CREATE TABLE IF NOT EXISTS "push_subscriptions" (
+		"id" SERIAL PRIMARY KEY,
+		"email" VARCHAR(512) NOT NULL,
+		"uuid" VARCHAR(512) NOT NULL,
+		"endpoint" TEXT NOT NULL,
+		"p256dh" VARCHAR(512) NOT NULL,
+		"auth" VARCHAR(512) NOT NULL,
+		"created_at" TIMESTAMPTZ DEFAULT now(),
+		UNIQUE ("email", "endpoint")
+	  );`)
+
+	if err != nil {
+		logger.Fatal("Error creating schema for push_subscriptions", zap.Error(err))
+	}
+
+	_, err = db.Exec(`CREATE TABLE IF NOT EXISTS "fcm_tokens" (
+		"id" SERIAL PRIMARY KEY,
+		"email" VARCHAR(512) NOT NULL,
+		"uuid" VARCHAR(512) NOT NULL,
+		"token" VARCHAR(1024) NOT NULL,
+		"platform" VARCHAR(16) NOT NULL,
+		"created_at" TIMESTAMPTZ DEFAULT now(),
+		"updated_at" TIMESTAMPTZ DEFAULT now(),
+		UNIQUE ("email", "uuid")
+	  );`)
+
+	if err != nil {
+		logger.Fatal("Error creating schema for fcm_tokens", zap.Error(err))
+	}
+
+	_, err = db.Exec(`CREATE TABLE IF NOT EXISTS "notification_settings" (
+		"email" VARCHAR(512) PRIMARY KEY,
+		"timezone" VARCHAR(64) NOT NULL DEFAULT 'UTC',
+		"quiet_start" VARCHAR(5) NOT NULL DEFAULT '',
+		"quiet_end" VARCHAR(5) NOT NULL DEFAULT '',
+		"updated_at" TIMESTAMPTZ DEFAULT now()
+	  );`)
+
+	if err != nil {
+		logger.Fatal("Error creating schema for notification_settings", zap.Error(err))
+	}
+
+	_, err = db.Exec(`ALTER TABLE "notification_settings" ADD COLUMN IF NOT EXISTS "locale" VARCHAR(16) NOT NULL DEFAULT '';`)
+
+	if err != nil {
+		logger.Fatal("Error adding locale to notification_settings", zap.Error(err))
+	}
+
+	_, err = db.Exec(`CREATE TABLE IF NOT EXISTS "notification_preferences" (
+		"email" VARCHAR(512) NOT NULL,
+		"channel" VARCHAR(32) NOT NULL,
+		"events" TEXT[],
+		"max_per_hour" INTEGER NOT NULL DEFAULT 0,
+		"updated_at" TIMESTAMPTZ DEFAULT now(),
+		PRIMARY KEY ("email", "channel")
+	  );`)
+
+	if err != nil {
+		logger.Fatal("Error creating schema for notification_preferences", zap.Error(err))
+	}
+
+	_, err = db.Exec(`CREATE TABLE IF NOT EXISTS "channel_tenants" (
+		"channel" VARCHAR(32) NOT NULL,
+		"identity" VARCHAR(512) NOT NULL,
+		"tenant_id" VARCHAR(64) NOT NULL,
+		"updated_at" TIMESTAMPTZ DEFAULT now(),
+		PRIMARY KEY ("channel", "identity")
+	  );`)
+
+	if err != nil {
+		logger.Fatal("Error creating schema for channel_tenants", zap.Error(err))
+	}
+
 	return db
 }
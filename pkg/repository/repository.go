@@ -1,10 +1,8 @@
 package repository
 
 import (
-	"context"
-	"encoding/json"
 	"github.com/go-pg/pg"
-	"github.com/iraunit/get-link-backend/pkg/cryptography"
+	"github.com/iraunit/get-link-backend/util"
 	"github.com/iraunit/get-link-backend/util/bean"
 	"github.com/redis/go-redis/v9"
 	"go.uber.org/zap"
@@ -20,16 +18,67 @@ type User struct {
 }
 
 type Repository interface {
-	AddLink(getLink *bean.GetLink, decryptedData *bean.GetLink, receiverMail string)
+	AddLink(getLink *bean.GetLink)
 	DeleteLink(data *bean.GetLink) error
+	AddLinkTag(data *bean.GetLink, tag string) error
 	GetAllLink(dst string, uuid string) *[]bean.GetLink
 	InsertUpdateWhatsappNumber(claims *bean.WhatsappEmail) error
 	GetEmailsFromWhatsappNumber(number string) ([]bean.WhatsappEmail, error)
+	DeleteWhatsappEmail(email, number string) error
+	GetWhatsappNumbersFromEmail(email string) ([]bean.WhatsappEmail, error)
+	ClearWhatsappNumber(email, number string) error
+	UpsertWhatsappMessageStatus(status *bean.WhatsappMessageStatus) (bool, error)
+	DeleteWhatsappMessageStatusesBefore(before time.Time) error
+	GetWhatsappMessageStatuses(number string, messageID string, limit int) ([]bean.WhatsappMessageStatus, error)
 	GetEmailsFromTelegramSender(sender string) ([]bean.TelegramEmail, error)
 	IsUserPremiumUser(userEmail string) bool
 	InsertUpdateTelegramNumber(email, chatId, senderId string) error
 	GetEmailsFromEmail(sender string) ([]bean.TelegramEmail, error)
+	DeleteTelegramEmail(email, senderId string) error
+	InsertTelegramGroup(group *bean.TelegramGroup) error
+	GetEmailsFromTelegramGroup(chatId string) ([]bean.TelegramGroup, error)
+	DeleteTelegramGroup(chatId, email string) error
 	GetWhatsappNumberFromEmail(email string) (string, error)
+	InsertInboundEmailAddress(address *bean.InboundEmailAddress) error
+	GetInboundEmailAddressFromCode(code string) (*bean.InboundEmailAddress, error)
+	GetInboundEmailAddressFromOwner(owner string) (*bean.InboundEmailAddress, error)
+	InsertUpdateInboundEmailSender(sender *bean.InboundEmailSender) error
+	GetEmailsFromInboundSender(sender string) ([]bean.InboundEmailSender, error)
+	GetLinksSince(receiver string, since time.Time) ([]bean.GetLink, error)
+	GetDigestSubscription(email string) (*bean.DigestSubscription, error)
+	GetAllDigestSubscriptions() ([]bean.DigestSubscription, error)
+	InsertUpdateDigestSubscription(subscription *bean.DigestSubscription) error
+	UpdateDigestSubscriptionTime(email, column string, t time.Time) error
+	DeleteDigestSubscription(email string) error
+	InsertOutboxMessage(message *bean.OutboxMessage) error
+	ClaimOutboxMessages(limit int, staleAfter time.Duration) ([]bean.OutboxMessage, error)
+	UpdateOutboxMessage(message *bean.OutboxMessage) error
+	GetOutboxMessages(status string, limit int) ([]bean.OutboxMessage, error)
+	RetryOutboxMessage(id int) error
+	DeleteOutboxMessagesBefore(status string, before time.Time) error
+	InsertMirrorRule(rule *bean.MirrorRule) error
+	GetMirrorRules(email string) ([]bean.MirrorRule, error)
+	DeleteMirrorRule(id int, email string) error
+	InsertChannelIdentity(identity *bean.ChannelIdentity) error
+	GetChannelIdentities(channel, identity string) ([]bean.ChannelIdentity, error)
+	DeleteChannelIdentity(channel, identity, email string) error
+	InsertUpdateChannelTenant(channelTenant *bean.ChannelTenant) error
+	GetChannelTenant(channel, identity string) (*bean.ChannelTenant, error)
+	GetVapidKey() (*bean.VapidKey, error)
+	InsertVapidKey(key *bean.VapidKey) error
+	InsertUpdatePushSubscription(subscription *bean.PushSubscription) error
+	GetPushSubscriptions(email string) ([]bean.PushSubscription, error)
+	GetPushSubscription(id int) (*bean.PushSubscription, error)
+	DeletePushSubscription(email, endpoint string) error
+	DeletePushSubscriptionByID(id int) error
+	InsertUpdateFcmToken(token *bean.FcmToken) error
+	GetFcmTokens(email string) ([]bean.FcmToken, error)
+	GetFcmToken(id int) (*bean.FcmToken, error)
+	DeleteFcmToken(email, uuid string) error
+	DeleteFcmTokenByID(id int) error
+	GetNotificationSettings(email string) (*bean.NotificationSettings, error)
+	GetNotificationPreferences(email string) ([]bean.NotificationPreference, error)
+	SaveNotificationPreferences(settings *bean.NotificationSettings, preferences []bean.NotificationPreference) error
 }
 
 type Impl struct {
@@ -48,21 +97,10 @@ func NewRepositoryImpl(db *pg.DB, logger *zap.SugaredLogger, client *redis.Clien
 	}
 }
 
-func (impl *Impl) AddLink(getLink *bean.GetLink, decryptedData *bean.GetLink, receiverMail string) {
+func (impl *Impl) AddLink(getLink *bean.GetLink) {
 	impl.lock.Lock()
 	defer impl.lock.Unlock()
-	result, err := impl.db.Model(getLink).Insert()
-	if result.RowsAffected() > 0 {
-		pubSubMessage := bean.PubSubMessage{Message: decryptedData.Message, UUID: decryptedData.UUID, ID: getLink.ID, Sender: decryptedData.Sender}
-		pubSubMessageJson, err := json.Marshal(pubSubMessage)
-		encryptedJson, err := cryptography.EncryptData(receiverMail, string(pubSubMessageJson), impl.logger)
-		if err != nil {
-			impl.logger.Errorw("Error in encrypting json", "Error: ", err)
-			return
-		} else {
-			_ = impl.client.Publish(context.Background(), getLink.Receiver, encryptedJson).Err()
-		}
-	}
+	_, err := impl.db.Model(getLink).Insert()
 	if err != nil {
 		impl.logger.Errorw("Error in adding link", "Error: ", err)
 	}
@@ -71,11 +109,35 @@ func (impl *Impl) AddLink(getLink *bean.GetLink, decryptedData *bean.GetLink, re
 func (impl *Impl) DeleteLink(data *bean.GetLink) error {
 	impl.lock.Lock()
 	defer impl.lock.Unlock()
-	_, err := impl.db.Model(data).WherePK().Delete()
+	result, err := impl.db.Model(data).
+		Where("id = ?", data.ID).
+		Where("receiver = ?", data.Receiver).
+		Delete()
 	if err != nil {
 		impl.logger.Errorw("Error in deleting link", "Error: ", err)
 		return err
 	}
+	if result.RowsAffected() == 0 {
+		return pg.ErrNoRows
+	}
+	return nil
+}
+
+func (impl *Impl) AddLinkTag(data *bean.GetLink, tag string) error {
+	impl.lock.Lock()
+	defer impl.lock.Unlock()
+	result, err := impl.db.Model(data).
+		Set("tags = CASE WHEN ? = ANY(coalesce(tags, '{}')) THEN tags ELSE array_append(coalesce(tags, '{}'), ?) END", tag, tag).
+		Where("id = ?", data.ID).
+		Where("receiver = ?", data.Receiver).
+		Update()
+	if err != nil {
+		impl.logger.Errorw("Error in tagging link", "Error: ", err)
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return pg.ErrNoRows
+	}
 	return nil
 }
 
@@ -86,7 +148,7 @@ func (impl *Impl) GetAllLink(receiver string, uuid string) *[]bean.GetLink {
 	var result []bean.GetLink
 	impl.logger.Infow("Info", "Receiver", receiver, "UUID", uuid)
 	err := impl.db.Model(&result).
-		Column("id", "sender", "message", "uuid").
+		Column("id", "sender", "message", "uuid", "created_at", "tags").
 		Where("receiver=?", receiver).
 		Where("uuid != ?", uuid).
 		Select()
@@ -133,6 +195,46 @@ func (impl *Impl) GetEmailsFromWhatsappNumber(number string) ([]bean.WhatsappEma
 	return result, nil
 }
 
+func (impl *Impl) DeleteWhatsappEmail(email, number string) error {
+	impl.lock.Lock()
+	defer impl.lock.Unlock()
+	_, err := impl.db.Model(&bean.WhatsappEmail{}).
+		Where("email = ?", email).
+		Where("whatsapp_number = ?", number).
+		Delete()
+	if err != nil {
+		impl.logger.Errorw("Error in deleting whatsapp email", "Error: ", err)
+	}
+	return err
+}
+
+func (impl *Impl) GetWhatsappNumbersFromEmail(email string) ([]bean.WhatsappEmail, error) {
+	impl.lock.Lock()
+	defer impl.lock.Unlock()
+	var result []bean.WhatsappEmail
+	err := impl.db.Model(&result).Column("whatsapp_number").Where("email = ?", email).Select()
+	if err != nil {
+		impl.logger.Errorw("Error in getting numbers from email", "Error: ", err)
+		return nil, err
+	}
+	return result, nil
+}
+
+func (impl *Impl) ClearWhatsappNumber(email, number string) error {
+	impl.lock.Lock()
+	defer impl.lock.Unlock()
+	_, err := impl.db.Model(&User{}).
+		Set("whatsapp_number = NULL").
+		Where("email = ?", email).
+		// the profile number may be saved with a + or spaces, WhatsApp sends digits only
+		Where("regexp_replace(whatsapp_number, '[^0-9]', '', 'g') = regexp_replace(?, '[^0-9]', '', 'g')", number).
+		Update()
+	if err != nil {
+		impl.logger.Errorw("Error in clearing whatsapp number", "Error: ", err)
+	}
+	return err
+}
+
 func (impl *Impl) IsUserPremiumUser(userEmail string) bool {
 	impl.lock.Lock()
 	defer impl.lock.Unlock()
@@ -173,6 +275,54 @@ func (impl *Impl) GetEmailsFromTelegramSender(sender string) ([]bean.TelegramEma
 	return result, nil
 }
 
+func (impl *Impl) DeleteTelegramEmail(email, senderId string) error {
+	impl.lock.Lock()
+	defer impl.lock.Unlock()
+	_, err := impl.db.Model(&bean.TelegramEmail{}).
+		Where("email = ?", email).
+		Where("sender_id = ?", senderId).
+		Delete()
+	if err != nil {
+		impl.logger.Errorw("Error in deleting telegram email", "Error: ", err)
+	}
+	return err
+}
+
+func (impl *Impl) InsertTelegramGroup(group *bean.TelegramGroup) error {
+	impl.lock.Lock()
+	defer impl.lock.Unlock()
+	_, err := impl.db.Model(group).OnConflict("DO NOTHING").Insert()
+	if err != nil {
+		impl.logger.Errorw("Error in inserting telegram group", "Error: ", err)
+	}
+	return err
+}
+
+func (impl *Impl) GetEmailsFromTelegramGroup(chatId string) ([]bean.TelegramGroup, error) {
+	impl.lock.Lock()
+	defer impl.lock.Unlock()
+	var result []bean.TelegramGroup
+	err := impl.db.Model(&result).Column("email").Where("chat_id = ?", chatId).Select()
+	if err != nil {
+		impl.logger.Errorw("Error in getting emails from telegram group", "Error: ", err)
+		return nil, err
+	}
+	return result, nil
+}
+
+func (impl *Impl) DeleteTelegramGroup(chatId, email string) error {
+	impl.lock.Lock()
+	defer impl.lock.Unlock()
+	_, err := impl.db.Model(&bean.TelegramGroup{}).
+		Where("chat_id = ?", chatId).
+		Where("email = ?", email).
+		Delete()
+	if err != nil {
+		impl.logger.Errorw("Error in deleting telegram group", "Error: ", err)
+	}
+	return err
+}
+
 func (impl *Impl) GetEmailsFromEmail(sender string) ([]bean.TelegramEmail, error) {
 	impl.lock.Lock()
 	defer impl.lock.Unlock()
@@ -197,3 +347,595 @@ func (impl *Impl) GetWhatsappNumberFromEmail(email string) (string, error) {
 	}
 	return user.WhatsappNumber, nil
 }
+
+func (impl *Impl) InsertInboundEmailAddress(address *bean.InboundEmailAddress) error {
+	impl.lock.Lock()
+	defer impl.lock.Unlock()
+	_, err := impl.db.Model(address).Insert()
+	if err != nil {
+		impl.logger.Errorw("Error in inserting inbound email address", "Error: ", err)
+	}
+	return err
+}
+
+func (impl *Impl) GetInboundEmailAddressFromCode(code string) (*bean.InboundEmailAddress, error) {
+	impl.lock.Lock()
+	defer impl.lock.Unlock()
+	var address bean.InboundEmailAddress
+	err := impl.db.Model(&address).Where("code = ?", code).Select()
+	if err != nil {
+		impl.logger.Errorw("Error in getting inbound email address from code", "Error: ", err)
+		return nil, err
+	}
+	return &address, nil
+}
+
+func (impl *Impl) GetInboundEmailAddressFromOwner(owner string) (*bean.InboundEmailAddress, error) {
+	impl.lock.Lock()
+	defer impl.lock.Unlock()
+	var address bean.InboundEmailAddress
+	err := impl.db.Model(&address).Where("owner = ?", owner).Select()
+	if err != nil {
+		return nil, err
+	}
+	return &address, nil
+}
+
+func (impl *Impl) InsertUpdateInboundEmailSender(sender *bean.InboundEmailSender) error {
+	impl.lock.Lock()
+	defer impl.lock.Unlock()
+	_, err := impl.db.Model(&bean.InboundEmailSender{}).Where("email = ?", sender.Email).Delete()
+	if err != nil {
+		impl.logger.Errorw("Error in verifying inbound email sender", "Error: ", err)
+		return err
+	}
+	_, err = impl.db.Model(sender).Insert()
+	if err != nil {
+		impl.logger.Errorw("Error in verifying inbound email sender", "Error: ", err)
+	}
+	return err
+}
+
+func (impl *Impl) GetEmailsFromInboundSender(sender string) ([]bean.InboundEmailSender, error) {
+	impl.lock.Lock()
+	defer impl.lock.Unlock()
+	var result []bean.InboundEmailSender
+	err := impl.db.Model(&result).Column("email").Where("sender = ?", sender).Select()
+	if err != nil {
+		impl.logger.Errorw("Error in getting emails from inbound sender", "Error: ", err)
+		return nil, err
+	}
+	return result, nil
+}
+
+func (impl *Impl) GetLinksSince(receiver string, since time.Time) ([]bean.GetLink, error) {
+	impl.lock.Lock()
+	defer impl.lock.Unlock()
+
+	var result []bean.GetLink
+	err := impl.db.Model(&result).
+		Column("id", "sender", "message", "uuid", "created_at", "tags").
+		Where("receiver = ?", receiver).
+		Where("created_at > ?", since).
+		Order("created_at ASC").
+		Select()
+	if err != nil {
+		impl.logger.Errorw("Error in getting links since", "Error: ", err)
+		return nil, err
+	}
+	return result, nil
+}
+
+func (impl *Impl) GetDigestSubscription(email string) (*bean.DigestSubscription, error) {
+	impl.lock.Lock()
+	defer impl.lock.Unlock()
+	var subscription bean.DigestSubscription
+	err := impl.db.Model(&subscription).Where("email = ?", email).Select()
+	if err != nil {
+		return nil, err
+	}
+	return &subscription, nil
+}
+
+func (impl *Impl) GetAllDigestSubscriptions() ([]bean.DigestSubscription, error) {
+	impl.lock.Lock()
+	defer impl.lock.Unlock()
+	var result []bean.DigestSubscription
+	err := impl.db.Model(&result).Select()
+	if err != nil {
+		impl.logger.Errorw("Error in getting digest subscriptions", "Error: ", err)
+		return nil, err
+	}
+	return result, nil
+}
+
+func (impl *Impl) InsertUpdateDigestSubscription(subscription *bean.DigestSubscription) error {
+	impl.lock.Lock()
+	defer impl.lock.Unlock()
+	_, err := impl.db.Model(subscription).
+		OnConflict("(email) DO UPDATE").
+		Set("frequency = EXCLUDED.frequency, hour = EXCLUDED.hour, weekday = EXCLUDED.weekday, timezone = EXCLUDED.timezone").
+		Insert()
+	if err != nil {
+		impl.logger.Errorw("Error in saving digest subscription", "Error: ", err)
+	}
+	return err
+}
+
+func (impl *Impl) UpdateDigestSubscriptionTime(email, column string, t time.Time) error {
+	impl.lock.Lock()
+	defer impl.lock.Unlock()
+	_, err := impl.db.Model(&bean.DigestSubscription{}).Set("? = ?", pg.F(column), t).Where("email = ?", email).Update()
+	if err != nil {
+		impl.logger.Errorw("Error in updating digest subscription", "Column", column, "Error: ", err)
+	}
+	return err
+}
+
+func (impl *Impl) DeleteDigestSubscription(email string) error {
+	impl.lock.Lock()
+	defer impl.lock.Unlock()
+	_, err := impl.db.Model(&bean.DigestSubscription{}).Where("email = ?", email).Delete()
+	if err != nil {
+		impl.logger.Errorw("Error in deleting digest subscription", "Error: ", err)
+	}
+	return err
+}
+
+func (impl *Impl) InsertOutboxMessage(message *bean.OutboxMessage) error {
+	impl.lock.Lock()
+	defer impl.lock.Unlock()
+	_, err := impl.db.Model(message).Insert()
+	if err != nil {
+		impl.logger.Errorw("Error in inserting outbox message", "Error: ", err)
+	}
+	return err
+}
+
+func (impl *Impl) ClaimOutboxMessages(limit int, staleAfter time.Duration) ([]bean.OutboxMessage, error) {
+	impl.lock.Lock()
+	defer impl.lock.Unlock()
+	var result []bean.OutboxMessage
+	_, err := impl.db.Query(&result, `UPDATE "outbox_messages" SET "status" = ?, "updated_at" = now()
+		WHERE "id" IN (
+			SELECT "id" FROM "outbox_messages"
+			WHERE ("status" = ? AND "next_attempt_at" <= now()) OR ("status" = ? AND "updated_at" < ?)
+			ORDER BY "next_attempt_at"
+			LIMIT ?
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING *`, util.OutboxSending, util.OutboxPending, util.OutboxSending, time.Now().Add(-staleAfter), limit)
+	if err != nil {
+		impl.logger.Errorw("Error in claiming outbox messages", "Error: ", err)
+		return nil, err
+	}
+	return result, nil
+}
+
+func (impl *Impl) UpdateOutboxMessage(message *bean.OutboxMessage) error {
+	impl.lock.Lock()
+	defer impl.lock.Unlock()
+	message.UpdatedAt = time.Now()
+	_, err := impl.db.Model(message).
+		Column("status", "attempts", "next_attempt_at", "last_error", "updated_at").
+		WherePK().
+		Update()
+	if err != nil {
+		impl.logger.Errorw("Error in updating outbox message", "Error: ", err)
+	}
+	return err
+}
+
+func (impl *Impl) GetOutboxMessages(status string, limit int) ([]bean.OutboxMessage, error) {
+	impl.lock.Lock()
+	defer impl.lock.Unlock()
+	var result []bean.OutboxMessage
+	query := impl.db.Model(&result).Order("updated_at DESC").Limit(limit)
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	err := query.Select()
+	if err != nil {
+		impl.logger.Errorw("Error in getting outbox messages", "Error: ", err)
+		return nil, err
+	}
+	return result, nil
+}
+
+func (impl *Impl) DeleteOutboxMessagesBefore(status string, before time.Time) error {
+	impl.lock.Lock()
+	defer impl.lock.Unlock()
+	_, err := impl.db.Model((*bean.OutboxMessage)(nil)).Where("status = ? AND updated_at < ?", status, before).Delete()
+	if err != nil {
+		impl.logger.Errorw("Error in deleting old outbox messages", "Status", status, "Error: ", err)
+	}
+	return err
+}
+
+func (impl *Impl) RetryOutboxMessage(id int) error {
+	impl.lock.Lock()
+	defer impl.lock.Unlock()
+	result, err := impl.db.Model(&bean.OutboxMessage{}).
+		Set("status = ?, attempts = 0, next_attempt_at = now(), updated_at = now()", util.OutboxPending).
+		Where("id = ?", id).
+		Update()
+	if err != nil {
+		impl.logger.Errorw("Error in retrying outbox message", "Error: ", err)
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return pg.ErrNoRows
+	}
+	return nil
+}
+
+func (impl *Impl) InsertMirrorRule(rule *bean.MirrorRule) error {
+	impl.lock.Lock()
+	defer impl.lock.Unlock()
+	_, err := impl.db.Model(rule).OnConflict("DO NOTHING").Insert()
+	if err != nil {
+		impl.logger.Errorw("Error in inserting mirror rule", "Error: ", err)
+	}
+	return err
+}
+
+func (impl *Impl) GetMirrorRules(email string) ([]bean.MirrorRule, error) {
+	impl.lock.Lock()
+	defer impl.lock.Unlock()
+	var result []bean.MirrorRule
+	err := impl.db.Model(&result).Where("email = ?", email).Order("id").Select()
+	if err != nil {
+		impl.logger.Errorw("Error in getting mirror rules", "Error: ", err)
+		return nil, err
+	}
+	return result, nil
+}
+
+func (impl *Impl) DeleteMirrorRule(id int, email string) error {
+	impl.lock.Lock()
+	defer impl.lock.Unlock()
+	result, err := impl.db.Model(&bean.MirrorRule{}).
+		Where("id = ?", id).
+		Where("email = ?", email).
+		Delete()
+	if err != nil {
+		impl.logger.Errorw("Error in deleting mirror rule", "Error: ", err)
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return pg.ErrNoRows
+	}
+	return nil
+}
+
+// whatsappStatusOrder ranks statuses so late or repeated callbacks never move a message backwards.
+var whatsappStatusOrder = []string{util.WhatsappStatusAccepted, util.WhatsappStatusSent, util.WhatsappStatusDelivered, util.WhatsappStatusRead, util.WhatsappStatusFailed}
+
+// UpsertWhatsappMessageStatus reports whether the status moved the message forward.
+func (impl *Impl) UpsertWhatsappMessageStatus(status *bean.WhatsappMessageStatus) (bool, error) {
+	impl.lock.Lock()
+	defer impl.lock.Unlock()
+	status.UpdatedAt = time.Now()
+	result, err := impl.db.Model(status).
+		OnConflict("(message_id) DO UPDATE").
+		Set("status = EXCLUDED.status, error_code = EXCLUDED.error_code, error_title = EXCLUDED.error_title, updated_at = EXCLUDED.updated_at").
+		Where("array_position(?, EXCLUDED.status) > array_position(?, ?TableAlias.status)", pg.Array(whatsappStatusOrder), pg.Array(whatsappStatusOrder)).
+		Insert()
+	if err != nil {
+		impl.logger.Errorw("Error in saving whatsapp message status", "Error: ", err)
+		return false, err
+	}
+	return result.RowsAffected() > 0, nil
+}
+
+func (impl *Impl) DeleteWhatsappMessageStatusesBefore(before time.Time) error {
+	impl.lock.Lock()
+	defer impl.lock.Unlock()
+	_, err := impl.db.Model((*bean.WhatsappMessageStatus)(nil)).Where("updated_at < ?", before).Delete()
+	if err != nil {
+		impl.logger.Errorw("Error in deleting old whatsapp message statuses", "Error: ", err)
+	}
+	return err
+}
+
+func (impl *Impl) GetWhatsappMessageStatuses(number string, messageID string, limit int) ([]bean.WhatsappMessageStatus, error) {
+	impl.lock.Lock()
+	defer impl.lock.Unlock()
+	var result []bean.WhatsappMessageStatus
+	query := impl.db.Model(&result).Where("whatsapp_number = ?", number).Order("updated_at DESC").Limit(limit)
+	if messageID != "" {
+		query = query.Where("message_id = ?", messageID)
+	}
+	err := query.Select()
+	if err != nil {
+		impl.logger.Errorw("Error in getting whatsapp message statuses", "Error: ", err)
+		return nil, err
+	}
+	return result, nil
+}
+
+func (impl *Impl) InsertChannelIdentity(identity *bean.ChannelIdentity) error {
+	impl.lock.Lock()
+	defer impl.lock.Unlock()
+	_, err := impl.db.Model(identity).OnConflict("DO NOTHING").Insert()
+	if err != nil {
+		impl.logger.Errorw("Error in inserting channel identity", "Channel", identity.Channel, "Error: ", err)
+	}
+	return err
+}
+
+func (impl *Impl) GetChannelIdentities(channel, identity string) ([]bean.ChannelIdentity, error) {
+	impl.lock.Lock()
+	defer impl.lock.Unlock()
+	var result []bean.ChannelIdentity
+	err := impl.db.Model(&result).
+		Where("channel = ?", channel).
+		Where("identity = ?", identity).
+		Select()
+	if err != nil {
+		impl.logger.Errorw("Error in getting channel identities", "Channel", channel, "Error: ", err)
+		return nil, err
+	}
+	return result, nil
+}
+
+func (impl *Impl) DeleteChannelIdentity(channel, identity, email string) error {
+	impl.lock.Lock()
+	defer impl.lock.Unlock()
+	_, err := impl.db.Model(&bean.ChannelIdentity{}).
+		Where("channel = ?", channel).
+		Where("identity = ?", identity).
+		Where("email = ?", email).
+		Delete()
+	if err != nil {
+		impl.logger.Errorw("Error in deleting channel identity", "Channel", channel, "Error: ", err)
+	}
+	return err
+}
+
+func (impl *Impl) InsertUpdateChannelTenant(channelTenant *bean.ChannelTenant) error {
+	impl.lock.Lock()
+	defer impl.lock.Unlock()
+	channelTenant.UpdatedAt = time.Now()
+	_, err := impl.db.Model(channelTenant).
+		OnConflict("(channel, identity) DO UPDATE").
+		Set("tenant_id = EXCLUDED.tenant_id, updated_at = EXCLUDED.updated_at").
+		Insert()
+	if err != nil {
+		impl.logger.Errorw("Error in saving channel tenant", "Channel", channelTenant.Channel, "Error: ", err)
+	}
+	return err
+}
+
+func (impl *Impl) GetChannelTenant(channel, identity string) (*bean.ChannelTenant, error) {
+	impl.lock.Lock()
+	defer impl.lock.Unlock()
+	channelTenant := &bean.ChannelTenant{Channel: channel, Identity: identity}
+	err := impl.db.Model(channelTenant).WherePK().Select()
+	if err != nil {
+		if err != pg.ErrNoRows {
+			impl.logger.Errorw("Error in getting channel tenant", "Channel", channel, "Error: ", err)
+		}
+		return nil, err
+	}
+	return channelTenant, nil
+}
+
+func (impl *Impl) GetVapidKey() (*bean.VapidKey, error) {
+	impl.lock.Lock()
+	defer impl.lock.Unlock()
+	key := &bean.VapidKey{ID: 1}
+	err := impl.db.Model(key).WherePK().Select()
+	if err != nil {
+		if err != pg.ErrNoRows {
+			impl.logger.Errorw("Error in getting vapid key", "Error: ", err)
+		}
+		return nil, err
+	}
+	return key, nil
+}
+
+// InsertVapidKey keeps the first key written, so instances starting together agree on one key.
+func (impl *Impl) InsertVapidKey(key *bean.VapidKey) error {
+	impl.lock.Lock()
+	defer impl.lock.Unlock()
+	key.ID = 1
+	_, err := impl.db.Model(key).OnConflict("DO NOTHING").Insert()
+	if err != nil {
+		impl.logger.Errorw("Error in inserting vapid key", "Error: ", err)
+	}
+	return err
+}
+
+func (impl *Impl) InsertUpdatePushSubscription(subscription *bean.PushSubscription) error {
+	impl.lock.Lock()
+	defer impl.lock.Unlock()
+	_, err := impl.db.Model(subscription).
+		OnConflict("(email, endpoint) DO UPDATE").
+		Set("uuid = EXCLUDED.uuid, p256dh = EXCLUDED.p256dh, auth = EXCLUDED.auth").
+		Returning("id").
+		Insert()
+	if err != nil {
+		impl.logger.Errorw("Error in inserting push subscription", "Error: ", err)
+	}
+	return err
+}
+
+func (impl *Impl) GetPushSubscriptions(email string) ([]bean.PushSubscription, error) {
+	impl.lock.Lock()
+	defer impl.lock.Unlock()
+	var result []bean.PushSubscription
+	err := impl.db.Model(&result).Where("email = ?", email).Order("id").Select()
+	if err != nil {
+		impl.logger.Errorw("Error in getting push subscriptions", "Error: ", err)
+		return nil, err
+	}
+	return result, nil
+}
+
+func (impl *Impl) GetPushSubscription(id int) (*bean.PushSubscription, error) {
+	impl.lock.Lock()
+	defer impl.lock.Unlock()
+	subscription := &bean.PushSubscription{}
+	err := impl.db.Model(subscription).Where("id = ?", id).Select()
+	if err != nil {
+		if err != pg.ErrNoRows {
+			impl.logger.Errorw("Error in getting push subscription", "Error: ", err)
+		}
+		return nil, err
+	}
+	return subscription, nil
+}
+
+func (impl *Impl) DeletePushSubscription(email, endpoint string) error {
+	impl.lock.Lock()
+	defer impl.lock.Unlock()
+	result, err := impl.db.Model(&bean.PushSubscription{}).
+		Where("email = ?", email).
+		Where("endpoint = ?", endpoint).
+		Delete()
+	if err != nil {
+		impl.logger.Errorw("Error in deleting push subscription", "Error: ", err)
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return pg.ErrNoRows
+	}
+	return nil
+}
+
+func (impl *Impl) DeletePushSubscriptionByID(id int) error {
+	impl.lock.Lock()
+	defer impl.lock.Unlock()
+	_, err := impl.db.Model(&bean.PushSubscription{}).Where("id = ?", id).Delete()
+	if err != nil {
+		impl.logger.Errorw("Error in deleting push subscription", "Error: ", err)
+	}
+	return err
+}
+
+// InsertUpdateFcmToken keeps one token per device, replacing it when the app refreshes its token.
+func (impl *Impl) InsertUpdateFcmToken(token *bean.FcmToken) error {
+	impl.lock.Lock()
+	defer impl.lock.Unlock()
+	token.UpdatedAt = time.Now()
+	_, err := impl.db.Model(token).
+		OnConflict("(email, uuid) DO UPDATE").
+		Set("token = EXCLUDED.token, platform = EXCLUDED.platform, updated_at = EXCLUDED.updated_at").
+		Returning("id").
+		Insert()
+	if err != nil {
+		impl.logger.Errorw("Error in inserting fcm token", "Error: ", err)
+	}
+	return err
+}
+
+func (impl *Impl) GetFcmTokens(email string) ([]bean.FcmToken, error) {
+	impl.lock.Lock()
+	defer impl.lock.Unlock()
+	var result []bean.FcmToken
+	err := impl.db.Model(&result).Where("email = ?", email).Order("id").Select()
+	if err != nil {
+		impl.logger.Errorw("Error in getting fcm tokens", "Error: ", err)
+		return nil, err
+	}
+	return result, nil
+}
+
+func (impl *Impl) GetFcmToken(id int) (*bean.FcmToken, error) {
+	impl.lock.Lock()
+	defer impl.lock.Unlock()
+	token := &bean.FcmToken{}
+	err := impl.db.Model(token).Where("id = ?", id).Select()
+	if err != nil {
+		if err != pg.ErrNoRows {
+			impl.logger.Errorw("Error in getting fcm token", "Error: ", err)
+		}
+		return nil, err
+	}
+	return token, nil
+}
+
+func (impl *Impl) DeleteFcmToken(email, uuid string) error {
+	impl.lock.Lock()
+	defer impl.lock.Unlock()
+	result, err := impl.db.Model(&bean.FcmToken{}).
+		Where("email = ?", email).
+		Where("uuid = ?", uuid).
+		Delete()
+	if err != nil {
+		impl.logger.Errorw("Error in deleting fcm token", "Error: ", err)
+		return err
+	}
+	if result.RowsAffected() == 0 {
+		return pg.ErrNoRows
+	}
+	return nil
+}
+
+func (impl *Impl) DeleteFcmTokenByID(id int) error {
+	impl.lock.Lock()
+	defer impl.lock.Unlock()
+	_, err := impl.db.Model(&bean.FcmToken{}).Where("id = ?", id).Delete()
+	if err != nil {
+		impl.logger.Errorw("Error in deleting fcm token", "Error: ", err)
+	}
+	return err
+}
+
+func (impl *Impl) GetNotificationSettings(email string) (*bean.NotificationSettings, error) {
+	impl.lock.Lock()
+	defer impl.lock.Unlock()
+	settings := &bean.NotificationSettings{Email: email}
+	err := impl.db.Model(settings).WherePK().Select()
+	if err != nil {
+		if err != pg.ErrNoRows {
+			impl.logger.Errorw("Error in getting notification settings", "Error: ", err)
+		}
+		return nil, err
+	}
+	return settings, nil
+}
+
+func (impl *Impl) GetNotificationPreferences(email string) ([]bean.NotificationPreference, error) {
+	impl.lock.Lock()
+	defer impl.lock.Unlock()
+	var result []bean.NotificationPreference
+	err := impl.db.Model(&result).Where("email = ?", email).Select()
+	if err != nil {
+		impl.logger.Errorw("Error in getting notification preferences", "Error: ", err)
+		return nil, err
+	}
+	return result, nil
+}
+
+// SaveNotificationPreferences replaces the settings and the given channels' preferences in one transaction.
+func (impl *Impl) SaveNotificationPreferences(settings *bean.NotificationSettings, preferences []bean.NotificationPreference) error {
+	impl.lock.Lock()
+	defer impl.lock.Unlock()
+	err := impl.db.RunInTransaction(func(tx *pg.Tx) error {
+		settings.UpdatedAt = time.Now()
+		_, err := tx.Model(settings).
+			OnConflict("(email) DO UPDATE").
+			Set("timezone = EXCLUDED.timezone, quiet_start = EXCLUDED.quiet_start, quiet_end = EXCLUDED.quiet_end, locale = EXCLUDED.locale, updated_at = EXCLUDED.updated_at").
+			Insert()
+		if err != nil {
+			return err
+		}
+		for i := range preferences {
+			preferences[i].UpdatedAt = settings.UpdatedAt
+			_, err = tx.Model(&preferences[i]).
+				OnConflict("(email, channel) DO UPDATE").
+				Set("events = EXCLUDED.events, max_per_hour = EXCLUDED.max_per_hour, updated_at = EXCLUDED.updated_at").
+				Insert()
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		impl.logger.Errorw("Error in saving notification preferences", "Error: ", err)
+	}
+	return err
+}
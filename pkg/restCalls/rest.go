@@ -1,10 +1,10 @@
 package restCalls
 
 import (
+	"bytes"
 	"encoding/json"
 	"errors"
 	"fmt"
-	"github.com/caarlos0/env"
 	"github.com/go-resty/resty/v2"
 	"github.com/iraunit/get-link-backend/pkg/fileManager"
 	"github.com/iraunit/get-link-backend/util"
@@ -16,37 +16,32 @@ import (
 )
 
 type RestClient interface {
-	SendWhatsappMessage(url string, body interface{}) (string, error)
-	GetMediaDataFromId(url string) (*bean.WhatsappMedia, error)
+	SendWhatsappMessage(url, token string, body interface{}) (string, error)
+	GetMediaDataFromId(url, token string) (*bean.WhatsappMedia, error)
 	DownloadMediaFromUrl(url string, token string, filePath string, userEmail string)
 	DownloadTelegramMediaFromUrl(url, filePath, userEmail string)
+	UploadWhatsappMedia(url, token, fileName, mimeType string, data []byte) (string, error)
 }
 
 type RestClientImpl struct {
 	logger      *zap.SugaredLogger
-	cfg         bean.WhatsAppConfig
 	async       *util.Async
 	fileManager fileManager.FileManager
 }
 
 func NewRestClientImpl(logger *zap.SugaredLogger, async *util.Async, fileManager fileManager.FileManager) *RestClientImpl {
-	cfg := bean.WhatsAppConfig{}
-	if err := env.Parse(&cfg); err != nil {
-		logger.Fatal("Error in parsing config", "Error", err)
-	}
 	return &RestClientImpl{
 		logger:      logger,
-		cfg:         cfg,
 		async:       async,
 		fileManager: fileManager,
 	}
 }
 
-func (impl *RestClientImpl) SendWhatsappMessage(url string, body interface{}) (string, error) {
+func (impl *RestClientImpl) SendWhatsappMessage(url, token string, body interface{}) (string, error) {
 	client := resty.New()
 	resp, err := client.R().
 		SetHeader("Content-Type", "application/json").
-		SetHeader("Authorization", "Bearer "+impl.cfg.AuthToken).
+		SetHeader("Authorization", "Bearer "+token).
 		SetBody(body).
 		Post(url)
 
@@ -55,14 +50,19 @@ func (impl *RestClientImpl) SendWhatsappMessage(url string, body interface{}) (s
 		return "", err
 	}
 
+	if resp.IsError() {
+		impl.logger.Errorw("Error in sending whatsapp message. status not ok.", "Status", resp.StatusCode(), "Body", string(resp.Body()))
+		return "", errors.New(fmt.Sprintf("Status : %d", resp.StatusCode()))
+	}
+
 	return string(resp.Body()), nil
 }
 
-func (impl *RestClientImpl) GetMediaDataFromId(url string) (*bean.WhatsappMedia, error) {
+func (impl *RestClientImpl) GetMediaDataFromId(url, token string) (*bean.WhatsappMedia, error) {
 	client := resty.New()
 	resp, err := client.R().
 		SetHeader("Content-Type", "application/json").
-		SetHeader("Authorization", "Bearer "+impl.cfg.AuthToken).
+		SetHeader("Authorization", "Bearer "+token).
 		Get(url)
 
 	if err != nil {
@@ -104,7 +104,7 @@ func (impl *RestClientImpl) DownloadMediaFromUrl(url, token, filePath, userEmail
 		stringReader := strings.NewReader(string(resp.Body()))
 		stringReadCloser := io.NopCloser(stringReader)
 
-		_ = impl.fileManager.SaveFileToPath(stringReadCloser, filePath, userEmail)
+		_ = impl.fileManager.SaveFileToPath(stringReadCloser, filePath, userEmail, util.WHATSAPP)
 	})
 
 }
@@ -128,7 +128,34 @@ func (impl *RestClientImpl) DownloadTelegramMediaFromUrl(url, filePath, userEmai
 		stringReader := strings.NewReader(string(resp.Body()))
 		stringReadCloser := io.NopCloser(stringReader)
 
-		_ = impl.fileManager.SaveFileToPath(stringReadCloser, filePath, userEmail)
+		_ = impl.fileManager.SaveFileToPath(stringReadCloser, filePath, userEmail, util.TELEGRAM)
 	})
 
 }
+
+func (impl *RestClientImpl) UploadWhatsappMedia(url, token, fileName, mimeType string, data []byte) (string, error) {
+	client := resty.New()
+	resp, err := client.R().
+		SetHeader("Authorization", "Bearer "+token).
+		SetFormData(map[string]string{"messaging_product": "whatsapp", "type": mimeType}).
+		SetMultipartField("file", fileName, mimeType, bytes.NewReader(data)).
+		Post(url)
+
+	if err != nil {
+		impl.logger.Errorw("Error in uploading whatsapp media", "Error", err)
+		return "", err
+	}
+
+	if resp.IsError() {
+		impl.logger.Errorw("Error in uploading whatsapp media. status not ok.", "Status", resp.StatusCode(), "Body", string(resp.Body()))
+		return "", errors.New(fmt.Sprintf("Status : %d", resp.StatusCode()))
+	}
+
+	var upload bean.WhatsAppBusinessUploadResponse
+	err = json.Unmarshal(resp.Body(), &upload)
+	if err != nil {
+		impl.logger.Errorw("Error unmarshalling response body", "Error", err)
+		return "", err
+	}
+	return upload.ID, nil
+}
@@ -0,0 +1,273 @@
+package services
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/go-pg/pg"
+	"github.com/iraunit/get-link-backend/pkg/cryptography"
+	"github.com/iraunit/get-link-backend/pkg/fileManager"
+	"github.com/iraunit/get-link-backend/pkg/messages"
+	"github.com/iraunit/get-link-backend/pkg/repository"
+	tokenService2 "github.com/iraunit/get-link-backend/pkg/services/tokenService"
+	"github.com/iraunit/get-link-backend/util"
+	"github.com/iraunit/get-link-backend/util/bean"
+	"go.uber.org/zap"
+	"io"
+	"net/url"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Channel is a messenger users link to their Get-Link account. The identity is the channel's own id for the
+// user: a Telegram user id, a WhatsApp number, or a Slack or Discord user id.
+type Channel interface {
+	Name() string
+	// Receive saves an inbound message for every account linked to its identity. It returns pg.ErrNoRows when none is.
+	Receive(message *bean.InboundMessage) error
+	VerifyIdentity(userEmail, identity string) error
+	SendText(identity, text string) error
+	// Render renders a message with the catalog of identity's tenant, in the language of the account linked to it.
+	Render(identity, key string, data messages.Data) string
+	SendFile(identity, fileName string, data []byte) error
+	GetLinkedAccounts(userEmail string) ([]bean.LinkedAccount, error)
+	UnlinkAccount(userEmail string, account bean.LinkedAccount) error
+}
+
+var setEmailRegex = regexp.MustCompile(`(?i)^set\s+email\s+([A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,})$`)
+
+// messengerInbox is the receive path shared by channels: linking identities, saving text as links and files into
+// the channel's folder, and answering the plain-text commands every channel understands.
+type messengerInbox struct {
+	channel        string
+	displayName    string
+	baseUrl        string
+	freeLimitMB    int
+	premiumLimitMB int
+	logger         *zap.SugaredLogger
+	repository     repository.Repository
+	linkService    LinkService
+	fileManager    fileManager.FileManager
+	tokenService   tokenService2.TokenService
+	mailService    MailService
+	notification   NotificationService
+	catalog        messages.Catalog
+}
+
+// reply handles a text or file message from identity and returns the answer to send back.
+func (inbox *messengerInbox) reply(channel Channel, message *bean.InboundMessage) string {
+	text := strings.TrimSpace(message.Text)
+	if len(message.Files) == 0 {
+		if match := setEmailRegex.FindStringSubmatch(text); match != nil {
+			return inbox.startVerification(message.Identity, match[1])
+		}
+		switch strings.ToLower(text) {
+		case "", "help", "start":
+			return inbox.render(message.Identity, "channel.help", nil)
+		case "list":
+			return inbox.listLinks(message.Identity)
+		}
+	}
+
+	err := channel.Receive(message)
+	if errors.Is(err, pg.ErrNoRows) {
+		return inbox.render(message.Identity, "channel.set_email_first", nil)
+	} else if err != nil {
+		inbox.logger.Errorw("Error in saving message", "Channel", inbox.channel, "Error", err)
+		return inbox.render(message.Identity, "channel.error", nil)
+	}
+	if len(message.Files) > 0 {
+		return inbox.render(message.Identity, "channel.files_saved", messages.Data{"Count": len(message.Files)})
+	}
+	return inbox.render(message.Identity, "channel.link_saved", nil)
+}
+
+// render executes key in the language picked by the first account linked to identity that has one.
+func (inbox *messengerInbox) render(identity, key string, data messages.Data) string {
+	locale := ""
+	if emails, err := inbox.getEmails(identity); err == nil {
+		for _, email := range emails {
+			if locale = inbox.notification.GetLocale(email); locale != "" {
+				break
+			}
+		}
+	}
+	return inbox.catalog.Render(locale, key, data)
+}
+
+func (inbox *messengerInbox) startVerification(identity, email string) string {
+	token, err := inbox.tokenService.ChannelVerificationToken(&bean.ChannelVerificationClaims{Email: email, Channel: inbox.channel, Identity: identity})
+	if err != nil {
+		inbox.logger.Errorw("Error in generating token", "Error", err)
+		return inbox.render(identity, "channel.token_failed", nil)
+	}
+	err = inbox.mailService.SendTemplateMail(email, util.MailTemplateVerification, bean.VerificationMailData{
+		Intro:      inbox.render(identity, "channel.verification_intro", messages.Data{"Channel": inbox.displayName}),
+		ActionUrl:  fmt.Sprintf("%s%s?token=%s", inbox.baseUrl, util.VerifyChannelEmail, url.QueryEscape(token)),
+		ActionText: inbox.render(identity, "channel.verification_action", nil),
+	})
+	if err != nil {
+		inbox.logger.Errorw("Error in sending mail", "Error", err)
+		return inbox.render(identity, "channel.mail_failed", nil)
+	}
+	return inbox.render(identity, "channel.verification_sent", nil)
+}
+
+func (inbox *messengerInbox) listLinks(identity string) string {
+	emails, err := inbox.getEmails(identity)
+	if err != nil {
+		return inbox.render(identity, "channel.set_email_first", nil)
+	}
+	var message strings.Builder
+	for _, email := range emails {
+		allLinks := inbox.linkService.GetAllLink(email, "")
+		if allLinks == nil {
+			continue
+		}
+		links := *allLinks
+		sort.Slice(links, func(i, j int) bool {
+			return links[i].ID > links[j].ID
+		})
+		for _, link := range links[:min(defaultTelegramListSize, len(links))] {
+			message.WriteString(fmt.Sprintf("#%d %s\n", link.ID, link.Message))
+		}
+	}
+	if message.Len() == 0 {
+		return inbox.render(identity, "no_links", nil)
+	}
+	return message.String()
+}
+
+// save stores the message's text as a link and its files in the channel folder of every email, returning the link ids.
+func (inbox *messengerInbox) save(emails []string, message *bean.InboundMessage) ([]int, error) {
+	var linkIDs []int
+	for _, email := range emails {
+		if text := strings.TrimSpace(message.Text); text != "" {
+			link := &bean.GetLink{Receiver: email, Sender: email, Message: text, UUID: inbox.channel}
+			inbox.linkService.AddLink(email, link)
+			if link.ID != 0 {
+				linkIDs = append(linkIDs, link.ID)
+			}
+		}
+		if len(message.Files) == 0 {
+			continue
+		}
+		folderPath, err := inbox.userFolder(email)
+		if err != nil {
+			return nil, err
+		}
+		for _, file := range message.Files {
+			err = inbox.fileManager.SaveFileToPath(io.NopCloser(bytes.NewReader(file.Data)), path.Join(folderPath, path.Base(file.Name)+".bin"), email, inbox.channel)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	return linkIDs, nil
+}
+
+// userFolder returns the channel folder for email after expiring old files and applying the storage limit.
+func (inbox *messengerInbox) userFolder(email string) (string, error) {
+	folderPath := inbox.fileManager.GetPathToSaveFileFromApp(util.EncodeString(email), inbox.channel)
+	inbox.fileManager.DeleteFileFromPathOlderThan24Hours(folderPath)
+	folderSize, err := inbox.fileManager.GetSizeOfADirectory(folderPath)
+	if err != nil {
+		inbox.logger.Errorw("Error in getting folder size", "Error", err)
+		return "", err
+	}
+	maxLimit := inbox.freeLimitMB
+	if inbox.repository.IsUserPremiumUser(email) {
+		maxLimit = inbox.premiumLimitMB
+	}
+	if folderSize > int64(maxLimit) {
+		inbox.fileManager.DeleteAllFileFromPath(folderPath)
+	}
+	return folderPath, nil
+}
+
+// link stores identity for userEmail in channel_identities, keyed by the identity and by the email.
+func (inbox *messengerInbox) link(userEmail, identity string) error {
+	encryptedIdentity, err := cryptography.EncryptData(identity, identity, inbox.logger)
+	if err != nil {
+		return err
+	}
+	identityEmail, err := cryptography.EncryptData(identity, userEmail, inbox.logger)
+	if err != nil {
+		return err
+	}
+	if err = inbox.repository.InsertChannelIdentity(&bean.ChannelIdentity{Channel: inbox.channel, Identity: encryptedIdentity, Email: identityEmail}); err != nil {
+		return err
+	}
+	encryptedEmail, err := cryptography.EncryptData(userEmail, userEmail, inbox.logger)
+	if err != nil {
+		return err
+	}
+	emailIdentity, err := cryptography.EncryptData(userEmail, identity, inbox.logger)
+	if err != nil {
+		return err
+	}
+	return inbox.repository.InsertChannelIdentity(&bean.ChannelIdentity{Channel: inbox.channel, Identity: encryptedEmail, Email: emailIdentity})
+}
+
+func (inbox *messengerInbox) unlink(userEmail, identity string) error {
+	encryptedIdentity, err := cryptography.EncryptData(identity, identity, inbox.logger)
+	if err != nil {
+		return err
+	}
+	identityEmail, err := cryptography.EncryptData(identity, userEmail, inbox.logger)
+	if err != nil {
+		return err
+	}
+	if err = inbox.repository.DeleteChannelIdentity(inbox.channel, encryptedIdentity, identityEmail); err != nil {
+		return err
+	}
+	encryptedEmail, err := cryptography.EncryptData(userEmail, userEmail, inbox.logger)
+	if err != nil {
+		return err
+	}
+	emailIdentity, err := cryptography.EncryptData(userEmail, identity, inbox.logger)
+	if err != nil {
+		return err
+	}
+	return inbox.repository.DeleteChannelIdentity(inbox.channel, encryptedEmail, emailIdentity)
+}
+
+// getEmails returns the emails linked to identity, or pg.ErrNoRows when there are none.
+func (inbox *messengerInbox) getEmails(identity string) ([]string, error) {
+	return inbox.lookup(identity)
+}
+
+func (inbox *messengerInbox) getIdentities(userEmail string) ([]string, error) {
+	identities, err := inbox.lookup(userEmail)
+	if errors.Is(err, pg.ErrNoRows) {
+		return nil, nil
+	}
+	return identities, err
+}
+
+// lookup decrypts the rows keyed by key, which is either an identity or an email.
+func (inbox *messengerInbox) lookup(key string) ([]string, error) {
+	encryptedKey, err := cryptography.EncryptData(key, key, inbox.logger)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := inbox.repository.GetChannelIdentities(inbox.channel, encryptedKey)
+	if err != nil {
+		return nil, err
+	}
+	var values []string
+	for _, row := range rows {
+		value, err := cryptography.DecryptData(key, row.Email, inbox.logger)
+		if err != nil {
+			inbox.logger.Errorw("Error in decrypting data", "Error", err)
+			continue
+		}
+		values = append(values, value)
+	}
+	if len(values) == 0 {
+		return nil, pg.ErrNoRows
+	}
+	return values, nil
+}
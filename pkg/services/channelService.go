@@ -0,0 +1,94 @@
+package services
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"github.com/go-pg/pg"
+	"github.com/iraunit/get-link-backend/pkg/messages"
+	"github.com/iraunit/get-link-backend/util/bean"
+	"go.uber.org/zap"
+)
+
+type ChannelService interface {
+	GetLinkedAccounts(userEmail string) ([]bean.LinkedAccount, error)
+	UnlinkAccount(userEmail string, id string) error
+	VerifyIdentity(claims *bean.ChannelVerificationClaims) error
+}
+
+type ChannelServiceImpl struct {
+	logger   *zap.SugaredLogger
+	channels []Channel
+}
+
+func NewChannelServiceImpl(logger *zap.SugaredLogger, telegramService TelegramService, whatsappService WhatsappService, slackService SlackService, discordService DiscordService, smsService SmsService) *ChannelServiceImpl {
+	return &ChannelServiceImpl{
+		logger:   logger,
+		channels: []Channel{telegramService, whatsappService, slackService, discordService, smsService},
+	}
+}
+
+func (impl *ChannelServiceImpl) GetLinkedAccounts(userEmail string) ([]bean.LinkedAccount, error) {
+	var accounts []bean.LinkedAccount
+	for _, channel := range impl.channels {
+		channelAccounts, err := channel.GetLinkedAccounts(userEmail)
+		if err != nil {
+			impl.logger.Errorw("Error in getting linked accounts", "Channel", channel.Name(), "Error", err)
+			return nil, err
+		}
+		accounts = append(accounts, channelAccounts...)
+	}
+	for i := range accounts {
+		accounts[i].ID = channelAccountID(accounts[i])
+	}
+	return accounts, nil
+}
+
+func (impl *ChannelServiceImpl) UnlinkAccount(userEmail string, id string) error {
+	accounts, err := impl.GetLinkedAccounts(userEmail)
+	if err != nil {
+		return err
+	}
+	for _, account := range accounts {
+		if account.ID != id {
+			continue
+		}
+		channel, err := impl.getChannel(account.Channel)
+		if err != nil {
+			return err
+		}
+		if err = channel.UnlinkAccount(userEmail, account); err != nil {
+			impl.logger.Errorw("Error in unlinking account", "Channel", account.Channel, "Error", err)
+		}
+		return err
+	}
+	return pg.ErrNoRows
+}
+
+// VerifyIdentity completes the email verification started from a channel's 'set email' message.
+func (impl *ChannelServiceImpl) VerifyIdentity(claims *bean.ChannelVerificationClaims) error {
+	channel, err := impl.getChannel(claims.Channel)
+	if err != nil {
+		return err
+	}
+	if err = channel.VerifyIdentity(claims.Email, claims.Identity); err != nil {
+		impl.logger.Errorw("Error in verifying channel identity", "Channel", claims.Channel, "Error", err)
+		return err
+	}
+	return channel.SendText(claims.Identity, channel.Render(claims.Identity, "channel.connected", messages.Data{"Email": claims.Email}))
+}
+
+func (impl *ChannelServiceImpl) getChannel(name string) (Channel, error) {
+	for _, channel := range impl.channels {
+		if channel.Name() == name {
+			return channel, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown channel %s", name)
+}
+
+// channelAccountID derives a stable id so the raw chat id or number never reaches the client.
+func channelAccountID(account bean.LinkedAccount) string {
+	sum := sha256.Sum256([]byte(account.Channel + ":" + account.Address))
+	return hex.EncodeToString(sum[:8])
+}
@@ -0,0 +1,144 @@
+package services
+
+import (
+	"encoding/json"
+	"github.com/iraunit/get-link-backend/pkg/fileManager"
+	"github.com/iraunit/get-link-backend/pkg/messages"
+	"github.com/iraunit/get-link-backend/pkg/repository"
+	"github.com/iraunit/get-link-backend/util/bean"
+	"go.uber.org/zap"
+	"io"
+	"testing"
+)
+
+// fakeRepository keeps channel identities in memory. Methods the channels do not use panic through the nil interface.
+type fakeRepository struct {
+	repository.Repository
+	identities []bean.ChannelIdentity
+	premium    map[string]bool
+}
+
+func (repo *fakeRepository) InsertChannelIdentity(identity *bean.ChannelIdentity) error {
+	repo.identities = append(repo.identities, *identity)
+	return nil
+}
+
+func (repo *fakeRepository) GetChannelIdentities(channel, identity string) ([]bean.ChannelIdentity, error) {
+	var rows []bean.ChannelIdentity
+	for _, row := range repo.identities {
+		if row.Channel == channel && row.Identity == identity {
+			rows = append(rows, row)
+		}
+	}
+	return rows, nil
+}
+
+func (repo *fakeRepository) IsUserPremiumUser(userEmail string) bool {
+	return repo.premium[userEmail]
+}
+
+type fakeLinkService struct {
+	LinkService
+	links []*bean.GetLink
+}
+
+func (service *fakeLinkService) AddLink(userEmail string, data *bean.GetLink) {
+	data.ID = len(service.links) + 1
+	service.links = append(service.links, data)
+}
+
+type savedFile struct {
+	path, email string
+	data        []byte
+}
+
+type fakeFileManager struct {
+	fileManager.FileManager
+	folderSizeMB int64
+	cleared      []string
+	files        []savedFile
+}
+
+func (manager *fakeFileManager) GetPathToSaveFileFromApp(userEmail, appName string) string {
+	return "/tmp/data/" + userEmail + "/" + appName
+}
+
+func (manager *fakeFileManager) DeleteFileFromPathOlderThan24Hours(path string) {}
+
+func (manager *fakeFileManager) GetSizeOfADirectory(path string) (int64, error) {
+	return manager.folderSizeMB, nil
+}
+
+func (manager *fakeFileManager) DeleteAllFileFromPath(path string) {
+	manager.cleared = append(manager.cleared, path)
+}
+
+func (manager *fakeFileManager) SaveFileToPath(data io.ReadCloser, path, userEmail, sourceUUID string) error {
+	bytes, err := io.ReadAll(data)
+	if err != nil {
+		return err
+	}
+	manager.files = append(manager.files, savedFile{path: path, email: userEmail, data: bytes})
+	return nil
+}
+
+type fakeNotification struct {
+	NotificationService
+}
+
+func (notification *fakeNotification) GetLocale(userEmail string) string {
+	return ""
+}
+
+type outboxEntry struct {
+	channel, recipient string
+	payload            []byte
+}
+
+type fakeOutbox struct {
+	OutboxService
+	senders  map[string]OutboxSender
+	messages []outboxEntry
+}
+
+func (outbox *fakeOutbox) RegisterSender(channel string, sender OutboxSender) {
+	if outbox.senders == nil {
+		outbox.senders = map[string]OutboxSender{}
+	}
+	outbox.senders[channel] = sender
+}
+
+func (outbox *fakeOutbox) Enqueue(channel, recipient string, payload interface{}) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	outbox.messages = append(outbox.messages, outboxEntry{channel: channel, recipient: recipient, payload: data})
+	return nil
+}
+
+// flush delivers the queued messages with the registered senders, like the outbox workers do.
+func (outbox *fakeOutbox) flush(t *testing.T) {
+	t.Helper()
+	for _, message := range outbox.messages {
+		if err := outbox.senders[message.channel](message.recipient, message.payload); err != nil {
+			t.Fatalf("sending %s message to %s: %v", message.channel, message.recipient, err)
+		}
+	}
+	outbox.messages = nil
+}
+
+func newTestInbox(channel string) *messengerInbox {
+	return &messengerInbox{
+		channel:        channel,
+		displayName:    channel,
+		freeLimitMB:    100,
+		premiumLimitMB: 500,
+		logger:         zap.NewNop().Sugar(),
+		repository:     &fakeRepository{},
+		linkService:    &fakeLinkService{},
+		fileManager:    &fakeFileManager{},
+		notification:   &fakeNotification{},
+		catalog:        messages.NewCatalogImpl(zap.NewNop().Sugar()),
+	}
+}
@@ -0,0 +1,299 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/caarlos0/env"
+	"github.com/iraunit/get-link-backend/pkg/cryptography"
+	"github.com/iraunit/get-link-backend/pkg/fileManager"
+	"github.com/iraunit/get-link-backend/pkg/repository"
+	tokenService2 "github.com/iraunit/get-link-backend/pkg/services/tokenService"
+	"github.com/iraunit/get-link-backend/util"
+	"github.com/iraunit/get-link-backend/util/bean"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+	"net/url"
+	"time"
+	_ "time/tzdata"
+)
+
+const digestLockKey = "digest:lock"
+
+type DigestService interface {
+	GetSubscription(userEmail string) (*bean.DigestSubscription, error)
+	Subscribe(userEmail string, subscription *bean.DigestSubscription) error
+	Unsubscribe(userEmail string) error
+	MarkAllRead(userEmail string) error
+	SendDueDigests()
+}
+
+type DigestServiceImpl struct {
+	logger       *zap.SugaredLogger
+	cfg          bean.DigestCfg
+	client       *redis.Client
+	repository   repository.Repository
+	mailService  MailService
+	tokenService tokenService2.TokenService
+	fileManager  fileManager.FileManager
+}
+
+func NewDigestServiceImpl(logger *zap.SugaredLogger, async *util.Async, client *redis.Client, repository repository.Repository, mailService MailService, tokenService tokenService2.TokenService, fileManager fileManager.FileManager) *DigestServiceImpl {
+	cfg := bean.DigestCfg{}
+	if err := env.Parse(&cfg); err != nil {
+		logger.Fatal("Error loading Cfg from env", "Error", zap.Error(err))
+	}
+	if cfg.EncryptionKey == "" {
+		logger.Fatal("ENCRYPTION_KEY is required to encrypt digest subscriptions")
+	}
+	impl := &DigestServiceImpl{
+		logger:       logger,
+		cfg:          cfg,
+		client:       client,
+		repository:   repository,
+		mailService:  mailService,
+		tokenService: tokenService,
+		fileManager:  fileManager,
+	}
+
+	async.Run(func() {
+		ticker := time.NewTicker(cfg.Interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			impl.SendDueDigests()
+		}
+	})
+	return impl
+}
+
+// subscriptions are keyed by the email encrypted with ENCRYPTION_KEY, so the scheduler can decrypt it to send digests.
+func (impl *DigestServiceImpl) encryptEmail(userEmail string) (string, error) {
+	encryptedEmail, err := cryptography.EncryptData(impl.cfg.EncryptionKey, userEmail, impl.logger)
+	if err != nil {
+		impl.logger.Errorw("Error in encrypting data", "Error: ", err)
+	}
+	return encryptedEmail, err
+}
+
+func (impl *DigestServiceImpl) GetSubscription(userEmail string) (*bean.DigestSubscription, error) {
+	encryptedEmail, err := impl.encryptEmail(userEmail)
+	if err != nil {
+		return nil, err
+	}
+	return impl.repository.GetDigestSubscription(encryptedEmail)
+}
+
+func (impl *DigestServiceImpl) Subscribe(userEmail string, subscription *bean.DigestSubscription) error {
+	if subscription.Frequency != util.DigestDaily && subscription.Frequency != util.DigestWeekly {
+		return fmt.Errorf("frequency must be %s or %s", util.DigestDaily, util.DigestWeekly)
+	}
+	if subscription.Hour < 0 || subscription.Hour > 23 {
+		return errors.New("hour must be between 0 and 23")
+	}
+	if subscription.Weekday < 0 || subscription.Weekday > 6 {
+		return errors.New("weekday must be between 0 (Sunday) and 6 (Saturday)")
+	}
+	if subscription.Timezone == "" {
+		subscription.Timezone = "UTC"
+	}
+	if _, err := time.LoadLocation(subscription.Timezone); err != nil {
+		return fmt.Errorf("unknown timezone %s", subscription.Timezone)
+	}
+	encryptedEmail, err := impl.encryptEmail(userEmail)
+	if err != nil {
+		return err
+	}
+	subscription.Email = encryptedEmail
+	return impl.repository.InsertUpdateDigestSubscription(subscription)
+}
+
+func (impl *DigestServiceImpl) Unsubscribe(userEmail string) error {
+	encryptedEmail, err := impl.encryptEmail(userEmail)
+	if err != nil {
+		return err
+	}
+	return impl.repository.DeleteDigestSubscription(encryptedEmail)
+}
+
+func (impl *DigestServiceImpl) MarkAllRead(userEmail string) error {
+	encryptedEmail, err := impl.encryptEmail(userEmail)
+	if err != nil {
+		return err
+	}
+	return impl.repository.UpdateDigestSubscriptionTime(encryptedEmail, "read_until", time.Now())
+}
+
+func (impl *DigestServiceImpl) SendDueDigests() {
+	ctx := context.Background()
+	acquired, err := impl.client.SetNX(ctx, digestLockKey, "1", impl.cfg.Interval/2).Result()
+	if err != nil || !acquired {
+		return
+	}
+
+	subscriptions, err := impl.repository.GetAllDigestSubscriptions()
+	if err != nil {
+		return
+	}
+
+	now := time.Now()
+	for i := range subscriptions {
+		subscription := &subscriptions[i]
+		scheduled := lastScheduledDigest(subscription, now)
+		if !subscription.LastSentAt.Before(scheduled) || !subscription.CreatedAt.Before(scheduled) {
+			continue
+		}
+
+		since := subscription.LastSentAt
+		if since.IsZero() {
+			since = previousDigestPeriod(subscription, scheduled)
+		}
+		if subscription.ReadUntil.After(since) {
+			since = subscription.ReadUntil
+		}
+
+		userEmail, err := cryptography.DecryptData(impl.cfg.EncryptionKey, subscription.Email, impl.logger)
+		if err != nil {
+			impl.logger.Errorw("Error in decrypting digest subscription", "Error", err)
+			continue
+		}
+		err = impl.sendDigest(userEmail, subscription.Frequency, since)
+		if err != nil {
+			impl.logger.Errorw("Error in sending digest", "Error", err)
+			continue
+		}
+		_ = impl.repository.UpdateDigestSubscriptionTime(subscription.Email, "last_sent_at", now)
+	}
+}
+
+func (impl *DigestServiceImpl) sendDigest(userEmail, frequency string, since time.Time) error {
+	sections, err := impl.buildDigest(userEmail, since)
+	if err != nil {
+		return err
+	}
+	if len(sections) == 0 {
+		return nil
+	}
+
+	unsubscribeUrl, err := impl.actionUrl(userEmail, util.DigestActionUnsubscribe, util.DigestUnsubscribe)
+	if err != nil {
+		return err
+	}
+	markReadUrl, err := impl.actionUrl(userEmail, util.DigestActionMarkRead, util.DigestMarkRead)
+	if err != nil {
+		return err
+	}
+
+	data := bean.DigestMailData{
+		Frequency:      frequency,
+		Since:          since.UTC().Format(time.RFC1123),
+		MarkReadUrl:    markReadUrl,
+		UnsubscribeUrl: unsubscribeUrl,
+	}
+	for _, section := range sections {
+		mailSection := bean.DigestMailSection{Title: digestSourceTitle(section.Source)}
+		for _, link := range section.Links {
+			mailSection.Links = append(mailSection.Links, link.Message)
+		}
+		for _, file := range section.Files {
+			mailSection.Files = append(mailSection.Files, bean.DigestMailFile{Name: file.Name, SizeKB: file.Size / 1000, Url: impl.cfg.BaseUrl + file.ShareableLink})
+		}
+		data.Sections = append(data.Sections, mailSection)
+	}
+
+	return impl.mailService.SendListTemplateMail(userEmail, util.MailTemplateDigest, data, unsubscribeUrl)
+}
+
+func (impl *DigestServiceImpl) buildDigest(userEmail string, since time.Time) ([]bean.DigestSection, error) {
+	var sections []bean.DigestSection
+	index := make(map[string]int)
+	section := func(source string) *bean.DigestSection {
+		if i, ok := index[source]; ok {
+			return &sections[i]
+		}
+		index[source] = len(sections)
+		sections = append(sections, bean.DigestSection{Source: source})
+		return &sections[len(sections)-1]
+	}
+
+	receiver, err := cryptography.EncryptData(userEmail, userEmail, impl.logger)
+	if err != nil {
+		impl.logger.Errorw("Error in encrypting data", "Error: ", err)
+		return nil, err
+	}
+	links, err := impl.repository.GetLinksSince(receiver, since)
+	if err != nil {
+		return nil, err
+	}
+	for _, link := range links {
+		link.Message, err = cryptography.DecryptData(userEmail, link.Message, impl.logger)
+		if err != nil {
+			impl.logger.Errorw("Error in decrypting data", "Error: ", err)
+			continue
+		}
+		s := section(link.UUID)
+		s.Links = append(s.Links, link)
+	}
+
+	for _, appName := range storedFileApps {
+		files, err := impl.fileManager.ListAllFilesFromApp(userEmail, appName)
+		if err != nil {
+			continue
+		}
+		for _, file := range files {
+			if file.ModTime.After(since) {
+				s := section(appName)
+				s.Files = append(s.Files, file)
+			}
+		}
+	}
+	return sections, nil
+}
+
+func (impl *DigestServiceImpl) actionUrl(userEmail, action, route string) (string, error) {
+	token, err := impl.tokenService.DigestActionToken(&bean.DigestClaims{Email: userEmail, Action: action})
+	if err != nil {
+		impl.logger.Errorw("Error in generating token", "Error", err)
+		return "", err
+	}
+	return fmt.Sprintf("%s%s?token=%s", impl.cfg.BaseUrl, route, url.QueryEscape(token)), nil
+}
+
+func lastScheduledDigest(subscription *bean.DigestSubscription, now time.Time) time.Time {
+	loc, err := time.LoadLocation(subscription.Timezone)
+	if err != nil {
+		loc = time.UTC
+	}
+	local := now.In(loc)
+	scheduled := time.Date(local.Year(), local.Month(), local.Day(), subscription.Hour, 0, 0, 0, loc)
+	if subscription.Frequency == util.DigestWeekly {
+		scheduled = scheduled.AddDate(0, 0, -((int(local.Weekday()) - subscription.Weekday + 7) % 7))
+		if scheduled.After(local) {
+			scheduled = scheduled.AddDate(0, 0, -7)
+		}
+	} else if scheduled.After(local) {
+		scheduled = scheduled.AddDate(0, 0, -1)
+	}
+	return scheduled
+}
+
+func previousDigestPeriod(subscription *bean.DigestSubscription, scheduled time.Time) time.Time {
+	if subscription.Frequency == util.DigestWeekly {
+		return scheduled.AddDate(0, 0, -7)
+	}
+	return scheduled.AddDate(0, 0, -1)
+}
+
+func digestSourceTitle(source string) string {
+	switch source {
+	case util.WHATSAPP:
+		return "WhatsApp"
+	case util.TELEGRAM:
+		return "Telegram"
+	case util.EMAIL:
+		return "Email"
+	case util.GETLINK:
+		return "Files from your devices"
+	default:
+		return fmt.Sprintf("Device %s", source)
+	}
+}
@@ -0,0 +1,280 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/caarlos0/env"
+	"github.com/go-resty/resty/v2"
+	"github.com/iraunit/get-link-backend/pkg/fileManager"
+	"github.com/iraunit/get-link-backend/pkg/messages"
+	"github.com/iraunit/get-link-backend/pkg/repository"
+	tokenService2 "github.com/iraunit/get-link-backend/pkg/services/tokenService"
+	"github.com/iraunit/get-link-backend/util"
+	"github.com/iraunit/get-link-backend/util/bean"
+	"go.uber.org/zap"
+	"path"
+)
+
+const (
+	discordInteractionPing    = 1
+	discordInteractionCommand = 2
+	discordResponsePong       = 1
+	discordResponseMessage    = 4
+	discordFlagEphemeral      = 64
+	discordOptionString       = 3
+	discordOptionAttachment   = 11
+	discordCommandName        = "getlink"
+	discordMaxMessageLength   = 2000
+	discordMaxInboundBytes    = 25 << 20
+)
+
+// discordCommand is registered with the description rendered from discord.command.description.
+var discordCommand = bean.DiscordApplicationCommand{
+	Name: discordCommandName,
+	Options: []bean.DiscordCommandOptionDef{
+		{Type: discordOptionString, Name: "text", Description: "A link or note to save, or 'set email you@example.com', 'list', 'help'"},
+		{Type: discordOptionAttachment, Name: "file", Description: "A file to save"},
+	},
+}
+
+type DiscordService interface {
+	Channel
+	HandleInteraction(interaction *bean.DiscordInteraction) *bean.DiscordInteractionResponse
+}
+
+type DiscordServiceImpl struct {
+	logger *zap.SugaredLogger
+	cfg    bean.DiscordCfg
+	async  *util.Async
+	client *resty.Client
+	outbox OutboxService
+	inbox  *messengerInbox
+}
+
+func NewDiscordServiceImpl(logger *zap.SugaredLogger, async *util.Async, repository repository.Repository, linkService LinkService, fileManager fileManager.FileManager, tokenService tokenService2.TokenService, mailService MailService, outbox OutboxService, notificationService NotificationService, catalog messages.Catalog, tenantService TenantService) *DiscordServiceImpl {
+	cfg := bean.DiscordCfg{}
+	if err := env.Parse(&cfg); err != nil {
+		logger.Fatal("Error loading Cfg from env", "Error", zap.Error(err))
+	}
+	impl := &DiscordServiceImpl{
+		logger: logger,
+		cfg:    cfg,
+		async:  async,
+		client: resty.New().SetBaseURL(cfg.ApiUrl).SetHeader("Authorization", "Bot "+cfg.BotToken),
+		outbox: outbox,
+		inbox: &messengerInbox{
+			channel:        util.DISCORD,
+			displayName:    "Discord",
+			baseUrl:        cfg.BaseUrl,
+			freeLimitMB:    util.FreeChannelFileLimitSizeMB,
+			premiumLimitMB: util.PremiumChannelFileLimitSizeMB,
+			logger:         logger,
+			repository:     repository,
+			linkService:    linkService,
+			fileManager:    fileManager,
+			tokenService:   tokenService,
+			mailService:    mailService,
+			notification:   notificationService,
+			catalog:        catalog.ForTenant(tenantService.Default()),
+		},
+	}
+	outbox.RegisterSender(util.DISCORD, impl.sendOutboxMessage)
+	if cfg.BotToken != "" && cfg.ApplicationID != "" {
+		async.Run(impl.registerCommands)
+	}
+	return impl
+}
+
+func (impl *DiscordServiceImpl) Name() string {
+	return util.DISCORD
+}
+
+func (impl *DiscordServiceImpl) registerCommands() {
+	command := discordCommand
+	command.Description = impl.inbox.catalog.Render("", "discord.command.description", nil)
+	resp, err := impl.client.R().SetBody([]bean.DiscordApplicationCommand{command}).
+		Put(fmt.Sprintf("/applications/%s/commands", impl.cfg.ApplicationID))
+	if err != nil || resp.IsError() {
+		impl.logger.Errorw("error in registering discord commands", "error", err)
+	}
+}
+
+// HandleInteraction answers the /getlink command. Attachments are saved async, since Discord expects a reply within 3 seconds.
+func (impl *DiscordServiceImpl) HandleInteraction(interaction *bean.DiscordInteraction) *bean.DiscordInteractionResponse {
+	if interaction.Type == discordInteractionPing {
+		return &bean.DiscordInteractionResponse{Type: discordResponsePong}
+	}
+	if interaction.Type != discordInteractionCommand || interaction.Data.Name != discordCommandName {
+		return discordReply(impl.inbox.catalog.Render("", "discord.unknown_command", nil))
+	}
+	user := interaction.User
+	if interaction.Member != nil {
+		user = &interaction.Member.User
+	}
+	if user == nil {
+		return discordReply(impl.inbox.catalog.Render("", "discord.unknown_user", nil))
+	}
+
+	message := &bean.InboundMessage{Identity: user.ID, Name: user.Username}
+	var attachment *bean.DiscordAttachment
+	for _, option := range interaction.Data.Options {
+		switch option.Name {
+		case "text":
+			message.Text = option.Value
+		case "file":
+			if resolved, ok := interaction.Data.Resolved.Attachments[option.Value]; ok {
+				attachment = &resolved
+			}
+		}
+	}
+	if attachment == nil {
+		return discordReply(impl.inbox.reply(impl, message))
+	}
+
+	impl.async.Run(func() {
+		data, err := impl.downloadAttachment(attachment)
+		if err != nil {
+			impl.logger.Errorw("Error in downloading discord attachment", "Attachment", attachment.ID, "Error", err)
+			_ = impl.SendText(user.ID, impl.inbox.render(user.ID, "channel.download_failed", messages.Data{"FileName": attachment.Filename}))
+			return
+		}
+		message.Files = []bean.InboundFile{{Name: attachment.Filename, Data: data}}
+		_ = impl.SendText(user.ID, impl.inbox.reply(impl, message))
+	})
+	return discordReply(impl.inbox.render(user.ID, "discord.saving", messages.Data{"FileName": attachment.Filename}))
+}
+
+func (impl *DiscordServiceImpl) Receive(message *bean.InboundMessage) error {
+	emails, err := impl.inbox.getEmails(message.Identity)
+	if err != nil {
+		return err
+	}
+	_, err = impl.inbox.save(emails, message)
+	return err
+}
+
+func (impl *DiscordServiceImpl) VerifyIdentity(userEmail, identity string) error {
+	return impl.inbox.link(userEmail, identity)
+}
+
+func (impl *DiscordServiceImpl) SendText(identity, text string) error {
+	return impl.outbox.Enqueue(util.DISCORD, identity, bean.OutboxTextMessage{Text: text})
+}
+
+func (impl *DiscordServiceImpl) Render(identity, key string, data messages.Data) string {
+	return impl.inbox.render(identity, key, data)
+}
+
+func (impl *DiscordServiceImpl) sendOutboxMessage(recipient string, payload []byte) error {
+	var message bean.OutboxTextMessage
+	if err := json.Unmarshal(payload, &message); err != nil {
+		return err
+	}
+	channelId, err := impl.dmChannel(recipient)
+	if err != nil {
+		return err
+	}
+	resp, err := impl.client.R().SetBody(bean.DiscordMessage{Content: truncateDiscordMessage(message.Text)}).
+		Post(fmt.Sprintf("/channels/%s/messages", channelId))
+	if err != nil {
+		return err
+	}
+	if resp.IsError() {
+		return fmt.Errorf("status : %d", resp.StatusCode())
+	}
+	return nil
+}
+
+func (impl *DiscordServiceImpl) SendFile(identity, fileName string, data []byte) error {
+	_, mimeType, err := resolveOutgoingMedia(discordMediaRules, fileName, data)
+	if err != nil {
+		return err
+	}
+	channelId, err := impl.dmChannel(identity)
+	if err != nil {
+		return fmt.Errorf("error in opening Discord conversation. Please try again later")
+	}
+	resp, err := impl.client.R().
+		SetMultipartFormData(map[string]string{"payload_json": "{}"}).
+		SetMultipartField("files[0]", path.Base(fileName), mimeType, bytes.NewReader(data)).
+		Post(fmt.Sprintf("/channels/%s/messages", channelId))
+	if err != nil || resp.IsError() {
+		impl.logger.Errorw("Error in sending discord file", "Error", err)
+		return fmt.Errorf("error in sending file to Discord. Please try again later")
+	}
+	return nil
+}
+
+func (impl *DiscordServiceImpl) GetLinkedAccounts(userEmail string) ([]bean.LinkedAccount, error) {
+	identities, err := impl.inbox.getIdentities(userEmail)
+	if err != nil {
+		return nil, err
+	}
+	var accounts []bean.LinkedAccount
+	for _, identity := range identities {
+		account := bean.LinkedAccount{Channel: util.DISCORD, Identity: util.MaskIdentity(identity), Address: identity}
+		var user bean.DiscordUser
+		if resp, err := impl.client.R().SetResult(&user).Get("/users/" + identity); err == nil && !resp.IsError() {
+			account.Name = user.GlobalName
+			if account.Name == "" {
+				account.Name = user.Username
+			}
+		}
+		accounts = append(accounts, account)
+	}
+	return accounts, nil
+}
+
+func (impl *DiscordServiceImpl) UnlinkAccount(userEmail string, account bean.LinkedAccount) error {
+	if err := impl.inbox.unlink(userEmail, account.Address); err != nil {
+		return err
+	}
+	return impl.SendText(account.Address, impl.inbox.catalog.Render(impl.inbox.notification.GetLocale(userEmail), "discord.unlinked_from_web", messages.Data{"Email": userEmail}))
+}
+
+// dmChannel opens, or returns the existing, direct message channel with a user.
+func (impl *DiscordServiceImpl) dmChannel(userId string) (string, error) {
+	var channel bean.DiscordChannel
+	resp, err := impl.client.R().SetBody(map[string]string{"recipient_id": userId}).SetResult(&channel).Post("/users/@me/channels")
+	if err != nil {
+		impl.logger.Errorw("Error in opening discord dm channel", "Error", err)
+		return "", err
+	}
+	if resp.IsError() {
+		impl.logger.Errorw("Error in opening discord dm channel. status not ok.", "Status", resp.StatusCode())
+		return "", fmt.Errorf("status : %d", resp.StatusCode())
+	}
+	return channel.ID, nil
+}
+
+func (impl *DiscordServiceImpl) downloadAttachment(attachment *bean.DiscordAttachment) ([]byte, error) {
+	if attachment.Size > discordMaxInboundBytes {
+		return nil, fmt.Errorf("%s is too large", attachment.Filename)
+	}
+	// attachment urls are signed CDN links and must not carry the bot token
+	resp, err := resty.New().R().Get(attachment.URL)
+	if err != nil {
+		return nil, err
+	}
+	if resp.IsError() {
+		return nil, fmt.Errorf("status : %d", resp.StatusCode())
+	}
+	return resp.Body(), nil
+}
+
+func discordReply(content string) *bean.DiscordInteractionResponse {
+	return &bean.DiscordInteractionResponse{
+		Type: discordResponseMessage,
+		Data: &bean.DiscordMessage{Content: truncateDiscordMessage(content), Flags: discordFlagEphemeral},
+	}
+}
+
+// truncateDiscordMessage cuts with "..." at Discord's limit. Replies like the link list are multi-line.
+func truncateDiscordMessage(message string) string {
+	runes := []rune(message)
+	if len(runes) <= discordMaxMessageLength {
+		return message
+	}
+	return string(runes[:discordMaxMessageLength-3]) + "..."
+}
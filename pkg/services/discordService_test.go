@@ -0,0 +1,155 @@
+package services
+
+import (
+	"encoding/json"
+	"github.com/go-resty/resty/v2"
+	"github.com/iraunit/get-link-backend/util"
+	"github.com/iraunit/get-link-backend/util/bean"
+	"go.uber.org/zap"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func newTestDiscordService(server *httptest.Server) (*DiscordServiceImpl, *fakeOutbox) {
+	outbox := &fakeOutbox{}
+	impl := &DiscordServiceImpl{
+		logger: zap.NewNop().Sugar(),
+		client: resty.New().SetBaseURL(server.URL).SetHeader("Authorization", "Bot test-token"),
+		outbox: outbox,
+		inbox:  newTestInbox(util.DISCORD),
+	}
+	outbox.RegisterSender(util.DISCORD, impl.sendOutboxMessage)
+	return impl, outbox
+}
+
+// discordApi fakes the dm channel and message endpoints, calling onMessage with each message request.
+func discordApi(t *testing.T, onMessage func(r *http.Request)) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.Header.Get("Authorization") != "Bot test-token" {
+			t.Errorf("unexpected authorization %q", r.Header.Get("Authorization"))
+		}
+		switch r.URL.Path {
+		case "/users/@me/channels":
+			var body map[string]string
+			_ = json.NewDecoder(r.Body).Decode(&body)
+			if body["recipient_id"] != "123" {
+				t.Errorf("unexpected recipient %v", body)
+			}
+			_, _ = w.Write([]byte(`{"id":"D123"}`))
+		case "/channels/D123/messages":
+			onMessage(r)
+			_, _ = w.Write([]byte(`{}`))
+		default:
+			t.Errorf("unexpected request %s", r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+}
+
+func TestDiscordPing(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	defer server.Close()
+	impl, _ := newTestDiscordService(server)
+
+	response := impl.HandleInteraction(&bean.DiscordInteraction{Type: discordInteractionPing})
+	if response.Type != discordResponsePong {
+		t.Fatalf("expected pong, got %+v", response)
+	}
+}
+
+func TestDiscordInteractionSavesText(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	defer server.Close()
+	impl, _ := newTestDiscordService(server)
+	if err := impl.VerifyIdentity("someone@example.com", "123"); err != nil {
+		t.Fatal(err)
+	}
+
+	response := impl.HandleInteraction(&bean.DiscordInteraction{
+		Type:   discordInteractionCommand,
+		Member: &bean.DiscordMember{User: bean.DiscordUser{ID: "123", Username: "user"}},
+		Data: bean.DiscordInteractionData{
+			Name:    discordCommandName,
+			Options: []bean.DiscordCommandOption{{Name: "text", Type: discordOptionString, Value: "https://example.com"}},
+		},
+	})
+	if response.Type != discordResponseMessage || response.Data.Flags != discordFlagEphemeral || response.Data.Content != "Message sent to Get-Link." {
+		t.Fatalf("expected an ephemeral reply, got %+v", response)
+	}
+	links := impl.inbox.linkService.(*fakeLinkService).links
+	if len(links) != 1 || links[0].Receiver != "someone@example.com" || links[0].Message != "https://example.com" || links[0].UUID != util.DISCORD {
+		t.Fatalf("unexpected links %+v", links)
+	}
+}
+
+func TestDiscordReceiveSavesFiles(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	defer server.Close()
+	impl, _ := newTestDiscordService(server)
+	if err := impl.VerifyIdentity("someone@example.com", "123"); err != nil {
+		t.Fatal(err)
+	}
+
+	err := impl.Receive(&bean.InboundMessage{Identity: "123", Files: []bean.InboundFile{{Name: "photo.png", Data: []byte("png")}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := impl.inbox.fileManager.(*fakeFileManager).files
+	if len(files) != 1 || files[0].email != "someone@example.com" || files[0].path != "/tmp/data/"+util.EncodeString("someone@example.com")+"/discord/photo.png.bin" {
+		t.Fatalf("unexpected files %+v", files)
+	}
+}
+
+func TestDiscordSendText(t *testing.T) {
+	var message bean.DiscordMessage
+	server := discordApi(t, func(r *http.Request) {
+		_ = json.NewDecoder(r.Body).Decode(&message)
+	})
+	defer server.Close()
+	impl, outbox := newTestDiscordService(server)
+
+	if err := impl.SendText("123", "hello"); err != nil {
+		t.Fatal(err)
+	}
+	outbox.flush(t)
+	if message.Content != "hello" {
+		t.Fatalf("unexpected message %+v", message)
+	}
+}
+
+func TestDiscordSendFile(t *testing.T) {
+	var fileName string
+	var data []byte
+	server := discordApi(t, func(r *http.Request) {
+		file, header, err := r.FormFile("files[0]")
+		if err != nil {
+			t.Errorf("reading file: %v", err)
+			return
+		}
+		defer file.Close()
+		fileName = header.Filename
+		data, _ = io.ReadAll(file)
+	})
+	defer server.Close()
+	impl, _ := newTestDiscordService(server)
+
+	if err := impl.SendFile("123", "folder/notes.txt", []byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+	if fileName != "notes.txt" || string(data) != "hello" {
+		t.Fatalf("unexpected upload %s %q", fileName, data)
+	}
+}
+
+func TestDiscordSendFileTooLarge(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	defer server.Close()
+	impl, _ := newTestDiscordService(server)
+
+	if err := impl.SendFile("123", "big.txt", make([]byte, 10<<20+1)); err == nil {
+		t.Fatal("expected an error for a file over the Discord limit")
+	}
+}
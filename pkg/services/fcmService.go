@@ -0,0 +1,322 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/caarlos0/env"
+	"github.com/go-pg/pg"
+	"github.com/go-resty/resty/v2"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/iraunit/get-link-backend/pkg/cryptography"
+	"github.com/iraunit/get-link-backend/pkg/fileManager"
+	"github.com/iraunit/get-link-backend/pkg/messages"
+	"github.com/iraunit/get-link-backend/pkg/repository"
+	"github.com/iraunit/get-link-backend/util"
+	"github.com/iraunit/get-link-backend/util/bean"
+	"go.uber.org/zap"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	fcmScope             = "https://www.googleapis.com/auth/firebase.messaging"
+	fcmDefaultTokenUrl   = "https://oauth2.googleapis.com/token"
+	fcmSendFormat        = "/v1/projects/%s/messages:send"
+	fcmCollapseLinks     = "getlink-links"
+	fcmCollapseFiles     = "getlink-files"
+	fcmCollapseAlerts    = "getlink-alerts"
+	fcmMaxBodyLength     = 200
+	fcmPlatformAndroid   = "android"
+	fcmPlatformIos       = "ios"
+	fcmErrorUnregistered = "UNREGISTERED"
+)
+
+type FcmService interface {
+	RegisterToken(userEmail, uuid string, request *bean.FcmTokenRequest) error
+	UnregisterToken(userEmail, uuid string) error
+}
+
+type FcmServiceImpl struct {
+	logger       *zap.SugaredLogger
+	cfg          bean.FcmCfg
+	async        *util.Async
+	client       *resty.Client
+	repository   repository.Repository
+	linkService  LinkService
+	outbox       OutboxService
+	notification NotificationService
+	account      *bean.FcmServiceAccount
+	lock         *sync.Mutex
+	accessToken  string
+	expiresAt    time.Time
+}
+
+func NewFcmServiceImpl(logger *zap.SugaredLogger, async *util.Async, repository repository.Repository, linkService LinkService, fileManager fileManager.FileManager, outbox OutboxService, notificationService NotificationService) *FcmServiceImpl {
+	cfg := bean.FcmCfg{}
+	if err := env.Parse(&cfg); err != nil {
+		logger.Fatal("Error loading Cfg from env", "Error", zap.Error(err))
+	}
+	impl := &FcmServiceImpl{
+		logger:       logger,
+		cfg:          cfg,
+		async:        async,
+		client:       resty.New().SetBaseURL(cfg.ApiUrl),
+		repository:   repository,
+		linkService:  linkService,
+		outbox:       outbox,
+		notification: notificationService,
+		lock:         &sync.Mutex{},
+	}
+	if cfg.CredentialsFile != "" {
+		account, err := readFcmServiceAccount(cfg.CredentialsFile)
+		if err != nil {
+			logger.Fatal("Error loading FCM credentials", "Error", zap.Error(err))
+		}
+		impl.account = account
+		if impl.cfg.ProjectID == "" {
+			impl.cfg.ProjectID = account.ProjectID
+		}
+		if impl.cfg.TokenUrl == "" {
+			impl.cfg.TokenUrl = account.TokenUri
+		}
+	}
+	if impl.cfg.TokenUrl == "" {
+		impl.cfg.TokenUrl = fcmDefaultTokenUrl
+	}
+
+	outbox.RegisterSender(util.FCM, impl.sendOutboxMessage)
+	if impl.cfg.ProjectID == "" || (impl.account == nil && cfg.AccessToken == "") {
+		logger.Infow("FCM is not configured, mobile push notifications are disabled")
+		return impl
+	}
+	linkService.RegisterAddLinkHook(impl.notifyLink)
+	fileManager.RegisterSaveFileHook(impl.notifyFile)
+	notificationService.RegisterNotifier(util.FCM, impl.sendNotification)
+	return impl
+}
+
+func readFcmServiceAccount(credentialsFile string) (*bean.FcmServiceAccount, error) {
+	data, err := os.ReadFile(credentialsFile)
+	if err != nil {
+		return nil, err
+	}
+	account := &bean.FcmServiceAccount{}
+	if err = json.Unmarshal(data, account); err != nil {
+		return nil, err
+	}
+	return account, nil
+}
+
+func (impl *FcmServiceImpl) RegisterToken(userEmail, uuid string, request *bean.FcmTokenRequest) error {
+	if request.Token == "" {
+		return fmt.Errorf("[token] is missing")
+	}
+	if request.Platform != fcmPlatformAndroid && request.Platform != fcmPlatformIos {
+		return fmt.Errorf("platform must be %s or %s", fcmPlatformAndroid, fcmPlatformIos)
+	}
+	encryptedEmail, err := cryptography.EncryptData(userEmail, userEmail, impl.logger)
+	if err != nil {
+		impl.logger.Errorw("Error in encrypting data", "Error: ", err)
+		return err
+	}
+	encryptedToken, err := cryptography.EncryptData(userEmail, request.Token, impl.logger)
+	if err != nil {
+		impl.logger.Errorw("Error in encrypting data", "Error: ", err)
+		return err
+	}
+	return impl.repository.InsertUpdateFcmToken(&bean.FcmToken{Email: encryptedEmail, UUID: uuid, Token: encryptedToken, Platform: request.Platform})
+}
+
+func (impl *FcmServiceImpl) UnregisterToken(userEmail, uuid string) error {
+	encryptedEmail, err := cryptography.EncryptData(userEmail, userEmail, impl.logger)
+	if err != nil {
+		impl.logger.Errorw("Error in encrypting data", "Error: ", err)
+		return err
+	}
+	return impl.repository.DeleteFcmToken(encryptedEmail, uuid)
+}
+
+func (impl *FcmServiceImpl) notifyLink(userEmail string, link bean.GetLink) {
+	notification := impl.notification.Message(userEmail, "notification.link_received", messages.Data{"Message": link.Message})
+	impl.notify(userEmail, link.UUID, util.EventLinkReceived, bean.FcmPayload{
+		CollapseKey: fcmCollapseLinks,
+		Title:       notification.Title,
+		Body:        truncateRunes(notification.Message, fcmMaxBodyLength),
+		Data:        map[string]string{"event": "link", "id": strconv.Itoa(link.ID), "uuid": link.UUID},
+	})
+}
+
+func (impl *FcmServiceImpl) notifyFile(userEmail, appName, fileName, sourceUUID string) {
+	notification := impl.notification.Message(userEmail, "notification.file_received", messages.Data{"FileName": fileName})
+	impl.notify(userEmail, sourceUUID, util.EventFileReceived, bean.FcmPayload{
+		CollapseKey: fcmCollapseFiles,
+		Title:       notification.Title,
+		Body:        truncateRunes(notification.Message, fcmMaxBodyLength),
+		Data:        map[string]string{"event": "file", "app": appName, "name": fileName},
+	})
+}
+
+func (impl *FcmServiceImpl) notify(userEmail, sourceUUID, event string, payload bean.FcmPayload) {
+	impl.async.Run(func() {
+		at, err := impl.notification.Check(userEmail, util.FCM, event)
+		if err != nil {
+			return
+		}
+		_ = impl.push(userEmail, sourceUUID, payload, at)
+	})
+}
+
+func (impl *FcmServiceImpl) sendNotification(userEmail string, notification bean.Notification, at time.Time) error {
+	return impl.push(userEmail, "", bean.FcmPayload{
+		CollapseKey: fcmCollapseAlerts,
+		Title:       notification.Title,
+		Body:        truncateRunes(notification.Message, fcmMaxBodyLength),
+		Data:        map[string]string{"event": "notification", "url": notification.ActionUrl},
+	}, at)
+}
+
+// push queues the payload for every registered device of the user that has no open websocket, skipping the source device.
+func (impl *FcmServiceImpl) push(userEmail, sourceUUID string, payload bean.FcmPayload, at time.Time) error {
+	encryptedEmail, err := cryptography.EncryptData(userEmail, userEmail, impl.logger)
+	if err != nil {
+		return err
+	}
+	tokens, err := impl.repository.GetFcmTokens(encryptedEmail)
+	if err != nil {
+		return err
+	}
+	for _, token := range tokens {
+		if token.UUID == sourceUUID || impl.linkService.IsDeviceConnected(userEmail, token.UUID) {
+			continue
+		}
+		payload.TokenID = token.ID
+		if err = impl.outbox.EnqueueAt(util.FCM, userEmail, payload, at); err != nil {
+			impl.logger.Errorw("Error in queueing fcm message", "Error", err)
+		}
+	}
+	return nil
+}
+
+func (impl *FcmServiceImpl) sendOutboxMessage(userEmail string, payload []byte) error {
+	var message bean.FcmPayload
+	if err := json.Unmarshal(payload, &message); err != nil {
+		return err
+	}
+	token, err := impl.repository.GetFcmToken(message.TokenID)
+	if errors.Is(err, pg.ErrNoRows) {
+		// the device unregistered after the push was queued
+		return nil
+	} else if err != nil {
+		return err
+	}
+	registrationToken, err := cryptography.DecryptData(userEmail, token.Token, impl.logger)
+	if err != nil {
+		return err
+	}
+	accessToken, err := impl.getAccessToken()
+	if err != nil {
+		return err
+	}
+
+	// the collapse key makes a burst of links show as one notification, replaced by the latest
+	request := bean.FcmSendRequest{Message: bean.FcmMessage{
+		Token:        registrationToken,
+		Data:         message.Data,
+		Notification: &bean.FcmNotification{Title: message.Title, Body: message.Body},
+		Android: &bean.FcmAndroidConfig{
+			CollapseKey:  message.CollapseKey,
+			Priority:     "high",
+			Ttl:          fmt.Sprintf("%ds", int(impl.cfg.TTL.Seconds())),
+			Notification: &bean.FcmAndroidNotification{Tag: message.CollapseKey},
+		},
+		Apns: &bean.FcmApnsConfig{Headers: map[string]string{"apns-collapse-id": message.CollapseKey}},
+	}}
+	var fcmError bean.FcmErrorResponse
+	resp, err := impl.client.R().
+		SetAuthToken(accessToken).
+		SetBody(request).
+		SetError(&fcmError).
+		Post(fmt.Sprintf(fcmSendFormat, impl.cfg.ProjectID))
+	if err != nil {
+		impl.logger.Errorw("Error in sending fcm message", "Error", err)
+		return err
+	}
+	if !resp.IsError() {
+		return nil
+	}
+
+	if fcmErrorCode(&fcmError) == fcmErrorUnregistered {
+		// the app was uninstalled, the token will never work again
+		impl.logger.Infow("Removing invalid fcm token", "ID", token.ID, "Status", fcmError.Error.Status)
+		return impl.repository.DeleteFcmTokenByID(token.ID)
+	}
+	if resp.StatusCode() == http.StatusUnauthorized {
+		impl.lock.Lock()
+		impl.accessToken = ""
+		impl.lock.Unlock()
+	}
+	impl.logger.Errorw("Error in sending fcm message. status not ok.", "Status", resp.StatusCode(), "Message", fcmError.Error.Message)
+	return fmt.Errorf("status : %d", resp.StatusCode())
+}
+
+func fcmErrorCode(fcmError *bean.FcmErrorResponse) string {
+	for _, detail := range fcmError.Error.Details {
+		if detail.ErrorCode != "" {
+			return detail.ErrorCode
+		}
+	}
+	return ""
+}
+
+// getAccessToken returns a cached OAuth token, exchanging a signed service account assertion when it is about to expire.
+func (impl *FcmServiceImpl) getAccessToken() (string, error) {
+	if impl.cfg.AccessToken != "" {
+		return impl.cfg.AccessToken, nil
+	}
+	if impl.account == nil {
+		return "", fmt.Errorf("fcm is not configured")
+	}
+	impl.lock.Lock()
+	defer impl.lock.Unlock()
+	if impl.accessToken != "" && time.Now().Before(impl.expiresAt) {
+		return impl.accessToken, nil
+	}
+
+	key, err := jwt.ParseRSAPrivateKeyFromPEM([]byte(impl.account.PrivateKey))
+	if err != nil {
+		return "", err
+	}
+	now := time.Now()
+	assertion, err := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+		"iss":   impl.account.ClientEmail,
+		"scope": fcmScope,
+		"aud":   impl.cfg.TokenUrl,
+		"iat":   now.Unix(),
+		"exp":   now.Add(time.Hour).Unix(),
+	}).SignedString(key)
+	if err != nil {
+		return "", err
+	}
+
+	var token bean.OAuthTokenResponse
+	resp, err := resty.New().R().
+		SetFormData(map[string]string{"grant_type": "urn:ietf:params:oauth:grant-type:jwt-bearer", "assertion": assertion}).
+		SetResult(&token).
+		Post(impl.cfg.TokenUrl)
+	if err != nil {
+		impl.logger.Errorw("Error in getting fcm access token", "Error", err)
+		return "", err
+	}
+	if resp.IsError() {
+		impl.logger.Errorw("Error in getting fcm access token. status not ok.", "Status", resp.StatusCode())
+		return "", fmt.Errorf("status : %d", resp.StatusCode())
+	}
+	impl.accessToken = token.AccessToken
+	// refresh a minute early so a token never expires mid request
+	impl.expiresAt = now.Add(time.Duration(token.ExpiresIn)*time.Second - time.Minute)
+	return impl.accessToken, nil
+}
@@ -0,0 +1,325 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/caarlos0/env"
+	"github.com/go-pg/pg"
+	"github.com/iraunit/get-link-backend/pkg/cryptography"
+	"github.com/iraunit/get-link-backend/pkg/fileManager"
+	"github.com/iraunit/get-link-backend/pkg/repository"
+	tokenService2 "github.com/iraunit/get-link-backend/pkg/services/tokenService"
+	"github.com/iraunit/get-link-backend/util"
+	"github.com/iraunit/get-link-backend/util/bean"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+	"net/mail"
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+)
+
+const inboundVerificationKey = "inbound:verification:%s"
+
+var inboundLinkRegex = regexp.MustCompile(`https?://[^\s<>"']+`)
+
+var (
+	ErrInboundSenderNotVerified      = errors.New("inbound email sender is not verified")
+	ErrInboundSenderNotAuthenticated = errors.New("inbound email passed neither SPF nor DKIM")
+)
+
+type InboundEmailService interface {
+	GetInboundAddress(userEmail string) (string, error)
+	ReceiveEmail(email *bean.InboundEmail) error
+	VerifyInboundSender(claims *bean.InboundEmailVerificationClaims) error
+}
+
+type InboundEmailServiceImpl struct {
+	logger       *zap.SugaredLogger
+	cfg          bean.InboundEmailCfg
+	ctx          context.Context
+	client       *redis.Client
+	mailService  MailService
+	tokenService tokenService2.TokenService
+	repository   repository.Repository
+	linkService  LinkService
+	fileManager  fileManager.FileManager
+}
+
+func NewInboundEmailServiceImpl(logger *zap.SugaredLogger, client *redis.Client, mailService MailService, tokenService tokenService2.TokenService, repository repository.Repository, linkService LinkService, fileManager fileManager.FileManager) *InboundEmailServiceImpl {
+	cfg := bean.InboundEmailCfg{}
+	if err := env.Parse(&cfg); err != nil {
+		logger.Fatal("Error loading Cfg from env", "Error", zap.Error(err))
+	}
+	return &InboundEmailServiceImpl{
+		logger:       logger,
+		cfg:          cfg,
+		ctx:          context.Background(),
+		client:       client,
+		mailService:  mailService,
+		tokenService: tokenService,
+		repository:   repository,
+		linkService:  linkService,
+		fileManager:  fileManager,
+	}
+}
+
+func (impl *InboundEmailServiceImpl) GetInboundAddress(userEmail string) (string, error) {
+	owner, err := cryptography.EncryptData(userEmail, userEmail, impl.logger)
+	if err != nil {
+		impl.logger.Errorw("Error in encrypting data", "Error: ", err)
+		return "", err
+	}
+
+	address, err := impl.repository.GetInboundEmailAddressFromOwner(owner)
+	if err == nil {
+		return fmt.Sprintf("%s@%s", address.Code, impl.cfg.Domain), nil
+	}
+	if !errors.Is(err, pg.ErrNoRows) {
+		impl.logger.Errorw("Error in getting inbound email address", "Error: ", err)
+		return "", err
+	}
+
+	code, err := generateInboundCode()
+	if err != nil {
+		impl.logger.Errorw("Error in generating inbound email code", "Error: ", err)
+		return "", err
+	}
+	encryptedEmail, err := cryptography.EncryptData(code, userEmail, impl.logger)
+	if err != nil {
+		impl.logger.Errorw("Error in encrypting data", "Error: ", err)
+		return "", err
+	}
+
+	err = impl.repository.InsertInboundEmailAddress(&bean.InboundEmailAddress{Code: code, Email: encryptedEmail, Owner: owner})
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s@%s", code, impl.cfg.Domain), nil
+}
+
+func (impl *InboundEmailServiceImpl) ReceiveEmail(email *bean.InboundEmail) error {
+	defer func() {
+		for _, attachment := range email.Attachments {
+			_ = attachment.Data.Close()
+		}
+	}()
+
+	sender, err := mail.ParseAddress(email.From)
+	if err != nil {
+		impl.logger.Errorw("Error in parsing sender address", "From", email.From, "Error: ", err)
+		return err
+	}
+	senderAddress := strings.ToLower(sender.Address)
+	if impl.cfg.RequireSenderAuth && !isSenderAuthenticated(email, senderAddress) {
+		return ErrInboundSenderNotAuthenticated
+	}
+
+	userEmail, err := impl.getUserFromRecipients(email.To)
+	if err != nil {
+		return err
+	}
+
+	verified, err := impl.isSenderVerified(senderAddress, userEmail)
+	if err != nil {
+		return err
+	}
+	if !verified {
+		impl.sendSenderVerificationMail(senderAddress, userEmail)
+		return ErrInboundSenderNotVerified
+	}
+
+	for _, link := range inboundLinkRegex.FindAllString(email.Text, -1) {
+		impl.linkService.AddLink(userEmail, &bean.GetLink{Receiver: userEmail, Sender: userEmail, Message: link, UUID: util.EMAIL})
+	}
+
+	if len(email.Attachments) == 0 {
+		return nil
+	}
+
+	folderPath := impl.fileManager.GetPathToSaveFileFromApp(util.EncodeString(userEmail), util.EMAIL)
+	impl.fileManager.DeleteFileFromPathOlderThan24Hours(folderPath)
+	folderSize, err := impl.fileManager.GetSizeOfADirectory(folderPath)
+	if err != nil {
+		impl.logger.Errorw("Error in getting folder size", "Error", err)
+		return err
+	}
+	maxLimit := util.FreeEmailFileLimitSizeMB
+	if impl.repository.IsUserPremiumUser(userEmail) {
+		maxLimit = util.PremiumEmailFileLimitSizeMB
+	}
+	if folderSize > int64(maxLimit) {
+		impl.fileManager.DeleteAllFileFromPath(folderPath)
+	}
+
+	taken := map[string]bool{}
+	if files, err := impl.fileManager.ListAllFilesFromApp(userEmail, util.EMAIL); err == nil {
+		for _, file := range files {
+			taken[file.Name] = true
+		}
+	}
+	for _, attachment := range email.Attachments {
+		if attachment.Size > impl.cfg.MaxAttachmentSizeMB<<20 {
+			impl.logger.Infow("Skipping email attachment over the size limit", "FileName", attachment.FileName, "Size", attachment.Size)
+			continue
+		}
+		fileName := util.SanitizeFilename(attachment.FileName)
+		if fileName == "" {
+			fileName = util.GetFileNameFromType("attachment", "")
+		}
+		fileName = uniqueFileName(fileName, taken)
+		err = impl.fileManager.SaveFileToPath(attachment.Data, path.Join(folderPath, fileName+".bin"), userEmail, util.EMAIL)
+		if err != nil {
+			impl.logger.Errorw("Error in saving email attachment", "FileName", fileName, "Error", err)
+			return err
+		}
+	}
+	return nil
+}
+
+func (impl *InboundEmailServiceImpl) VerifyInboundSender(claims *bean.InboundEmailVerificationClaims) error {
+	sender := strings.ToLower(claims.Sender)
+	encryptedEmail, err := cryptography.EncryptData(sender, claims.Email, impl.logger)
+	if err != nil {
+		impl.logger.Errorw("Error in encrypting data", "Error: ", err)
+		return err
+	}
+	encryptedSender, err := cryptography.EncryptData(sender, sender, impl.logger)
+	if err != nil {
+		impl.logger.Errorw("Error in encrypting data", "Error: ", err)
+		return err
+	}
+	return impl.repository.InsertUpdateInboundEmailSender(&bean.InboundEmailSender{Email: encryptedEmail, Sender: encryptedSender})
+}
+
+func (impl *InboundEmailServiceImpl) getUserFromRecipients(to string) (string, error) {
+	recipients, err := mail.ParseAddressList(to)
+	if err != nil {
+		impl.logger.Errorw("Error in parsing recipient address", "To", to, "Error: ", err)
+		return "", err
+	}
+
+	for _, recipient := range recipients {
+		parts := strings.SplitN(strings.ToLower(recipient.Address), "@", 2)
+		if len(parts) != 2 || parts[1] != strings.ToLower(impl.cfg.Domain) {
+			continue
+		}
+		address, err := impl.repository.GetInboundEmailAddressFromCode(parts[0])
+		if err != nil {
+			continue
+		}
+		userEmail, err := cryptography.DecryptData(address.Code, address.Email, impl.logger)
+		if err != nil {
+			impl.logger.Errorw("Error in decrypting data", "Error: ", err)
+			return "", err
+		}
+		return userEmail, nil
+	}
+	return "", pg.ErrNoRows
+}
+
+func (impl *InboundEmailServiceImpl) isSenderVerified(sender, userEmail string) (bool, error) {
+	encryptedSender, err := cryptography.EncryptData(sender, sender, impl.logger)
+	if err != nil {
+		impl.logger.Errorw("Error in encryption", "Error: ", err)
+		return false, err
+	}
+	allEmails, err := impl.repository.GetEmailsFromInboundSender(encryptedSender)
+	if err != nil {
+		return false, err
+	}
+	for _, email := range allEmails {
+		decryptedEmail, err := cryptography.DecryptData(sender, email.Email, impl.logger)
+		if err != nil {
+			impl.logger.Errorw("Error in decrypting data", "Error: ", err)
+			continue
+		}
+		if decryptedEmail == userEmail {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (impl *InboundEmailServiceImpl) sendSenderVerificationMail(sender, userEmail string) {
+	if !impl.takeVerification(sender, userEmail) {
+		impl.logger.Infow("Inbound sender verification mail already sent", "Sender", sender)
+		return
+	}
+	claims := bean.InboundEmailVerificationClaims{Email: userEmail, Sender: sender}
+	token, err := impl.tokenService.InboundEmailVerificationToken(&claims)
+	if err != nil {
+		impl.logger.Errorw("Error in generating token", "Error", err)
+		return
+	}
+	err = impl.mailService.SendTemplateMail(userEmail, util.MailTemplateVerification, bean.VerificationMailData{
+		Intro:      fmt.Sprintf("%s tried to add links and files to your Get-Link account by email. Please click on the below link to allow this sender.", sender),
+		ActionUrl:  fmt.Sprintf("%s%s?token=%s", impl.cfg.BaseUrl, util.VerifyInboundEmail, url.QueryEscape(token)),
+		ActionText: "Allow sender",
+	})
+	if err != nil {
+		impl.logger.Errorw("Error in sending mail", "Error", err)
+		return
+	}
+	impl.logger.Infow("Inbound Sender Verification Mail Sent", "Sender", sender)
+}
+
+// takeVerification reports whether a verification mail may be sent for the pair, allowing one per VerificationTTL so
+// repeated mail from an unknown sender does not flood the user. Redis errors allow it.
+func (impl *InboundEmailServiceImpl) takeVerification(sender, userEmail string) bool {
+	encryptedSender, err := cryptography.EncryptData(userEmail, sender, impl.logger)
+	if err != nil {
+		return true
+	}
+	ok, err := impl.client.SetNX(impl.ctx, fmt.Sprintf(inboundVerificationKey, encryptedSender), 1, impl.cfg.VerificationTTL).Result()
+	if err != nil {
+		impl.logger.Errorw("Error in checking inbound verification mail", "Error", err)
+		return true
+	}
+	return ok
+}
+
+// isSenderAuthenticated accepts a passing SPF verdict for an envelope sender of the From domain, or a passing DKIM
+// signature of the From domain. A pass for any other domain says nothing about who wrote the From header.
+func isSenderAuthenticated(email *bean.InboundEmail, sender string) bool {
+	domain := addressDomain(sender)
+	if domain == "" {
+		return false
+	}
+	if envelope, err := mail.ParseAddress(email.EnvelopeFrom); err == nil && strings.EqualFold(strings.TrimSpace(email.SPF), "pass") &&
+		addressDomain(envelope.Address) == domain {
+		return true
+	}
+	return strings.Contains(strings.ToLower(email.DKIM), "@"+domain+" : pass")
+}
+
+func addressDomain(address string) string {
+	at := strings.LastIndex(address, "@")
+	if at < 0 {
+		return ""
+	}
+	return strings.ToLower(address[at+1:])
+}
+
+// uniqueFileName numbers fileName when an earlier file or attachment took it, so neither is overwritten.
+func uniqueFileName(fileName string, taken map[string]bool) string {
+	ext := path.Ext(fileName)
+	base := strings.TrimSuffix(fileName, ext)
+	for i := 1; taken[fileName]; i++ {
+		fileName = fmt.Sprintf("%s-%d%s", base, i, ext)
+	}
+	taken[fileName] = true
+	return fileName
+}
+
+func generateInboundCode() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
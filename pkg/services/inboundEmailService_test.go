@@ -0,0 +1,40 @@
+package services
+
+import (
+	"github.com/iraunit/get-link-backend/util/bean"
+	"testing"
+)
+
+func TestInboundSenderAuthentication(t *testing.T) {
+	cases := []struct {
+		email bean.InboundEmail
+		want  bool
+	}{
+		{bean.InboundEmail{EnvelopeFrom: "bounce@Example.com", SPF: "Pass"}, true},
+		{bean.InboundEmail{EnvelopeFrom: "bounce@attacker.com", SPF: "pass"}, false},
+		{bean.InboundEmail{SPF: "pass"}, false},
+		{bean.InboundEmail{DKIM: "pass"}, false},
+		{bean.InboundEmail{DKIM: "{@example.com : pass}"}, true},
+		{bean.InboundEmail{SPF: "softfail", DKIM: "{@attacker.com : pass}"}, false},
+		{bean.InboundEmail{DKIM: "{@sub.example.com : pass}"}, false},
+		{bean.InboundEmail{}, false},
+	}
+	for _, c := range cases {
+		if got := isSenderAuthenticated(&c.email, "user@example.com"); got != c.want {
+			t.Fatalf("expected %v for %+v, got %v", c.want, c.email, got)
+		}
+	}
+}
+
+func TestInboundUniqueFileName(t *testing.T) {
+	taken := map[string]bool{"report.pdf": true}
+	if got := uniqueFileName("report.pdf", taken); got != "report-1.pdf" {
+		t.Fatalf("expected report-1.pdf, got %s", got)
+	}
+	if got := uniqueFileName("report.pdf", taken); got != "report-2.pdf" {
+		t.Fatalf("expected report-2.pdf, got %s", got)
+	}
+	if got := uniqueFileName("notes", taken); got != "notes" {
+		t.Fatalf("expected notes, got %s", got)
+	}
+}
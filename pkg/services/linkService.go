@@ -2,14 +2,25 @@ package services
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
 	"github.com/gorilla/websocket"
 	"github.com/iraunit/get-link-backend/pkg/cryptography"
 	"github.com/iraunit/get-link-backend/pkg/repository"
+	"github.com/iraunit/get-link-backend/util"
 	"github.com/iraunit/get-link-backend/util/bean"
 	"github.com/redis/go-redis/v9"
 	"go.uber.org/zap"
 	"sync"
+	"time"
+)
+
+const (
+	// presenceKey counts open websocket connections per device uuid. It expires in case an instance dies with
+	// connections open, so a crashed server only mutes push notifications for a while.
+	presenceKey = "presence:%s"
+	presenceTTL = 24 * time.Hour
 )
 
 type LinkService interface {
@@ -20,25 +31,36 @@ type LinkService interface {
 	AddLink(userEmail string, data *bean.GetLink)
 	GetAllLink(userEmail string, uuid string) *[]bean.GetLink
 	DeleteLink(userEmail string, data *bean.GetLink) error
+	TagLink(userEmail string, data *bean.GetLink, tag string) error
 	VerifyWhatsapp(userEmail string, claims *bean.WhatsappEmail) error
+	RegisterAddLinkHook(hook AddLinkHook)
+	// PublishEvent pushes a typed event, which clients tell from links by its event field, to the user's websockets.
+	PublishEvent(userEmail string, event string, data interface{}) error
+	IsDeviceConnected(userEmail string, uuid string) bool
 }
 
+// AddLinkHook is called with the decrypted link after it has been stored for userEmail.
+type AddLinkHook func(userEmail string, link bean.GetLink)
+
 type LinkServiceImpl struct {
-	logger     *zap.SugaredLogger
-	client     *redis.Client
-	lock       *sync.Mutex
-	Users      *map[string]bean.User
-	Repository repository.Repository
+	logger       *zap.SugaredLogger
+	client       *redis.Client
+	lock         *sync.Mutex
+	Users        *map[string]bean.User
+	Repository   repository.Repository
+	notification NotificationService
+	hooks        []AddLinkHook
 }
 
-func NewLinkServiceImpl(client *redis.Client, logger *zap.SugaredLogger, users *map[string]bean.User, repository repository.Repository) *LinkServiceImpl {
+func NewLinkServiceImpl(client *redis.Client, logger *zap.SugaredLogger, users *map[string]bean.User, repository repository.Repository, notificationService NotificationService) *LinkServiceImpl {
 
 	return &LinkServiceImpl{
-		logger:     logger,
-		client:     client,
-		lock:       &sync.Mutex{},
-		Users:      users,
-		Repository: repository,
+		logger:       logger,
+		client:       client,
+		lock:         &sync.Mutex{},
+		Users:        users,
+		Repository:   repository,
+		notification: notificationService,
 	}
 }
 
@@ -49,6 +71,8 @@ func (impl *LinkServiceImpl) ReadMessages(conn *websocket.Conn, userEmail string
 		impl.logger.Errorw("Error in encryption", "Error: ", err)
 		return
 	}
+	impl.updatePresence(encryptedEmail, uuid, 1)
+	defer impl.updatePresence(encryptedEmail, uuid, -1)
 	for {
 		_, message, err := conn.ReadMessage()
 		if err != nil {
@@ -76,7 +100,11 @@ func (impl *LinkServiceImpl) ReadMessages(conn *websocket.Conn, userEmail string
 			Message:  encryptedMsg,
 			UUID:     uuid,
 		}
-		impl.Repository.AddLink(&data, &bean.GetLink{Sender: userEmail, Receiver: userEmail, Message: string(message), UUID: uuid}, userEmail)
+		decryptedData := bean.GetLink{Sender: userEmail, Receiver: userEmail, Message: string(message), UUID: uuid}
+		impl.Repository.AddLink(&data)
+		decryptedData.ID = data.ID
+		impl.publishLink(userEmail, decryptedData)
+		impl.runAddLinkHooks(userEmail, decryptedData)
 		err = impl.client.Publish(ctx, encryptedEmail, encryptedMsg).Err()
 		if err != nil {
 			impl.logger.Errorw("Error in publishing message to Redis", "Error: ", err)
@@ -149,7 +177,107 @@ func (impl *LinkServiceImpl) AddLink(userEmail string, data *bean.GetLink) {
 	data.Receiver = receiverMailEncrypted
 	data.Message = encryptedData
 
-	impl.Repository.AddLink(data, &decryptedData, receiverMail)
+	impl.Repository.AddLink(data)
+	decryptedData.ID = data.ID
+	// links sent from the user's own websocket are always echoed, the others follow the websocket preferences
+	at, err := impl.notification.Check(receiverMail, util.WEBSOCKET, util.EventLinkReceived)
+	if err == nil && !at.After(time.Now()) {
+		impl.publishLink(receiverMail, decryptedData)
+	}
+	impl.runAddLinkHooks(receiverMail, decryptedData)
+}
+
+// publishLink pushes a stored link to the user's open websocket connections.
+func (impl *LinkServiceImpl) publishLink(userEmail string, link bean.GetLink) {
+	if link.ID == 0 {
+		return
+	}
+	encryptedEmail, err := cryptography.EncryptData(userEmail, userEmail, impl.logger)
+	if err != nil {
+		impl.logger.Errorw("Error in encrypting data", "Error: ", err)
+		return
+	}
+	linkJson, err := json.Marshal(bean.PubSubMessage{Message: link.Message, UUID: link.UUID, ID: link.ID, Sender: link.Sender})
+	if err != nil {
+		impl.logger.Errorw("Error in marshalling link", "Error: ", err)
+		return
+	}
+	encryptedJson, err := cryptography.EncryptData(userEmail, string(linkJson), impl.logger)
+	if err != nil {
+		impl.logger.Errorw("Error in encrypting json", "Error: ", err)
+		return
+	}
+	if err = impl.client.Publish(context.Background(), encryptedEmail, encryptedJson).Err(); err != nil {
+		impl.logger.Errorw("Error in publishing link", "Error: ", err)
+	}
+}
+
+func (impl *LinkServiceImpl) PublishEvent(userEmail string, event string, data interface{}) error {
+	encryptedEmail, err := cryptography.EncryptData(userEmail, userEmail, impl.logger)
+	if err != nil {
+		impl.logger.Errorw("Error in encrypting data", "Error: ", err)
+		return err
+	}
+	eventJson, err := json.Marshal(bean.PubSubMessage{Event: event, Data: data})
+	if err != nil {
+		impl.logger.Errorw("Error in marshalling event", "Error: ", err)
+		return err
+	}
+	encryptedJson, err := cryptography.EncryptData(userEmail, string(eventJson), impl.logger)
+	if err != nil {
+		impl.logger.Errorw("Error in encrypting json", "Error: ", err)
+		return err
+	}
+	return impl.client.Publish(context.Background(), encryptedEmail, encryptedJson).Err()
+}
+
+func (impl *LinkServiceImpl) RegisterAddLinkHook(hook AddLinkHook) {
+	impl.lock.Lock()
+	defer impl.lock.Unlock()
+	impl.hooks = append(impl.hooks, hook)
+}
+
+func (impl *LinkServiceImpl) runAddLinkHooks(userEmail string, link bean.GetLink) {
+	if link.ID == 0 {
+		return
+	}
+	impl.lock.Lock()
+	hooks := impl.hooks
+	impl.lock.Unlock()
+	for _, hook := range hooks {
+		hook(userEmail, link)
+	}
+}
+
+// IsDeviceConnected reports whether the device has an open websocket on any instance. Redis errors count as connected.
+func (impl *LinkServiceImpl) IsDeviceConnected(userEmail string, uuid string) bool {
+	encryptedEmail, err := cryptography.EncryptData(userEmail, userEmail, impl.logger)
+	if err != nil {
+		return true
+	}
+	count, err := impl.client.HGet(context.Background(), fmt.Sprintf(presenceKey, encryptedEmail), uuid).Int()
+	if errors.Is(err, redis.Nil) {
+		return false
+	} else if err != nil {
+		impl.logger.Errorw("Error in getting device presence", "Error: ", err)
+		return true
+	}
+	return count > 0
+}
+
+func (impl *LinkServiceImpl) updatePresence(encryptedEmail string, uuid string, delta int64) {
+	ctx := context.Background()
+	key := fmt.Sprintf(presenceKey, encryptedEmail)
+	count, err := impl.client.HIncrBy(ctx, key, uuid, delta).Result()
+	if err != nil {
+		impl.logger.Errorw("Error in updating device presence", "Error: ", err)
+		return
+	}
+	if count <= 0 {
+		impl.client.HDel(ctx, key, uuid)
+		return
+	}
+	impl.client.Expire(ctx, key, presenceTTL)
 }
 
 func (impl *LinkServiceImpl) HandleConnection(conn *websocket.Conn, userEmail string) {
@@ -215,6 +343,9 @@ func (impl *LinkServiceImpl) GetAllLink(userEmail string, uuid string) *[]bean.G
 	}
 
 	allLinks := impl.Repository.GetAllLink(encryptedEmail, uuid)
+	if allLinks == nil {
+		return nil
+	}
 	for i := 0; i < len(*allLinks); i++ {
 		(*allLinks)[i].Sender, err = cryptography.DecryptData(userEmail, (*allLinks)[i].Sender, impl.logger)
 		if err != nil {
@@ -241,6 +372,16 @@ func (impl *LinkServiceImpl) DeleteLink(userEmail string, data *bean.GetLink) er
 	data.Receiver = encryptedEmail
 	return impl.Repository.DeleteLink(data)
 }
+
+func (impl *LinkServiceImpl) TagLink(userEmail string, data *bean.GetLink, tag string) error {
+	encryptedEmail, err := cryptography.EncryptData(userEmail, userEmail, impl.logger)
+	if err != nil {
+		impl.logger.Errorw("Error in encrypting data", "Error: ", err)
+		return err
+	}
+	data.Receiver = encryptedEmail
+	return impl.Repository.AddLinkTag(data, tag)
+}
 func (impl *LinkServiceImpl) VerifyWhatsapp(userEmail string, claims *bean.WhatsappEmail) error {
 	sender := claims.WhatAppNumber
 	encryptedEmail, err := cryptography.EncryptData(sender, userEmail, impl.logger)
@@ -257,5 +398,26 @@ func (impl *LinkServiceImpl) VerifyWhatsapp(userEmail string, claims *bean.Whats
 		}
 		claims.WhatAppNumber = encryptedWhatsapp
 	}
-	return impl.Repository.InsertUpdateWhatsappNumber(claims)
+	err = impl.Repository.InsertUpdateWhatsappNumber(claims)
+	if err != nil {
+		return err
+	}
+
+	return linkWhatsappEmail(impl.Repository, impl.logger, userEmail, sender)
+}
+
+// linkWhatsappEmail keys number by the email as well, one channel identity per number, so every linked number can be
+// listed and unlinked from the web. whatsapp_emails is keyed by the number only.
+func linkWhatsappEmail(repository repository.Repository, logger *zap.SugaredLogger, userEmail, number string) error {
+	encryptedEmail, err := cryptography.EncryptData(userEmail, userEmail, logger)
+	if err != nil {
+		logger.Errorw("Error in encrypting data", "Error: ", err)
+		return err
+	}
+	encryptedNumber, err := cryptography.EncryptData(userEmail, number, logger)
+	if err != nil {
+		logger.Errorw("Error in encrypting data", "Error: ", err)
+		return err
+	}
+	return repository.InsertChannelIdentity(&bean.ChannelIdentity{Channel: util.WHATSAPP, Identity: encryptedEmail, Email: encryptedNumber})
 }
@@ -1,39 +1,273 @@
 package services
 
 import (
+	"bytes"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/caarlos0/env"
+	"github.com/iraunit/get-link-backend/pkg/mailTemplates"
+	"github.com/iraunit/get-link-backend/util"
 	"github.com/iraunit/get-link-backend/util/bean"
 	"go.uber.org/zap"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
 	"net/smtp"
+	"net/textproto"
+	"strings"
+	"time"
 )
 
 type MailService interface {
-	SendMail(receiver string, subject string, body string) error
+	SendTemplateMail(receiver string, templateName string, data interface{}) error
+	// SendTenantTemplateMail sends the template from the tenant's mail sender.
+	SendTenantTemplateMail(tenant *bean.Tenant, receiver string, templateName string, data interface{}) error
+	// SendNotificationMail sends the notification template for event, if the receiver's preferences allow it.
+	SendNotificationMail(receiver string, event string, data bean.NotificationMailData) error
+	// SendListTemplateMail sends a template the receiver subscribed to, which mail clients can unsubscribe from with a
+	// POST to unsubscribeUrl.
+	SendListTemplateMail(receiver string, templateName string, data interface{}, unsubscribeUrl string) error
 }
 
 type MailServiceImpl struct {
-	logger *zap.SugaredLogger
-	cfg    bean.MailConfig
+	logger              *zap.SugaredLogger
+	cfg                 bean.MailConfig
+	outbox              OutboxService
+	notificationService NotificationService
+	tenants             TenantService
 }
 
-func NewMailServiceImpl(logger *zap.SugaredLogger) *MailServiceImpl {
+func NewMailServiceImpl(logger *zap.SugaredLogger, outbox OutboxService, notificationService NotificationService, tenantService TenantService) *MailServiceImpl {
 	cfg := bean.MailConfig{}
 	if err := env.Parse(&cfg); err != nil {
 		logger.Fatal("Error loading Cfg from env", "Error", zap.Error(err))
 	}
-	return &MailServiceImpl{
-		logger: logger,
-		cfg:    cfg,
+	impl := &MailServiceImpl{
+		logger:              logger,
+		cfg:                 cfg,
+		outbox:              outbox,
+		notificationService: notificationService,
+		tenants:             tenantService,
 	}
+	outbox.RegisterSender(util.MAIL, impl.sendOutboxMail)
+	notificationService.RegisterNotifier(util.MAIL, impl.notify)
+	return impl
 }
 
-func (impl *MailServiceImpl) SendMail(receiver string, subject string, body string) error {
-	auth := smtp.PlainAuth("", impl.cfg.From, impl.cfg.Password, impl.cfg.Host)
-	msg := "From: " + impl.cfg.From + "\n" +
-		"To: " + receiver + "\n" +
-		"Subject: " + subject + "\n\n" +
-		body
-	err := smtp.SendMail(fmt.Sprintf("%s:%s", impl.cfg.Host, impl.cfg.Port), auth, impl.cfg.From, []string{receiver}, []byte(msg))
-	return err
+func (impl *MailServiceImpl) SendTemplateMail(receiver string, templateName string, data interface{}) error {
+	return impl.sendTemplateMailAt(receiver, templateName, data, time.Now())
+}
+
+func (impl *MailServiceImpl) SendTenantTemplateMail(tenant *bean.Tenant, receiver string, templateName string, data interface{}) error {
+	rendered, err := mailTemplates.Render(templateName, tenant, data)
+	if err != nil {
+		impl.logger.Errorw("Error in rendering mail template", "Template", templateName, "Error", err)
+		return err
+	}
+	return impl.outbox.Enqueue(util.MAIL, receiver, bean.OutboxMail{Subject: rendered.Subject, Text: rendered.Text, HTML: rendered.HTML, From: tenant.MailFrom, FromName: tenant.MailFromName})
+}
+
+func (impl *MailServiceImpl) SendNotificationMail(receiver string, event string, data bean.NotificationMailData) error {
+	at, err := impl.notificationService.Check(receiver, util.MAIL, event)
+	if err != nil {
+		return err
+	}
+	return impl.sendTemplateMailAt(receiver, util.MailTemplateNotification, data, at)
+}
+
+func (impl *MailServiceImpl) SendListTemplateMail(receiver string, templateName string, data interface{}, unsubscribeUrl string) error {
+	rendered, err := mailTemplates.Render(templateName, impl.tenants.Default(), data)
+	if err != nil {
+		impl.logger.Errorw("Error in rendering mail template", "Template", templateName, "Error", err)
+		return err
+	}
+	return impl.outbox.Enqueue(util.MAIL, receiver, bean.OutboxMail{Subject: rendered.Subject, Text: rendered.Text, HTML: rendered.HTML, UnsubscribeUrl: unsubscribeUrl})
+}
+
+func (impl *MailServiceImpl) notify(userEmail string, notification bean.Notification, at time.Time) error {
+	data := bean.NotificationMailData{Title: notification.Title, Message: notification.Message, ActionUrl: notification.ActionUrl}
+	if data.ActionUrl != "" {
+		data.ActionText = "Open " + impl.brand()
+	}
+	return impl.sendTemplateMailAt(userEmail, util.MailTemplateNotification, data, at)
+}
+
+func (impl *MailServiceImpl) sendTemplateMailAt(receiver string, templateName string, data interface{}, at time.Time) error {
+	rendered, err := mailTemplates.Render(templateName, impl.tenants.Default(), data)
+	if err != nil {
+		impl.logger.Errorw("Error in rendering mail template", "Template", templateName, "Error", err)
+		return err
+	}
+	return impl.outbox.EnqueueAt(util.MAIL, receiver, bean.OutboxMail{Subject: rendered.Subject, Text: rendered.Text, HTML: rendered.HTML}, at)
+}
+
+// brand is the name of the default tenant, which sends the mails that are not tied to a tenant.
+func (impl *MailServiceImpl) brand() string {
+	if name := impl.tenants.Default().Name; name != "" {
+		return name
+	}
+	return "Get-Link"
+}
+
+func (impl *MailServiceImpl) sendOutboxMail(receiver string, payload []byte) error {
+	var outboxMail bean.OutboxMail
+	if err := json.Unmarshal(payload, &outboxMail); err != nil {
+		return err
+	}
+	from := mail.Address{Name: impl.cfg.FromName, Address: impl.cfg.From}
+	if outboxMail.From != "" {
+		from = mail.Address{Name: outboxMail.FromName, Address: outboxMail.From}
+	}
+	if outboxMail.HTML == "" {
+		return impl.sendPlainMail(from, receiver, &outboxMail)
+	}
+	return impl.sendAlternativeMail(from, receiver, &outboxMail)
+}
+
+func (impl *MailServiceImpl) sendPlainMail(from mail.Address, receiver string, outboxMail *bean.OutboxMail) error {
+	var msg bytes.Buffer
+	impl.writeHeaders(&msg, from, receiver, outboxMail)
+	msg.WriteString("Content-Type: text/plain; charset=\"UTF-8\"\r\n")
+	msg.WriteString("Content-Transfer-Encoding: quoted-printable\r\n\r\n")
+	if err := writeQuotedPrintable(&msg, outboxMail.Text); err != nil {
+		return err
+	}
+	return impl.deliver(from.Address, receiver, msg.Bytes())
+}
+
+func (impl *MailServiceImpl) sendAlternativeMail(from mail.Address, receiver string, outboxMail *bean.OutboxMail) error {
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	for _, part := range []struct {
+		contentType string
+		content     string
+	}{
+		{"text/plain; charset=\"UTF-8\"", outboxMail.Text},
+		{"text/html; charset=\"UTF-8\"", outboxMail.HTML},
+	} {
+		header := textproto.MIMEHeader{}
+		header.Set("Content-Type", part.contentType)
+		header.Set("Content-Transfer-Encoding", "quoted-printable")
+		w, err := writer.CreatePart(header)
+		if err != nil {
+			return err
+		}
+		if err = writeQuotedPrintable(w, part.content); err != nil {
+			return err
+		}
+	}
+	if err := writer.Close(); err != nil {
+		return err
+	}
+
+	var msg bytes.Buffer
+	impl.writeHeaders(&msg, from, receiver, outboxMail)
+	msg.WriteString(fmt.Sprintf("Content-Type: multipart/alternative; boundary=\"%s\"\r\n\r\n", writer.Boundary()))
+	msg.Write(body.Bytes())
+	return impl.deliver(from.Address, receiver, msg.Bytes())
+}
+
+func (impl *MailServiceImpl) writeHeaders(msg *bytes.Buffer, from mail.Address, receiver string, outboxMail *bean.OutboxMail) {
+	msg.WriteString("From: " + from.String() + "\r\n")
+	msg.WriteString("To: " + receiver + "\r\n")
+	msg.WriteString("Subject: " + mime.QEncoding.Encode("UTF-8", outboxMail.Subject) + "\r\n")
+	if outboxMail.UnsubscribeUrl != "" {
+		msg.WriteString("List-Unsubscribe: <" + outboxMail.UnsubscribeUrl + ">\r\n")
+		msg.WriteString("List-Unsubscribe-Post: List-Unsubscribe=One-Click\r\n")
+	}
+	msg.WriteString("Date: " + time.Now().Format(time.RFC1123Z) + "\r\n")
+	msg.WriteString("Message-ID: " + impl.messageId(from.Address) + "\r\n")
+	msg.WriteString("MIME-Version: 1.0\r\n")
+}
+
+func (impl *MailServiceImpl) messageId(from string) string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	domain := impl.cfg.Host
+	if at := strings.LastIndex(from, "@"); at != -1 {
+		domain = from[at+1:]
+	}
+	return fmt.Sprintf("<%s.%d@%s>", hex.EncodeToString(b), time.Now().UnixNano(), domain)
+}
+
+// deliver sends msg through the shared SMTP server, which must accept every tenant's sender address.
+func (impl *MailServiceImpl) deliver(from string, receiver string, msg []byte) error {
+	addr := net.JoinHostPort(impl.cfg.Host, impl.cfg.Port)
+	tlsConfig := &tls.Config{ServerName: impl.cfg.Host, InsecureSkipVerify: impl.cfg.InsecureSkipVerify}
+	dialer := &net.Dialer{Timeout: 15 * time.Second}
+
+	var conn net.Conn
+	var err error
+	if impl.cfg.Security == util.MailSecurityTLS {
+		conn, err = tls.DialWithDialer(dialer, "tcp", addr, tlsConfig)
+	} else {
+		conn, err = dialer.Dial("tcp", addr)
+	}
+	if err != nil {
+		impl.logger.Errorw("Error in connecting to mail server", "Address", addr, "Error", err)
+		return err
+	}
+
+	client, err := smtp.NewClient(conn, impl.cfg.Host)
+	if err != nil {
+		_ = conn.Close()
+		return err
+	}
+	defer func(client *smtp.Client) {
+		_ = client.Close()
+	}(client)
+
+	if impl.cfg.Security == util.MailSecurityStartTLS || impl.cfg.Security == util.MailSecurityOpportunistic {
+		ok, _ := client.Extension("STARTTLS")
+		if ok {
+			if err = client.StartTLS(tlsConfig); err != nil {
+				return err
+			}
+		} else if impl.cfg.Security == util.MailSecurityStartTLS {
+			return errors.New("mail server does not support STARTTLS")
+		}
+	}
+
+	if impl.cfg.Password != "" {
+		username := impl.cfg.Username
+		if username == "" {
+			username = impl.cfg.From
+		}
+		if err = client.Auth(smtp.PlainAuth("", username, impl.cfg.Password, impl.cfg.Host)); err != nil {
+			return err
+		}
+	}
+
+	if err = client.Mail(from); err != nil {
+		return err
+	}
+	if err = client.Rcpt(receiver); err != nil {
+		return err
+	}
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err = w.Write(msg); err != nil {
+		return err
+	}
+	if err = w.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
+
+func writeQuotedPrintable(w io.Writer, content string) error {
+	qp := quotedprintable.NewWriter(w)
+	if _, err := qp.Write([]byte(content)); err != nil {
+		return err
+	}
+	return qp.Close()
 }
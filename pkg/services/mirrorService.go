@@ -0,0 +1,106 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"github.com/iraunit/get-link-backend/pkg/cryptography"
+	"github.com/iraunit/get-link-backend/pkg/repository"
+	"github.com/iraunit/get-link-backend/util"
+	"github.com/iraunit/get-link-backend/util/bean"
+	"go.uber.org/zap"
+	"strings"
+)
+
+type MirrorService interface {
+	GetRules(userEmail string) ([]bean.MirrorRule, error)
+	AddRule(userEmail string, rule *bean.MirrorRule) error
+	DeleteRule(userEmail string, id int) error
+}
+
+type MirrorServiceImpl struct {
+	logger          *zap.SugaredLogger
+	async           *util.Async
+	repository      repository.Repository
+	telegramService TelegramService
+	whatsappService WhatsappService
+}
+
+func NewMirrorServiceImpl(logger *zap.SugaredLogger, async *util.Async, repository repository.Repository, linkService LinkService, telegramService TelegramService, whatsappService WhatsappService) *MirrorServiceImpl {
+	impl := &MirrorServiceImpl{
+		logger:          logger,
+		async:           async,
+		repository:      repository,
+		telegramService: telegramService,
+		whatsappService: whatsappService,
+	}
+	linkService.RegisterAddLinkHook(impl.mirrorLink)
+	return impl
+}
+
+func (impl *MirrorServiceImpl) GetRules(userEmail string) ([]bean.MirrorRule, error) {
+	encryptedEmail, err := cryptography.EncryptData(userEmail, userEmail, impl.logger)
+	if err != nil {
+		impl.logger.Errorw("Error in encrypting data", "Error: ", err)
+		return nil, err
+	}
+	return impl.repository.GetMirrorRules(encryptedEmail)
+}
+
+func (impl *MirrorServiceImpl) AddRule(userEmail string, rule *bean.MirrorRule) error {
+	rule.Source = strings.TrimSpace(rule.Source)
+	if rule.Source == "" {
+		rule.Source = util.MirrorAnySource
+	}
+	if rule.Target != util.TELEGRAM && rule.Target != util.WHATSAPP {
+		return fmt.Errorf("target must be %s or %s", util.TELEGRAM, util.WHATSAPP)
+	}
+	if rule.Source == rule.Target {
+		return fmt.Errorf("links from %s cannot be mirrored back to %s", rule.Source, rule.Target)
+	}
+	encryptedEmail, err := cryptography.EncryptData(userEmail, userEmail, impl.logger)
+	if err != nil {
+		impl.logger.Errorw("Error in encrypting data", "Error: ", err)
+		return err
+	}
+	rule.ID = 0
+	rule.Email = encryptedEmail
+	return impl.repository.InsertMirrorRule(rule)
+}
+
+func (impl *MirrorServiceImpl) DeleteRule(userEmail string, id int) error {
+	encryptedEmail, err := cryptography.EncryptData(userEmail, userEmail, impl.logger)
+	if err != nil {
+		impl.logger.Errorw("Error in encrypting data", "Error: ", err)
+		return err
+	}
+	return impl.repository.DeleteMirrorRule(id, encryptedEmail)
+}
+
+func (impl *MirrorServiceImpl) mirrorLink(userEmail string, link bean.GetLink) {
+	impl.async.Run(func() {
+		rules, err := impl.GetRules(userEmail)
+		if err != nil {
+			return
+		}
+		sent := make(map[string]bool)
+		for _, rule := range rules {
+			// links that came in from the target channel are never echoed back to it
+			if sent[rule.Target] || link.UUID == rule.Target {
+				continue
+			}
+			if rule.Source != util.MirrorAnySource && rule.Source != link.UUID {
+				continue
+			}
+			switch rule.Target {
+			case util.TELEGRAM:
+				err = impl.telegramService.NotifyLink(userEmail, link.Message)
+			case util.WHATSAPP:
+				err = impl.whatsappService.NotifyLink(userEmail, link.Message)
+			}
+			if err != nil && !errors.Is(err, ErrNotificationMuted) {
+				impl.logger.Errorw("Error in mirroring link", "Target", rule.Target, "Error", err)
+			}
+			sent[rule.Target] = true
+		}
+	})
+}
@@ -0,0 +1,310 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/caarlos0/env"
+	"github.com/go-pg/pg"
+	"github.com/iraunit/get-link-backend/pkg/cryptography"
+	"github.com/iraunit/get-link-backend/pkg/messages"
+	"github.com/iraunit/get-link-backend/pkg/repository"
+	"github.com/iraunit/get-link-backend/util"
+	"github.com/iraunit/get-link-backend/util/bean"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+const notificationRateKey = "notification:rate:%s:%s"
+
+var ErrNotificationMuted = errors.New("muted by your notification preferences")
+
+var notificationEvents = []string{util.EventLinkReceived, util.EventFileReceived, util.EventShareDownloaded}
+
+// notificationDefaults are the events each channel sends until the user saves their own preferences.
+// Mail stays quiet for links and files, which the digest already covers.
+var notificationDefaults = map[string][]string{
+	util.MAIL:      {util.EventShareDownloaded},
+	util.TELEGRAM:  {util.EventLinkReceived, util.EventFileReceived},
+	util.WHATSAPP:  {util.EventLinkReceived, util.EventFileReceived},
+	util.WEBPUSH:   notificationEvents,
+	util.FCM:       notificationEvents,
+	util.WEBSOCKET: {util.EventLinkReceived},
+}
+
+var notificationChannels = []string{util.MAIL, util.TELEGRAM, util.WHATSAPP, util.WEBPUSH, util.FCM, util.WEBSOCKET}
+
+// Notifier delivers a notification to userEmail over one channel, no earlier than at.
+type Notifier func(userEmail string, notification bean.Notification, at time.Time) error
+
+type NotificationService interface {
+	GetPreferences(userEmail string) (*bean.NotificationPreferences, error)
+	SavePreferences(userEmail string, preferences *bean.NotificationPreferences) error
+	// GetLocale returns the language the user picked for bot replies, or "" to detect it.
+	GetLocale(userEmail string) string
+	// Check reports when an event may be sent over channel: now, or the end of the user's quiet hours.
+	// It returns ErrNotificationMuted when the event is turned off or the channel's hourly cap is reached.
+	Check(userEmail, channel, event string) (time.Time, error)
+	RegisterNotifier(channel string, notifier Notifier)
+	Notify(userEmail, event string, notification bean.Notification)
+	// Message renders the catalog keys <key>.title and <key>.message in the user's language.
+	Message(userEmail, key string, data messages.Data) bean.Notification
+}
+
+type NotificationServiceImpl struct {
+	logger     *zap.SugaredLogger
+	cfg        bean.NotificationCfg
+	client     *redis.Client
+	repository repository.Repository
+	catalog    messages.Catalog
+	lock       *sync.RWMutex
+	notifiers  map[string]Notifier
+}
+
+func NewNotificationServiceImpl(logger *zap.SugaredLogger, client *redis.Client, repository repository.Repository, catalog messages.Catalog) *NotificationServiceImpl {
+	cfg := bean.NotificationCfg{}
+	if err := env.Parse(&cfg); err != nil {
+		logger.Fatal("Error loading Cfg from env", "Error", zap.Error(err))
+	}
+	return &NotificationServiceImpl{
+		logger:     logger,
+		cfg:        cfg,
+		client:     client,
+		repository: repository,
+		catalog:    catalog,
+		lock:       &sync.RWMutex{},
+		notifiers:  make(map[string]Notifier),
+	}
+}
+
+func (impl *NotificationServiceImpl) GetPreferences(userEmail string) (*bean.NotificationPreferences, error) {
+	encryptedEmail, err := cryptography.EncryptData(userEmail, userEmail, impl.logger)
+	if err != nil {
+		impl.logger.Errorw("Error in encrypting data", "Error: ", err)
+		return nil, err
+	}
+	preferences := &bean.NotificationPreferences{Timezone: "UTC"}
+	settings, err := impl.repository.GetNotificationSettings(encryptedEmail)
+	if err == nil {
+		preferences.Timezone = settings.Timezone
+		preferences.QuietStart = settings.QuietStart
+		preferences.QuietEnd = settings.QuietEnd
+		preferences.Locale = settings.Locale
+	} else if !errors.Is(err, pg.ErrNoRows) {
+		return nil, err
+	}
+
+	saved, err := impl.repository.GetNotificationPreferences(encryptedEmail)
+	if err != nil {
+		return nil, err
+	}
+	for _, channel := range notificationChannels {
+		preference := bean.NotificationPreference{Channel: channel, Events: notificationDefaults[channel], MaxPerHour: impl.cfg.DefaultMaxPerHour}
+		if channel == util.WEBSOCKET {
+			// open apps sync every link unless the user sets a cap
+			preference.MaxPerHour = 0
+		}
+		for _, row := range saved {
+			if row.Channel == channel {
+				preference = row
+			}
+		}
+		if preference.Events == nil {
+			preference.Events = []string{}
+		}
+		preferences.Channels = append(preferences.Channels, preference)
+	}
+	return preferences, nil
+}
+
+// SavePreferences updates the quiet hours and the channels present in the request. Other channels keep their settings.
+func (impl *NotificationServiceImpl) SavePreferences(userEmail string, preferences *bean.NotificationPreferences) error {
+	if preferences.Timezone == "" {
+		preferences.Timezone = "UTC"
+	}
+	if _, err := time.LoadLocation(preferences.Timezone); err != nil {
+		return fmt.Errorf("unknown timezone %s", preferences.Timezone)
+	}
+	if (preferences.QuietStart == "") != (preferences.QuietEnd == "") {
+		return fmt.Errorf("quiet_start and quiet_end must be set together")
+	}
+	for _, value := range []string{preferences.QuietStart, preferences.QuietEnd} {
+		if _, err := parseClock(value); value != "" && err != nil {
+			return fmt.Errorf("quiet hours must be in HH:MM format")
+		}
+	}
+	if preferences.Locale != "" {
+		locale := impl.catalog.Match(preferences.Locale)
+		if locale == "" {
+			return fmt.Errorf("unsupported locale %s, supported locales are %s", preferences.Locale, strings.Join(impl.catalog.Locales(), ", "))
+		}
+		preferences.Locale = locale
+	}
+	for _, preference := range preferences.Channels {
+		if !slices.Contains(notificationChannels, preference.Channel) {
+			return fmt.Errorf("unknown channel %s", preference.Channel)
+		}
+		for _, event := range preference.Events {
+			if !slices.Contains(notificationEvents, event) {
+				return fmt.Errorf("unknown event %s", event)
+			}
+		}
+		if preference.MaxPerHour < 0 {
+			return fmt.Errorf("max_per_hour cannot be negative")
+		}
+	}
+
+	encryptedEmail, err := cryptography.EncryptData(userEmail, userEmail, impl.logger)
+	if err != nil {
+		impl.logger.Errorw("Error in encrypting data", "Error: ", err)
+		return err
+	}
+	rows := make([]bean.NotificationPreference, len(preferences.Channels))
+	for i, preference := range preferences.Channels {
+		rows[i] = bean.NotificationPreference{Email: encryptedEmail, Channel: preference.Channel, Events: preference.Events, MaxPerHour: preference.MaxPerHour}
+		if rows[i].Events == nil {
+			rows[i].Events = []string{}
+		}
+	}
+	return impl.repository.SaveNotificationPreferences(&bean.NotificationSettings{
+		Email:      encryptedEmail,
+		Timezone:   preferences.Timezone,
+		QuietStart: preferences.QuietStart,
+		QuietEnd:   preferences.QuietEnd,
+		Locale:     preferences.Locale,
+	}, rows)
+}
+
+func (impl *NotificationServiceImpl) GetLocale(userEmail string) string {
+	encryptedEmail, err := cryptography.EncryptData(userEmail, userEmail, impl.logger)
+	if err != nil {
+		return ""
+	}
+	settings, err := impl.repository.GetNotificationSettings(encryptedEmail)
+	if err != nil {
+		return ""
+	}
+	return settings.Locale
+}
+
+func (impl *NotificationServiceImpl) Check(userEmail, channel, event string) (time.Time, error) {
+	now := time.Now()
+	preferences, err := impl.GetPreferences(userEmail)
+	if err != nil {
+		// never lose a notification because preferences could not be read
+		return now, nil
+	}
+	var preference bean.NotificationPreference
+	for _, p := range preferences.Channels {
+		if p.Channel == channel {
+			preference = p
+		}
+	}
+	if !slices.Contains(preference.Events, event) {
+		return time.Time{}, ErrNotificationMuted
+	}
+	if preference.MaxPerHour > 0 && !impl.takeRate(userEmail, channel, preference.MaxPerHour) {
+		impl.logger.Infow("Notification rate cap reached", "Channel", channel, "Event", event)
+		return time.Time{}, ErrNotificationMuted
+	}
+	return quietHoursEnd(preferences, now), nil
+}
+
+// takeRate counts a notification in the channel's current one hour window and reports whether it is within max.
+func (impl *NotificationServiceImpl) takeRate(userEmail, channel string, max int) bool {
+	encryptedEmail, err := cryptography.EncryptData(userEmail, userEmail, impl.logger)
+	if err != nil {
+		return true
+	}
+	ctx := context.Background()
+	key := fmt.Sprintf(notificationRateKey, encryptedEmail, channel)
+	count, err := impl.client.Incr(ctx, key).Result()
+	if err != nil {
+		impl.logger.Errorw("Error in counting notification", "Error: ", err)
+		return true
+	}
+	if count == 1 {
+		impl.client.Expire(ctx, key, time.Hour)
+	}
+	return count <= int64(max)
+}
+
+func (impl *NotificationServiceImpl) RegisterNotifier(channel string, notifier Notifier) {
+	impl.lock.Lock()
+	defer impl.lock.Unlock()
+	impl.notifiers[channel] = notifier
+}
+
+// Notify sends the notification over every registered channel the user allows for event.
+func (impl *NotificationServiceImpl) Notify(userEmail, event string, notification bean.Notification) {
+	impl.lock.RLock()
+	notifiers := make(map[string]Notifier, len(impl.notifiers))
+	for channel, notifier := range impl.notifiers {
+		notifiers[channel] = notifier
+	}
+	impl.lock.RUnlock()
+
+	for channel, notifier := range notifiers {
+		at, err := impl.Check(userEmail, channel, event)
+		if err != nil {
+			continue
+		}
+		if err = notifier(userEmail, notification, at); err != nil {
+			impl.logger.Errorw("Error in sending notification", "Channel", channel, "Event", event, "Error", err)
+		}
+	}
+}
+
+func (impl *NotificationServiceImpl) Message(userEmail, key string, data messages.Data) bean.Notification {
+	locale := impl.GetLocale(userEmail)
+	return bean.Notification{
+		Title:   impl.catalog.Render(locale, key+".title", data),
+		Message: impl.catalog.Render(locale, key+".message", data),
+	}
+}
+
+// quietHoursEnd returns now, or when the quiet hours now falls in end. Quiet hours may span midnight.
+func quietHoursEnd(preferences *bean.NotificationPreferences, now time.Time) time.Time {
+	start, err := parseClock(preferences.QuietStart)
+	if err != nil {
+		return now
+	}
+	end, err := parseClock(preferences.QuietEnd)
+	if err != nil || start == end {
+		return now
+	}
+	location, err := time.LoadLocation(preferences.Timezone)
+	if err != nil {
+		location = time.UTC
+	}
+	local := now.In(location)
+	minute := time.Duration(local.Hour())*time.Hour + time.Duration(local.Minute())*time.Minute
+	endToday := time.Date(local.Year(), local.Month(), local.Day(), int(end/time.Hour), int(end%time.Hour/time.Minute), 0, 0, location)
+
+	if start < end {
+		if minute >= start && minute < end {
+			return endToday
+		}
+		return now
+	}
+	if minute >= start {
+		return endToday.AddDate(0, 0, 1)
+	}
+	if minute < end {
+		return endToday
+	}
+	return now
+}
+
+// parseClock parses HH:MM into the duration since midnight.
+func parseClock(value string) (time.Duration, error) {
+	t, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, err
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
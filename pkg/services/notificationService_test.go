@@ -0,0 +1,43 @@
+package services
+
+import (
+	"github.com/iraunit/get-link-backend/util/bean"
+	"testing"
+	"time"
+)
+
+func TestQuietHoursEnd(t *testing.T) {
+	kolkata, err := time.LoadLocation("Asia/Kolkata")
+	if err != nil {
+		t.Skipf("time zone data unavailable: %v", err)
+	}
+	at := func(day, hour, minute int) time.Time {
+		return time.Date(2024, time.March, day, hour, minute, 0, 0, time.UTC)
+	}
+	cases := []struct {
+		name        string
+		preferences bean.NotificationPreferences
+		now         time.Time
+		want        time.Time
+	}{
+		{"inside same-day window", bean.NotificationPreferences{QuietStart: "13:00", QuietEnd: "15:00"}, at(10, 14, 0), at(10, 15, 0)},
+		{"before same-day window", bean.NotificationPreferences{QuietStart: "13:00", QuietEnd: "15:00"}, at(10, 12, 59), at(10, 12, 59)},
+		{"at start of same-day window", bean.NotificationPreferences{QuietStart: "13:00", QuietEnd: "15:00"}, at(10, 13, 0), at(10, 15, 0)},
+		{"at end of same-day window", bean.NotificationPreferences{QuietStart: "13:00", QuietEnd: "15:00"}, at(10, 15, 0), at(10, 15, 0)},
+		{"overnight window before midnight", bean.NotificationPreferences{QuietStart: "22:00", QuietEnd: "07:00"}, at(10, 23, 30), at(11, 7, 0)},
+		{"overnight window after midnight", bean.NotificationPreferences{QuietStart: "22:00", QuietEnd: "07:00"}, at(11, 2, 0), at(11, 7, 0)},
+		{"at start of overnight window", bean.NotificationPreferences{QuietStart: "22:00", QuietEnd: "07:00"}, at(10, 22, 0), at(11, 7, 0)},
+		{"at end of overnight window", bean.NotificationPreferences{QuietStart: "22:00", QuietEnd: "07:00"}, at(11, 7, 0), at(11, 7, 0)},
+		{"outside overnight window", bean.NotificationPreferences{QuietStart: "22:00", QuietEnd: "07:00"}, at(10, 12, 0), at(10, 12, 0)},
+		{"non-UTC zone inside window", bean.NotificationPreferences{QuietStart: "22:00", QuietEnd: "07:00", Timezone: "Asia/Kolkata"}, at(10, 17, 0), time.Date(2024, time.March, 11, 7, 0, 0, 0, kolkata)},
+		{"non-UTC zone outside window", bean.NotificationPreferences{QuietStart: "22:00", QuietEnd: "07:00", Timezone: "Asia/Kolkata"}, at(10, 2, 0), at(10, 2, 0)},
+		{"unknown zone falls back to UTC", bean.NotificationPreferences{QuietStart: "22:00", QuietEnd: "07:00", Timezone: "Nowhere/Void"}, at(10, 23, 0), at(11, 7, 0)},
+		{"equal start and end", bean.NotificationPreferences{QuietStart: "22:00", QuietEnd: "22:00"}, at(10, 22, 0), at(10, 22, 0)},
+		{"unset quiet hours", bean.NotificationPreferences{}, at(10, 23, 0), at(10, 23, 0)},
+	}
+	for _, c := range cases {
+		if got := quietHoursEnd(&c.preferences, c.now); !got.Equal(c.want) {
+			t.Fatalf("%s: expected %v, got %v", c.name, c.want, got)
+		}
+	}
+}
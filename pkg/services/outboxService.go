@@ -0,0 +1,203 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/caarlos0/env"
+	"github.com/iraunit/get-link-backend/pkg/cryptography"
+	"github.com/iraunit/get-link-backend/pkg/repository"
+	"github.com/iraunit/get-link-backend/util"
+	"github.com/iraunit/get-link-backend/util/bean"
+	"go.uber.org/zap"
+	"sync"
+	"time"
+)
+
+// OutboxSender delivers one outbox message. Returning an error schedules a retry.
+type OutboxSender func(recipient string, payload []byte) error
+
+type OutboxService interface {
+	RegisterSender(channel string, sender OutboxSender)
+	// Enqueue returns once the message is stored. Delivery happens later, so callers report it as queued, not sent.
+	Enqueue(channel, recipient string, payload interface{}) error
+	EnqueueAt(channel, recipient string, payload interface{}, at time.Time) error
+	GetMessages(status string, limit int) ([]bean.OutboxMessage, error)
+	Retry(id int) error
+}
+
+type OutboxServiceImpl struct {
+	logger     *zap.SugaredLogger
+	cfg        bean.OutboxCfg
+	repository repository.Repository
+	lock       *sync.RWMutex
+	senders    map[string]OutboxSender
+	queue      chan bean.OutboxMessage
+}
+
+func NewOutboxServiceImpl(logger *zap.SugaredLogger, async *util.Async, repository repository.Repository) *OutboxServiceImpl {
+	cfg := bean.OutboxCfg{}
+	if err := env.Parse(&cfg); err != nil {
+		logger.Fatal("Error loading Cfg from env", "Error", zap.Error(err))
+	}
+	if cfg.EncryptionKey == "" {
+		logger.Fatal("ENCRYPTION_KEY is required to encrypt queued messages")
+	}
+	// without workers nothing drains the queue and enqueueing blocks forever
+	if cfg.Workers < 1 {
+		logger.Fatal("OUTBOX_WORKERS must be at least 1")
+	}
+	impl := &OutboxServiceImpl{
+		logger:     logger,
+		cfg:        cfg,
+		repository: repository,
+		lock:       &sync.RWMutex{},
+		senders:    make(map[string]OutboxSender),
+		queue:      make(chan bean.OutboxMessage, cfg.Workers),
+	}
+
+	for i := 0; i < cfg.Workers; i++ {
+		async.Run(impl.work)
+	}
+	async.Run(impl.poll)
+	async.Run(impl.prune)
+	return impl
+}
+
+func (impl *OutboxServiceImpl) RegisterSender(channel string, sender OutboxSender) {
+	impl.lock.Lock()
+	defer impl.lock.Unlock()
+	impl.senders[channel] = sender
+}
+
+func (impl *OutboxServiceImpl) Enqueue(channel, recipient string, payload interface{}) error {
+	return impl.EnqueueAt(channel, recipient, payload, time.Now())
+}
+
+func (impl *OutboxServiceImpl) EnqueueAt(channel, recipient string, payload interface{}, at time.Time) error {
+	payloadJson, err := json.Marshal(payload)
+	if err != nil {
+		impl.logger.Errorw("Error in marshalling outbox payload", "Channel", channel, "Error", err)
+		return err
+	}
+	encryptedRecipient, err := cryptography.EncryptData(impl.cfg.EncryptionKey, recipient, impl.logger)
+	if err != nil {
+		return err
+	}
+	encryptedPayload, err := cryptography.EncryptData(impl.cfg.EncryptionKey, string(payloadJson), impl.logger)
+	if err != nil {
+		return err
+	}
+	return impl.repository.InsertOutboxMessage(&bean.OutboxMessage{
+		Channel:       channel,
+		Recipient:     encryptedRecipient,
+		Payload:       encryptedPayload,
+		Status:        util.OutboxPending,
+		MaxAttempts:   impl.cfg.MaxAttempts,
+		NextAttemptAt: at,
+	})
+}
+
+func (impl *OutboxServiceImpl) GetMessages(status string, limit int) ([]bean.OutboxMessage, error) {
+	messages, err := impl.repository.GetOutboxMessages(status, limit)
+	if err != nil {
+		return nil, err
+	}
+	for i := range messages {
+		recipient, err := cryptography.DecryptData(impl.cfg.EncryptionKey, messages[i].Recipient, impl.logger)
+		if err != nil {
+			recipient = ""
+		}
+		messages[i].Recipient = util.MaskIdentity(recipient)
+	}
+	return messages, nil
+}
+
+func (impl *OutboxServiceImpl) Retry(id int) error {
+	return impl.repository.RetryOutboxMessage(id)
+}
+
+func (impl *OutboxServiceImpl) poll() {
+	ticker := time.NewTicker(impl.cfg.PollInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		messages, err := impl.repository.ClaimOutboxMessages(impl.cfg.Workers*4, impl.cfg.ClaimTimeout)
+		if err != nil {
+			continue
+		}
+		for _, message := range messages {
+			impl.queue <- message
+		}
+	}
+}
+
+// prune deletes sent messages after OUTBOX_SENT_RETENTION and failed ones after OUTBOX_FAILED_RETENTION every hour.
+func (impl *OutboxServiceImpl) prune() {
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+	for range ticker.C {
+		_ = impl.repository.DeleteOutboxMessagesBefore(util.OutboxSent, time.Now().Add(-impl.cfg.SentRetention))
+		_ = impl.repository.DeleteOutboxMessagesBefore(util.OutboxFailed, time.Now().Add(-impl.cfg.FailedRetention))
+	}
+}
+
+func (impl *OutboxServiceImpl) work() {
+	for message := range impl.queue {
+		impl.deliver(&message)
+	}
+}
+
+func (impl *OutboxServiceImpl) deliver(message *bean.OutboxMessage) {
+	impl.lock.RLock()
+	sender, ok := impl.senders[message.Channel]
+	impl.lock.RUnlock()
+
+	var err error
+	if !ok {
+		err = fmt.Errorf("no sender registered for channel %s", message.Channel)
+	} else {
+		err = impl.send(sender, message)
+	}
+
+	message.Attempts++
+	if err == nil {
+		message.Status = util.OutboxSent
+		message.LastError = ""
+	} else if message.Attempts >= message.MaxAttempts {
+		impl.logger.Errorw("Outbox message failed permanently", "ID", message.ID, "Channel", message.Channel, "Attempts", message.Attempts, "Error", err)
+		message.Status = util.OutboxFailed
+		message.LastError = err.Error()
+	} else {
+		message.Status = util.OutboxPending
+		message.LastError = err.Error()
+		message.NextAttemptAt = time.Now().Add(impl.backoff(message.Attempts))
+	}
+	_ = impl.repository.UpdateOutboxMessage(message)
+}
+
+func (impl *OutboxServiceImpl) send(sender OutboxSender, message *bean.OutboxMessage) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("sender panicked: %v", r)
+		}
+	}()
+	recipient, err := cryptography.DecryptData(impl.cfg.EncryptionKey, message.Recipient, impl.logger)
+	if err != nil {
+		return err
+	}
+	payload, err := cryptography.DecryptData(impl.cfg.EncryptionKey, message.Payload, impl.logger)
+	if err != nil {
+		return err
+	}
+	return sender(recipient, []byte(payload))
+}
+
+func (impl *OutboxServiceImpl) backoff(attempts int) time.Duration {
+	delay := impl.cfg.BaseBackoff
+	for i := 1; i < attempts && delay < impl.cfg.MaxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > impl.cfg.MaxBackoff {
+		delay = impl.cfg.MaxBackoff
+	}
+	return delay
+}
@@ -0,0 +1,94 @@
+package services
+
+import (
+	"fmt"
+	"github.com/iraunit/get-link-backend/util"
+	"mime"
+	"net/http"
+	"path"
+	"slices"
+)
+
+// outgoingMediaRule describes one way a channel can deliver a file. A nil mimeTypes accepts any type.
+type outgoingMediaRule struct {
+	kind      string
+	mimeTypes []string
+	maxBytes  int64
+}
+
+const (
+	mediaKindPhoto    = "photo"
+	mediaKindImage    = "image"
+	mediaKindVideo    = "video"
+	mediaKindAudio    = "audio"
+	mediaKindDocument = "document"
+)
+
+var storedFileApps = []string{util.TELEGRAM, util.WHATSAPP, util.SLACK, util.DISCORD, util.SMS, util.EMAIL, util.GETLINK}
+
+// Rules are tried in order, so a photo too large for sendPhoto still goes out as a document.
+var telegramMediaRules = []outgoingMediaRule{
+	{kind: mediaKindPhoto, mimeTypes: []string{"image/jpeg", "image/png", "image/webp"}, maxBytes: 10 << 20},
+	{kind: mediaKindDocument, maxBytes: 50 << 20},
+}
+
+var whatsappMediaRules = []outgoingMediaRule{
+	{kind: mediaKindImage, mimeTypes: []string{"image/jpeg", "image/png"}, maxBytes: 5 << 20},
+	{kind: mediaKindVideo, mimeTypes: []string{"video/mp4", "video/3gpp"}, maxBytes: 16 << 20},
+	{kind: mediaKindAudio, mimeTypes: []string{"audio/aac", "audio/mp4", "audio/mpeg", "audio/amr", "audio/ogg"}, maxBytes: 16 << 20},
+	{kind: mediaKindDocument, mimeTypes: []string{
+		"text/plain",
+		"application/pdf",
+		"application/msword",
+		"application/vnd.ms-excel",
+		"application/vnd.ms-powerpoint",
+		"application/vnd.openxmlformats-officedocument.wordprocessingml.document",
+		"application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+		"application/vnd.openxmlformats-officedocument.presentationml.presentation",
+	}, maxBytes: 100 << 20},
+}
+
+var slackMediaRules = []outgoingMediaRule{
+	{kind: mediaKindDocument, maxBytes: 1 << 30},
+}
+
+var discordMediaRules = []outgoingMediaRule{
+	{kind: mediaKindDocument, maxBytes: 10 << 20},
+}
+
+// resolveOutgoingMedia picks the first rule that accepts the file's MIME type and size.
+func resolveOutgoingMedia(rules []outgoingMediaRule, fileName string, data []byte) (string, string, error) {
+	return resolveOutgoingMediaOfSize(rules, fileName, int64(len(data)), data)
+}
+
+// resolveOutgoingMediaOfSize resolves a file before it is read. Without data the type comes from the extension alone.
+func resolveOutgoingMediaOfSize(rules []outgoingMediaRule, fileName string, size int64, data []byte) (string, string, error) {
+	mimeType, err := util.GetMimeTypeFromExtension(path.Ext(fileName))
+	if err != nil && data == nil {
+		mimeType = "application/octet-stream"
+	} else if err != nil {
+		mimeType = http.DetectContentType(data)
+	}
+	if mediaType, _, err := mime.ParseMediaType(mimeType); err == nil {
+		mimeType = mediaType
+	}
+
+	typeAllowed := false
+	for _, rule := range rules {
+		if rule.mimeTypes != nil && !slices.Contains(rule.mimeTypes, mimeType) {
+			continue
+		}
+		typeAllowed = true
+		if size <= rule.maxBytes {
+			return rule.kind, mimeType, nil
+		}
+	}
+	if !typeAllowed {
+		return "", "", fmt.Errorf("files of type %s cannot be sent to this app", mimeType)
+	}
+	return "", "", fmt.Errorf("%s is too large to send to this app", fileName)
+}
+
+func isStoredFileApp(appName string) bool {
+	return slices.Contains(storedFileApps, appName)
+}
@@ -0,0 +1,258 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/caarlos0/env"
+	"github.com/go-resty/resty/v2"
+	"github.com/iraunit/get-link-backend/pkg/fileManager"
+	"github.com/iraunit/get-link-backend/pkg/messages"
+	"github.com/iraunit/get-link-backend/pkg/repository"
+	tokenService2 "github.com/iraunit/get-link-backend/pkg/services/tokenService"
+	"github.com/iraunit/get-link-backend/util"
+	"github.com/iraunit/get-link-backend/util/bean"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+	"path"
+	"regexp"
+	"strconv"
+)
+
+const (
+	slackMaxInboundFileBytes = 50 << 20
+	slackEventKey            = "slack:event:%s"
+)
+
+// slackLinkRegex matches Slack's <url|label> and <mailto:address|label> markup, which hides the raw value.
+var slackLinkRegex = regexp.MustCompile(`<(?:mailto:)?([^|>]+)(?:\|[^>]*)?>`)
+
+type SlackService interface {
+	Channel
+	HandleEvent(envelope *bean.SlackEventEnvelope)
+	HandleCommand(command *bean.SlackSlashCommand) string
+}
+
+type SlackServiceImpl struct {
+	logger *zap.SugaredLogger
+	cfg    bean.SlackCfg
+	async  *util.Async
+	ctx    context.Context
+	redis  *redis.Client
+	client *resty.Client
+	outbox OutboxService
+	inbox  *messengerInbox
+}
+
+func NewSlackServiceImpl(logger *zap.SugaredLogger, async *util.Async, client *redis.Client, repository repository.Repository, linkService LinkService, fileManager fileManager.FileManager, tokenService tokenService2.TokenService, mailService MailService, outbox OutboxService, notificationService NotificationService, catalog messages.Catalog, tenantService TenantService) *SlackServiceImpl {
+	cfg := bean.SlackCfg{}
+	if err := env.Parse(&cfg); err != nil {
+		logger.Fatal("Error loading Cfg from env", "Error", zap.Error(err))
+	}
+	impl := &SlackServiceImpl{
+		logger: logger,
+		cfg:    cfg,
+		async:  async,
+		ctx:    context.Background(),
+		redis:  client,
+		client: resty.New().SetBaseURL(cfg.ApiUrl).SetAuthToken(cfg.BotToken),
+		outbox: outbox,
+		inbox: &messengerInbox{
+			channel:        util.SLACK,
+			displayName:    "Slack",
+			baseUrl:        cfg.BaseUrl,
+			freeLimitMB:    util.FreeChannelFileLimitSizeMB,
+			premiumLimitMB: util.PremiumChannelFileLimitSizeMB,
+			logger:         logger,
+			repository:     repository,
+			linkService:    linkService,
+			fileManager:    fileManager,
+			tokenService:   tokenService,
+			mailService:    mailService,
+			notification:   notificationService,
+			catalog:        catalog.ForTenant(tenantService.Default()),
+		},
+	}
+	outbox.RegisterSender(util.SLACK, impl.sendOutboxMessage)
+	return impl
+}
+
+func (impl *SlackServiceImpl) Name() string {
+	return util.SLACK
+}
+
+// HandleEvent processes direct messages to the app. Slack expects an answer within 3 seconds, so work is done async.
+func (impl *SlackServiceImpl) HandleEvent(envelope *bean.SlackEventEnvelope) {
+	event := envelope.Event
+	if event.Type != "message" || event.ChannelType != "im" || event.BotID != "" || event.User == "" {
+		return
+	}
+	if event.Subtype != "" && event.Subtype != "file_share" {
+		return
+	}
+	if !impl.isNewEvent(envelope.EventID) {
+		return
+	}
+	impl.async.Run(func() {
+		message := &bean.InboundMessage{Identity: event.User, Text: slackText(event.Text)}
+		for _, file := range event.Files {
+			data, err := impl.downloadFile(file)
+			if err != nil {
+				impl.logger.Errorw("Error in downloading slack file", "File", file.ID, "Error", err)
+				_ = impl.SendText(event.User, impl.inbox.render(event.User, "channel.download_failed", messages.Data{"FileName": file.Name}))
+				return
+			}
+			message.Files = append(message.Files, bean.InboundFile{Name: file.Name, Data: data})
+		}
+		_ = impl.SendText(event.User, impl.inbox.reply(impl, message))
+	})
+}
+
+// isNewEvent remembers the event id, so retries of an event that was already delivered are skipped.
+// Redis errors count as new.
+func (impl *SlackServiceImpl) isNewEvent(eventID string) bool {
+	if eventID == "" {
+		return true
+	}
+	isNew, err := impl.redis.SetNX(impl.ctx, fmt.Sprintf(slackEventKey, eventID), 1, impl.cfg.EventDedupTTL).Result()
+	if err != nil {
+		impl.logger.Errorw("Error in checking slack event id", "ID", eventID, "Error", err)
+		return true
+	}
+	if !isNew {
+		impl.logger.Infow("Skipping duplicate slack event", "ID", eventID)
+	}
+	return isNew
+}
+
+func (impl *SlackServiceImpl) HandleCommand(command *bean.SlackSlashCommand) string {
+	return impl.inbox.reply(impl, &bean.InboundMessage{Identity: command.UserID, Name: command.UserName, Text: slackText(command.Text)})
+}
+
+func (impl *SlackServiceImpl) Receive(message *bean.InboundMessage) error {
+	emails, err := impl.inbox.getEmails(message.Identity)
+	if err != nil {
+		return err
+	}
+	_, err = impl.inbox.save(emails, message)
+	return err
+}
+
+func (impl *SlackServiceImpl) VerifyIdentity(userEmail, identity string) error {
+	return impl.inbox.link(userEmail, identity)
+}
+
+func (impl *SlackServiceImpl) SendText(identity, text string) error {
+	return impl.outbox.Enqueue(util.SLACK, identity, bean.OutboxTextMessage{Text: text})
+}
+
+func (impl *SlackServiceImpl) Render(identity, key string, data messages.Data) string {
+	return impl.inbox.render(identity, key, data)
+}
+
+func (impl *SlackServiceImpl) sendOutboxMessage(recipient string, payload []byte) error {
+	var message bean.OutboxTextMessage
+	if err := json.Unmarshal(payload, &message); err != nil {
+		return err
+	}
+	_, err := impl.callApi("chat.postMessage", nil, map[string]string{"channel": recipient, "text": message.Text})
+	return err
+}
+
+// SendFile uses the external upload flow: reserve an upload url, post the bytes, then share the file in the DM.
+func (impl *SlackServiceImpl) SendFile(identity, fileName string, data []byte) error {
+	if _, _, err := resolveOutgoingMedia(slackMediaRules, fileName, data); err != nil {
+		return err
+	}
+	conversation, err := impl.callApi("conversations.open", nil, map[string]string{"users": identity})
+	if err != nil {
+		return fmt.Errorf("error in opening Slack conversation. Please try again later")
+	}
+	upload, err := impl.callApi("files.getUploadURLExternal", map[string]string{"filename": path.Base(fileName), "length": strconv.Itoa(len(data))}, nil)
+	if err != nil {
+		return fmt.Errorf("error in uploading file to Slack. Please try again later")
+	}
+	resp, err := impl.client.R().SetBody(data).Post(upload.UploadUrl)
+	if err != nil || resp.IsError() {
+		impl.logger.Errorw("Error in uploading slack file", "Error", err)
+		return fmt.Errorf("error in uploading file to Slack. Please try again later")
+	}
+	_, err = impl.callApi("files.completeUploadExternal", nil, map[string]interface{}{
+		"files":      []map[string]string{{"id": upload.FileID, "title": path.Base(fileName)}},
+		"channel_id": conversation.Channel.ID,
+	})
+	if err != nil {
+		return fmt.Errorf("error in sending file to Slack. Please try again later")
+	}
+	return nil
+}
+
+func (impl *SlackServiceImpl) GetLinkedAccounts(userEmail string) ([]bean.LinkedAccount, error) {
+	identities, err := impl.inbox.getIdentities(userEmail)
+	if err != nil {
+		return nil, err
+	}
+	var accounts []bean.LinkedAccount
+	for _, identity := range identities {
+		account := bean.LinkedAccount{Channel: util.SLACK, Identity: util.MaskIdentity(identity), Address: identity}
+		if user, err := impl.callApi("users.info", map[string]string{"user": identity}, nil); err == nil {
+			account.Name = user.User.RealName
+		}
+		accounts = append(accounts, account)
+	}
+	return accounts, nil
+}
+
+func (impl *SlackServiceImpl) UnlinkAccount(userEmail string, account bean.LinkedAccount) error {
+	if err := impl.inbox.unlink(userEmail, account.Address); err != nil {
+		return err
+	}
+	text := impl.inbox.catalog.Render(impl.inbox.notification.GetLocale(userEmail), "channel.unlinked_from_web", messages.Data{"Channel": impl.inbox.displayName, "Email": userEmail})
+	return impl.SendText(account.Address, text)
+}
+
+func (impl *SlackServiceImpl) downloadFile(file bean.SlackFile) ([]byte, error) {
+	if file.Size > slackMaxInboundFileBytes {
+		return nil, fmt.Errorf("%s is too large", file.Name)
+	}
+	resp, err := impl.client.R().Get(file.UrlPrivateDownload)
+	if err != nil {
+		return nil, err
+	}
+	if resp.IsError() {
+		return nil, fmt.Errorf("status : %d", resp.StatusCode())
+	}
+	return resp.Body(), nil
+}
+
+// callApi calls a Web API method. Some methods only accept form bodies, so form is sent when body is nil.
+func (impl *SlackServiceImpl) callApi(method string, form map[string]string, body interface{}) (*bean.SlackApiResponse, error) {
+	request := impl.client.R()
+	if body != nil {
+		request.SetHeader("Content-Type", "application/json; charset=utf-8").SetBody(body)
+	} else {
+		request.SetFormData(form)
+	}
+	resp, err := request.Post("/" + method)
+	if err != nil {
+		impl.logger.Errorw("Error in calling slack api", "Method", method, "Error", err)
+		return nil, err
+	}
+	if resp.IsError() {
+		impl.logger.Errorw("Error in calling slack api. status not ok.", "Method", method, "Status", resp.StatusCode())
+		return nil, fmt.Errorf("status : %d", resp.StatusCode())
+	}
+	var result bean.SlackApiResponse
+	if err = json.Unmarshal(resp.Body(), &result); err != nil {
+		return nil, err
+	}
+	if !result.Ok {
+		impl.logger.Errorw("Error in calling slack api", "Method", method, "Error", result.Error)
+		return nil, fmt.Errorf("slack %s: %s", method, result.Error)
+	}
+	return &result, nil
+}
+
+func slackText(text string) string {
+	return slackLinkRegex.ReplaceAllString(text, "$1")
+}
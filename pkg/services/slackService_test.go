@@ -0,0 +1,168 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/go-pg/pg"
+	"github.com/go-resty/resty/v2"
+	"github.com/iraunit/get-link-backend/util"
+	"github.com/iraunit/get-link-backend/util/bean"
+	"go.uber.org/zap"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func newTestSlackService(server *httptest.Server) (*SlackServiceImpl, *fakeOutbox) {
+	outbox := &fakeOutbox{}
+	impl := &SlackServiceImpl{
+		logger: zap.NewNop().Sugar(),
+		client: resty.New().SetBaseURL(server.URL).SetAuthToken("xoxb-test"),
+		outbox: outbox,
+		inbox:  newTestInbox(util.SLACK),
+	}
+	outbox.RegisterSender(util.SLACK, impl.sendOutboxMessage)
+	return impl, outbox
+}
+
+func TestSlackReceiveSavesTextAndFiles(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	defer server.Close()
+	impl, _ := newTestSlackService(server)
+	if err := impl.VerifyIdentity("someone@example.com", "U123"); err != nil {
+		t.Fatal(err)
+	}
+
+	err := impl.Receive(&bean.InboundMessage{
+		Identity: "U123",
+		Text:     "https://example.com",
+		Files:    []bean.InboundFile{{Name: "notes.txt", Data: []byte("hello")}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	links := impl.inbox.linkService.(*fakeLinkService).links
+	if len(links) != 1 || links[0].Receiver != "someone@example.com" || links[0].Message != "https://example.com" || links[0].UUID != util.SLACK {
+		t.Fatalf("unexpected links %+v", links)
+	}
+	files := impl.inbox.fileManager.(*fakeFileManager).files
+	if len(files) != 1 || files[0].email != "someone@example.com" || !strings.HasSuffix(files[0].path, "/slack/notes.txt.bin") || string(files[0].data) != "hello" {
+		t.Fatalf("unexpected files %+v", files)
+	}
+}
+
+func TestSlackFolderLimitUsesPremiumEmail(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	defer server.Close()
+	impl, _ := newTestSlackService(server)
+	if err := impl.VerifyIdentity("someone@example.com", "U123"); err != nil {
+		t.Fatal(err)
+	}
+	manager := impl.inbox.fileManager.(*fakeFileManager)
+	manager.folderSizeMB = util.FreeChannelFileLimitSizeMB + 1
+	message := &bean.InboundMessage{Identity: "U123", Files: []bean.InboundFile{{Name: "notes.txt", Data: []byte("hello")}}}
+
+	if err := impl.Receive(message); err != nil {
+		t.Fatal(err)
+	}
+	if len(manager.cleared) != 1 {
+		t.Fatalf("expected the free folder over its limit to be cleared, got %v", manager.cleared)
+	}
+
+	impl.inbox.repository.(*fakeRepository).premium = map[string]bool{"someone@example.com": true}
+	manager.cleared = nil
+	if err := impl.Receive(message); err != nil {
+		t.Fatal(err)
+	}
+	if len(manager.cleared) != 0 {
+		t.Fatalf("expected the premium folder to be kept, got %v", manager.cleared)
+	}
+}
+
+func TestSlackReceiveUnlinkedIdentity(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	defer server.Close()
+	impl, _ := newTestSlackService(server)
+
+	err := impl.Receive(&bean.InboundMessage{Identity: "U404", Text: "https://example.com"})
+	if !errors.Is(err, pg.ErrNoRows) {
+		t.Fatalf("expected pg.ErrNoRows, got %v", err)
+	}
+}
+
+func TestSlackSendText(t *testing.T) {
+	var body map[string]string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/chat.postMessage" || r.Header.Get("Authorization") != "Bearer xoxb-test" {
+			t.Errorf("unexpected request %s with %q", r.URL.Path, r.Header.Get("Authorization"))
+		}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		_, _ = w.Write([]byte(`{"ok":true}`))
+	}))
+	defer server.Close()
+	impl, outbox := newTestSlackService(server)
+
+	if err := impl.SendText("U123", "hello"); err != nil {
+		t.Fatal(err)
+	}
+	if len(outbox.messages) != 1 || outbox.messages[0].recipient != "U123" {
+		t.Fatalf("expected one queued message, got %+v", outbox.messages)
+	}
+	outbox.flush(t)
+	if body["channel"] != "U123" || body["text"] != "hello" {
+		t.Fatalf("unexpected chat.postMessage body %v", body)
+	}
+}
+
+func TestSlackSendTextApiError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"ok":false,"error":"channel_not_found"}`))
+	}))
+	defer server.Close()
+	impl, _ := newTestSlackService(server)
+
+	payload, _ := json.Marshal(bean.OutboxTextMessage{Text: "hello"})
+	if err := impl.sendOutboxMessage("U123", payload); err == nil || !strings.Contains(err.Error(), "channel_not_found") {
+		t.Fatalf("expected channel_not_found, got %v", err)
+	}
+}
+
+func TestSlackSendFile(t *testing.T) {
+	var uploaded []byte
+	var completed map[string]interface{}
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/conversations.open":
+			_, _ = w.Write([]byte(`{"ok":true,"channel":{"id":"D123"}}`))
+		case "/files.getUploadURLExternal":
+			if r.FormValue("filename") != "notes.txt" || r.FormValue("length") != "5" {
+				t.Errorf("unexpected upload request %v", r.Form)
+			}
+			_, _ = w.Write([]byte(`{"ok":true,"upload_url":"` + server.URL + `/upload","file_id":"F123"}`))
+		case "/upload":
+			uploaded, _ = io.ReadAll(r.Body)
+		case "/files.completeUploadExternal":
+			_ = json.NewDecoder(r.Body).Decode(&completed)
+			_, _ = w.Write([]byte(`{"ok":true}`))
+		default:
+			t.Errorf("unexpected request %s", r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+	impl, _ := newTestSlackService(server)
+
+	if err := impl.SendFile("U123", "folder/notes.txt", []byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+	if string(uploaded) != "hello" {
+		t.Fatalf("unexpected upload %q", uploaded)
+	}
+	if completed["channel_id"] != "D123" {
+		t.Fatalf("unexpected completeUploadExternal body %v", completed)
+	}
+}
@@ -0,0 +1,268 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/caarlos0/env"
+	"github.com/go-pg/pg"
+	"github.com/go-resty/resty/v2"
+	"github.com/iraunit/get-link-backend/pkg/cryptography"
+	"github.com/iraunit/get-link-backend/pkg/fileManager"
+	"github.com/iraunit/get-link-backend/pkg/messages"
+	"github.com/iraunit/get-link-backend/pkg/repository"
+	tokenService2 "github.com/iraunit/get-link-backend/pkg/services/tokenService"
+	"github.com/iraunit/get-link-backend/util"
+	"github.com/iraunit/get-link-backend/util/bean"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+	"io"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	// Twilio rejects bodies longer than 1600 characters.
+	maxSmsBodyLength     = 1600
+	smsMaxInboundBytes   = 5 << 20
+	smsSendMessageFormat = "/2010-04-01/Accounts/%s/Messages.json"
+	smsReplyRateKey      = "sms:reply:%s"
+)
+
+type SmsService interface {
+	Channel
+	HandleMessage(message *bean.SmsMessage)
+	SendMessageFromWeb(userEmail string, message string) error
+	GetIfUserIsPremium(userEmail string) bool
+}
+
+type SmsServiceImpl struct {
+	logger     *zap.SugaredLogger
+	cfg        bean.SmsCfg
+	async      *util.Async
+	ctx        context.Context
+	redis      *redis.Client
+	client     *resty.Client
+	media      *resty.Client
+	repository repository.Repository
+	outbox     OutboxService
+	inbox      *messengerInbox
+}
+
+func NewSmsServiceImpl(logger *zap.SugaredLogger, async *util.Async, client *redis.Client, repository repository.Repository, linkService LinkService, fileManager fileManager.FileManager, tokenService tokenService2.TokenService, mailService MailService, outbox OutboxService, notificationService NotificationService, catalog messages.Catalog, tenantService TenantService) *SmsServiceImpl {
+	cfg := bean.SmsCfg{}
+	if err := env.Parse(&cfg); err != nil {
+		logger.Fatal("Error loading Cfg from env", "Error", zap.Error(err))
+	}
+	impl := &SmsServiceImpl{
+		logger:     logger,
+		cfg:        cfg,
+		async:      async,
+		ctx:        context.Background(),
+		redis:      client,
+		client:     resty.New().SetBaseURL(cfg.ApiUrl).SetBasicAuth(cfg.AccountSid, cfg.AuthToken),
+		media:      resty.New(),
+		repository: repository,
+		outbox:     outbox,
+		inbox: &messengerInbox{
+			channel:        util.SMS,
+			displayName:    "SMS",
+			baseUrl:        cfg.BaseUrl,
+			freeLimitMB:    util.FreeChannelFileLimitSizeMB,
+			premiumLimitMB: util.PremiumChannelFileLimitSizeMB,
+			logger:         logger,
+			repository:     repository,
+			linkService:    linkService,
+			fileManager:    fileManager,
+			tokenService:   tokenService,
+			mailService:    mailService,
+			notification:   notificationService,
+			catalog:        catalog.ForTenant(tenantService.Default()),
+		},
+	}
+	outbox.RegisterSender(util.SMS, impl.sendOutboxMessage)
+	return impl
+}
+
+func (impl *SmsServiceImpl) Name() string {
+	return util.SMS
+}
+
+// HandleMessage saves an inbound SMS or MMS and texts back a confirmation. Media downloads can be slow, so it runs async.
+func (impl *SmsServiceImpl) HandleMessage(sms *bean.SmsMessage) {
+	impl.async.Run(func() {
+		if !impl.mayReply(sms.From) {
+			impl.logger.Infow("Dropping sms from unlinked number over the reply limit", "MessageSid", sms.MessageSid)
+			return
+		}
+		message := &bean.InboundMessage{Identity: sms.From, Text: sms.Body}
+		for i, media := range sms.Media {
+			data, err := impl.downloadMedia(media)
+			if err != nil {
+				impl.logger.Errorw("Error in downloading sms media", "MessageSid", sms.MessageSid, "Error", err)
+				_ = impl.SendText(sms.From, impl.inbox.render(sms.From, "channel.download_failed", nil))
+				return
+			}
+			extension, err := util.GetFileExtension(media.ContentType)
+			if err != nil {
+				extension = ".bin"
+			}
+			message.Files = append(message.Files, bean.InboundFile{Name: fmt.Sprintf("%s-%d%s", sms.MessageSid, i, extension), Data: data})
+		}
+		_ = impl.SendText(sms.From, impl.inbox.reply(impl, message))
+	})
+}
+
+// mayReply reports whether number may be answered. Linked numbers always may, others only UnlinkedReplyLimit times an
+// hour, so a spoofed sender cannot turn Get-Link into a source of texts and verification mails.
+func (impl *SmsServiceImpl) mayReply(number string) bool {
+	_, err := impl.inbox.getEmails(number)
+	if !errors.Is(err, pg.ErrNoRows) {
+		return true
+	}
+	encryptedNumber, err := cryptography.EncryptData(number, number, impl.logger)
+	if err != nil {
+		return false
+	}
+	key := fmt.Sprintf(smsReplyRateKey, encryptedNumber)
+	count, err := impl.redis.Incr(impl.ctx, key).Result()
+	if err != nil {
+		impl.logger.Errorw("Error in counting sms replies", "Error", err)
+		return false
+	}
+	if count == 1 {
+		impl.redis.Expire(impl.ctx, key, time.Hour)
+	}
+	return count <= int64(impl.cfg.UnlinkedReplyLimit)
+}
+
+func (impl *SmsServiceImpl) Receive(message *bean.InboundMessage) error {
+	emails, err := impl.inbox.getEmails(message.Identity)
+	if err != nil {
+		return err
+	}
+	_, err = impl.inbox.save(emails, message)
+	return err
+}
+
+func (impl *SmsServiceImpl) VerifyIdentity(userEmail, number string) error {
+	return impl.inbox.link(userEmail, number)
+}
+
+func (impl *SmsServiceImpl) SendText(number, text string) error {
+	return impl.outbox.Enqueue(util.SMS, number, bean.OutboxTextMessage{Text: text})
+}
+
+func (impl *SmsServiceImpl) Render(number, key string, data messages.Data) string {
+	return impl.inbox.render(number, key, data)
+}
+
+func (impl *SmsServiceImpl) sendOutboxMessage(number string, payload []byte) error {
+	var message bean.OutboxTextMessage
+	if err := json.Unmarshal(payload, &message); err != nil {
+		return err
+	}
+	body := []rune(message.Text)
+	if len(body) > maxSmsBodyLength {
+		body = append(body[:maxSmsBodyLength-3], []rune("...")...)
+	}
+	var sent bean.SmsSendResponse
+	resp, err := impl.client.R().
+		SetFormData(map[string]string{"To": number, "From": impl.cfg.FromNumber, "Body": string(body)}).
+		SetResult(&sent).
+		SetError(&sent).
+		Post(fmt.Sprintf(smsSendMessageFormat, impl.cfg.AccountSid))
+	if err != nil {
+		impl.logger.Errorw("Error in sending sms", "Error", err)
+		return err
+	}
+	if resp.IsError() {
+		impl.logger.Errorw("Error in sending sms. status not ok.", "Status", resp.StatusCode(), "Message", sent.Message)
+		return fmt.Errorf("status : %d", resp.StatusCode())
+	}
+	return nil
+}
+
+// SendFile is not supported: MMS needs a public media url and is not available in most countries.
+func (impl *SmsServiceImpl) SendFile(number, fileName string, data []byte) error {
+	return fmt.Errorf("files cannot be sent over SMS")
+}
+
+func (impl *SmsServiceImpl) SendMessageFromWeb(userEmail string, message string) error {
+	numbers, err := impl.inbox.getIdentities(userEmail)
+	if err != nil {
+		return err
+	}
+	if len(numbers) == 0 {
+		return errors.New(impl.inbox.catalog.Render(impl.inbox.notification.GetLocale(userEmail), "sms.not_connected", nil))
+	}
+	for _, number := range numbers {
+		if err = impl.SendText(number, message); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (impl *SmsServiceImpl) GetIfUserIsPremium(userEmail string) bool {
+	return impl.repository.IsUserPremiumUser(userEmail)
+}
+
+func (impl *SmsServiceImpl) GetLinkedAccounts(userEmail string) ([]bean.LinkedAccount, error) {
+	numbers, err := impl.inbox.getIdentities(userEmail)
+	if err != nil {
+		return nil, err
+	}
+	var accounts []bean.LinkedAccount
+	for _, number := range numbers {
+		accounts = append(accounts, bean.LinkedAccount{Channel: util.SMS, Identity: util.MaskIdentity(number), Address: number})
+	}
+	return accounts, nil
+}
+
+func (impl *SmsServiceImpl) UnlinkAccount(userEmail string, account bean.LinkedAccount) error {
+	if err := impl.inbox.unlink(userEmail, account.Address); err != nil {
+		return err
+	}
+	return impl.SendText(account.Address, impl.inbox.catalog.Render(impl.inbox.notification.GetLocale(userEmail), "sms.unlinked_from_web", messages.Data{"Email": userEmail}))
+}
+
+// downloadMedia fetches MMS media, which Twilio serves behind the account's basic auth. The credentials are only sent
+// to Twilio, since the url comes from the webhook.
+func (impl *SmsServiceImpl) downloadMedia(media bean.SmsMedia) ([]byte, error) {
+	request := impl.media.R().SetDoNotParseResponse(true)
+	if impl.isTwilioUrl(media.Url) {
+		request.SetBasicAuth(impl.cfg.AccountSid, impl.cfg.AuthToken)
+	}
+	resp, err := request.Get(media.Url)
+	if err != nil {
+		return nil, err
+	}
+	body := resp.RawBody()
+	defer body.Close()
+	if resp.IsError() {
+		return nil, fmt.Errorf("status : %d", resp.StatusCode())
+	}
+	data, err := io.ReadAll(io.LimitReader(body, smsMaxInboundBytes+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > smsMaxInboundBytes {
+		return nil, fmt.Errorf("attachment is too large")
+	}
+	return data, nil
+}
+
+func (impl *SmsServiceImpl) isTwilioUrl(rawUrl string) bool {
+	mediaUrl, err := url.Parse(rawUrl)
+	if err != nil {
+		return false
+	}
+	apiUrl, err := url.Parse(impl.cfg.ApiUrl)
+	if err == nil && mediaUrl.Host == apiUrl.Host {
+		return true
+	}
+	return mediaUrl.Scheme == "https" && strings.HasSuffix(mediaUrl.Hostname(), ".twilio.com")
+}
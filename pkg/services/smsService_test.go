@@ -0,0 +1,50 @@
+package services
+
+import (
+	"github.com/go-resty/resty/v2"
+	"github.com/iraunit/get-link-backend/util/bean"
+	"go.uber.org/zap"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func newTestSmsService(apiUrl string) *SmsServiceImpl {
+	return &SmsServiceImpl{
+		logger: zap.NewNop().Sugar(),
+		cfg:    bean.SmsCfg{AccountSid: "AC123", AuthToken: "test-token", ApiUrl: apiUrl},
+		media:  resty.New(),
+	}
+}
+
+func TestSmsDownloadMediaSendsCredentialsOnlyToTwilio(t *testing.T) {
+	var authorized bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _, authorized = r.BasicAuth()
+		_, _ = w.Write([]byte("media"))
+	}))
+	defer server.Close()
+
+	impl := newTestSmsService(server.URL)
+	if _, err := impl.downloadMedia(bean.SmsMedia{Url: server.URL + "/media"}); err != nil || !authorized {
+		t.Fatalf("expected an authorized download from the api host, got authorized %v, error %v", authorized, err)
+	}
+
+	impl = newTestSmsService("https://api.twilio.com")
+	if _, err := impl.downloadMedia(bean.SmsMedia{Url: server.URL + "/media"}); err != nil || authorized {
+		t.Fatalf("expected an anonymous download from another host, got authorized %v, error %v", authorized, err)
+	}
+}
+
+func TestSmsDownloadMediaRejectsLargeMedia(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(strings.Repeat("a", smsMaxInboundBytes+1)))
+	}))
+	defer server.Close()
+
+	impl := newTestSmsService(server.URL)
+	if _, err := impl.downloadMedia(bean.SmsMedia{Url: server.URL}); err == nil {
+		t.Fatal("expected media over the limit to be rejected")
+	}
+}
@@ -0,0 +1,228 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/go-pg/pg"
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+	"github.com/iraunit/get-link-backend/pkg/cryptography"
+	"github.com/iraunit/get-link-backend/pkg/messages"
+	"github.com/iraunit/get-link-backend/util"
+	"github.com/iraunit/get-link-backend/util/bean"
+	"github.com/redis/go-redis/v9"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	telegramCallbackPrefix = "cb:"
+	telegramCallbackKey    = "telegram:callback:%s"
+	telegramCallbackTTL    = 7 * 24 * time.Hour
+	telegramReminderDelay  = 24 * time.Hour
+	telegramWorkTag        = "work"
+)
+
+const (
+	telegramActionDeleteLink = "delete-link"
+	telegramActionTagLink    = "tag-link"
+	telegramActionRemind     = "remind"
+	telegramActionShareFile  = "share-file"
+	telegramActionDeleteFile = "delete-file"
+)
+
+var telegramUrlRegex = regexp.MustCompile(`https?://\S+`)
+
+// linkButtons builds the keyboard attached to a saved link confirmation.
+func (impl *TelegramImpl) linkButtons(locale string, senderId int64, linkIDs []int, message string) [][]bean.TelegramButton {
+	var firstRow []bean.TelegramButton
+	if link := telegramUrlRegex.FindString(message); link != "" {
+		if _, err := url.ParseRequestURI(link); err == nil {
+			firstRow = append(firstRow, bean.TelegramButton{Text: impl.catalog.Render(locale, "button.open", nil), Url: link})
+		}
+	}
+	firstRow = append(firstRow, impl.callbackButton(senderId, impl.catalog.Render(locale, "button.delete", nil), &bean.MessengerCallback{Action: telegramActionDeleteLink, LinkIDs: linkIDs}))
+	return [][]bean.TelegramButton{
+		firstRow,
+		{
+			impl.callbackButton(senderId, impl.catalog.Render(locale, "button.tag_work", nil), &bean.MessengerCallback{Action: telegramActionTagLink, LinkIDs: linkIDs}),
+			impl.callbackButton(senderId, impl.catalog.Render(locale, "button.remind", nil), &bean.MessengerCallback{Action: telegramActionRemind, Message: message}),
+		},
+	}
+}
+
+// fileButtons builds the keyboard attached to a file upload confirmation.
+func (impl *TelegramImpl) fileButtons(locale string, senderId int64, fileName string) [][]bean.TelegramButton {
+	return [][]bean.TelegramButton{{
+		impl.callbackButton(senderId, impl.catalog.Render(locale, "button.share_link", nil), &bean.MessengerCallback{Action: telegramActionShareFile, FileName: fileName}),
+		impl.callbackButton(senderId, impl.catalog.Render(locale, "button.delete", nil), &bean.MessengerCallback{Action: telegramActionDeleteFile, FileName: fileName}),
+	}}
+}
+
+// callbackButton stores the action in Redis encrypted with the sender id, since callback data is limited to 64 bytes.
+func (impl *TelegramImpl) callbackButton(senderId int64, text string, callback *bean.MessengerCallback) bean.TelegramButton {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	id := hex.EncodeToString(b)
+
+	callbackJson, err := json.Marshal(callback)
+	if err != nil {
+		impl.logger.Errorw("error in marshalling telegram callback", "error", err)
+		return bean.TelegramButton{Text: text, CallbackData: telegramCallbackPrefix}
+	}
+	encrypted, err := cryptography.EncryptData(strconv.FormatInt(senderId, 10), string(callbackJson), impl.logger)
+	if err != nil {
+		return bean.TelegramButton{Text: text, CallbackData: telegramCallbackPrefix}
+	}
+	err = impl.client.Set(impl.ctx, fmt.Sprintf(telegramCallbackKey, id), encrypted, telegramCallbackTTL).Err()
+	if err != nil {
+		impl.logger.Errorw("error in saving telegram callback", "error", err)
+	}
+	return bean.TelegramButton{Text: text, CallbackData: telegramCallbackPrefix + id}
+}
+
+func (impl *TelegramImpl) handleCallback(ctx context.Context, b *bot.Bot, update *models.Update) {
+	query := update.CallbackQuery
+	sender := strconv.FormatInt(query.From.ID, 10)
+	impl.rememberBot(query.From.ID)
+	impl.rememberLanguage(query.From.ID, &query.From)
+	locale := impl.locale(query.From.ID)
+	answer := impl.dispatchCallback(query, sender, locale)
+	_, err := b.AnswerCallbackQuery(ctx, &bot.AnswerCallbackQueryParams{CallbackQueryID: query.ID, Text: impl.catalog.Render(locale, answer, nil)})
+	if err != nil {
+		impl.logger.Errorw("error in answering telegram callback", "error", err)
+	}
+}
+
+// dispatchCallback runs the action behind a button and returns the message key of the answer.
+func (impl *TelegramImpl) dispatchCallback(query *models.CallbackQuery, sender, locale string) string {
+	encrypted, err := impl.client.Get(impl.ctx, fmt.Sprintf(telegramCallbackKey, strings.TrimPrefix(query.Data, telegramCallbackPrefix))).Result()
+	if errors.Is(err, redis.Nil) {
+		return "action_expired"
+	} else if err != nil {
+		impl.logger.Errorw("error in getting telegram callback", "error", err)
+		return "telegram.callback_failed"
+	}
+
+	var callback bean.MessengerCallback
+	decrypted, err := cryptography.DecryptData(sender, encrypted, impl.logger)
+	if err != nil || json.Unmarshal([]byte(decrypted), &callback) != nil {
+		return "not_allowed"
+	}
+
+	emails, err := impl.getLinkedEmails(sender)
+	if err != nil {
+		return "telegram.set_email_first"
+	}
+
+	var chatID int64
+	if query.Message.Message != nil {
+		chatID = query.Message.Message.Chat.ID
+	}
+
+	switch callback.Action {
+	case telegramActionDeleteLink:
+		if !impl.forEachLink(emails, callback.LinkIDs, func(email string, link *bean.GetLink) error {
+			return impl.linkService.DeleteLink(email, link)
+		}) {
+			return "link_not_found"
+		}
+		impl.removeKeyboard(query)
+		return "link_deleted"
+	case telegramActionTagLink:
+		if !impl.forEachLink(emails, callback.LinkIDs, func(email string, link *bean.GetLink) error {
+			return impl.linkService.TagLink(email, link, telegramWorkTag)
+		}) {
+			return "link_not_found"
+		}
+		return "tagged_work"
+	case telegramActionRemind:
+		if chatID == 0 {
+			return "telegram.reminder_unavailable"
+		}
+		err = impl.outbox.EnqueueAt(util.TELEGRAM, strconv.FormatInt(chatID, 10), bean.OutboxTelegramMessage{Text: impl.catalog.Render(locale, "reminder", messages.Data{"Message": callback.Message})}, time.Now().Add(telegramReminderDelay))
+		if err != nil {
+			return "telegram.reminder_failed"
+		}
+		return "reminder_set"
+	case telegramActionShareFile:
+		if chatID == 0 {
+			return "telegram.share_unavailable"
+		}
+		var links []string
+		for _, email := range emails {
+			token, err := impl.tokenService.ShareFileVerificationToken(&bean.ShareFileClaims{Email: email, AppName: util.TELEGRAM, FileName: callback.FileName + ".bin"})
+			if err != nil {
+				impl.logger.Errorw("Error in generating shareable link", "Error", err)
+				continue
+			}
+			links = append(links, fmt.Sprintf("%s/download-shared-file/%s/%s?Authorization=%s", impl.cfg.BaseUrl, url.PathEscape(util.TELEGRAM), url.PathEscape(callback.FileName), url.QueryEscape(token)))
+		}
+		if len(links) == 0 {
+			return "share_link_failed"
+		}
+		impl.SendTelegramMessage(chatID, impl.catalog.Render(locale, "share_link", messages.Data{"FileName": callback.FileName, "Links": strings.Join(links, "\n")}))
+		return "telegram.share_link_sent"
+	case telegramActionDeleteFile:
+		for _, email := range emails {
+			err = impl.fileManager.DeleteAFileInAppFolder(callback.FileName+".bin", email, util.TELEGRAM)
+			if err != nil {
+				return "file_delete_failed"
+			}
+		}
+		impl.removeKeyboard(query)
+		return "file_deleted"
+	}
+	return "unknown_action"
+}
+
+// forEachLink applies fn to every link id under every linked email and reports whether any link matched.
+func (impl *TelegramImpl) forEachLink(emails []string, linkIDs []int, fn func(email string, link *bean.GetLink) error) bool {
+	found := false
+	for _, email := range emails {
+		for _, id := range linkIDs {
+			err := fn(email, &bean.GetLink{ID: id})
+			if err == nil {
+				found = true
+			} else if !errors.Is(err, pg.ErrNoRows) {
+				impl.logger.Errorw("error in updating link from telegram callback", "error", err)
+			}
+		}
+	}
+	return found
+}
+
+func (impl *TelegramImpl) removeKeyboard(query *models.CallbackQuery) {
+	if query.Message.Message == nil {
+		return
+	}
+	_, err := impl.bot.EditMessageReplyMarkup(impl.ctx, &bot.EditMessageReplyMarkupParams{
+		ChatID:    query.Message.Message.Chat.ID,
+		MessageID: query.Message.Message.ID,
+	})
+	if err != nil {
+		impl.logger.Errorw("error in removing telegram keyboard", "error", err)
+	}
+}
+
+func telegramReplyMarkup(buttons [][]bean.TelegramButton) models.ReplyMarkup {
+	if len(buttons) == 0 {
+		return nil
+	}
+	keyboard := make([][]models.InlineKeyboardButton, 0, len(buttons))
+	for _, row := range buttons {
+		var keyboardRow []models.InlineKeyboardButton
+		for _, button := range row {
+			keyboardRow = append(keyboardRow, models.InlineKeyboardButton{Text: button.Text, URL: button.Url, CallbackData: button.CallbackData})
+		}
+		keyboard = append(keyboard, keyboardRow)
+	}
+	return &models.InlineKeyboardMarkup{InlineKeyboard: keyboard}
+}
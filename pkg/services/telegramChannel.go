@@ -0,0 +1,106 @@
+package services
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+	"github.com/iraunit/get-link-backend/pkg/messages"
+	"github.com/iraunit/get-link-backend/util"
+	"github.com/iraunit/get-link-backend/util/bean"
+	"path"
+	"strconv"
+	"time"
+)
+
+func (impl *TelegramImpl) Name() string {
+	return util.TELEGRAM
+}
+
+func (impl *TelegramImpl) Receive(message *bean.InboundMessage) error {
+	_, err := impl.receive(message)
+	return err
+}
+
+func (impl *TelegramImpl) receive(message *bean.InboundMessage) ([]int, error) {
+	emails, err := impl.getLinkedEmails(message.Identity)
+	if err != nil {
+		return nil, err
+	}
+	return impl.inbox.save(emails, message)
+}
+
+// VerifyIdentity links a Telegram user id. In a private chat the chat id is the user id.
+func (impl *TelegramImpl) VerifyIdentity(userEmail, identity string) error {
+	id, err := strconv.ParseInt(identity, 10, 64)
+	if err != nil {
+		return err
+	}
+	return impl.VerifyTelegram(userEmail, &bean.TelegramVerificationClaims{Email: userEmail, SenderId: id, ChatId: id})
+}
+
+func (impl *TelegramImpl) SendText(identity, text string) error {
+	chatId, err := strconv.ParseInt(identity, 10, 64)
+	if err != nil {
+		return err
+	}
+	return impl.outbox.Enqueue(util.TELEGRAM, strconv.FormatInt(chatId, 10), bean.OutboxTelegramMessage{Text: text})
+}
+
+func (impl *TelegramImpl) Render(identity, key string, data messages.Data) string {
+	chatId, err := strconv.ParseInt(identity, 10, 64)
+	if err != nil {
+		return impl.catalog.Render("", key, data)
+	}
+	return impl.botFor(chatId).catalog.Render(impl.locale(chatId), key, data)
+}
+
+// SendFile sends the bytes right away, as only stored files are queued. See sendStoredFile.
+func (impl *TelegramImpl) SendFile(identity, fileName string, data []byte) error {
+	chatId, err := strconv.ParseInt(identity, 10, 64)
+	if err != nil {
+		return err
+	}
+	return impl.sendFileData(chatId, fileName, data)
+}
+
+// sendStoredFile checks the stored file can be sent and queues a reference to it, so it is retried like text messages
+// and read only when sent.
+func (impl *TelegramImpl) sendStoredFile(chatId int64, userEmail, appName, fileName string, at time.Time) error {
+	size, err := impl.fileManager.GetFileSize(fileName, userEmail, appName)
+	if err != nil {
+		return fmt.Errorf("file %s not found", fileName)
+	}
+	if _, _, err = resolveOutgoingMediaOfSize(telegramMediaRules, fileName, size, nil); err != nil {
+		return err
+	}
+	return impl.outbox.EnqueueAt(util.TELEGRAM, strconv.FormatInt(chatId, 10), bean.OutboxTelegramMessage{FileName: fileName, Email: userEmail, AppName: appName}, at)
+}
+
+func (impl *TelegramImpl) sendOutboxFile(chatId int64, message *bean.OutboxTelegramMessage) error {
+	data, err := impl.fileManager.GetDecryptedFile(message.FileName, message.Email, message.AppName)
+	if err != nil {
+		impl.logger.Errorw("error in reading queued telegram file", "error", err)
+		return err
+	}
+	return impl.sendFileData(chatId, message.FileName, data)
+}
+
+func (impl *TelegramImpl) sendFileData(chatId int64, fileName string, data []byte) error {
+	kind, _, err := resolveOutgoingMedia(telegramMediaRules, fileName, data)
+	if err != nil {
+		return err
+	}
+	file := &models.InputFileUpload{Filename: path.Base(fileName), Data: bytes.NewReader(data)}
+	b := impl.botFor(chatId).bot
+	if kind == mediaKindPhoto {
+		_, err = b.SendPhoto(impl.ctx, &bot.SendPhotoParams{ChatID: chatId, Photo: file})
+	} else {
+		_, err = b.SendDocument(impl.ctx, &bot.SendDocumentParams{ChatID: chatId, Document: file})
+	}
+	if err != nil {
+		impl.logger.Errorw("error in sending telegram file", "kind", kind, "error", err)
+		return err
+	}
+	return nil
+}
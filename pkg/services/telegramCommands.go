@@ -0,0 +1,319 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"github.com/go-pg/pg"
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+	"github.com/iraunit/get-link-backend/pkg/cryptography"
+	"github.com/iraunit/get-link-backend/pkg/messages"
+	"github.com/iraunit/get-link-backend/util"
+	"github.com/iraunit/get-link-backend/util/bean"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultTelegramListSize  = 5
+	maxTelegramListSize      = 50
+	maxTelegramMessageLength = 4000
+)
+
+// descriptions are the telegram.command.<name> messages
+var (
+	telegramPrivateCommands = []string{"list", "delete", "files", "get", "unlink", "bindchannel", "unbindchannel", "help"}
+	telegramGroupCommands   = []string{"bindgroup", "unbindgroup", "join", "leave", "help"}
+)
+
+// registerCommands sets the private and group menus in the default locale, and per language for Telegram apps in a
+// catalog language.
+func (impl *TelegramImpl) registerCommands() {
+	var params []*bot.SetMyCommandsParams
+	for _, locale := range append([]string{""}, impl.catalog.Locales()...) {
+		// Telegram only accepts two-letter language codes
+		if locale != "" && len(locale) != 2 {
+			continue
+		}
+		params = append(params,
+			&bot.SetMyCommandsParams{Commands: impl.commandMenu(locale, telegramPrivateCommands), Scope: &models.BotCommandScopeAllPrivateChats{}, LanguageCode: locale},
+			&bot.SetMyCommandsParams{Commands: impl.commandMenu(locale, telegramGroupCommands), Scope: &models.BotCommandScopeAllGroupChats{}, LanguageCode: locale},
+		)
+	}
+	for _, param := range params {
+		_, err := impl.bot.SetMyCommands(impl.ctx, param)
+		if err != nil {
+			impl.logger.Errorw("error in setting telegram commands", "language", param.LanguageCode, "error", err)
+		}
+	}
+}
+
+func (impl *TelegramImpl) commandMenu(locale string, names []string) []models.BotCommand {
+	var commands []models.BotCommand
+	for _, command := range names {
+		commands = append(commands, models.BotCommand{Command: command, Description: impl.catalog.Render(locale, "telegram.command."+command, nil)})
+	}
+	return commands
+}
+
+// handleCommand runs a bot command and reports whether the message was one.
+func (impl *TelegramImpl) handleCommand(update *models.Update) bool {
+	fields := strings.Fields(update.Message.Text)
+	if len(fields) == 0 || !strings.HasPrefix(fields[0], "/") {
+		return false
+	}
+	command := strings.ToLower(strings.SplitN(strings.TrimPrefix(fields[0], "/"), "@", 2)[0])
+	args := fields[1:]
+	chatID := update.Message.Chat.ID
+
+	switch command {
+	case "start":
+		if len(args) > 0 {
+			impl.connectWithCode(update.Message, args[0])
+		} else {
+			impl.reply(chatID, "telegram.help", nil)
+		}
+		return true
+	case "help":
+		impl.reply(chatID, "telegram.help", nil)
+		return true
+	case "bindchannel", "unbindchannel":
+		impl.bindChannel(chatID, update.Message.From, args, command == "bindchannel")
+		return true
+	case "list", "delete", "files", "get", "unlink":
+	default:
+		return false
+	}
+
+	sender := strconv.FormatInt(update.Message.From.ID, 10)
+	emails, err := impl.getLinkedEmails(sender)
+	if err != nil {
+		impl.reply(chatID, "telegram.set_email_first", nil)
+		return true
+	}
+
+	switch command {
+	case "list":
+		impl.listLinks(chatID, emails, args)
+	case "delete":
+		impl.deleteLink(chatID, emails, args)
+	case "files":
+		impl.listFiles(chatID, emails)
+	case "get":
+		impl.sendFile(chatID, emails, strings.Join(args, " "))
+	case "unlink":
+		impl.unlink(chatID, sender, emails)
+	}
+	return true
+}
+
+func (impl *TelegramImpl) getLinkedEmails(sender string) ([]string, error) {
+	allEmails, err := impl.GetUsersFromTelegramNumber(sender)
+	if err != nil {
+		return nil, err
+	}
+	var emails []string
+	for _, email := range allEmails {
+		decryptedEmail, err := cryptography.DecryptData(sender, email.Email, impl.logger)
+		if err != nil {
+			impl.logger.Errorw("Error in decrypting data", "Error", err)
+			return nil, err
+		}
+		emails = append(emails, decryptedEmail)
+	}
+	return emails, nil
+}
+
+func (impl *TelegramImpl) listLinks(chatID int64, emails []string, args []string) {
+	n := defaultTelegramListSize
+	if len(args) > 0 {
+		parsed, err := strconv.Atoi(args[0])
+		if err != nil || parsed <= 0 {
+			impl.reply(chatID, "telegram.list_usage", nil)
+			return
+		}
+		n = min(parsed, maxTelegramListSize)
+	}
+
+	var message strings.Builder
+	for _, email := range emails {
+		allLinks := impl.linkService.GetAllLink(email, "")
+		if allLinks == nil || len(*allLinks) == 0 {
+			continue
+		}
+		links := *allLinks
+		sort.Slice(links, func(i, j int) bool {
+			return links[i].ID > links[j].ID
+		})
+		if len(emails) > 1 {
+			message.WriteString(email + "\n")
+		}
+		for _, link := range links[:min(n, len(links))] {
+			message.WriteString(fmt.Sprintf("#%d %s\n", link.ID, link.Message))
+		}
+		message.WriteString("\n")
+	}
+	locale := impl.locale(chatID)
+	if message.Len() == 0 {
+		impl.SendTelegramMessage(chatID, impl.catalog.Render(locale, "no_links", nil))
+		return
+	}
+	impl.SendTelegramMessage(chatID, truncateTelegramMessage(message.String())+"\n\n"+impl.catalog.Render(locale, "telegram.list_footer", nil))
+}
+
+func (impl *TelegramImpl) deleteLink(chatID int64, emails []string, args []string) {
+	if len(args) == 0 {
+		impl.reply(chatID, "telegram.delete_usage", nil)
+		return
+	}
+	id, err := strconv.Atoi(strings.TrimPrefix(args[0], "#"))
+	if err != nil || id <= 0 {
+		impl.reply(chatID, "telegram.delete_usage", nil)
+		return
+	}
+	for _, email := range emails {
+		err = impl.linkService.DeleteLink(email, &bean.GetLink{ID: id})
+		if err == nil {
+			impl.reply(chatID, "link_id_deleted", messages.Data{"ID": id})
+			return
+		}
+		if !errors.Is(err, pg.ErrNoRows) {
+			impl.reply(chatID, "telegram.delete_failed", nil)
+			return
+		}
+	}
+	impl.reply(chatID, "telegram.link_id_not_found", messages.Data{"ID": id})
+}
+
+func (impl *TelegramImpl) listFiles(chatID int64, emails []string) {
+	var message strings.Builder
+	for _, email := range emails {
+		for _, appName := range storedFileApps {
+			files, err := impl.fileManager.ListAllFilesFromApp(email, appName)
+			if err != nil {
+				continue
+			}
+			for _, file := range files {
+				message.WriteString(fmt.Sprintf("%s (%d KB)\n%s%s\n\n", file.Name, file.Size/1000, impl.cfg.BaseUrl, file.ShareableLink))
+			}
+		}
+	}
+	locale := impl.locale(chatID)
+	if message.Len() == 0 {
+		impl.SendTelegramMessage(chatID, impl.catalog.Render(locale, "no_files", nil))
+		return
+	}
+	impl.SendTelegramMessage(chatID, truncateTelegramMessage(message.String())+"\n\n"+impl.catalog.Render(locale, "telegram.files_footer", nil))
+}
+
+func (impl *TelegramImpl) sendFile(chatID int64, emails []string, fileName string) {
+	if fileName == "" {
+		impl.reply(chatID, "telegram.get_usage", nil)
+		return
+	}
+	for _, email := range emails {
+		for _, appName := range storedFileApps {
+			if _, err := impl.fileManager.GetFileSize(fileName, email, appName); err != nil {
+				continue
+			}
+			if err := impl.sendStoredFile(chatID, email, appName, fileName, time.Now()); err != nil {
+				impl.logger.Errorw("error in sending telegram document", "error", err)
+				impl.reply(chatID, "telegram.send_file_failed", nil)
+			}
+			return
+		}
+	}
+	impl.reply(chatID, "telegram.file_not_found", messages.Data{"FileName": fileName})
+}
+
+func (impl *TelegramImpl) unlink(chatID int64, sender string, emails []string) {
+	locale := impl.locale(chatID)
+	for _, email := range emails {
+		if impl.unlinkSender(email, sender) != nil {
+			impl.SendTelegramMessage(chatID, impl.catalog.Render(locale, "telegram.unlink_failed", nil))
+			return
+		}
+	}
+	impl.SendTelegramMessage(chatID, impl.catalog.Render(locale, "telegram.unlinked", nil))
+}
+
+// unlinkSender removes both the sender-keyed and the email-keyed rows written by VerifyTelegram.
+func (impl *TelegramImpl) unlinkSender(email, sender string) error {
+	encryptedSender, err := cryptography.EncryptData(sender, sender, impl.logger)
+	if err != nil {
+		return err
+	}
+	senderEmail, err := cryptography.EncryptData(sender, email, impl.logger)
+	if err != nil {
+		return err
+	}
+	userEmail, err := cryptography.EncryptData(email, email, impl.logger)
+	if err != nil {
+		return err
+	}
+	userSender, err := cryptography.EncryptData(email, sender, impl.logger)
+	if err != nil {
+		return err
+	}
+	if err = impl.repository.DeleteTelegramEmail(senderEmail, encryptedSender); err != nil {
+		return err
+	}
+	if err = impl.repository.DeleteChannelIdentity(util.TELEGRAM, userEmail, userSender); err != nil {
+		return err
+	}
+	// email-keyed row written before the email keys moved to channel_identities
+	return impl.repository.DeleteTelegramEmail(userEmail, userSender)
+}
+
+func (impl *TelegramImpl) GetLinkedAccounts(userEmail string) ([]bean.LinkedAccount, error) {
+	rows, err := impl.GetUsersFromEmail(userEmail)
+	if errors.Is(err, pg.ErrNoRows) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var accounts []bean.LinkedAccount
+	for _, row := range rows {
+		sender, err := cryptography.DecryptData(userEmail, row.SenderId, impl.logger)
+		if err != nil {
+			impl.logger.Errorw("Error in decrypting data", "Error", err)
+			continue
+		}
+		account := bean.LinkedAccount{Channel: util.TELEGRAM, Identity: util.MaskIdentity(sender), Address: sender}
+		if chatId, err := strconv.ParseInt(sender, 10, 64); err == nil {
+			if chat, err := impl.botFor(chatId).bot.GetChat(impl.ctx, &bot.GetChatParams{ChatID: chatId}); err == nil {
+				account.Name = strings.TrimSpace(chat.FirstName + " " + chat.LastName)
+			}
+		}
+		accounts = append(accounts, account)
+	}
+	return accounts, nil
+}
+
+func (impl *TelegramImpl) UnlinkAccount(userEmail string, account bean.LinkedAccount) error {
+	chatId, parseErr := strconv.ParseInt(account.Address, 10, 64)
+	tenantBot := impl.botFor(chatId)
+	// the locale is read first, since the account has no linked email once it is unlinked
+	locale := tenantBot.locale(chatId)
+	if err := impl.unlinkSender(userEmail, account.Address); err != nil {
+		return err
+	}
+	if parseErr == nil {
+		impl.SendTelegramMessage(chatId, tenantBot.catalog.Render(locale, "telegram.unlinked_from_web", messages.Data{"Email": userEmail}))
+	}
+	return nil
+}
+
+func truncateTelegramMessage(message string) string {
+	message = strings.TrimSpace(message)
+	if len(message) <= maxTelegramMessageLength {
+		return message
+	}
+	cut := strings.LastIndex(message[:maxTelegramMessageLength], "\n")
+	if cut <= 0 {
+		cut = maxTelegramMessageLength
+	}
+	return message[:cut] + "\n..."
+}
@@ -0,0 +1,69 @@
+package services
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/go-telegram/bot/models"
+	"github.com/iraunit/get-link-backend/pkg/cryptography"
+	"github.com/iraunit/get-link-backend/pkg/messages"
+	"github.com/iraunit/get-link-backend/util/bean"
+	"github.com/redis/go-redis/v9"
+	"time"
+)
+
+const (
+	telegramConnectKey = "telegram:connect:%s"
+	telegramConnectTTL = 10 * time.Minute
+)
+
+// CreateConnectLink returns a t.me deep link whose one-time code binds the Telegram account that opens it to userEmail.
+func (impl *TelegramImpl) CreateConnectLink(userEmail string, origin string) (string, error) {
+	botUsername := impl.botForTenant(impl.tenants.ByOrigin(origin).ID).botUsername
+	if botUsername == "" {
+		return "", errors.New("telegram bot is not available")
+	}
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	code := hex.EncodeToString(b)
+
+	encryptedEmail, err := cryptography.EncryptData(code, userEmail, impl.logger)
+	if err != nil {
+		impl.logger.Errorw("Error in encrypting data", "Error: ", err)
+		return "", err
+	}
+	err = impl.client.Set(impl.ctx, fmt.Sprintf(telegramConnectKey, code), encryptedEmail, telegramConnectTTL).Err()
+	if err != nil {
+		impl.logger.Errorw("Error in saving telegram connect code", "Error: ", err)
+		return "", err
+	}
+	return fmt.Sprintf("https://t.me/%s?start=%s", botUsername, code), nil
+}
+
+func (impl *TelegramImpl) connectWithCode(message *models.Message, code string) {
+	encryptedEmail, err := impl.client.GetDel(impl.ctx, fmt.Sprintf(telegramConnectKey, code)).Result()
+	if errors.Is(err, redis.Nil) {
+		impl.reply(message.Chat.ID, "telegram.connect_invalid", nil)
+		return
+	} else if err != nil {
+		impl.logger.Errorw("Error in getting telegram connect code", "Error: ", err)
+		impl.reply(message.Chat.ID, "telegram.connect_failed", nil)
+		return
+	}
+
+	email, err := cryptography.DecryptData(code, encryptedEmail, impl.logger)
+	if err != nil {
+		impl.reply(message.Chat.ID, "telegram.connect_failed", nil)
+		return
+	}
+
+	claims := bean.TelegramVerificationClaims{Email: email, ChatId: message.Chat.ID, SenderId: message.From.ID}
+	if err = impl.VerifyTelegram(email, &claims); err != nil {
+		impl.reply(message.Chat.ID, "telegram.connect_failed", nil)
+		return
+	}
+	impl.reply(message.Chat.ID, "telegram.connected", messages.Data{"Email": email})
+}
@@ -0,0 +1,256 @@
+package services
+
+import (
+	"fmt"
+	"github.com/go-telegram/bot"
+	"github.com/go-telegram/bot/models"
+	"github.com/iraunit/get-link-backend/pkg/cryptography"
+	"github.com/iraunit/get-link-backend/pkg/messages"
+	"github.com/iraunit/get-link-backend/util"
+	"github.com/iraunit/get-link-backend/util/bean"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+const (
+	telegramChatTypePrivate = "private"
+	telegramChatTypeChannel = "channel"
+)
+
+// receiveGroupMessage handles messages from groups, supergroups and channels, which fan out to every bound account.
+func (impl *TelegramImpl) receiveGroupMessage(message *models.Message) {
+	isChannel := message.Chat.Type == telegramChatTypeChannel
+	text := message.Text
+	if text == "" {
+		text = message.Caption
+	}
+
+	if fields := strings.Fields(text); len(fields) > 0 && strings.HasPrefix(fields[0], "/") {
+		if !isChannel {
+			impl.handleGroupCommand(message, fields)
+		}
+		return
+	}
+	if !isChannel && !impl.isBotTriggered(message, text) {
+		return
+	}
+
+	emails, err := impl.getGroupEmails(message.Chat.ID)
+	if err != nil || len(emails) == 0 {
+		if !isChannel {
+			impl.reply(message.Chat.ID, "telegram.group_not_connected", nil)
+		}
+		return
+	}
+
+	if impl.botMention != nil {
+		text = strings.TrimSpace(impl.botMention.ReplaceAllString(text, ""))
+	}
+	saved, err := impl.saveGroupMedia(message, emails)
+	if err != nil {
+		impl.logger.Errorw("Error in saving telegram group media", "Error", err)
+		if !isChannel {
+			impl.reply(message.Chat.ID, "telegram.group_save_failed", messages.Data{"Error": err.Error()})
+		}
+		return
+	}
+	if !saved && text != "" {
+		for _, email := range emails {
+			impl.linkService.AddLink(email, &bean.GetLink{Receiver: email, Sender: email, Message: text, UUID: util.TELEGRAM})
+		}
+		saved = true
+	}
+	if saved && !isChannel {
+		impl.reply(message.Chat.ID, "telegram.group_saved", messages.Data{"Count": len(emails)})
+	}
+}
+
+func (impl *TelegramImpl) handleGroupCommand(message *models.Message, fields []string) {
+	parts := strings.SplitN(strings.TrimPrefix(fields[0], "/"), "@", 2)
+	if len(parts) == 2 && !strings.EqualFold(parts[1], impl.botUsername) {
+		return
+	}
+	args := fields[1:]
+
+	switch strings.ToLower(parts[0]) {
+	case "bindgroup":
+		impl.bindGroup(message.Chat.ID, message.From, args, true)
+	case "unbindgroup":
+		impl.bindGroup(message.Chat.ID, message.From, args, false)
+	case "join":
+		impl.joinGroup(message.Chat.ID, message.From, args, true)
+	case "leave":
+		impl.joinGroup(message.Chat.ID, message.From, args, false)
+	case "start", "help":
+		impl.reply(message.Chat.ID, "telegram.group_help", nil)
+	}
+}
+
+// bindChannel binds or unbinds a channel from a private chat, since channel posts carry no sender to check.
+func (impl *TelegramImpl) bindChannel(chatID int64, from *models.User, args []string, bind bool) {
+	if len(args) == 0 {
+		impl.reply(chatID, "telegram.bindchannel_usage", nil)
+		return
+	}
+	channel, err := impl.bot.GetChat(impl.ctx, &bot.GetChatParams{ChatID: args[0]})
+	if err != nil {
+		impl.reply(chatID, "telegram.channel_not_found", messages.Data{"Channel": args[0]})
+		return
+	}
+	if channel.Type != telegramChatTypeChannel {
+		impl.reply(chatID, "telegram.not_a_channel", messages.Data{"Channel": args[0]})
+		return
+	}
+	impl.bindChat(chatID, channel.ID, from, args[1:], bind)
+}
+
+func (impl *TelegramImpl) bindGroup(chatID int64, from *models.User, args []string, bind bool) {
+	if from == nil || from.IsBot {
+		impl.reply(chatID, "telegram.anonymous_admin", nil)
+		return
+	}
+	impl.bindChat(chatID, chatID, from, args, bind)
+}
+
+// joinGroup adds or removes a member's own accounts to a group an admin has bound, making it a team inbox.
+func (impl *TelegramImpl) joinGroup(chatID int64, from *models.User, args []string, join bool) {
+	if from == nil || from.IsBot {
+		impl.reply(chatID, "telegram.anonymous_admin", nil)
+		return
+	}
+	if join {
+		if bound, err := impl.getGroupEmails(chatID); err != nil || len(bound) == 0 {
+			impl.reply(chatID, "telegram.group_not_connected", nil)
+			return
+		}
+	}
+	emails, ok := impl.senderEmails(chatID, from, args)
+	if !ok {
+		return
+	}
+	impl.saveBinding(chatID, chatID, emails, join)
+}
+
+func (impl *TelegramImpl) bindChat(replyChatID, groupChatID int64, from *models.User, args []string, bind bool) {
+	member, err := impl.bot.GetChatMember(impl.ctx, &bot.GetChatMemberParams{ChatID: groupChatID, UserID: from.ID})
+	if err != nil || (member.Type != models.ChatMemberTypeOwner && member.Type != models.ChatMemberTypeAdministrator) {
+		impl.reply(replyChatID, "telegram.admins_only", nil)
+		return
+	}
+	emails, ok := impl.senderEmails(replyChatID, from, args)
+	if !ok {
+		return
+	}
+	impl.saveBinding(replyChatID, groupChatID, emails, bind)
+}
+
+// senderEmails returns the accounts connected to from, or the ones among them listed in args.
+func (impl *TelegramImpl) senderEmails(replyChatID int64, from *models.User, args []string) ([]string, bool) {
+	emails, err := impl.getLinkedEmails(strconv.FormatInt(from.ID, 10))
+	if err != nil {
+		impl.reply(replyChatID, "telegram.connect_private_first", nil)
+		return nil, false
+	}
+	for _, email := range args {
+		if !slices.Contains(emails, email) {
+			impl.reply(replyChatID, "telegram.email_not_connected", messages.Data{"Email": email})
+			return nil, false
+		}
+	}
+	if len(args) > 0 {
+		emails = args
+	}
+	return emails, true
+}
+
+func (impl *TelegramImpl) saveBinding(replyChatID, groupChatID int64, emails []string, bind bool) {
+	chatId := strconv.FormatInt(groupChatID, 10)
+	encryptedChatId, err := cryptography.EncryptData(chatId, chatId, impl.logger)
+	if err != nil {
+		impl.reply(replyChatID, "telegram.bind_failed", nil)
+		return
+	}
+	for _, email := range emails {
+		encryptedEmail, err := cryptography.EncryptData(chatId, email, impl.logger)
+		if err != nil {
+			impl.reply(replyChatID, "telegram.bind_failed", nil)
+			return
+		}
+		if bind {
+			err = impl.repository.InsertTelegramGroup(&bean.TelegramGroup{ChatId: encryptedChatId, Email: encryptedEmail})
+		} else {
+			err = impl.repository.DeleteTelegramGroup(encryptedChatId, encryptedEmail)
+		}
+		if err != nil {
+			impl.reply(replyChatID, "telegram.bind_failed", nil)
+			return
+		}
+	}
+
+	if bind {
+		impl.reply(replyChatID, "telegram.bound", messages.Data{"Emails": strings.Join(emails, ", ")})
+	} else {
+		impl.reply(replyChatID, "telegram.unbound", messages.Data{"Emails": strings.Join(emails, ", ")})
+	}
+}
+
+func (impl *TelegramImpl) getGroupEmails(groupChatID int64) ([]string, error) {
+	chatId := strconv.FormatInt(groupChatID, 10)
+	encryptedChatId, err := cryptography.EncryptData(chatId, chatId, impl.logger)
+	if err != nil {
+		return nil, err
+	}
+	groups, err := impl.repository.GetEmailsFromTelegramGroup(encryptedChatId)
+	if err != nil {
+		return nil, err
+	}
+	var emails []string
+	for _, group := range groups {
+		email, err := cryptography.DecryptData(chatId, group.Email, impl.logger)
+		if err != nil {
+			impl.logger.Errorw("Error in decrypting data", "Error", err)
+			continue
+		}
+		emails = append(emails, email)
+	}
+	return emails, nil
+}
+
+// isBotTriggered reports whether a group message mentions the bot or replies to it, so ordinary chatter is ignored.
+func (impl *TelegramImpl) isBotTriggered(message *models.Message, text string) bool {
+	if impl.botMention == nil {
+		return false
+	}
+	if reply := message.ReplyToMessage; reply != nil && reply.From != nil && strings.EqualFold(reply.From.Username, impl.botUsername) {
+		return true
+	}
+	return impl.botMention.MatchString(text)
+}
+
+func (impl *TelegramImpl) saveGroupMedia(message *models.Message, emails []string) (bool, error) {
+	var fileID, fileName string
+	switch {
+	case len(message.Photo) > 0:
+		fileID = message.Photo[len(message.Photo)-1].FileID
+	case message.Document != nil:
+		fileID, fileName = message.Document.FileID, message.Document.FileName
+	case message.Audio != nil:
+		fileID, fileName = message.Audio.FileID, message.Audio.FileName
+	case message.Video != nil:
+		fileID, fileName = message.Video.FileID, message.Video.FileName
+	default:
+		return false, nil
+	}
+
+	filePath, err := impl.getFilePath(fileID)
+	if err != nil {
+		return false, err
+	}
+	if fileName == "" {
+		fileNameArray := strings.Split(filePath, "/")
+		fileName = fileNameArray[len(fileNameArray)-1]
+	}
+	err = impl.downloadMediaForEmails(fmt.Sprintf("https://api.telegram.org/file/bot%s/%s", impl.cfg.TelegramToken, filePath), strconv.FormatInt(message.Chat.ID, 10), emails, fileName)
+	return err == nil, err
+}
@@ -3,6 +3,7 @@ package services
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/caarlos0/env"
 	"github.com/go-pg/pg"
@@ -10,11 +11,13 @@ import (
 	"github.com/go-telegram/bot/models"
 	"github.com/iraunit/get-link-backend/pkg/cryptography"
 	"github.com/iraunit/get-link-backend/pkg/fileManager"
+	"github.com/iraunit/get-link-backend/pkg/messages"
 	"github.com/iraunit/get-link-backend/pkg/repository"
 	"github.com/iraunit/get-link-backend/pkg/restCalls"
 	tokenService2 "github.com/iraunit/get-link-backend/pkg/services/tokenService"
 	"github.com/iraunit/get-link-backend/util"
 	"github.com/iraunit/get-link-backend/util/bean"
+	"github.com/redis/go-redis/v9"
 	"go.uber.org/zap"
 	"io"
 	"net/http"
@@ -25,6 +28,12 @@ import (
 	"regexp"
 
 	"strings"
+	"time"
+)
+
+const (
+	telegramLanguageKey = "telegram:language:%d"
+	telegramLanguageTTL = 30 * 24 * time.Hour
 )
 
 type TelegramFileResponse struct {
@@ -41,17 +50,28 @@ type File struct {
 }
 
 type TelegramService interface {
+	Channel
 	ReceiveTelegramMessage(ctx context.Context, b *bot.Bot, update *models.Update)
 	SendTelegramMessage(chatID int64, message string)
 	VerifyTelegram(email string, claims *bean.TelegramVerificationClaims) error
 	GetUsersFromTelegramNumber(sender string) ([]bean.TelegramEmail, error)
 	GetUsersFromEmail(email string) ([]bean.TelegramEmail, error)
+	// WebhookHandler returns the handler of the bot with botID, the part of its token before the colon.
+	WebhookHandler(botID string) (http.HandlerFunc, bool)
+	// CreateConnectLink returns a deep link to the bot of the tenant that serves origin.
+	CreateConnectLink(userEmail string, origin string) (string, error)
+	SendMessageToEmail(userEmail string, message string) error
+	NotifyLink(userEmail string, message string) error
+	SendFileToEmail(userEmail, appName, fileName string) error
 }
 
 type TelegramImpl struct {
 	logger       *zap.SugaredLogger
 	async        *util.Async
+	client       *redis.Client
 	bot          *bot.Bot
+	botUsername  string
+	botMention   *regexp.Regexp
 	ctx          context.Context
 	mailService  MailService
 	tokenService tokenService2.TokenService
@@ -60,17 +80,29 @@ type TelegramImpl struct {
 	linkService  LinkService
 	fileManager  fileManager.FileManager
 	restClient   restCalls.RestClient
+	outbox       OutboxService
+	notification NotificationService
+	catalog      messages.Catalog
+	inbox        *messengerInbox
+	tenant       *bean.Tenant
+	tenants      TenantService
+	// bots holds one TelegramImpl per tenant with a bot, the default tenant's first
+	bots []*TelegramImpl
 }
 
-func NewTelegramService(logger *zap.SugaredLogger, async *util.Async, mailService MailService, tokenService tokenService2.TokenService, repository repository.Repository, linkService LinkService, fileManager fileManager.FileManager, restClient restCalls.RestClient) *TelegramImpl {
+func NewTelegramService(logger *zap.SugaredLogger, async *util.Async, client *redis.Client, mailService MailService, tokenService tokenService2.TokenService, repository repository.Repository, linkService LinkService, fileManager fileManager.FileManager, restClient restCalls.RestClient, outbox OutboxService, notificationService NotificationService, catalog messages.Catalog, tenantService TenantService) *TelegramImpl {
 	ctx := context.Background()
 	cfg := &bean.TelegramCfg{}
 	if err := env.Parse(cfg); err != nil {
 		logger.Fatal("Error loading Cfg from env", "Error", zap.Error(err))
 	}
-	impl := &TelegramImpl{
+	if cfg.Mode == util.TelegramModeWebhook && cfg.WebhookSecret == "" {
+		logger.Fatal("TELEGRAM_WEBHOOK_SECRET is required in webhook mode")
+	}
+	shared := &TelegramImpl{
 		logger:       logger,
 		async:        async,
+		client:       client,
 		ctx:          ctx,
 		cfg:          cfg,
 		mailService:  mailService,
@@ -79,108 +111,199 @@ func NewTelegramService(logger *zap.SugaredLogger, async *util.Async, mailServic
 		fileManager:  fileManager,
 		linkService:  linkService,
 		restClient:   restClient,
+		outbox:       outbox,
+		notification: notificationService,
+		tenants:      tenantService,
+	}
+	var bots []*TelegramImpl
+	for _, tenant := range tenantService.Tenants() {
+		if tenant.TelegramToken != "" {
+			bots = append(bots, shared.newBot(tenant, catalog))
+		}
+	}
+	if len(bots) == 0 {
+		logger.Fatal("No telegram bot configured, set TELEGRAM_TOKEN or the telegram_token of a tenant")
+	}
+
+	impl := bots[0]
+	outbox.RegisterSender(util.TELEGRAM, impl.sendOutboxMessage)
+	notificationService.RegisterNotifier(util.TELEGRAM, impl.notify)
+	for _, b := range bots {
+		b.bots = bots
+		b.registerCommands()
+		if cfg.Mode == util.TelegramModeWebhook {
+			b.startWebhook()
+		} else {
+			b.startPolling()
+		}
+	}
+	return impl
+}
+
+// newBot copies the shared service for the tenant's bot, with its own token, replies and folder limits.
+func (impl *TelegramImpl) newBot(tenant *bean.Tenant, catalog messages.Catalog) *TelegramImpl {
+	tenantBot := *impl
+	cfg := *impl.cfg
+	cfg.TelegramToken = tenant.TelegramToken
+	tenantBot.cfg = &cfg
+	tenantBot.tenant = tenant
+	tenantBot.catalog = catalog.ForTenant(tenant)
+
+	freeLimitMB, premiumLimitMB := fileLimits(tenant, util.FreeTelegramFileLimitSizeMB, util.PremiumTelegramFileLimitSizeMB)
+	tenantBot.inbox = &messengerInbox{
+		channel:        util.TELEGRAM,
+		displayName:    "Telegram",
+		baseUrl:        cfg.BaseUrl,
+		freeLimitMB:    freeLimitMB,
+		premiumLimitMB: premiumLimitMB,
+		logger:         impl.logger,
+		repository:     impl.repository,
+		linkService:    impl.linkService,
+		fileManager:    impl.fileManager,
+		tokenService:   impl.tokenService,
+		mailService:    impl.mailService,
+		notification:   impl.notification,
+		catalog:        tenantBot.catalog,
 	}
 
 	opts := []bot.Option{
-		bot.WithDefaultHandler(impl.ReceiveTelegramMessage),
+		bot.WithDefaultHandler(tenantBot.ReceiveTelegramMessage),
 	}
 
 	b, err := bot.New(cfg.TelegramToken, opts...)
 	if err != nil {
-		logger.Fatalw("error in creating telegram bot", "error", err)
+		impl.logger.Fatalw("error in creating telegram bot", "tenant", tenant.ID, "error", err)
 	}
 
-	impl.bot = b
+	tenantBot.bot = b
+	if me, err := b.GetMe(impl.ctx); err == nil {
+		tenantBot.botUsername = me.Username
+		tenantBot.botMention = regexp.MustCompile(`(?i)@` + regexp.QuoteMeta(me.Username) + `\b`)
+	} else {
+		impl.logger.Errorw("error in getting telegram bot info", "tenant", tenant.ID, "error", err)
+	}
+	b.RegisterHandler(bot.HandlerTypeCallbackQueryData, telegramCallbackPrefix, bot.MatchTypePrefix, tenantBot.handleCallback)
+	return &tenantBot
+}
 
-	async.Run(func() {
-		b.Start(ctx)
-	})
-	return impl
+// botFor returns the bot chatID last wrote to, or the default tenant's bot.
+func (impl *TelegramImpl) botFor(chatID int64) *TelegramImpl {
+	return impl.botForTenant(impl.tenants.ForIdentity(util.TELEGRAM, strconv.FormatInt(chatID, 10)).ID)
+}
+
+func (impl *TelegramImpl) botForTenant(tenantID string) *TelegramImpl {
+	for _, b := range impl.bots {
+		if b.tenant.ID == tenantID {
+			return b
+		}
+	}
+	return impl.bots[0]
+}
+
+func (impl *TelegramImpl) rememberBot(chatID int64) {
+	impl.tenants.Remember(util.TELEGRAM, strconv.FormatInt(chatID, 10), impl.tenant)
 }
 
 func (impl *TelegramImpl) ReceiveTelegramMessage(ctx context.Context, b *bot.Bot, update *models.Update) {
 	impl.bot = b
+	if update.ChannelPost != nil {
+		impl.rememberBot(update.ChannelPost.Chat.ID)
+		impl.receiveGroupMessage(update.ChannelPost)
+		return
+	}
+	if update.Message == nil {
+		return
+	}
+	impl.rememberBot(update.Message.Chat.ID)
+	impl.rememberLanguage(update.Message.Chat.ID, update.Message.From)
+	if update.Message.Chat.Type != telegramChatTypePrivate {
+		impl.receiveGroupMessage(update.Message)
+		return
+	}
+	if impl.handleCommand(update) {
+		return
+	}
 	if strings.Contains(strings.ToLower(update.Message.Text), "set email") {
 		// send email here
 		re := regexp.MustCompile(`[a-zA-Z0-9._%+-]+@[a-zA-Z0-9.-]+\.[a-zA-Z]{2,}`)
 		emails := re.FindAllString(update.Message.Text, -1)
 		if len(emails) == 0 {
-			impl.SendTelegramMessage(update.Message.Chat.ID, "Please enter valid email")
+			impl.reply(update.Message.Chat.ID, "telegram.invalid_email", nil)
 			return
 		}
 		claims := bean.TelegramVerificationClaims{Email: emails[0], ChatId: update.Message.Chat.ID, SenderId: update.Message.From.ID}
 		token, err := impl.tokenService.TelegramEmailVerificationToken(&claims)
 		if err != nil {
 			impl.logger.Errorw("Error in generating token", "Error", err)
-			impl.SendTelegramMessage(update.Message.Chat.ID, "error in generating token. Please contact support at https://x.com/iraunit")
+			impl.reply(update.Message.Chat.ID, "telegram.token_failed", nil)
 			return
 		}
-		emailBody := fmt.Sprintf("Please click on the below link to verify your email. \n\n %s%s?token=%s", impl.cfg.BaseUrl, util.VerifyTelegramEmail, url.QueryEscape(token))
-		err = impl.mailService.SendMail(emails[0], "Get-Link - Email Verification", emailBody)
+		err = impl.mailService.SendTenantTemplateMail(impl.tenant, emails[0], util.MailTemplateVerification, bean.VerificationMailData{
+			Intro:      fmt.Sprintf("Please click on the below link to verify your email and connect your Telegram account to %s.", impl.catalog.Render("", "brand", nil)),
+			ActionUrl:  fmt.Sprintf("%s%s?token=%s", impl.cfg.BaseUrl, util.VerifyTelegramEmail, url.QueryEscape(token)),
+			ActionText: "Verify email",
+		})
 		if err != nil {
 			impl.logger.Errorw("Error in sending mail", "Error", err)
-			impl.SendTelegramMessage(update.Message.Chat.ID, "Error in sending verification mail. Please contact support at https://x.com/iraunit")
+			impl.reply(update.Message.Chat.ID, "telegram.mail_failed", nil)
 			return
 		}
-		impl.SendTelegramMessage(update.Message.Chat.ID, "Please check your email for verification link. \n\nYou can share your feedback or report an issue on codingkaro.in.\n\nRegards\nRaunit Verma\nShypt Solution")
+		impl.reply(update.Message.Chat.ID, "telegram.verification_sent", nil)
 
 	} else if update.Message.Text != "" {
-		allEmails, err := impl.GetUsersFromTelegramNumber(strconv.FormatInt(update.Message.From.ID, 10))
+		linkIDs, err := impl.receive(&bean.InboundMessage{Identity: strconv.FormatInt(update.Message.From.ID, 10), Text: update.Message.Text})
 		if err != nil {
 			impl.logger.Errorw("Error in getting users from telegram number", "Error", err)
-			impl.SendTelegramMessage(update.Message.Chat.ID, "Have you set your email here. Please send 'set email youremail@gmail.com' and then verify by clicking on the link received on your email.")
+			impl.reply(update.Message.Chat.ID, "telegram.set_email_first", nil)
 			return
 		}
-		for _, email := range allEmails {
-			decryptedEmail, err := cryptography.DecryptData(strconv.FormatInt(update.Message.From.ID, 10), email.Email, impl.logger)
-			if err != nil {
-				impl.logger.Errorw("Error in decrypting data", "Error", err)
-				impl.SendTelegramMessage(update.Message.Chat.ID, "Error in decrypting data")
-				return
-			}
-			impl.linkService.AddLink(decryptedEmail, &bean.GetLink{Receiver: decryptedEmail, Sender: decryptedEmail, Message: update.Message.Text, UUID: "telegram"})
-		}
-		impl.SendTelegramMessage(update.Message.Chat.ID, "Message sent to Get Link.\nVisit codingkaro.in for more.\nHelp: https://twitter.com/iraunitverma")
+		locale := impl.locale(update.Message.Chat.ID)
+		impl.sendTelegramMessageWithButtons(update.Message.Chat.ID, impl.catalog.Render(locale, "telegram.link_saved", nil), impl.linkButtons(locale, update.Message.From.ID, linkIDs, update.Message.Text))
 	} else {
 		if update.Message.Photo != nil && len(update.Message.Photo) > 0 {
+			locale := impl.locale(update.Message.Chat.ID)
+			image := impl.catalog.Render(locale, "telegram.media.image", nil)
 			flag := true
+			fileName := ""
 			for _, photo := range update.Message.Photo {
 				filePath, err := impl.getFilePath(photo.FileID)
 				if err != nil {
 					impl.logger.Errorw("Error in getting file path", "Error", err)
-					impl.SendTelegramMessage(update.Message.Chat.ID, "Error in getting file path, cannot send image to get link devices.")
+					impl.SendTelegramMessage(update.Message.Chat.ID, impl.catalog.Render(locale, "telegram.file_path_failed", messages.Data{"Type": image}))
 					return
 				}
 				fileArray := strings.Split(filePath, "/")
-				fileName := fileArray[len(fileArray)-1]
+				fileName = fileArray[len(fileArray)-1]
 				err = impl.downloadMedia(fmt.Sprintf("https://api.telegram.org/file/bot%s/%s", impl.cfg.TelegramToken, filePath), strconv.FormatInt(update.Message.From.ID, 10), fileName)
 				if err != nil {
 					impl.logger.Errorw("Error in downloading media", "Error", err)
-					impl.SendTelegramMessage(update.Message.Chat.ID, "error in downloading media, cannot send image to get link devices. "+err.Error())
+					impl.SendTelegramMessage(update.Message.Chat.ID, impl.catalog.Render(locale, "telegram.download_failed", messages.Data{"Type": image, "Error": err.Error()}))
 					flag = false
 				}
 			}
 			if flag {
-				impl.SendTelegramMessage(update.Message.Chat.ID, "Image uploaded successfully. \n\nYou can share your feedback or report an issue on codingkaro.in.\n\nRegards\nRaunit Verma\nShypt Solution")
+				impl.sendTelegramMessageWithButtons(update.Message.Chat.ID, impl.catalog.Render(locale, "telegram.uploaded", messages.Data{"Type": image}), impl.fileButtons(locale, update.Message.From.ID, fileName))
 			}
 		} else {
 			switch {
 			case update.Message.Document != nil:
-				impl.handleMedia("Document", update.Message.Document.FileID, update.Message.Document.FileName, update.Message.Chat.ID, update.Message.From.ID)
+				impl.handleMedia("document", update.Message.Document.FileID, update.Message.Document.FileName, update.Message.Chat.ID, update.Message.From.ID)
 			case update.Message.Audio != nil:
-				impl.handleMedia("Audio", update.Message.Audio.FileID, update.Message.Audio.FileName, update.Message.Chat.ID, update.Message.From.ID)
+				impl.handleMedia("audio", update.Message.Audio.FileID, update.Message.Audio.FileName, update.Message.Chat.ID, update.Message.From.ID)
 			case update.Message.Video != nil:
-				impl.handleMedia("Video", update.Message.Video.FileID, update.Message.Video.FileName, update.Message.Chat.ID, update.Message.From.ID)
+				impl.handleMedia("video", update.Message.Video.FileID, update.Message.Video.FileName, update.Message.Chat.ID, update.Message.From.ID)
 			}
 		}
 	}
 }
 
 func (impl *TelegramImpl) handleMedia(uploadType string, fileID, fileName string, chatID, userID int64) {
+	locale := impl.locale(chatID)
+	data := messages.Data{"Type": impl.catalog.Render(locale, "telegram.media."+uploadType, nil)}
 	filePath, err := impl.getFilePath(fileID)
 	if err != nil {
 		impl.logger.Errorw(fmt.Sprintf("Error in getting file path for %s", uploadType), "Error", err)
-		impl.SendTelegramMessage(chatID, fmt.Sprintf("Error in getting file path, cannot send %s to get link devices.", uploadType))
+		impl.SendTelegramMessage(chatID, impl.catalog.Render(locale, "telegram.file_path_failed", data))
 		return
 	}
 	if fileName == "" {
@@ -190,72 +313,163 @@ func (impl *TelegramImpl) handleMedia(uploadType string, fileID, fileName string
 	err = impl.downloadMedia(fmt.Sprintf("https://api.telegram.org/file/bot%s/%s", impl.cfg.TelegramToken, filePath), strconv.FormatInt(userID, 10), fileName)
 	if err != nil {
 		impl.logger.Errorw(fmt.Sprintf("Error in downloading %s", uploadType), "Error", err)
-		impl.SendTelegramMessage(chatID, fmt.Sprintf("Error in downloading %s, cannot send %s to get link devices. %s", uploadType, uploadType, err.Error()))
+		data["Error"] = err.Error()
+		impl.SendTelegramMessage(chatID, impl.catalog.Render(locale, "telegram.download_failed", data))
 	} else {
-		impl.SendTelegramMessage(chatID, fmt.Sprintf("%s uploaded successfully.\n\nYou can share your feedback or report an issue on codingkaro.in.\n\nRegards\nRaunit Verma\nShypt Solution", uploadType))
+		impl.sendTelegramMessageWithButtons(chatID, impl.catalog.Render(locale, "telegram.uploaded", data), impl.fileButtons(locale, userID, fileName))
 	}
 }
 
 func (impl *TelegramImpl) SendTelegramMessage(chatID int64, message string) {
-	if impl.bot == nil {
+	impl.sendTelegramMessageWithButtons(chatID, message, nil)
+}
+
+func (impl *TelegramImpl) reply(chatID int64, key string, data messages.Data) {
+	impl.SendTelegramMessage(chatID, impl.catalog.Render(impl.locale(chatID), key, data))
+}
+
+// locale returns the language picked by an account linked to chatID, else the Telegram app language of its last sender.
+// In a private chat the chat id is the user id.
+func (impl *TelegramImpl) locale(chatID int64) string {
+	if emails, err := impl.getLinkedEmails(strconv.FormatInt(chatID, 10)); err == nil {
+		for _, email := range emails {
+			if locale := impl.notification.GetLocale(email); locale != "" {
+				return locale
+			}
+		}
+	}
+	language, err := impl.client.Get(impl.ctx, fmt.Sprintf(telegramLanguageKey, chatID)).Result()
+	if err != nil {
+		return ""
+	}
+	return language
+}
+
+func (impl *TelegramImpl) rememberLanguage(chatID int64, from *models.User) {
+	if from == nil || from.LanguageCode == "" {
 		return
 	}
-	_, err := impl.bot.SendMessage(impl.ctx, &bot.SendMessageParams{
-		ChatID: chatID,
-		Text:   message,
-	})
+	err := impl.client.Set(impl.ctx, fmt.Sprintf(telegramLanguageKey, chatID), from.LanguageCode, telegramLanguageTTL).Err()
 	if err != nil {
-		impl.logger.Errorw("error in sending telegram message", "error", err)
+		impl.logger.Errorw("error in saving telegram language", "error", err)
 	}
 }
 
-func (impl *TelegramImpl) VerifyTelegram(email string, claims *bean.TelegramVerificationClaims) error {
-	senderId := strconv.FormatInt(claims.SenderId, 10)
-	chatId := strconv.FormatInt(claims.ChatId, 10)
-	senderIdCpy := senderId
-	chatIdCpy := chatId
-	encryptedEmail, err := cryptography.EncryptData(senderId, email, impl.logger)
+func (impl *TelegramImpl) SendMessageToEmail(userEmail string, message string) error {
+	return impl.sendMessageToEmailAt(userEmail, message, time.Now())
+}
+
+// NotifyLink sends a link the user did not send themselves to every chat of the user, subject to their notification preferences.
+func (impl *TelegramImpl) NotifyLink(userEmail string, message string) error {
+	at, err := impl.notification.Check(userEmail, util.TELEGRAM, util.EventLinkReceived)
 	if err != nil {
-		impl.logger.Errorw("Error in encrypting data", "Error: ", err)
 		return err
 	}
+	return impl.sendMessageToEmailAt(userEmail, message, at)
+}
 
-	if claims.SenderId != 0 {
-		senderId, err = cryptography.EncryptData(senderId, senderId, impl.logger)
+func (impl *TelegramImpl) notify(userEmail string, notification bean.Notification, at time.Time) error {
+	return impl.sendMessageToEmailAt(userEmail, fmt.Sprintf("%s\n\n%s", notification.Title, notification.Message), at)
+}
+
+func (impl *TelegramImpl) sendMessageToEmailAt(userEmail string, message string, at time.Time) error {
+	chatIds, err := impl.getChatIdsFromEmail(userEmail)
+	if err != nil {
+		return err
+	}
+	for _, chatId := range chatIds {
+		err = impl.outbox.EnqueueAt(util.TELEGRAM, strconv.FormatInt(chatId, 10), bean.OutboxTelegramMessage{Text: message}, at)
 		if err != nil {
-			impl.logger.Errorw("Error in encrypting data", "Error: ", err)
+			impl.logger.Errorw("error in queueing telegram message", "error", err)
+		}
+	}
+	return nil
+}
+
+func (impl *TelegramImpl) SendFileToEmail(userEmail, appName, fileName string) error {
+	if !isStoredFileApp(appName) {
+		return fmt.Errorf("unknown app %s", appName)
+	}
+	chatIds, err := impl.getChatIdsFromEmail(userEmail)
+	if err != nil {
+		return errors.New(impl.catalog.Render(impl.notification.GetLocale(userEmail), "telegram.not_connected", nil))
+	}
+	for _, chatId := range chatIds {
+		if err = impl.sendStoredFile(chatId, userEmail, appName, fileName, time.Now()); err != nil {
 			return err
 		}
 	}
-	if claims.ChatId != 0 {
-		chatId, err = cryptography.EncryptData(senderId, chatId, impl.logger)
+	return nil
+}
+
+func (impl *TelegramImpl) getChatIdsFromEmail(userEmail string) ([]int64, error) {
+	allEmails, err := impl.GetUsersFromEmail(userEmail)
+	if err != nil {
+		return nil, err
+	}
+	var chatIds []int64
+	for _, email := range allEmails {
+		decryptedData, err := cryptography.DecryptData(userEmail, email.ChatId, impl.logger)
 		if err != nil {
-			impl.logger.Errorw("Error in encrypting data", "Error: ", err)
-			return err
+			impl.logger.Errorw("Error in decrypting data", "Error: ", err)
+			return nil, err
 		}
+		chatId, err := strconv.ParseInt(decryptedData, 10, 64)
+		if err != nil {
+			impl.logger.Errorw("Error in converting string to int", "Error: ", err)
+			return nil, err
+		}
+		chatIds = append(chatIds, chatId)
 	}
-	claims.Email = encryptedEmail
-	err = impl.repository.InsertUpdateTelegramNumber(encryptedEmail, chatId, senderId)
+	return chatIds, nil
+}
+
+func (impl *TelegramImpl) sendTelegramMessageWithButtons(chatID int64, message string, buttons [][]bean.TelegramButton) {
+	err := impl.outbox.Enqueue(util.TELEGRAM, strconv.FormatInt(chatID, 10), bean.OutboxTelegramMessage{Text: message, Buttons: buttons})
 	if err != nil {
-		impl.logger.Errorw("Error in inserting data", "Error: ", err)
+		impl.logger.Errorw("error in queueing telegram message", "error", err)
+	}
+}
+
+func (impl *TelegramImpl) sendOutboxMessage(recipient string, payload []byte) error {
+	chatID, err := strconv.ParseInt(recipient, 10, 64)
+	if err != nil {
+		return err
+	}
+	var message bean.OutboxTelegramMessage
+	if err = json.Unmarshal(payload, &message); err != nil {
 		return err
 	}
+	if message.FileName != "" {
+		return impl.sendOutboxFile(chatID, &message)
+	}
+	_, err = impl.botFor(chatID).bot.SendMessage(impl.ctx, &bot.SendMessageParams{
+		ChatID:      chatID,
+		Text:        message.Text,
+		ReplyMarkup: telegramReplyMarkup(message.Buttons),
+	})
+	return err
+}
 
-	encryptedEmail, err = cryptography.EncryptData(email, email, impl.logger)
+func (impl *TelegramImpl) VerifyTelegram(email string, claims *bean.TelegramVerificationClaims) error {
+	senderId := strconv.FormatInt(claims.SenderId, 10)
+	chatId := strconv.FormatInt(claims.ChatId, 10)
+	senderIdCpy := senderId
+	encryptedEmail, err := cryptography.EncryptData(senderId, email, impl.logger)
 	if err != nil {
 		impl.logger.Errorw("Error in encrypting data", "Error: ", err)
 		return err
 	}
 
 	if claims.SenderId != 0 {
-		senderId, err = cryptography.EncryptData(email, senderIdCpy, impl.logger)
+		senderId, err = cryptography.EncryptData(senderId, senderId, impl.logger)
 		if err != nil {
 			impl.logger.Errorw("Error in encrypting data", "Error: ", err)
 			return err
 		}
 	}
 	if claims.ChatId != 0 {
-		chatId, err = cryptography.EncryptData(email, chatIdCpy, impl.logger)
+		chatId, err = cryptography.EncryptData(senderId, chatId, impl.logger)
 		if err != nil {
 			impl.logger.Errorw("Error in encrypting data", "Error: ", err)
 			return err
@@ -268,7 +482,19 @@ func (impl *TelegramImpl) VerifyTelegram(email string, claims *bean.TelegramVeri
 		return err
 	}
 
-	return nil
+	// keyed by the email as well, one channel identity per account, so every linked account can be listed and
+	// unlinked from the web. Accounts are linked in private chats, where the chat id is the sender id.
+	encryptedEmail, err = cryptography.EncryptData(email, email, impl.logger)
+	if err != nil {
+		impl.logger.Errorw("Error in encrypting data", "Error: ", err)
+		return err
+	}
+	encryptedSender, err := cryptography.EncryptData(email, senderIdCpy, impl.logger)
+	if err != nil {
+		impl.logger.Errorw("Error in encrypting data", "Error: ", err)
+		return err
+	}
+	return impl.repository.InsertChannelIdentity(&bean.ChannelIdentity{Channel: util.TELEGRAM, Identity: encryptedEmail, Email: encryptedSender})
 }
 
 func (impl *TelegramImpl) GetUsersFromTelegramNumber(sender string) ([]bean.TelegramEmail, error) { // 2121983277
@@ -294,11 +520,27 @@ func (impl *TelegramImpl) GetUsersFromEmail(email string) ([]bean.TelegramEmail,
 		impl.logger.Errorw("Error in encryption", "Error: ", err)
 		return nil, err
 	}
-	allEmails, err := impl.repository.GetEmailsFromEmail(encryptedSender)
+	identities, err := impl.repository.GetChannelIdentities(util.TELEGRAM, encryptedSender)
+	if err != nil {
+		return nil, err
+	}
+	// email-keyed rows written before the email keys moved to channel_identities
+	rows, err := impl.repository.GetEmailsFromEmail(encryptedSender)
 	if err != nil {
 		impl.logger.Errorw("Error in getting emails from number", "Error: ", err)
 		return nil, err
 	}
+	var allEmails []bean.TelegramEmail
+	seen := make(map[string]bool)
+	for _, identity := range identities {
+		seen[identity.Email] = true
+		allEmails = append(allEmails, bean.TelegramEmail{Email: identity.Identity, SenderId: identity.Email, ChatId: identity.Email})
+	}
+	for _, row := range rows {
+		if !seen[row.SenderId] {
+			allEmails = append(allEmails, row)
+		}
+	}
 	if len(allEmails) == 0 {
 		return nil, pg.ErrNoRows
 	}
@@ -342,36 +584,21 @@ func (impl *TelegramImpl) getFilePath(fileID string) (string, error) {
 }
 
 func (impl *TelegramImpl) downloadMedia(url, sender, fileNameWithExtension string) error {
-
-	allEmails, err := impl.GetUsersFromTelegramNumber(sender)
+	emails, err := impl.getLinkedEmails(sender)
 	if err != nil {
 		impl.logger.Errorw("Error in getting user from telegram number", "Error", err)
 		return fmt.Errorf("have you set your email here. Please send 'set email youremail@gmail.com' and then verify by clicking on the link received on your email")
 	}
+	return impl.downloadMediaForEmails(url, sender, emails, fileNameWithExtension)
+}
 
-	for _, email := range allEmails {
-		decryptedEmail, err := cryptography.DecryptData(sender, email.Email, impl.logger)
+func (impl *TelegramImpl) downloadMediaForEmails(url, sender string, emails []string, fileNameWithExtension string) error {
+	for _, email := range emails {
+		folderPath, err := impl.inbox.userFolder(email)
 		if err != nil {
-			impl.logger.Errorw("Error in decrypting data", "Error: ", err)
-			return err
-		}
-		folderPath := impl.fileManager.GetPathToSaveFileFromTelegram(util.EncodeString(decryptedEmail))
-		impl.fileManager.DeleteFileFromPathOlderThan24Hours(folderPath)
-		folderSize, err := impl.fileManager.GetSizeOfADirectory(folderPath)
-		if err != nil {
-			impl.logger.Errorw("Error in getting folder size", "Error", err)
 			return err
 		}
-		maxLimit := util.FreeTelegramFileLimitSizeMB
-		if impl.repository.IsUserPremiumUser(email.Email) {
-			maxLimit = util.PremiumTelegramFileLimitSizeMB
-		}
-
-		if folderSize > int64(maxLimit) {
-			impl.fileManager.DeleteAllFileFromPath(folderPath)
-		}
-
-		impl.restClient.DownloadTelegramMediaFromUrl(url, path.Join(folderPath, fileNameWithExtension+".bin"), decryptedEmail)
+		impl.restClient.DownloadTelegramMediaFromUrl(url, path.Join(folderPath, fileNameWithExtension+".bin"), email)
 	}
 
 	return nil
@@ -0,0 +1,96 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"github.com/go-telegram/bot"
+	"github.com/iraunit/get-link-backend/util"
+	"github.com/redis/go-redis/v9"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const telegramLeaderKey = "telegram:poller:%s"
+
+// refreshTelegramLeader extends the lease only while this instance still holds it.
+var refreshTelegramLeader = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+return 0`)
+
+func (impl *TelegramImpl) WebhookHandler(botID string) (http.HandlerFunc, bool) {
+	for _, b := range impl.bots {
+		if b.botID() == botID {
+			return b.bot.WebhookHandler(), true
+		}
+	}
+	return nil, false
+}
+
+func (impl *TelegramImpl) botID() string {
+	id, _, _ := strings.Cut(impl.cfg.TelegramToken, ":")
+	return id
+}
+
+// startWebhook points the bot at the shared webhook route, which picks the bot from the bot query parameter.
+func (impl *TelegramImpl) startWebhook() {
+	_, err := impl.bot.SetWebhook(impl.ctx, &bot.SetWebhookParams{
+		URL:         fmt.Sprintf("%s%s?bot=%s", impl.cfg.BaseUrl, util.TelegramWebhook, url.QueryEscape(impl.botID())),
+		SecretToken: impl.cfg.WebhookSecret,
+	})
+	if err != nil {
+		impl.logger.Errorw("error in setting telegram webhook", "error", err)
+	}
+	impl.async.Run(func() {
+		impl.bot.StartWebhook(impl.ctx)
+	})
+}
+
+// startPolling long polls getUpdates from a single leader so replicas don't compete for updates.
+func (impl *TelegramImpl) startPolling() {
+	_, err := impl.bot.DeleteWebhook(impl.ctx, &bot.DeleteWebhookParams{})
+	if err != nil {
+		impl.logger.Errorw("error in deleting telegram webhook", "error", err)
+	}
+
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	instanceId := hex.EncodeToString(b)
+	leaderKey := fmt.Sprintf(telegramLeaderKey, impl.tenant.ID)
+
+	impl.async.Run(func() {
+		ticker := time.NewTicker(impl.cfg.LeaderTTL / 3)
+		defer ticker.Stop()
+		for {
+			acquired, err := impl.client.SetNX(impl.ctx, leaderKey, instanceId, impl.cfg.LeaderTTL).Result()
+			if err != nil {
+				impl.logger.Errorw("error in acquiring telegram poller lock", "error", err)
+			}
+			if acquired {
+				impl.logger.Infow("telegram poller leadership acquired", "tenant", impl.tenant.ID, "instance", instanceId)
+				impl.pollWhileLeader(leaderKey, instanceId, ticker)
+				impl.logger.Infow("telegram poller leadership lost", "tenant", impl.tenant.ID, "instance", instanceId)
+			}
+			<-ticker.C
+		}
+	})
+}
+
+func (impl *TelegramImpl) pollWhileLeader(leaderKey, instanceId string, ticker *time.Ticker) {
+	ctx, cancel := context.WithCancel(impl.ctx)
+	defer cancel()
+	impl.async.Run(func() {
+		impl.bot.Start(ctx)
+	})
+	for range ticker.C {
+		refreshed, err := refreshTelegramLeader.Run(impl.ctx, impl.client, []string{leaderKey}, instanceId, impl.cfg.LeaderTTL.Milliseconds()).Int()
+		if err != nil || refreshed == 0 {
+			return
+		}
+	}
+}
@@ -0,0 +1,262 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/caarlos0/env"
+	"github.com/iraunit/get-link-backend/pkg/cryptography"
+	"github.com/iraunit/get-link-backend/pkg/repository"
+	"github.com/iraunit/get-link-backend/util"
+	"github.com/iraunit/get-link-backend/util/bean"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+	"os"
+	"slices"
+	"strings"
+)
+
+const (
+	defaultTenantID = "default"
+	// tenantKey caches the channel_tenants row of an encrypted identity
+	tenantKey = "tenant:%s:%s"
+)
+
+type TenantService interface {
+	// Tenants returns every tenant, the default one first.
+	Tenants() []*bean.Tenant
+	Default() *bean.Tenant
+	// Get returns the tenant with id, or the default tenant if there is none.
+	Get(id string) *bean.Tenant
+	ByWhatsappPhoneID(phoneID string) (*bean.Tenant, bool)
+	// ByOrigin returns the tenant whose allowed origins contain origin, or the default tenant.
+	ByOrigin(origin string) *bean.Tenant
+	AllowedOrigins() []string
+	// ForIdentity returns the tenant identity last wrote to on channel, or the default tenant.
+	ForIdentity(channel, identity string) *bean.Tenant
+	// Remember records that identity wrote to tenant on channel, so replies go out from the same number or bot.
+	Remember(channel, identity string, tenant *bean.Tenant)
+}
+
+type TenantServiceImpl struct {
+	logger     *zap.SugaredLogger
+	cfg        bean.TenantCfg
+	ctx        context.Context
+	client     *redis.Client
+	repository repository.Repository
+	tenants    []*bean.Tenant
+}
+
+func NewTenantServiceImpl(logger *zap.SugaredLogger, client *redis.Client, repository repository.Repository) *TenantServiceImpl {
+	cfg := bean.TenantCfg{}
+	if err := env.Parse(&cfg); err != nil {
+		logger.Fatal("Error loading Cfg from env", "Error", zap.Error(err))
+	}
+	impl := &TenantServiceImpl{
+		logger:     logger,
+		cfg:        cfg,
+		ctx:        context.Background(),
+		client:     client,
+		repository: repository,
+	}
+
+	var err error
+	if cfg.File == "" {
+		impl.tenants, err = envTenant(cfg)
+	} else {
+		impl.tenants, err = readTenants(cfg.File)
+	}
+	if err != nil {
+		logger.Fatalw("Error loading tenants", "File", cfg.File, "Error", err)
+	}
+	for _, tenant := range impl.tenants {
+		logger.Infow("Loaded tenant", "ID", tenant.ID, "Name", tenant.Name, "WhatsappPhoneID", tenant.WhatsappPhoneID)
+	}
+	return impl
+}
+
+// envTenant is the single tenant of a deployment without TENANTS_FILE. Like the default tenant of a file, it must be
+// able to verify whatsapp webhooks.
+func envTenant(cfg bean.TenantCfg) ([]*bean.Tenant, error) {
+	whatsappCfg := bean.WhatsAppConfig{}
+	telegramCfg := bean.TelegramCfg{}
+	mailCfg := bean.MailConfig{}
+	for _, cfg := range []interface{}{&whatsappCfg, &telegramCfg, &mailCfg} {
+		if err := env.Parse(cfg); err != nil {
+			return nil, err
+		}
+	}
+	if whatsappCfg.AppSecret == "" {
+		return nil, fmt.Errorf("WHATSAPP_APP_SECRET is required to verify whatsapp webhooks")
+	}
+	return []*bean.Tenant{{
+		ID:                  defaultTenantID,
+		Name:                mailCfg.FromName,
+		WhatsappPhoneID:     whatsappCfg.PhoneID,
+		WhatsappAccessToken: whatsappCfg.AuthToken,
+		WhatsappAppSecret:   whatsappCfg.AppSecret,
+		TelegramToken:       telegramCfg.TelegramToken,
+		MailFrom:            mailCfg.From,
+		MailFromName:        mailCfg.FromName,
+		AllowedOrigins:      append(slices.Clone(util.DefaultAllowedOrigins), fmt.Sprintf(util.ChromeExtensionOrigin, cfg.ChromeExtensionID)),
+		Signature:           cfg.Signature,
+		FeedbackUrl:         cfg.FeedbackUrl,
+		ChromeExtensionID:   cfg.ChromeExtensionID,
+	}}, nil
+}
+
+// readTenants loads the tenants file. The first tenant is the default one, used for users no tenant has seen yet,
+// so it must have a WhatsApp number and a Telegram bot.
+func readTenants(file string) ([]*bean.Tenant, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var tenants []*bean.Tenant
+	if err = json.Unmarshal(data, &tenants); err != nil {
+		return nil, err
+	}
+	if len(tenants) == 0 {
+		return nil, fmt.Errorf("no tenants in %s", file)
+	}
+	if tenants[0].WhatsappPhoneID == "" || tenants[0].TelegramToken == "" {
+		return nil, fmt.Errorf("default tenant %s needs a whatsapp_phone_id and a telegram_token", tenants[0].ID)
+	}
+
+	ids := map[string]bool{}
+	phoneIDs := map[string]bool{}
+	tokens := map[string]bool{}
+	for _, tenant := range tenants {
+		if tenant.ID == "" {
+			return nil, fmt.Errorf("tenant %q has no id", tenant.Name)
+		}
+		if ids[tenant.ID] {
+			return nil, fmt.Errorf("duplicate tenant id %s", tenant.ID)
+		}
+		ids[tenant.ID] = true
+		if tenant.ChromeExtensionID == "" {
+			return nil, fmt.Errorf("tenant %s has no chrome_extension_id", tenant.ID)
+		}
+		tenant.AllowedOrigins = append(tenant.AllowedOrigins, fmt.Sprintf(util.ChromeExtensionOrigin, tenant.ChromeExtensionID))
+		if tenant.WhatsappPhoneID != "" {
+			if phoneIDs[tenant.WhatsappPhoneID] {
+				return nil, fmt.Errorf("whatsapp phone id of tenant %s is used by another tenant", tenant.ID)
+			}
+			if tenant.WhatsappAccessToken == "" || tenant.WhatsappAppSecret == "" {
+				return nil, fmt.Errorf("tenant %s has a whatsapp phone id but no whatsapp_access_token or whatsapp_app_secret", tenant.ID)
+			}
+			phoneIDs[tenant.WhatsappPhoneID] = true
+		}
+		if tenant.TelegramToken != "" {
+			if tokens[tenant.TelegramToken] {
+				return nil, fmt.Errorf("telegram token of tenant %s is used by another tenant", tenant.ID)
+			}
+			tokens[tenant.TelegramToken] = true
+		}
+	}
+	return tenants, nil
+}
+
+func (impl *TenantServiceImpl) Tenants() []*bean.Tenant {
+	return impl.tenants
+}
+
+func (impl *TenantServiceImpl) Default() *bean.Tenant {
+	return impl.tenants[0]
+}
+
+func (impl *TenantServiceImpl) Get(id string) *bean.Tenant {
+	for _, tenant := range impl.tenants {
+		if tenant.ID == id {
+			return tenant
+		}
+	}
+	return impl.Default()
+}
+
+func (impl *TenantServiceImpl) ByWhatsappPhoneID(phoneID string) (*bean.Tenant, bool) {
+	for _, tenant := range impl.tenants {
+		if phoneID != "" && tenant.WhatsappPhoneID == phoneID {
+			return tenant, true
+		}
+	}
+	return nil, false
+}
+
+func (impl *TenantServiceImpl) ByOrigin(origin string) *bean.Tenant {
+	for _, tenant := range impl.tenants {
+		if slices.ContainsFunc(tenant.AllowedOrigins, func(allowed string) bool { return strings.EqualFold(allowed, origin) }) {
+			return tenant
+		}
+	}
+	return impl.Default()
+}
+
+// AllowedOrigins returns the CORS origins of every tenant.
+func (impl *TenantServiceImpl) AllowedOrigins() []string {
+	var origins []string
+	for _, tenant := range impl.tenants {
+		for _, origin := range tenant.AllowedOrigins {
+			if !slices.Contains(origins, origin) {
+				origins = append(origins, origin)
+			}
+		}
+	}
+	return origins
+}
+
+func (impl *TenantServiceImpl) ForIdentity(channel, identity string) *bean.Tenant {
+	encryptedIdentity, err := cryptography.EncryptData(identity, identity, impl.logger)
+	if err != nil {
+		return impl.Default()
+	}
+	key := fmt.Sprintf(tenantKey, channel, encryptedIdentity)
+	id, err := impl.client.Get(impl.ctx, key).Result()
+	if err == nil {
+		return impl.Get(id)
+	}
+	if !errors.Is(err, redis.Nil) {
+		impl.logger.Errorw("Error in getting cached tenant", "Channel", channel, "Error", err)
+	}
+	channelTenant, err := impl.repository.GetChannelTenant(channel, encryptedIdentity)
+	if err != nil {
+		return impl.Default()
+	}
+	tenant := impl.Get(channelTenant.TenantID)
+	impl.cache(key, tenant)
+	return tenant
+}
+
+func (impl *TenantServiceImpl) Remember(channel, identity string, tenant *bean.Tenant) {
+	encryptedIdentity, err := cryptography.EncryptData(identity, identity, impl.logger)
+	if err != nil {
+		return
+	}
+	key := fmt.Sprintf(tenantKey, channel, encryptedIdentity)
+	if id, err := impl.client.Get(impl.ctx, key).Result(); err == nil && id == tenant.ID {
+		return
+	}
+	err = impl.repository.InsertUpdateChannelTenant(&bean.ChannelTenant{Channel: channel, Identity: encryptedIdentity, TenantID: tenant.ID})
+	if err != nil {
+		return
+	}
+	impl.cache(key, tenant)
+}
+
+func (impl *TenantServiceImpl) cache(key string, tenant *bean.Tenant) {
+	if err := impl.client.Set(impl.ctx, key, tenant.ID, impl.cfg.CacheTTL).Err(); err != nil {
+		impl.logger.Errorw("Error in caching tenant", "Error", err)
+	}
+}
+
+// fileLimits returns the tenant's WhatsApp and Telegram folder limits, falling back to the channel's.
+func fileLimits(tenant *bean.Tenant, freeLimitMB, premiumLimitMB int) (int, int) {
+	if tenant.Quotas.FreeFileLimitMB > 0 {
+		freeLimitMB = tenant.Quotas.FreeFileLimitMB
+	}
+	if tenant.Quotas.PremiumFileLimitMB > 0 {
+		premiumLimitMB = tenant.Quotas.PremiumFileLimitMB
+	}
+	return freeLimitMB, premiumLimitMB
+}
@@ -3,6 +3,7 @@ package tokenService
 import (
 	"github.com/caarlos0/env"
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/iraunit/get-link-backend/util"
 	"github.com/iraunit/get-link-backend/util/bean"
 	"go.uber.org/zap"
 	"time"
@@ -12,6 +13,9 @@ type TokenService interface {
 	WhatsappEmailVerificationToken(claims *bean.WhatsappVerificationClaims) (string, error)
 	TelegramEmailVerificationToken(claims *bean.TelegramVerificationClaims) (string, error)
 	ShareFileVerificationToken(claims *bean.ShareFileClaims) (string, error)
+	InboundEmailVerificationToken(claims *bean.InboundEmailVerificationClaims) (string, error)
+	DigestActionToken(claims *bean.DigestClaims) (string, error)
+	ChannelVerificationToken(claims *bean.ChannelVerificationClaims) (string, error)
 }
 
 type TokenServiceImpl struct {
@@ -56,3 +60,31 @@ func (impl *TokenServiceImpl) ShareFileVerificationToken(claims *bean.ShareFileC
 	tokenStr, err := token.SignedString([]byte(impl.cfg.JwtKey))
 	return tokenStr, err
 }
+
+func (impl *TokenServiceImpl) InboundEmailVerificationToken(claims *bean.InboundEmailVerificationClaims) (string, error) {
+	claims.ExpiresAt = &jwt.NumericDate{
+		Time: time.Now().Add(24 * time.Hour),
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	tokenStr, err := token.SignedString([]byte(impl.cfg.JwtKey))
+	return tokenStr, err
+}
+
+func (impl *TokenServiceImpl) DigestActionToken(claims *bean.DigestClaims) (string, error) {
+	claims.ExpiresAt = &jwt.NumericDate{
+		Time: time.Now().Add(30 * 24 * time.Hour),
+	}
+	claims.Audience = jwt.ClaimStrings{util.DigestAudience}
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	tokenStr, err := token.SignedString([]byte(impl.cfg.JwtKey))
+	return tokenStr, err
+}
+
+func (impl *TokenServiceImpl) ChannelVerificationToken(claims *bean.ChannelVerificationClaims) (string, error) {
+	claims.ExpiresAt = &jwt.NumericDate{
+		Time: time.Now().Add(24 * time.Hour),
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	tokenStr, err := token.SignedString([]byte(impl.cfg.JwtKey))
+	return tokenStr, err
+}
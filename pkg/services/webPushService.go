@@ -0,0 +1,365 @@
+package services
+
+import (
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/caarlos0/env"
+	"github.com/go-pg/pg"
+	"github.com/go-resty/resty/v2"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/iraunit/get-link-backend/pkg/cryptography"
+	"github.com/iraunit/get-link-backend/pkg/fileManager"
+	"github.com/iraunit/get-link-backend/pkg/repository"
+	"github.com/iraunit/get-link-backend/util"
+	"github.com/iraunit/get-link-backend/util/bean"
+	"go.uber.org/zap"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	webPushEventLink         = "link"
+	webPushEventFile         = "file"
+	webPushEventNotification = "notification"
+	webPushMaxMessageLength  = 500
+)
+
+type WebPushService interface {
+	GetPublicKey() string
+	Subscribe(userEmail, uuid string, subscription *bean.PushSubscriptionRequest) error
+	Unsubscribe(userEmail, endpoint string) error
+}
+
+type WebPushServiceImpl struct {
+	logger       *zap.SugaredLogger
+	cfg          bean.WebPushCfg
+	async        *util.Async
+	client       *resty.Client
+	repository   repository.Repository
+	linkService  LinkService
+	outbox       OutboxService
+	notification NotificationService
+	vapidKey     *ecdsa.PrivateKey
+	publicKey    string
+}
+
+func NewWebPushServiceImpl(logger *zap.SugaredLogger, async *util.Async, repository repository.Repository, linkService LinkService, fileManager fileManager.FileManager, outbox OutboxService, notificationService NotificationService) *WebPushServiceImpl {
+	cfg := bean.WebPushCfg{}
+	if err := env.Parse(&cfg); err != nil {
+		logger.Fatal("Error loading Cfg from env", "Error", zap.Error(err))
+	}
+	if cfg.EncryptionKey == "" {
+		logger.Fatal("ENCRYPTION_KEY is required to encrypt the stored VAPID key")
+	}
+	impl := &WebPushServiceImpl{
+		logger:       logger,
+		cfg:          cfg,
+		async:        async,
+		client:       resty.New(),
+		repository:   repository,
+		linkService:  linkService,
+		outbox:       outbox,
+		notification: notificationService,
+	}
+	if err := impl.loadVapidKey(); err != nil {
+		logger.Fatal("Error loading VAPID key", "Error", zap.Error(err))
+	}
+	outbox.RegisterSender(util.WEBPUSH, impl.sendOutboxMessage)
+	linkService.RegisterAddLinkHook(impl.notifyLink)
+	fileManager.RegisterSaveFileHook(impl.notifyFile)
+	notificationService.RegisterNotifier(util.WEBPUSH, impl.notify)
+	return impl
+}
+
+// loadVapidKey uses VAPID_PRIVATE_KEY when set, otherwise the key stored in Postgres, generating it on first start.
+func (impl *WebPushServiceImpl) loadVapidKey() error {
+	privateKey := impl.cfg.VapidPrivateKey
+	if privateKey == "" {
+		stored, err := impl.repository.GetVapidKey()
+		if errors.Is(err, pg.ErrNoRows) {
+			if err = impl.generateVapidKey(); err != nil {
+				return err
+			}
+			stored, err = impl.repository.GetVapidKey()
+		}
+		if err != nil {
+			return err
+		}
+		privateKey, err = cryptography.DecryptData(impl.cfg.EncryptionKey, stored.PrivateKey, impl.logger)
+		if err != nil {
+			return err
+		}
+	}
+
+	scalar, err := decodeBase64Url(privateKey)
+	if err != nil {
+		return err
+	}
+	key, err := ecdh.P256().NewPrivateKey(scalar)
+	if err != nil {
+		return err
+	}
+	public := key.PublicKey().Bytes()
+	impl.vapidKey = &ecdsa.PrivateKey{
+		PublicKey: ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(public[1:33]), Y: new(big.Int).SetBytes(public[33:])},
+		D:         new(big.Int).SetBytes(scalar),
+	}
+	impl.publicKey = base64.RawURLEncoding.EncodeToString(public)
+	return nil
+}
+
+func (impl *WebPushServiceImpl) generateVapidKey() error {
+	key, err := ecdh.P256().GenerateKey(rand.Reader)
+	if err != nil {
+		return err
+	}
+	encryptedKey, err := cryptography.EncryptData(impl.cfg.EncryptionKey, base64.RawURLEncoding.EncodeToString(key.Bytes()), impl.logger)
+	if err != nil {
+		return err
+	}
+	return impl.repository.InsertVapidKey(&bean.VapidKey{
+		PublicKey:  base64.RawURLEncoding.EncodeToString(key.PublicKey().Bytes()),
+		PrivateKey: encryptedKey,
+	})
+}
+
+// GetPublicKey returns the applicationServerKey browsers subscribe with.
+func (impl *WebPushServiceImpl) GetPublicKey() string {
+	return impl.publicKey
+}
+
+func (impl *WebPushServiceImpl) Subscribe(userEmail, uuid string, subscription *bean.PushSubscriptionRequest) error {
+	if !isPushServiceEndpoint(subscription.Endpoint, impl.cfg.EndpointHosts) {
+		return fmt.Errorf("endpoint must be an https url of a known push service")
+	}
+	p256dh, err := decodeBase64Url(subscription.Keys.P256dh)
+	if err != nil {
+		return fmt.Errorf("invalid p256dh key")
+	}
+	auth, err := decodeBase64Url(subscription.Keys.Auth)
+	if err != nil || !cryptography.ValidWebPushKeys(p256dh, auth) {
+		return fmt.Errorf("invalid subscription keys")
+	}
+
+	encryptedEmail, err := cryptography.EncryptData(userEmail, userEmail, impl.logger)
+	if err != nil {
+		impl.logger.Errorw("Error in encrypting data", "Error: ", err)
+		return err
+	}
+	encryptedEndpoint, err := cryptography.EncryptData(userEmail, subscription.Endpoint, impl.logger)
+	if err != nil {
+		impl.logger.Errorw("Error in encrypting data", "Error: ", err)
+		return err
+	}
+	encryptedP256dh, err := cryptography.EncryptData(userEmail, subscription.Keys.P256dh, impl.logger)
+	if err != nil {
+		impl.logger.Errorw("Error in encrypting data", "Error: ", err)
+		return err
+	}
+	encryptedAuth, err := cryptography.EncryptData(userEmail, subscription.Keys.Auth, impl.logger)
+	if err != nil {
+		impl.logger.Errorw("Error in encrypting data", "Error: ", err)
+		return err
+	}
+	return impl.repository.InsertUpdatePushSubscription(&bean.PushSubscription{
+		Email:    encryptedEmail,
+		UUID:     uuid,
+		Endpoint: encryptedEndpoint,
+		P256dh:   encryptedP256dh,
+		Auth:     encryptedAuth,
+	})
+}
+
+func (impl *WebPushServiceImpl) Unsubscribe(userEmail, endpoint string) error {
+	encryptedEmail, err := cryptography.EncryptData(userEmail, userEmail, impl.logger)
+	if err != nil {
+		impl.logger.Errorw("Error in encrypting data", "Error: ", err)
+		return err
+	}
+	encryptedEndpoint, err := cryptography.EncryptData(userEmail, endpoint, impl.logger)
+	if err != nil {
+		impl.logger.Errorw("Error in encrypting data", "Error: ", err)
+		return err
+	}
+	return impl.repository.DeletePushSubscription(encryptedEmail, encryptedEndpoint)
+}
+
+func (impl *WebPushServiceImpl) notifyLink(userEmail string, link bean.GetLink) {
+	impl.async.Run(func() {
+		at, err := impl.notification.Check(userEmail, util.WEBPUSH, util.EventLinkReceived)
+		if err != nil {
+			return
+		}
+		_ = impl.push(userEmail, link.UUID, bean.WebPushNotification{
+			Event:   webPushEventLink,
+			ID:      link.ID,
+			Message: truncateRunes(link.Message, webPushMaxMessageLength),
+			UUID:    link.UUID,
+		}, at)
+	})
+}
+
+func (impl *WebPushServiceImpl) notifyFile(userEmail, appName, fileName, sourceUUID string) {
+	impl.async.Run(func() {
+		at, err := impl.notification.Check(userEmail, util.WEBPUSH, util.EventFileReceived)
+		if err != nil {
+			return
+		}
+		_ = impl.push(userEmail, sourceUUID, bean.WebPushNotification{
+			Event:   webPushEventFile,
+			Message: truncateRunes(fileName, webPushMaxMessageLength),
+			App:     appName,
+		}, at)
+	})
+}
+
+func (impl *WebPushServiceImpl) notify(userEmail string, notification bean.Notification, at time.Time) error {
+	return impl.push(userEmail, "", bean.WebPushNotification{
+		Event:   webPushEventNotification,
+		Title:   notification.Title,
+		Message: truncateRunes(notification.Message, webPushMaxMessageLength),
+	}, at)
+}
+
+// push queues the notification for the user's subscribed devices, except sourceUUID and those with an open websocket.
+func (impl *WebPushServiceImpl) push(userEmail, sourceUUID string, notification bean.WebPushNotification, at time.Time) error {
+	encryptedEmail, err := cryptography.EncryptData(userEmail, userEmail, impl.logger)
+	if err != nil {
+		return err
+	}
+	subscriptions, err := impl.repository.GetPushSubscriptions(encryptedEmail)
+	if err != nil {
+		return err
+	}
+	for _, subscription := range subscriptions {
+		if subscription.UUID == sourceUUID || impl.linkService.IsDeviceConnected(userEmail, subscription.UUID) {
+			continue
+		}
+		err = impl.outbox.EnqueueAt(util.WEBPUSH, userEmail, bean.WebPushPayload{SubscriptionID: subscription.ID, Notification: notification}, at)
+		if err != nil {
+			impl.logger.Errorw("Error in queueing web push", "Error", err)
+		}
+	}
+	return nil
+}
+
+func (impl *WebPushServiceImpl) sendOutboxMessage(userEmail string, payload []byte) error {
+	var message bean.WebPushPayload
+	if err := json.Unmarshal(payload, &message); err != nil {
+		return err
+	}
+	subscription, err := impl.repository.GetPushSubscription(message.SubscriptionID)
+	if errors.Is(err, pg.ErrNoRows) {
+		// unsubscribed after the push was queued
+		return nil
+	} else if err != nil {
+		return err
+	}
+	endpoint, err := cryptography.DecryptData(userEmail, subscription.Endpoint, impl.logger)
+	if err != nil {
+		return err
+	}
+	if !isPushServiceEndpoint(endpoint, impl.cfg.EndpointHosts) {
+		impl.logger.Errorw("Skipping web push to an unknown push service", "SubscriptionID", subscription.ID)
+		return nil
+	}
+	p256dh, err := cryptography.DecryptData(userEmail, subscription.P256dh, impl.logger)
+	if err != nil {
+		return err
+	}
+	auth, err := cryptography.DecryptData(userEmail, subscription.Auth, impl.logger)
+	if err != nil {
+		return err
+	}
+
+	uaPublic, err := decodeBase64Url(p256dh)
+	if err != nil {
+		return err
+	}
+	authSecret, err := decodeBase64Url(auth)
+	if err != nil {
+		return err
+	}
+	plaintext, err := json.Marshal(message.Notification)
+	if err != nil {
+		return err
+	}
+	body, err := cryptography.EncryptWebPush(uaPublic, authSecret, plaintext)
+	if err != nil {
+		return err
+	}
+	authorization, err := impl.vapidAuthorization(endpoint)
+	if err != nil {
+		return err
+	}
+
+	resp, err := impl.client.R().
+		SetHeader("Authorization", authorization).
+		SetHeader("Content-Encoding", "aes128gcm").
+		SetHeader("Content-Type", "application/octet-stream").
+		SetHeader("TTL", strconv.Itoa(int(impl.cfg.TTL.Seconds()))).
+		SetBody(body).
+		Post(endpoint)
+	if err != nil {
+		impl.logger.Errorw("Error in sending web push", "Error", err)
+		return err
+	}
+	switch {
+	case resp.StatusCode() == http.StatusNotFound || resp.StatusCode() == http.StatusGone:
+		// the browser dropped the subscription, it will never accept pushes again
+		impl.logger.Infow("Removing expired push subscription", "ID", subscription.ID)
+		return impl.repository.DeletePushSubscriptionByID(subscription.ID)
+	case resp.IsError():
+		impl.logger.Errorw("Error in sending web push. status not ok.", "Status", resp.StatusCode(), "Body", resp.String())
+		return fmt.Errorf("status : %d", resp.StatusCode())
+	}
+	return nil
+}
+
+// vapidAuthorization signs the VAPID header (RFC 8292) for the push service that owns endpoint.
+func (impl *WebPushServiceImpl) vapidAuthorization(endpoint string) (string, error) {
+	endpointUrl, err := url.Parse(endpoint)
+	if err != nil {
+		return "", err
+	}
+	token, err := jwt.NewWithClaims(jwt.SigningMethodES256, jwt.MapClaims{
+		"aud": endpointUrl.Scheme + "://" + endpointUrl.Host,
+		"exp": time.Now().Add(12 * time.Hour).Unix(),
+		"sub": impl.cfg.Subject,
+	}).SignedString(impl.vapidKey)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("vapid t=%s, k=%s", token, impl.publicKey), nil
+}
+
+// decodeBase64Url accepts base64url with or without padding, as browsers and libraries differ.
+func decodeBase64Url(value string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(strings.TrimRight(value, "="))
+}
+
+// isPushServiceEndpoint reports whether endpoint is an https url on one of hosts or their subdomains.
+func isPushServiceEndpoint(endpoint string, hosts []string) bool {
+	u, err := url.Parse(endpoint)
+	if err != nil || u.Scheme != "https" || u.User != nil || (u.Port() != "" && u.Port() != "443") {
+		return false
+	}
+	host := strings.ToLower(u.Hostname())
+	for _, allowed := range hosts {
+		allowed = strings.ToLower(strings.TrimSpace(allowed))
+		if allowed != "" && (host == allowed || strings.HasSuffix(host, "."+allowed)) {
+			return true
+		}
+	}
+	return false
+}
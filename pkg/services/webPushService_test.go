@@ -0,0 +1,27 @@
+package services
+
+import "testing"
+
+func TestIsPushServiceEndpoint(t *testing.T) {
+	hosts := []string{"fcm.googleapis.com", "push.apple.com"}
+	cases := []struct {
+		endpoint string
+		want     bool
+	}{
+		{"https://fcm.googleapis.com/fcm/send/abc", true},
+		{"https://web.push.apple.com/QGuQy", true},
+		{"https://FCM.googleapis.com:443/fcm/send/abc", true},
+		{"http://fcm.googleapis.com/fcm/send/abc", false},
+		{"https://fcm.googleapis.com:8443/fcm/send/abc", false},
+		{"https://evilfcm.googleapis.com.attacker.com/", false},
+		{"https://127.0.0.1/push", false},
+		{"https://169.254.169.254/latest/meta-data", false},
+		{"https://user@fcm.googleapis.com/fcm/send/abc", false},
+		{"not a url", false},
+	}
+	for _, c := range cases {
+		if got := isPushServiceEndpoint(c.endpoint, hosts); got != c.want {
+			t.Fatalf("expected %v for %s, got %v", c.want, c.endpoint, got)
+		}
+	}
+}
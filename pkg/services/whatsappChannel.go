@@ -0,0 +1,37 @@
+package services
+
+import (
+	"github.com/iraunit/get-link-backend/pkg/messages"
+	"github.com/iraunit/get-link-backend/util"
+	"github.com/iraunit/get-link-backend/util/bean"
+)
+
+func (impl *WhatsappServiceImpl) Name() string {
+	return util.WHATSAPP
+}
+
+func (impl *WhatsappServiceImpl) Receive(message *bean.InboundMessage) error {
+	_, err := impl.receive(message)
+	return err
+}
+
+func (impl *WhatsappServiceImpl) receive(message *bean.InboundMessage) ([]int, error) {
+	emails, err := impl.getLinkedEmails(message.Identity)
+	if err != nil {
+		impl.logger.Errorw("Error in getting user from whatsapp number", "Error: ", err)
+		return nil, err
+	}
+	return impl.inboxFor(message.Identity).save(emails, message)
+}
+
+func (impl *WhatsappServiceImpl) VerifyIdentity(userEmail, number string) error {
+	return impl.linkService.VerifyWhatsapp(userEmail, &bean.WhatsappEmail{Email: userEmail, WhatAppNumber: number})
+}
+
+func (impl *WhatsappServiceImpl) SendText(number, text string) error {
+	return impl.SendMessage(number, text)
+}
+
+func (impl *WhatsappServiceImpl) Render(number, key string, data messages.Data) string {
+	return impl.catalogFor(number).Render(impl.locale(number), key, data)
+}
@@ -0,0 +1,182 @@
+package services
+
+import (
+	"errors"
+	"github.com/go-pg/pg"
+	"github.com/iraunit/get-link-backend/pkg/cryptography"
+	"github.com/iraunit/get-link-backend/pkg/messages"
+	"github.com/iraunit/get-link-backend/util"
+	"github.com/iraunit/get-link-backend/util/bean"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// handleCommand runs a text command and reports whether the message was one. Anything else is saved as a link.
+func (impl *WhatsappServiceImpl) handleCommand(message *bean.WhatsAppBusinessMessageData) (bool, error) {
+	fields := strings.Fields(strings.ToLower(message.Text.Body))
+	if len(fields) == 0 || len(fields) > 2 {
+		return false, nil
+	}
+	command := strings.TrimPrefix(fields[0], "/")
+	var id int
+	switch {
+	case len(fields) == 1 && (command == "help" || command == "start"):
+		return true, impl.reply(message.From, "whatsapp.help", nil)
+	case len(fields) == 1 && (command == "list" || command == "files" || command == "status" || command == "unlink"):
+	case len(fields) == 2 && command == "delete":
+		parsed, err := strconv.Atoi(strings.TrimPrefix(fields[1], "#"))
+		if err != nil || parsed <= 0 {
+			return false, nil
+		}
+		id = parsed
+	default:
+		return false, nil
+	}
+
+	emails, err := impl.getLinkedEmails(message.From)
+	if err != nil {
+		return true, err
+	}
+	switch command {
+	case "list":
+		return true, impl.listLinks(message.From, emails)
+	case "files":
+		return true, impl.listFiles(message.From, emails)
+	case "delete":
+		return true, impl.deleteLink(message.From, emails, id)
+	case "status":
+		return true, impl.sendStatus(message.From, emails)
+	default:
+		return true, impl.unlink(message.From, emails)
+	}
+}
+
+func (impl *WhatsappServiceImpl) deleteLink(number string, emails []string, id int) error {
+	for _, email := range emails {
+		err := impl.linkService.DeleteLink(email, &bean.GetLink{ID: id})
+		if err == nil {
+			return impl.reply(number, "link_id_deleted", messages.Data{"ID": id})
+		}
+		if !errors.Is(err, pg.ErrNoRows) {
+			return err
+		}
+	}
+	return impl.reply(number, "whatsapp.link_id_not_found", messages.Data{"ID": id})
+}
+
+func (impl *WhatsappServiceImpl) sendStatus(number string, emails []string) error {
+	var accounts []messages.Data
+	for _, email := range emails {
+		links := 0
+		if allLinks := impl.linkService.GetAllLink(email, ""); allLinks != nil {
+			links = len(*allLinks)
+		}
+		accounts = append(accounts, messages.Data{"Email": email, "Premium": impl.GetIfUserIsPremium(email), "Links": links})
+	}
+	return impl.reply(number, "whatsapp.status", messages.Data{"Accounts": accounts})
+}
+
+func (impl *WhatsappServiceImpl) unlink(number string, emails []string) error {
+	for _, email := range emails {
+		if err := impl.unlinkNumber(email, number); err != nil {
+			return err
+		}
+	}
+	return impl.reply(number, "whatsapp.unlinked", nil)
+}
+
+// unlinkNumber removes the number-keyed and the email-keyed rows, and the profile number if it matches.
+func (impl *WhatsappServiceImpl) unlinkNumber(email, number string) error {
+	numberEmail, err := cryptography.EncryptData(number, email, impl.logger)
+	if err != nil {
+		return err
+	}
+	numberNumber, err := cryptography.EncryptData(number, number, impl.logger)
+	if err != nil {
+		return err
+	}
+	emailEmail, err := cryptography.EncryptData(email, email, impl.logger)
+	if err != nil {
+		return err
+	}
+	emailNumber, err := cryptography.EncryptData(email, number, impl.logger)
+	if err != nil {
+		return err
+	}
+	if err = impl.repository.DeleteWhatsappEmail(numberEmail, numberNumber); err != nil {
+		return err
+	}
+	if err = impl.repository.DeleteChannelIdentity(util.WHATSAPP, emailEmail, emailNumber); err != nil {
+		return err
+	}
+	// email-keyed row written before the email keys moved to channel_identities
+	if err = impl.repository.DeleteWhatsappEmail(emailEmail, emailNumber); err != nil {
+		return err
+	}
+	return impl.repository.ClearWhatsappNumber(email, number)
+}
+
+func (impl *WhatsappServiceImpl) GetLinkedAccounts(userEmail string) ([]bean.LinkedAccount, error) {
+	var numbers []string
+	if number, err := impl.repository.GetWhatsappNumberFromEmail(userEmail); err == nil && number != "" {
+		numbers = append(numbers, number)
+	}
+	encryptedEmail, err := cryptography.EncryptData(userEmail, userEmail, impl.logger)
+	if err != nil {
+		return nil, err
+	}
+	identities, err := impl.repository.GetChannelIdentities(util.WHATSAPP, encryptedEmail)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := impl.repository.GetWhatsappNumbersFromEmail(encryptedEmail)
+	if err != nil {
+		return nil, err
+	}
+	encryptedNumbers := make([]string, 0, len(identities)+len(rows))
+	for _, identity := range identities {
+		encryptedNumbers = append(encryptedNumbers, identity.Email)
+	}
+	for _, row := range rows {
+		encryptedNumbers = append(encryptedNumbers, row.WhatAppNumber)
+	}
+	for _, encryptedNumber := range encryptedNumbers {
+		number, err := cryptography.DecryptData(userEmail, encryptedNumber, impl.logger)
+		if err != nil {
+			impl.logger.Errorw("Error in decrypting data", "Error: ", err)
+			continue
+		}
+		if !slices.Contains(numbers, number) {
+			numbers = append(numbers, number)
+		}
+	}
+
+	var accounts []bean.LinkedAccount
+	for _, number := range numbers {
+		accounts = append(accounts, bean.LinkedAccount{Channel: util.WHATSAPP, Identity: util.MaskIdentity(number), Address: number})
+	}
+	return accounts, nil
+}
+
+func (impl *WhatsappServiceImpl) UnlinkAccount(userEmail string, account bean.LinkedAccount) error {
+	// the locale is read first, since the number has no linked account once it is unlinked
+	locale := impl.locale(account.Address)
+	catalog := impl.catalogFor(account.Address)
+	if err := impl.unlinkNumber(userEmail, account.Address); err != nil {
+		return err
+	}
+	return impl.SendMessage(account.Address, catalog.Render(locale, "whatsapp.unlinked_from_web", messages.Data{"Email": userEmail}))
+}
+
+// backfillEmailKeys adds the email-keyed rows of numbers linked before they were stored, which can only be
+// decrypted once the number writes in.
+func (impl *WhatsappServiceImpl) backfillEmailKeys(number string) {
+	emails, err := impl.getLinkedEmails(number)
+	if err != nil {
+		return
+	}
+	for _, email := range emails {
+		_ = linkWhatsappEmail(impl.repository, impl.logger, email, number)
+	}
+}
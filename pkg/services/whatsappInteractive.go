@@ -0,0 +1,319 @@
+package services
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/go-pg/pg"
+	"github.com/iraunit/get-link-backend/pkg/cryptography"
+	"github.com/iraunit/get-link-backend/pkg/messages"
+	"github.com/iraunit/get-link-backend/util"
+	"github.com/iraunit/get-link-backend/util/bean"
+	"github.com/redis/go-redis/v9"
+	"net/url"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	whatsappCallbackKey   = "whatsapp:callback:%s"
+	whatsappCallbackTTL   = 7 * 24 * time.Hour
+	whatsappReminderDelay = 24 * time.Hour
+)
+
+// limits from the Cloud API interactive message reference
+const (
+	maxWhatsappListRows       = 10
+	maxWhatsappButtonTitle    = 20
+	maxWhatsappRowTitle       = 24
+	maxWhatsappRowDescription = 72
+	maxWhatsappBodyLength     = 1024
+)
+
+const (
+	whatsappActionShowLink   = "show-link"
+	whatsappActionDeleteLink = "delete-link"
+	whatsappActionRemind     = "remind"
+	whatsappActionShareFile  = "share-file"
+	whatsappActionDeleteFile = "delete-file"
+)
+
+// sendLinkActions shows a link picked from the list with its actions.
+func (impl *WhatsappServiceImpl) sendLinkActions(number string, linkIDs []int, message string) error {
+	locale := impl.locale(number)
+	catalog := impl.catalogFor(number)
+	return impl.sendButtons(number, message, []bean.WhatsAppBusinessReplyButton{
+		impl.replyButton(number, catalog.Render(locale, "button.delete", nil), &bean.MessengerCallback{Action: whatsappActionDeleteLink, LinkIDs: linkIDs}),
+		impl.replyButton(number, catalog.Render(locale, "button.remind", nil), &bean.MessengerCallback{Action: whatsappActionRemind, Message: message}),
+	})
+}
+
+func (impl *WhatsappServiceImpl) sendFileSaved(number, fileName string) error {
+	locale := impl.locale(number)
+	catalog := impl.catalogFor(number)
+	return impl.sendButtons(number, catalog.Render(locale, "whatsapp.file_saved", messages.Data{"FileName": fileName}), []bean.WhatsAppBusinessReplyButton{
+		impl.replyButton(number, catalog.Render(locale, "button.share_link", nil), &bean.MessengerCallback{Action: whatsappActionShareFile, AppName: util.WHATSAPP, FileName: fileName}),
+		impl.replyButton(number, catalog.Render(locale, "button.delete", nil), &bean.MessengerCallback{Action: whatsappActionDeleteFile, AppName: util.WHATSAPP, FileName: fileName}),
+	})
+}
+
+func (impl *WhatsappServiceImpl) sendButtons(number, body string, buttons []bean.WhatsAppBusinessReplyButton) error {
+	return impl.sendInteractive(number, bean.WhatsAppBusinessInteractive{
+		Type:   "button",
+		Body:   bean.WhatsAppBusinessTextData{Body: truncateRunes(body, maxWhatsappBodyLength)},
+		Action: bean.WhatsAppBusinessInteractiveAction{Buttons: buttons},
+	})
+}
+
+func (impl *WhatsappServiceImpl) sendList(number, body, button string, rows []bean.WhatsAppBusinessReply) error {
+	return impl.sendInteractive(number, bean.WhatsAppBusinessInteractive{
+		Type: "list",
+		Body: bean.WhatsAppBusinessTextData{Body: truncateRunes(body, maxWhatsappBodyLength)},
+		Action: bean.WhatsAppBusinessInteractiveAction{
+			Button:   button,
+			Sections: []bean.WhatsAppBusinessListSection{{Rows: rows}},
+		},
+	})
+}
+
+func (impl *WhatsappServiceImpl) sendInteractive(number string, interactive bean.WhatsAppBusinessInteractive) error {
+	return impl.outbox.Enqueue(util.WHATSAPP, number, bean.WhatsAppBusinessSendInteractiveMessage{
+		MessagingProduct: "whatsapp",
+		To:               number,
+		Type:             "interactive",
+		Interactive:      interactive,
+	})
+}
+
+func (impl *WhatsappServiceImpl) replyButton(number, title string, callback *bean.MessengerCallback) bean.WhatsAppBusinessReplyButton {
+	return bean.WhatsAppBusinessReplyButton{
+		Type:  "reply",
+		Reply: bean.WhatsAppBusinessReply{ID: impl.saveCallback(number, callback), Title: truncateRunes(title, maxWhatsappButtonTitle)},
+	}
+}
+
+// saveCallback stores the action in Redis encrypted with the sender's number and returns the reply id to send.
+func (impl *WhatsappServiceImpl) saveCallback(number string, callback *bean.MessengerCallback) string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	id := hex.EncodeToString(b)
+
+	callbackJson, err := json.Marshal(callback)
+	if err != nil {
+		impl.logger.Errorw("Error in marshalling whatsapp callback", "Error", err)
+		return id
+	}
+	encrypted, err := cryptography.EncryptData(number, string(callbackJson), impl.logger)
+	if err != nil {
+		return id
+	}
+	err = impl.client.Set(impl.ctx, fmt.Sprintf(whatsappCallbackKey, id), encrypted, whatsappCallbackTTL).Err()
+	if err != nil {
+		impl.logger.Errorw("Error in saving whatsapp callback", "Error", err)
+	}
+	return id
+}
+
+// receiveInteractive routes button and list replies, including quick replies on template messages.
+func (impl *WhatsappServiceImpl) receiveInteractive(message *bean.WhatsAppBusinessMessageData) error {
+	var id string
+	switch {
+	case message.Interactive.Type == "button_reply":
+		id = message.Interactive.ButtonReply.ID
+	case message.Interactive.Type == "list_reply":
+		id = message.Interactive.ListReply.ID
+	case message.Type == "button":
+		id = message.Button.Payload
+	}
+
+	encrypted, err := impl.client.Get(impl.ctx, fmt.Sprintf(whatsappCallbackKey, id)).Result()
+	if errors.Is(err, redis.Nil) {
+		return impl.reply(message.From, "action_expired", nil)
+	} else if err != nil {
+		impl.logger.Errorw("Error in getting whatsapp callback", "Error", err)
+		return err
+	}
+
+	var callback bean.MessengerCallback
+	decrypted, err := cryptography.DecryptData(message.From, encrypted, impl.logger)
+	if err != nil || json.Unmarshal([]byte(decrypted), &callback) != nil {
+		return impl.reply(message.From, "not_allowed", nil)
+	}
+
+	emails, err := impl.getLinkedEmails(message.From)
+	if err != nil {
+		return err
+	}
+	return impl.dispatchCallback(message.From, emails, &callback)
+}
+
+func (impl *WhatsappServiceImpl) dispatchCallback(number string, emails []string, callback *bean.MessengerCallback) error {
+	switch callback.Action {
+	case whatsappActionShowLink:
+		return impl.sendLinkActions(number, callback.LinkIDs, callback.Message)
+	case whatsappActionDeleteLink:
+		deleted := false
+		for _, email := range emails {
+			for _, id := range callback.LinkIDs {
+				err := impl.linkService.DeleteLink(email, &bean.GetLink{ID: id})
+				if err == nil {
+					deleted = true
+				} else if !errors.Is(err, pg.ErrNoRows) {
+					return err
+				}
+			}
+		}
+		if !deleted {
+			return impl.reply(number, "link_not_found", nil)
+		}
+		return impl.reply(number, "link_deleted", nil)
+	case whatsappActionRemind:
+		locale := impl.locale(number)
+		catalog := impl.catalogFor(number)
+		reminder := catalog.Render(locale, "reminder", messages.Data{"Message": callback.Message})
+		err := impl.outbox.EnqueueAt(util.WHATSAPP, number, newWhatsappTextMessage(number, reminder), time.Now().Add(whatsappReminderDelay))
+		if err != nil {
+			return err
+		}
+		return impl.SendMessage(number, catalog.Render(locale, "reminder_set", nil))
+	case whatsappActionShareFile:
+		var links []string
+		for _, email := range emails {
+			if !impl.hasFile(email, callback.AppName, callback.FileName) {
+				continue
+			}
+			token, err := impl.tokenService.ShareFileVerificationToken(&bean.ShareFileClaims{Email: email, AppName: callback.AppName, FileName: callback.FileName + ".bin"})
+			if err != nil {
+				impl.logger.Errorw("Error in generating shareable link", "Error", err)
+				continue
+			}
+			links = append(links, fmt.Sprintf("%s/download-shared-file/%s/%s?Authorization=%s", impl.cfg.Baseurl, url.PathEscape(callback.AppName), url.PathEscape(callback.FileName), url.QueryEscape(token)))
+		}
+		if len(links) == 0 {
+			return impl.reply(number, "share_link_failed", nil)
+		}
+		return impl.reply(number, "share_link", messages.Data{"FileName": callback.FileName, "Links": strings.Join(links, "\n")})
+	case whatsappActionDeleteFile:
+		for _, email := range emails {
+			if !impl.hasFile(email, callback.AppName, callback.FileName) {
+				continue
+			}
+			err := impl.fileManager.DeleteAFileInAppFolder(callback.FileName+".bin", email, callback.AppName)
+			if err != nil {
+				return impl.reply(number, "file_delete_failed", nil)
+			}
+		}
+		return impl.reply(number, "file_deleted", nil)
+	}
+	return impl.reply(number, "unknown_action", nil)
+}
+
+func (impl *WhatsappServiceImpl) listLinks(number string, emails []string) error {
+	var links []bean.GetLink
+	for _, email := range emails {
+		if allLinks := impl.linkService.GetAllLink(email, ""); allLinks != nil {
+			links = append(links, *allLinks...)
+		}
+	}
+	if len(links) == 0 {
+		return impl.reply(number, "no_links", nil)
+	}
+	sort.Slice(links, func(i, j int) bool {
+		return links[i].ID > links[j].ID
+	})
+
+	var rows []bean.WhatsAppBusinessReply
+	for _, link := range links[:min(len(links), maxWhatsappListRows)] {
+		rows = append(rows, bean.WhatsAppBusinessReply{
+			ID:          impl.saveCallback(number, &bean.MessengerCallback{Action: whatsappActionShowLink, LinkIDs: []int{link.ID}, Message: link.Message}),
+			Title:       truncateRunes(fmt.Sprintf("#%d %s", link.ID, link.Message), maxWhatsappRowTitle),
+			Description: truncateRunes(link.Message, maxWhatsappRowDescription),
+		})
+	}
+	locale := impl.locale(number)
+	catalog := impl.catalogFor(number)
+	return impl.sendList(number, catalog.Render(locale, "whatsapp.links_list", nil), catalog.Render(locale, "whatsapp.links_button", nil), rows)
+}
+
+func (impl *WhatsappServiceImpl) listFiles(number string, emails []string) error {
+	var files []bean.FileInfo
+	for _, email := range emails {
+		for _, appName := range storedFileApps {
+			appFiles, err := impl.fileManager.ListAllFilesFromApp(email, appName)
+			if err != nil {
+				continue
+			}
+			files = append(files, appFiles...)
+		}
+	}
+	if len(files) == 0 {
+		return impl.reply(number, "no_files", nil)
+	}
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].ModTime.After(files[j].ModTime)
+	})
+
+	var rows []bean.WhatsAppBusinessReply
+	for _, file := range files[:min(len(files), maxWhatsappListRows)] {
+		rows = append(rows, bean.WhatsAppBusinessReply{
+			ID:          impl.saveCallback(number, &bean.MessengerCallback{Action: whatsappActionShareFile, AppName: file.AppName, FileName: file.Name}),
+			Title:       truncateRunes(file.Name, maxWhatsappRowTitle),
+			Description: fmt.Sprintf("%s, %d KB", file.AppName, file.Size/1000),
+		})
+	}
+	locale := impl.locale(number)
+	catalog := impl.catalogFor(number)
+	return impl.sendList(number, catalog.Render(locale, "whatsapp.files_list", nil), catalog.Render(locale, "whatsapp.files_button", nil), rows)
+}
+
+// hasFile reports whether the app folder of email holds fileName, since a number linked to several accounts
+// usually has a file under only one of them.
+func (impl *WhatsappServiceImpl) hasFile(email, appName, fileName string) bool {
+	files, err := impl.fileManager.ListAllFilesFromApp(email, appName)
+	if err != nil {
+		return false
+	}
+	return slices.ContainsFunc(files, func(file bean.FileInfo) bool {
+		return file.Name == fileName
+	})
+}
+
+func (impl *WhatsappServiceImpl) getLinkedEmails(number string) ([]string, error) {
+	allEmails, err := impl.GetUsersFromWhatsappNumber(number)
+	if err != nil {
+		return nil, err
+	}
+	var emails []string
+	for _, email := range allEmails {
+		decryptedEmail, err := cryptography.DecryptData(number, email.Email, impl.logger)
+		if err != nil {
+			impl.logger.Errorw("Error in decrypting data", "Error: ", err)
+			return nil, err
+		}
+		emails = append(emails, decryptedEmail)
+	}
+	return emails, nil
+}
+
+func newWhatsappTextMessage(number, body string) bean.WhatsAppBusinessSendTextMessage {
+	return bean.WhatsAppBusinessSendTextMessage{
+		MessagingProduct: "whatsapp",
+		To:               number,
+		Type:             "text",
+		Text: bean.WhatsAppBusinessTextData{
+			Body: body,
+		},
+	}
+}
+
+func truncateRunes(value string, limit int) string {
+	runes := []rune(value)
+	if len(runes) <= limit {
+		return value
+	}
+	return string(runes[:limit-1]) + "…"
+}
@@ -0,0 +1,128 @@
+package services
+
+import (
+	"fmt"
+	"github.com/iraunit/get-link-backend/util"
+	"github.com/iraunit/get-link-backend/util/bean"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// receiveMedia saves image, document, video, audio and sticker messages as files and keeps any caption as a link note.
+func (impl *WhatsappServiceImpl) receiveMedia(message *bean.WhatsAppBusinessMessageData) error {
+	var id, fileName, caption string
+	fileType := message.Type
+	switch message.Type {
+	case "image":
+		id, caption = message.Image.ID, message.Image.Caption
+	case "document":
+		id, fileName, caption = message.Document.ID, message.Document.Filename, message.Document.Caption
+	case "video":
+		id, caption = message.Video.ID, message.Video.Caption
+	case "audio":
+		id = message.Audio.ID
+		if message.Audio.Voice {
+			fileType = "voice"
+		}
+	case "sticker":
+		id = message.Sticker.ID
+	}
+
+	data, err := impl.getMediaData(id, message.From)
+	if err != nil {
+		return err
+	}
+
+	if fileName == "" {
+		fileExtension, err := util.GetFileExtension(data.MimeType)
+		if err != nil {
+			impl.logger.Errorw("Error in getting file extension", "Error", err)
+			return err
+		}
+		fileName = util.GetFileNameFromType(fileType, data.MimeType) + fileExtension
+	}
+
+	err = impl.downloadMedia(data.Url, message.From, fileName)
+	if err != nil {
+		impl.logger.Errorw("Error in downloading media", "Error", err)
+		return err
+	}
+
+	if caption = strings.TrimSpace(caption); caption != "" {
+		err = impl.ParseMessageAndBroadcast(fmt.Sprintf("%s\n(%s)", caption, fileName), message.From)
+		if err != nil {
+			return err
+		}
+	}
+	return impl.sendFileSaved(message.From, fileName)
+}
+
+func (impl *WhatsappServiceImpl) receiveContacts(message *bean.WhatsAppBusinessMessageData) error {
+	if len(message.Contacts) == 0 {
+		return nil
+	}
+	var vcf strings.Builder
+	for i := range message.Contacts {
+		vcf.WriteString(vCard(&message.Contacts[i]))
+	}
+	name := message.Contacts[0].Name.FormattedName
+	if name == "" || len(message.Contacts) > 1 {
+		name = util.GetFileNameFromType("contacts", "text/vcard")
+	}
+	fileName := util.SanitizeFilename(name) + ".vcf"
+	err := impl.saveMedia([]byte(vcf.String()), message.From, fileName)
+	if err != nil {
+		return err
+	}
+	return impl.sendFileSaved(message.From, fileName)
+}
+
+func locationLink(location *bean.WhatsAppBusinessLocationData) string {
+	query := strconv.FormatFloat(location.Latitude, 'f', -1, 64) + "," + strconv.FormatFloat(location.Longitude, 'f', -1, 64)
+	var lines []string
+	if location.Name != "" {
+		lines = append(lines, location.Name)
+	}
+	if location.Address != "" {
+		lines = append(lines, location.Address)
+	}
+	lines = append(lines, "https://www.google.com/maps/search/?api=1&query="+url.QueryEscape(query))
+	return strings.Join(lines, "\n")
+}
+
+func vCard(contact *bean.WhatsAppBusinessSharedContact) string {
+	var card strings.Builder
+	card.WriteString("BEGIN:VCARD\r\nVERSION:3.0\r\n")
+	card.WriteString("FN:" + vCardEscape(contact.Name.FormattedName) + "\r\n")
+	card.WriteString("N:" + vCardEscape(contact.Name.LastName) + ";" + vCardEscape(contact.Name.FirstName) + ";;;\r\n")
+	if contact.Org.Company != "" {
+		card.WriteString("ORG:" + vCardEscape(contact.Org.Company) + "\r\n")
+	}
+	if contact.Org.Title != "" {
+		card.WriteString("TITLE:" + vCardEscape(contact.Org.Title) + "\r\n")
+	}
+	for _, phone := range contact.Phones {
+		card.WriteString(fmt.Sprintf("TEL;TYPE=%s:%s\r\n", vCardType(phone.Type, "CELL"), vCardEscape(phone.Phone)))
+	}
+	for _, email := range contact.Emails {
+		card.WriteString(fmt.Sprintf("EMAIL;TYPE=%s:%s\r\n", vCardType(email.Type, "INTERNET"), vCardEscape(email.Email)))
+	}
+	for _, u := range contact.Urls {
+		card.WriteString("URL:" + vCardEscape(u.Url) + "\r\n")
+	}
+	card.WriteString("END:VCARD\r\n")
+	return card.String()
+}
+
+func vCardType(t, fallback string) string {
+	t = strings.ToUpper(strings.TrimSpace(t))
+	if t == "" || strings.ContainsAny(t, ":;,\r\n") {
+		return fallback
+	}
+	return t
+}
+
+func vCardEscape(value string) string {
+	return strings.NewReplacer(`\`, `\\`, ",", `\,`, ";", `\;`, "\r\n", `\n`, "\n", `\n`).Replace(value)
+}
@@ -1,72 +1,342 @@
 package services
 
 import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/caarlos0/env"
 	"github.com/go-pg/pg"
 	"github.com/iraunit/get-link-backend/pkg/cryptography"
 	"github.com/iraunit/get-link-backend/pkg/fileManager"
+	"github.com/iraunit/get-link-backend/pkg/messages"
 	"github.com/iraunit/get-link-backend/pkg/repository"
 	"github.com/iraunit/get-link-backend/pkg/restCalls"
 	tokenService2 "github.com/iraunit/get-link-backend/pkg/services/tokenService"
 	"github.com/iraunit/get-link-backend/util"
 	"github.com/iraunit/get-link-backend/util/bean"
+	"github.com/redis/go-redis/v9"
 	"go.uber.org/zap"
 	"net/url"
 	"path"
 	"regexp"
 	"strings"
+	"time"
 )
 
+const (
+	whatsappMessageKey = "whatsapp:message:%s"
+	whatsappWindowKey  = "whatsapp:window:%s"
+	// values of whatsappMessageKey
+	whatsappMessageQueued    = "queued"
+	whatsappMessageProcessed = "processed"
+	// Meta only allows free-form messages within 24 hours of the user's last message.
+	whatsappWindow = 24 * time.Hour
+)
+
+var ErrWhatsappQueueFull = errors.New("whatsapp message queue is full")
+
+type whatsappJob struct {
+	message bean.WhatsAppBusinessMessageData
+	name    string
+	tenant  *bean.Tenant
+	status  *bean.WhatsAppBusinessStatus
+}
+
 type WhatsappService interface {
+	Channel
 	SendMessage(number string, body string) error
+	QueueMessage(message bean.WhatsAppBusinessMessageData, name string, phoneID string) error
+	QueueStatus(status bean.WhatsAppBusinessStatus) error
+	GetMessageStatuses(userEmail string, messageID string) ([]bean.WhatsappMessageStatus, error)
 	ReceiveMessage(message *bean.WhatsAppBusinessMessageData) error
 	VerifyEmail(message string, sender string)
 	ParseMessageAndBroadcast(message string, sender string) error
 	GetIfUserIsPremium(userEmail string) bool
-	SendMessageFromWeb(userEmail string, message string) error
+	// SendMessageFromWeb queues the message and returns the id GetMessageStatuses reports its delivery under.
+	SendMessageFromWeb(userEmail string, message string) (string, error)
+	NotifyLink(userEmail string, message string) error
+	SendFileFromWeb(userEmail, appName, fileName string) error
 }
 
 type WhatsappServiceImpl struct {
 	logger       *zap.SugaredLogger
 	cfg          bean.WhatsAppConfig
+	ctx          context.Context
+	client       *redis.Client
+	queue        chan whatsappJob
 	restClient   restCalls.RestClient
 	mailService  MailService
 	tokenService tokenService2.TokenService
 	repository   repository.Repository
 	linkService  LinkService
 	fileManager  fileManager.FileManager
+	outbox       OutboxService
+	notification NotificationService
+	tenants      TenantService
+	catalogs     map[string]messages.Catalog
+	inboxes      map[string]*messengerInbox
 }
 
-func NewWhatsappServiceImpl(logger *zap.SugaredLogger, restClient restCalls.RestClient, mailService MailService, tokenService tokenService2.TokenService, repository repository.Repository, linkService LinkService, fileManager fileManager.FileManager) *WhatsappServiceImpl {
+func NewWhatsappServiceImpl(logger *zap.SugaredLogger, async *util.Async, client *redis.Client, restClient restCalls.RestClient, mailService MailService, tokenService tokenService2.TokenService, repository repository.Repository, linkService LinkService, fileManager fileManager.FileManager, outbox OutboxService, notificationService NotificationService, catalog messages.Catalog, tenantService TenantService) *WhatsappServiceImpl {
 	cfg := bean.WhatsAppConfig{}
 	if err := env.Parse(&cfg); err != nil {
 		logger.Fatal("Error loading Cfg from env", "Error", zap.Error(err))
 	}
+	if cfg.Workers <= 0 {
+		logger.Fatalw("WHATSAPP_WORKERS must be at least 1", "Workers", cfg.Workers)
+	}
 
-	return &WhatsappServiceImpl{
+	impl := &WhatsappServiceImpl{
 		logger:       logger,
 		cfg:          cfg,
+		ctx:          context.Background(),
+		client:       client,
+		queue:        make(chan whatsappJob, cfg.QueueSize),
 		restClient:   restClient,
 		mailService:  mailService,
 		tokenService: tokenService,
 		repository:   repository,
 		linkService:  linkService,
 		fileManager:  fileManager,
+		outbox:       outbox,
+		notification: notificationService,
+		tenants:      tenantService,
+		catalogs:     make(map[string]messages.Catalog),
+		inboxes:      make(map[string]*messengerInbox),
+	}
+	for _, tenant := range tenantService.Tenants() {
+		freeLimitMB, premiumLimitMB := fileLimits(tenant, util.FreeWhatsappFileLimitSizeMB, util.PremiumWhatsappFileLimitSizeMB)
+		impl.catalogs[tenant.ID] = catalog.ForTenant(tenant)
+		impl.inboxes[tenant.ID] = &messengerInbox{
+			channel:        util.WHATSAPP,
+			displayName:    "WhatsApp",
+			baseUrl:        cfg.Baseurl,
+			freeLimitMB:    freeLimitMB,
+			premiumLimitMB: premiumLimitMB,
+			logger:         logger,
+			repository:     repository,
+			linkService:    linkService,
+			fileManager:    fileManager,
+			tokenService:   tokenService,
+			mailService:    mailService,
+			notification:   notificationService,
+			catalog:        impl.catalogs[tenant.ID],
+		}
 	}
+	outbox.RegisterSender(util.WHATSAPP, impl.sendOutboxMessage)
+	notificationService.RegisterNotifier(util.WHATSAPP, impl.notify)
+	for i := 0; i < cfg.Workers; i++ {
+		async.Run(impl.work)
+	}
+	async.Run(impl.pruneStatuses)
+	return impl
 }
 
 func (impl *WhatsappServiceImpl) SendMessage(number string, body string) error {
-	message := bean.WhatsAppBusinessSendTextMessage{
-		MessagingProduct: "whatsapp",
-		To:               number,
-		Type:             "text",
-		Text: bean.WhatsAppBusinessTextData{
-			Body: body,
+	return impl.outbox.Enqueue(util.WHATSAPP, number, newWhatsappTextMessage(number, body))
+}
+
+func (impl *WhatsappServiceImpl) reply(number, key string, data messages.Data) error {
+	return impl.SendMessage(number, impl.Render(number, key, data))
+}
+
+// tenant returns the tenant whose phone number last received a message from number, or the default tenant.
+func (impl *WhatsappServiceImpl) tenant(number string) *bean.Tenant {
+	return impl.tenants.ForIdentity(util.WHATSAPP, number)
+}
+
+func (impl *WhatsappServiceImpl) rememberTenant(number string, tenant *bean.Tenant) {
+	impl.tenants.Remember(util.WHATSAPP, number, tenant)
+}
+
+func (impl *WhatsappServiceImpl) catalogFor(number string) messages.Catalog {
+	return impl.catalogs[impl.tenant(number).ID]
+}
+
+func (impl *WhatsappServiceImpl) inboxFor(number string) *messengerInbox {
+	return impl.inboxes[impl.tenant(number).ID]
+}
+
+// locale returns the language picked by an account linked to number, or "" for the default.
+func (impl *WhatsappServiceImpl) locale(number string) string {
+	emails, err := impl.getLinkedEmails(number)
+	if err != nil {
+		return ""
+	}
+	for _, email := range emails {
+		if locale := impl.notification.GetLocale(email); locale != "" {
+			return locale
+		}
+	}
+	return ""
+}
+
+func (impl *WhatsappServiceImpl) sendOutboxMessage(number string, payload []byte) error {
+	if !impl.isWindowOpen(number) {
+		template, err := impl.templateMessage(number, payload)
+		if err != nil {
+			return err
+		}
+		if template != nil {
+			payload = template
+		}
+	}
+	tenant := impl.tenant(number)
+	_, err := impl.restClient.SendWhatsappMessage(fmt.Sprintf(util.WhatsappCloudApiSendMessage, tenant.WhatsappPhoneID), tenant.WhatsappAccessToken, payload)
+	if err != nil {
+		return err
+	}
+	var message bean.WhatsAppBusinessOutgoingMessage
+	if json.Unmarshal(payload, &message) == nil && message.BizOpaqueCallbackData != "" {
+		impl.saveStatus(number, &bean.WhatsappMessageStatus{MessageID: message.BizOpaqueCallbackData, Status: util.WhatsappStatusAccepted})
+	}
+	return nil
+}
+
+// templateMessage rewrites a text or interactive payload as the configured template. It returns nil for templates.
+func (impl *WhatsappServiceImpl) templateMessage(number string, payload []byte) ([]byte, error) {
+	var message bean.WhatsAppBusinessOutgoingMessage
+	if err := json.Unmarshal(payload, &message); err != nil {
+		return nil, err
+	}
+	var text string
+	switch message.Type {
+	case "template":
+		return nil, nil
+	case "text":
+		text = message.Text.Body
+	case "interactive":
+		text = message.Interactive.Body.Body
+	default:
+		return nil, fmt.Errorf("cannot send %s message outside the 24 hour window", message.Type)
+	}
+	return json.Marshal(bean.WhatsAppBusinessSendTemplateMessage{
+		MessagingProduct:      "whatsapp",
+		To:                    number,
+		Type:                  "template",
+		BizOpaqueCallbackData: message.BizOpaqueCallbackData,
+		Template: bean.WhatsAppBusinessTemplate{
+			Name:     impl.cfg.TemplateName,
+			Language: bean.WhatsAppBusinessTemplateLanguage{Code: impl.cfg.TemplateLanguage},
+			Components: []bean.WhatsAppBusinessTemplateComponent{{
+				Type: "body",
+				// template parameters may not contain newlines, so whitespace is collapsed
+				Parameters: []bean.WhatsAppBusinessTemplateParameter{{Type: "text", Text: truncateRunes(strings.Join(strings.Fields(text), " "), maxWhatsappBodyLength)}},
+			}},
 		},
+	})
+}
+
+func (impl *WhatsappServiceImpl) openWindow(number string) {
+	key, err := impl.windowKey(number)
+	if err != nil {
+		return
+	}
+	if err = impl.client.Set(impl.ctx, key, 1, whatsappWindow).Err(); err != nil {
+		impl.logger.Errorw("Error in saving whatsapp window", "Error", err)
+	}
+}
+
+// isWindowOpen reports whether the number messaged us in the last 24 hours. Redis errors count as open.
+func (impl *WhatsappServiceImpl) isWindowOpen(number string) bool {
+	key, err := impl.windowKey(number)
+	if err != nil {
+		return true
+	}
+	exists, err := impl.client.Exists(impl.ctx, key).Result()
+	if err != nil {
+		impl.logger.Errorw("Error in checking whatsapp window", "Error", err)
+		return true
+	}
+	return exists == 1
+}
+
+func (impl *WhatsappServiceImpl) windowKey(number string) (string, error) {
+	encryptedNumber, err := cryptography.EncryptData(number, number, impl.logger)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf(whatsappWindowKey, encryptedNumber), nil
+}
+
+// QueueMessage hands a webhook message to the workers once. Meta redelivers slow webhooks, so ids already seen are skipped.
+// phoneID is the business number the message was sent to, which picks the tenant.
+func (impl *WhatsappServiceImpl) QueueMessage(message bean.WhatsAppBusinessMessageData, name string, phoneID string) error {
+	tenant, ok := impl.tenants.ByWhatsappPhoneID(phoneID)
+	if !ok {
+		impl.logger.Errorw("Whatsapp message to unknown phone number id, using the default tenant", "ID", message.ID, "PhoneID", phoneID)
+		tenant = impl.tenants.Default()
+	}
+	key := fmt.Sprintf(whatsappMessageKey, message.ID)
+	isNew, err := impl.client.SetNX(impl.ctx, key, whatsappMessageQueued, impl.cfg.ProcessingTTL).Result()
+	if err != nil {
+		impl.logger.Errorw("Error in checking whatsapp message id", "ID", message.ID, "Error", err)
+	} else if !isNew {
+		impl.logger.Infow("Skipping duplicate whatsapp message", "ID", message.ID)
+		return nil
+	}
+
+	select {
+	case impl.queue <- whatsappJob{message: message, name: name, tenant: tenant}:
+		return nil
+	default:
+		// release the id so Meta's retry is processed instead of dropped
+		impl.client.Del(impl.ctx, key)
+		return ErrWhatsappQueueFull
+	}
+}
+
+func (impl *WhatsappServiceImpl) markProcessed(messageID string) {
+	err := impl.client.Set(impl.ctx, fmt.Sprintf(whatsappMessageKey, messageID), whatsappMessageProcessed, impl.cfg.DedupTTL).Err()
+	if err != nil {
+		impl.logger.Errorw("Error in marking whatsapp message processed", "ID", messageID, "Error", err)
+	}
+}
+
+// QueueStatus hands a delivery status to the workers. Statuses are idempotent, so they are not deduplicated.
+func (impl *WhatsappServiceImpl) QueueStatus(status bean.WhatsAppBusinessStatus) error {
+	select {
+	case impl.queue <- whatsappJob{status: &status}:
+		return nil
+	default:
+		return ErrWhatsappQueueFull
+	}
+}
+
+func (impl *WhatsappServiceImpl) work() {
+	for job := range impl.queue {
+		if job.status != nil {
+			impl.receiveStatus(job.status)
+		} else {
+			impl.processMessage(job)
+		}
+	}
+}
+
+func (impl *WhatsappServiceImpl) processMessage(job whatsappJob) {
+	defer func() {
+		if r := recover(); r != nil {
+			impl.logger.Errorw("Recovered from panic in handling whatsapp message", "ID", job.message.ID, "Error", r)
+		}
+	}()
+	// the id is only remembered for DedupTTL once handled, a crash before that leaves Meta's retry to be processed
+	defer impl.markProcessed(job.message.ID)
+	impl.rememberTenant(job.message.From, job.tenant)
+	impl.backfillEmailKeys(job.message.From)
+	impl.openWindow(job.message.From)
+	err := impl.ReceiveMessage(&job.message)
+	if err == nil {
+		return
+	}
+	if errors.Is(err, pg.ErrNoRows) {
+		_ = impl.reply(job.message.From, "whatsapp.set_email_first", messages.Data{"Name": job.name})
+	} else {
+		_ = impl.reply(job.message.From, "whatsapp.error", messages.Data{"Name": job.name})
+		impl.logger.Errorw("Error in handling message", "Message:", job.message, "Error: ", err)
 	}
-	_, err := impl.restClient.SendWhatsappMessage(fmt.Sprintf(util.WhatsappCloudApiSendMessage, impl.cfg.PhoneID), message)
-	return err
 }
 
 func (impl *WhatsappServiceImpl) ReceiveMessage(message *bean.WhatsAppBusinessMessageData) error {
@@ -75,48 +345,36 @@ func (impl *WhatsappServiceImpl) ReceiveMessage(message *bean.WhatsAppBusinessMe
 		regex := regexp.MustCompile(pattern)
 		if regex.MatchString(strings.ToLower(message.Text.Body)) {
 			impl.VerifyEmail(message.Text.Body, message.From)
-			_ = impl.SendMessage(message.From, "Thanks for using Get-Link. We have sent you an email for verification. Please verify your email. \n\nYou can share your feedback or report an issue on codingkaro.in.\n\nRegards\nRaunit Verma\nShypt Solution")
+			_ = impl.reply(message.From, "whatsapp.verification_sent", nil)
+		} else if handled, err := impl.handleCommand(message); handled {
+			return err
 		} else {
 			return impl.ParseMessageAndBroadcast(message.Text.Body, message.From)
 		}
-	} else if message.Type == "image" || message.Type == "document" || message.Type == "video" {
-		id := ""
-		fileName := ""
-		if message.Type == "image" {
-			id = message.Image.ID
-		} else if message.Type == "document" {
-			id = message.Document.ID
-			fileName = message.Document.Filename
-		} else if message.Type == "video" {
-			id = message.Video.ID
-		}
-
-		data, err := impl.getMediaData(id)
-		if err != nil {
-			return err
-		}
-
-		if message.Type != "document" {
-			fileExtension, err := util.GetFileExtension(data.MimeType)
-			if err != nil {
-				impl.logger.Errorw("Error in getting file extension", "Error", err)
-				return err
-			}
-			fileName = util.GetFileNameFromType(message.Type, data.MimeType) + fileExtension
-		}
-
-		err = impl.downloadMedia(data.Url, message.From, fileName)
-		if err != nil {
-			impl.logger.Errorw("Error in downloading media", "Error", err)
-			return err
+	} else {
+		switch message.Type {
+		case "image", "document", "video", "audio", "sticker":
+			return impl.receiveMedia(message)
+		case "location":
+			return impl.ParseMessageAndBroadcast(locationLink(&message.Location), message.From)
+		case "contacts":
+			return impl.receiveContacts(message)
+		case "interactive", "button":
+			return impl.receiveInteractive(message)
+		case "reaction":
+			return nil
+		default:
+			impl.logger.Infow("Unsupported whatsapp message type", "Type", message.Type)
+			return impl.reply(message.From, "whatsapp.unsupported_type", messages.Data{"Type": message.Type})
 		}
 	}
 	return nil
 }
 
-func (impl *WhatsappServiceImpl) getMediaData(id string) (*bean.WhatsappMedia, error) {
+// getMediaData looks up media sent by sender, with the access token of the number it was sent to.
+func (impl *WhatsappServiceImpl) getMediaData(id, sender string) (*bean.WhatsappMedia, error) {
 
-	mediaData, err := impl.restClient.GetMediaDataFromId(fmt.Sprintf(util.WhatsappCloudApiGetMediaDataUrl, id))
+	mediaData, err := impl.restClient.GetMediaDataFromId(fmt.Sprintf(util.WhatsappCloudApiGetMediaDataUrl, id), impl.tenant(sender).WhatsappAccessToken)
 
 	if err != nil {
 		impl.logger.Errorw("Error in getting whatsapp media data", "Error", err)
@@ -127,38 +385,32 @@ func (impl *WhatsappServiceImpl) getMediaData(id string) (*bean.WhatsappMedia, e
 }
 
 func (impl *WhatsappServiceImpl) downloadMedia(url, sender, fileNameWithExtension string) error {
+	return impl.forEachUserFolder(sender, func(email, folderPath string) error {
+		impl.restClient.DownloadMediaFromUrl(url, impl.tenant(sender).WhatsappAccessToken, path.Join(folderPath, fileNameWithExtension+".bin"), email)
+		return nil
+	})
+}
 
-	allEmails, err := impl.GetUsersFromWhatsappNumber(sender)
+func (impl *WhatsappServiceImpl) saveMedia(data []byte, sender, fileNameWithExtension string) error {
+	return impl.Receive(&bean.InboundMessage{Identity: sender, Files: []bean.InboundFile{{Name: fileNameWithExtension, Data: data}}})
+}
+
+// forEachUserFolder runs fn on the WhatsApp folder of every account linked to sender, after applying the storage limit.
+func (impl *WhatsappServiceImpl) forEachUserFolder(sender string, fn func(email, folderPath string) error) error {
+	emails, err := impl.getLinkedEmails(sender)
 	if err != nil {
 		impl.logger.Errorw("Error in getting user from whatsapp number", "Error", err)
 		return err
 	}
-
-	for _, email := range allEmails {
-		decryptedEmail, err := cryptography.DecryptData(sender, email.Email, impl.logger)
+	for _, email := range emails {
+		folderPath, err := impl.inboxFor(sender).userFolder(email)
 		if err != nil {
-			impl.logger.Errorw("Error in decrypting data", "Error: ", err)
 			return err
 		}
-		folderPath := impl.fileManager.GetPathToSaveFileFromWhatsapp(util.EncodeString(decryptedEmail))
-		impl.fileManager.DeleteFileFromPathOlderThan24Hours(folderPath)
-		folderSize, err := impl.fileManager.GetSizeOfADirectory(folderPath)
-		if err != nil {
-			impl.logger.Errorw("Error in getting folder size", "Error", err)
+		if err = fn(email, folderPath); err != nil {
 			return err
 		}
-		maxLimit := util.FreeWhatsappFileLimitSizeMB
-		if impl.repository.IsUserPremiumUser(email.Email) {
-			maxLimit = util.PremiumWhatsappFileLimitSizeMB
-		}
-
-		if folderSize > int64(maxLimit) {
-			impl.fileManager.DeleteAllFileFromPath(folderPath)
-		}
-
-		impl.restClient.DownloadMediaFromUrl(url, impl.cfg.AuthToken, path.Join(folderPath, fileNameWithExtension+".bin"), decryptedEmail)
 	}
-
 	return nil
 }
 
@@ -171,8 +423,11 @@ func (impl *WhatsappServiceImpl) VerifyEmail(message string, number string) {
 		impl.logger.Errorw("Error in generating token", "Error", err)
 		return
 	}
-	emailBody := fmt.Sprintf("Please click on the below link to verify your email. \n\n %s%s?token=%s", impl.cfg.Baseurl, util.VerifyWhatsappEmail, url.QueryEscape(token))
-	err = impl.mailService.SendMail(emails[0], "Get-Link - Email Verification", emailBody)
+	err = impl.mailService.SendTenantTemplateMail(impl.tenant(number), emails[0], util.MailTemplateVerification, bean.VerificationMailData{
+		Intro:      fmt.Sprintf("Please click on the below link to verify your email and connect your WhatsApp number to %s.", impl.catalogFor(number).Render("", "brand", nil)),
+		ActionUrl:  fmt.Sprintf("%s%s?token=%s", impl.cfg.Baseurl, util.VerifyWhatsappEmail, url.QueryEscape(token)),
+		ActionText: "Verify email",
+	})
 	if err != nil {
 		impl.logger.Errorw("Error in sending mail", "Error", err)
 		return
@@ -182,20 +437,8 @@ func (impl *WhatsappServiceImpl) VerifyEmail(message string, number string) {
 }
 
 func (impl *WhatsappServiceImpl) ParseMessageAndBroadcast(message string, sender string) error {
-	allEmails, err := impl.GetUsersFromWhatsappNumber(sender)
-	if err != nil {
-		impl.logger.Errorw("Error in getting user from whatsapp number", "Error: ", err)
-		return err
-	}
-	for _, email := range allEmails {
-		decryptedEmail, err := cryptography.DecryptData(sender, email.Email, impl.logger)
-		if err != nil {
-			impl.logger.Errorw("Error in decrypting data", "Error: ", err)
-			return err
-		}
-		impl.linkService.AddLink(decryptedEmail, &bean.GetLink{Receiver: decryptedEmail, Sender: decryptedEmail, Message: message, UUID: "whatsapp"})
-	}
-	return nil
+	_, err := impl.receive(&bean.InboundMessage{Identity: sender, Text: message})
+	return err
 }
 
 func (impl *WhatsappServiceImpl) GetUsersFromWhatsappNumber(sender string) ([]bean.WhatsappEmail, error) {
@@ -219,7 +462,31 @@ func (impl *WhatsappServiceImpl) GetIfUserIsPremium(email string) bool {
 	return impl.repository.IsUserPremiumUser(email)
 }
 
-func (impl *WhatsappServiceImpl) SendMessageFromWeb(userEmail string, message string) error {
+func (impl *WhatsappServiceImpl) SendMessageFromWeb(userEmail string, message string) (string, error) {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	messageID := hex.EncodeToString(b)
+	if err := impl.sendMessageFromWebAt(userEmail, message, messageID, time.Now()); err != nil {
+		return "", err
+	}
+	return messageID, nil
+}
+
+// NotifyLink sends a link the user did not send themselves to the user's number, subject to their notification preferences.
+func (impl *WhatsappServiceImpl) NotifyLink(userEmail string, message string) error {
+	at, err := impl.notification.Check(userEmail, util.WHATSAPP, util.EventLinkReceived)
+	if err != nil {
+		return err
+	}
+	return impl.sendMessageFromWebAt(userEmail, message, "", at)
+}
+
+func (impl *WhatsappServiceImpl) notify(userEmail string, notification bean.Notification, at time.Time) error {
+	return impl.sendMessageFromWebAt(userEmail, fmt.Sprintf("*%s*\n\n%s", notification.Title, notification.Message), "", at)
+}
+
+// sendMessageFromWebAt queues the message for the user's number. Delivery statuses are kept for messages with a messageID.
+func (impl *WhatsappServiceImpl) sendMessageFromWebAt(userEmail string, message string, messageID string, at time.Time) error {
 
 	number, err := impl.repository.GetWhatsappNumberFromEmail(userEmail)
 
@@ -228,5 +495,64 @@ func (impl *WhatsappServiceImpl) SendMessageFromWeb(userEmail string, message st
 		return fmt.Errorf("error in getting number from email. Have you set your number in profile")
 	}
 
-	return impl.SendMessage(number, message)
+	textMessage := newWhatsappTextMessage(number, message)
+	textMessage.BizOpaqueCallbackData = messageID
+	return impl.outbox.EnqueueAt(util.WHATSAPP, number, textMessage, at)
+}
+
+func (impl *WhatsappServiceImpl) SendFileFromWeb(userEmail, appName, fileName string) error {
+	if !isStoredFileApp(appName) {
+		return fmt.Errorf("unknown app %s", appName)
+	}
+	number, err := impl.repository.GetWhatsappNumberFromEmail(userEmail)
+	if err != nil || number == "" {
+		impl.logger.Errorw("Error in getting number from email", "Error: ", err)
+		return fmt.Errorf("error in getting number from email. Have you set your number in profile")
+	}
+	data, err := impl.fileManager.GetDecryptedFile(fileName, userEmail, appName)
+	if err != nil {
+		impl.logger.Errorw("Error in reading file", "Error", err)
+		return fmt.Errorf("file %s not found", fileName)
+	}
+	return impl.sendFileAt(number, fileName, data, time.Now())
+}
+
+func (impl *WhatsappServiceImpl) SendFile(number, fileName string, data []byte) error {
+	return impl.sendFileAt(number, fileName, data, time.Now())
+}
+
+// sendFileAt uploads the file right away, as uploaded media stays available for 30 days, and queues the message for at.
+func (impl *WhatsappServiceImpl) sendFileAt(number, fileName string, data []byte, at time.Time) error {
+	kind, mimeType, err := resolveOutgoingMedia(whatsappMediaRules, fileName, data)
+	if err != nil {
+		return err
+	}
+	if !impl.isWindowOpen(number) {
+		return errors.New(impl.Render(number, "whatsapp.file_window_closed", nil))
+	}
+
+	tenant := impl.tenant(number)
+	mediaId, err := impl.restClient.UploadWhatsappMedia(fmt.Sprintf(util.WhatsappCloudApiUploadMedia, tenant.WhatsappPhoneID), tenant.WhatsappAccessToken, path.Base(fileName), mimeType, data)
+	if err != nil {
+		return fmt.Errorf("error in uploading file to WhatsApp. Please try again later")
+	}
+
+	message := bean.WhatsAppBusinessSendMediaMessage{
+		MessagingProduct: "whatsapp",
+		To:               number,
+		Type:             kind,
+	}
+	media := &bean.WhatsAppBusinessMediaData{ID: mediaId}
+	switch kind {
+	case mediaKindImage:
+		message.Image = media
+	case mediaKindVideo:
+		message.Video = media
+	case mediaKindAudio:
+		message.Audio = media
+	default:
+		media.Filename = path.Base(fileName)
+		message.Document = media
+	}
+	return impl.outbox.EnqueueAt(util.WHATSAPP, number, message, at)
 }
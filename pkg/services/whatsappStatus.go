@@ -0,0 +1,85 @@
+package services
+
+import (
+	"fmt"
+	"github.com/iraunit/get-link-backend/pkg/cryptography"
+	"github.com/iraunit/get-link-backend/util"
+	"github.com/iraunit/get-link-backend/util/bean"
+	"strings"
+	"time"
+)
+
+const maxWhatsappStatuses = 50
+
+// receiveStatus stores the status of a message the user sent from the website and pushes it to the sender's websockets.
+// Bot replies carry no tracking id.
+func (impl *WhatsappServiceImpl) receiveStatus(status *bean.WhatsAppBusinessStatus) {
+	if status.BizOpaqueCallbackData == "" {
+		return
+	}
+	messageStatus := &bean.WhatsappMessageStatus{MessageID: status.BizOpaqueCallbackData, Status: status.Status}
+	if len(status.Errors) > 0 {
+		messageStatus.ErrorCode = status.Errors[0].Code
+		messageStatus.ErrorTitle = status.Errors[0].Title
+	}
+	if !impl.saveStatus(status.RecipientID, messageStatus) {
+		return
+	}
+
+	// web sends go to the sender's profile number, so the sender is the linked account with that number
+	emails, err := impl.getLinkedEmails(status.RecipientID)
+	if err != nil {
+		return
+	}
+	for _, email := range emails {
+		number, err := impl.repository.GetWhatsappNumberFromEmail(email)
+		if err != nil || digitsOnly(number) != digitsOnly(status.RecipientID) {
+			continue
+		}
+		if err = impl.linkService.PublishEvent(email, util.EventWhatsappStatus, messageStatus); err != nil {
+			impl.logger.Errorw("Error in publishing whatsapp status", "Error", err)
+		}
+	}
+}
+
+// saveStatus stores the status under the recipient's encrypted number and reports whether it moved the message forward.
+func (impl *WhatsappServiceImpl) saveStatus(number string, status *bean.WhatsappMessageStatus) bool {
+	encryptedNumber, err := cryptography.EncryptData(number, number, impl.logger)
+	if err != nil {
+		return false
+	}
+	status.WhatsappNumber = encryptedNumber
+	updated, err := impl.repository.UpsertWhatsappMessageStatus(status)
+	return err == nil && updated
+}
+
+// digitsOnly drops the + and spaces a profile number may be saved with, as WhatsApp sends digits only.
+func digitsOnly(number string) string {
+	return strings.Map(func(r rune) rune {
+		if r < '0' || r > '9' {
+			return -1
+		}
+		return r
+	}, number)
+}
+
+// pruneStatuses deletes statuses older than WHATSAPP_STATUS_RETENTION every hour.
+func (impl *WhatsappServiceImpl) pruneStatuses() {
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+	for range ticker.C {
+		_ = impl.repository.DeleteWhatsappMessageStatusesBefore(time.Now().Add(-impl.cfg.StatusRetention))
+	}
+}
+
+func (impl *WhatsappServiceImpl) GetMessageStatuses(userEmail string, messageID string) ([]bean.WhatsappMessageStatus, error) {
+	number, err := impl.repository.GetWhatsappNumberFromEmail(userEmail)
+	if err != nil || number == "" {
+		return nil, fmt.Errorf("error in getting number from email. Have you set your number in profile")
+	}
+	encryptedNumber, err := cryptography.EncryptData(number, number, impl.logger)
+	if err != nil {
+		return nil, err
+	}
+	return impl.repository.GetWhatsappMessageStatuses(encryptedNumber, messageID, maxWhatsappStatuses)
+}
@@ -3,6 +3,7 @@ package bean
 import (
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/gorilla/websocket"
+	"io"
 	"sync"
 	"time"
 )
@@ -47,18 +48,23 @@ type Response struct {
 }
 
 type GetLink struct {
-	ID       int    `sql:"id" json:"id,omitempty"`
-	Sender   string `sql:"sender" json:"sender,omitempty"`
-	Receiver string `sql:"receiver" json:"receiver,omitempty"`
-	Message  string `sql:"message" json:"message,omitempty"`
-	UUID     string `sql:"uuid" json:"uuid,omitempty"`
+	ID        int       `sql:"id" json:"id,omitempty"`
+	Sender    string    `sql:"sender" json:"sender,omitempty"`
+	Receiver  string    `sql:"receiver" json:"receiver,omitempty"`
+	Message   string    `sql:"message" json:"message,omitempty"`
+	UUID      string    `sql:"uuid" json:"uuid,omitempty"`
+	CreatedAt time.Time `sql:"created_at,default:now()" json:"created_at,omitempty"`
+	Tags      []string  `sql:"tags,array" json:"tags,omitempty"`
 }
 
+// PubSubMessage is a link pushed to the websockets, or another event when Event is set.
 type PubSubMessage struct {
-	Message string `json:"message,omitempty"`
-	UUID    string `json:"uuid,omitempty"`
-	ID      int    `json:"id,omitempty"`
-	Sender  string `json:"sender,omitempty"`
+	Message string      `json:"message,omitempty"`
+	UUID    string      `json:"uuid,omitempty"`
+	ID      int         `json:"id,omitempty"`
+	Sender  string      `json:"sender,omitempty"`
+	Event   string      `json:"event,omitempty"`
+	Data    interface{} `json:"data,omitempty"`
 }
 
 type Claims struct {
@@ -90,12 +96,28 @@ type WhatsAppConfig struct {
 	PhoneID     string `env:"PHONE_ID"`
 	AuthToken   string `env:"WHATSAPP_API_TOKEN"`
 	VerifyToken string `env:"VERIFY_TOKEN"`
+	AppSecret   string `env:"WHATSAPP_APP_SECRET"`
+	// WebhookMaxAge bounds how far a message timestamp may drift from now before it is treated as a replay.
+	WebhookMaxAge time.Duration `env:"WHATSAPP_WEBHOOK_MAX_AGE" envDefault:"5m"`
+	Workers       int           `env:"WHATSAPP_WORKERS" envDefault:"4"`
+	QueueSize     int           `env:"WHATSAPP_QUEUE_SIZE" envDefault:"100"`
+	DedupTTL      time.Duration `env:"WHATSAPP_DEDUP_TTL" envDefault:"24h"`
+	// ProcessingTTL holds a queued message id, so a message lost in a crash is processed when Meta retries it.
+	ProcessingTTL time.Duration `env:"WHATSAPP_PROCESSING_TTL" envDefault:"2m"`
+	// TemplateName is a pre-approved template with one body parameter, used outside the 24-hour window.
+	TemplateName     string `env:"WHATSAPP_TEMPLATE_NAME" envDefault:"getlink_message"`
+	TemplateLanguage string `env:"WHATSAPP_TEMPLATE_LANGUAGE" envDefault:"en"`
+	// StatusRetention is how long delivery statuses of messages sent from the website are kept.
+	StatusRetention time.Duration `env:"WHATSAPP_STATUS_RETENTION" envDefault:"720h"`
 }
 
 type TelegramCfg struct {
-	TelegramToken string `env:"TELEGRAM_TOKEN"`
-	BaseUrl       string `env:"BASE_URL"`
-	JwtKey        string `env:"JWT_KEY" envDefault:"secret"`
+	TelegramToken string        `env:"TELEGRAM_TOKEN"`
+	BaseUrl       string        `env:"BASE_URL"`
+	JwtKey        string        `env:"JWT_KEY" envDefault:"secret"`
+	Mode          string        `env:"TELEGRAM_MODE" envDefault:"polling"`
+	WebhookSecret string        `env:"TELEGRAM_WEBHOOK_SECRET"`
+	LeaderTTL     time.Duration `env:"TELEGRAM_LEADER_TTL" envDefault:"30s"`
 }
 
 type WhatsappEmail struct {
@@ -109,12 +131,23 @@ type TelegramEmail struct {
 	ChatId   string `sql:"chat_id" json:"chat_id,omitempty"`
 }
 
+type TelegramGroup struct {
+	ChatId    string    `sql:"chat_id,pk" json:"chat_id,omitempty"`
+	Email     string    `sql:"email,pk" json:"email,omitempty"`
+	CreatedAt time.Time `sql:"created_at,default:now()" json:"created_at,omitempty"`
+}
+
+// MailConfig is the shared SMTP server. Security is opportunistic (STARTTLS when the server offers it), starttls (required), tls or none.
 type MailConfig struct {
-	Host     string `env:"MAIL_HOST"`
-	Port     string `env:"MAIL_PORT"`
-	Username string `env:"MAIL_USERNAME"`
-	Password string `env:"MAIL_PASSWORD"`
-	From     string `env:"MAIL_FROM"`
+	Host               string `env:"MAIL_HOST"`
+	Port               string `env:"MAIL_PORT"`
+	Username           string `env:"MAIL_USERNAME"`
+	Password           string `env:"MAIL_PASSWORD"`
+	From               string `env:"MAIL_FROM"`
+	FromName           string `env:"MAIL_FROM_NAME" envDefault:"Get-Link"`
+	Security           string `env:"MAIL_SECURITY" envDefault:"opportunistic"`
+	InsecureSkipVerify bool   `env:"MAIL_TLS_INSECURE_SKIP_VERIFY" envDefault:"false"`
+	Type               string `env:"TYPE"`
 }
 
 type EncryptDecryptConfig struct {
@@ -137,3 +170,655 @@ type ShareFileClaims struct {
 	Email    string `json:"email,omitempty"`
 	jwt.RegisteredClaims
 }
+
+type InboundEmailCfg struct {
+	Domain        string `env:"INBOUND_EMAIL_DOMAIN"`
+	WebhookSecret string `env:"INBOUND_EMAIL_WEBHOOK_SECRET"`
+	BaseUrl       string `env:"BASE_URL"`
+	JwtKey        string `env:"JWT_KEY" envDefault:"secret"`
+	// RequireSenderAuth drops mail the provider did not see pass SPF or DKIM, since the From header is easy to forge.
+	RequireSenderAuth   bool          `env:"INBOUND_EMAIL_REQUIRE_SENDER_AUTH" envDefault:"true"`
+	VerificationTTL     time.Duration `env:"INBOUND_EMAIL_VERIFICATION_TTL" envDefault:"24h"`
+	MaxAttachmentSizeMB int64         `env:"INBOUND_EMAIL_MAX_ATTACHMENT_MB" envDefault:"25"`
+}
+
+type InboundEmailVerificationClaims struct {
+	Email  string `json:"email,omitempty"`
+	Sender string `json:"sender,omitempty"`
+	jwt.RegisteredClaims
+}
+
+type InboundEmailAddress struct {
+	Code  string `sql:"code,pk" json:"code,omitempty"`
+	Email string `sql:"email" json:"email,omitempty"`
+	Owner string `sql:"owner" json:"owner,omitempty"`
+}
+
+type InboundEmailSender struct {
+	Email  string `sql:"email,pk" json:"email,omitempty"`
+	Sender string `sql:"sender" json:"sender,omitempty"`
+}
+
+type InboundEmail struct {
+	From    string
+	To      string
+	Subject string
+	Text    string
+	// EnvelopeFrom is the SMTP MAIL FROM address that SPF checked. SPF and DKIM are the provider's verdicts, e.g.
+	// pass, or {@example.com : pass} for SendGrid's DKIM
+	EnvelopeFrom string
+	SPF          string
+	DKIM         string
+	Attachments []InboundEmailAttachment
+}
+
+type InboundEmailAttachment struct {
+	FileName string
+	Size     int64
+	Data     io.ReadCloser
+}
+
+type DigestCfg struct {
+	Interval      time.Duration `env:"DIGEST_INTERVAL" envDefault:"5m"`
+	BaseUrl       string        `env:"BASE_URL"`
+	JwtKey        string        `env:"JWT_KEY" envDefault:"secret"`
+	EncryptionKey string        `env:"ENCRYPTION_KEY"`
+}
+
+type DigestSubscription struct {
+	Email      string    `sql:"email,pk" json:"-"`
+	Frequency  string    `sql:"frequency" json:"frequency"`
+	Hour       int       `sql:"hour,notnull" json:"hour"`
+	Weekday    int       `sql:"weekday,notnull" json:"weekday"`
+	Timezone   string    `sql:"timezone" json:"timezone"`
+	LastSentAt time.Time `sql:"last_sent_at" json:"last_sent_at,omitempty"`
+	ReadUntil  time.Time `sql:"read_until" json:"-"`
+	CreatedAt  time.Time `sql:"created_at,default:now()" json:"created_at,omitempty"`
+}
+
+type DigestClaims struct {
+	Email  string `json:"email,omitempty"`
+	Action string `json:"action,omitempty"`
+	jwt.RegisteredClaims
+}
+
+type DigestSection struct {
+	Source string
+	Links  []GetLink
+	Files  []FileInfo
+}
+
+type VerificationMailData struct {
+	Intro      string
+	ActionUrl  string
+	ActionText string
+}
+
+type NotificationMailData struct {
+	Title      string
+	Message    string
+	ActionUrl  string
+	ActionText string
+}
+
+type DigestMailData struct {
+	Frequency      string
+	Since          string
+	Sections       []DigestMailSection
+	MarkReadUrl    string
+	UnsubscribeUrl string
+}
+
+type DigestMailSection struct {
+	Title string
+	Links []string
+	Files []DigestMailFile
+}
+
+type DigestMailFile struct {
+	Name   string
+	SizeKB int64
+	Url    string
+}
+
+type OutboxCfg struct {
+	Workers       int           `env:"OUTBOX_WORKERS" envDefault:"4"`
+	PollInterval  time.Duration `env:"OUTBOX_POLL_INTERVAL" envDefault:"2s"`
+	MaxAttempts   int           `env:"OUTBOX_MAX_ATTEMPTS" envDefault:"8"`
+	BaseBackoff   time.Duration `env:"OUTBOX_BASE_BACKOFF" envDefault:"10s"`
+	MaxBackoff    time.Duration `env:"OUTBOX_MAX_BACKOFF" envDefault:"1h"`
+	EncryptionKey string        `env:"ENCRYPTION_KEY"`
+	// ClaimTimeout is how long a message may stay sending before it is claimed again, so it must exceed the slowest send.
+	ClaimTimeout time.Duration `env:"OUTBOX_CLAIM_TIMEOUT" envDefault:"10m"`
+	// SentRetention and FailedRetention are how long delivered and permanently failed messages are kept.
+	SentRetention   time.Duration `env:"OUTBOX_SENT_RETENTION" envDefault:"24h"`
+	FailedRetention time.Duration `env:"OUTBOX_FAILED_RETENTION" envDefault:"168h"`
+}
+
+type AdminCfg struct {
+	AdminEmails []string `env:"ADMIN_EMAILS"`
+}
+
+type OutboxMessage struct {
+	ID            int       `sql:"id" json:"id"`
+	Channel       string    `sql:"channel" json:"channel"`
+	Recipient     string    `sql:"recipient" json:"recipient"`
+	Payload       string    `sql:"payload" json:"-"`
+	Status        string    `sql:"status" json:"status"`
+	Attempts      int       `sql:"attempts,notnull" json:"attempts"`
+	MaxAttempts   int       `sql:"max_attempts,notnull" json:"max_attempts"`
+	NextAttemptAt time.Time `sql:"next_attempt_at" json:"next_attempt_at"`
+	LastError     string    `sql:"last_error" json:"last_error,omitempty"`
+	CreatedAt     time.Time `sql:"created_at,default:now()" json:"created_at"`
+	UpdatedAt     time.Time `sql:"updated_at,default:now()" json:"updated_at"`
+}
+
+type OutboxMail struct {
+	Subject string `json:"subject"`
+	Text    string `json:"text"`
+	HTML    string `json:"html,omitempty"`
+	// From and FromName replace MAIL_FROM and MAIL_FROM_NAME for tenant mails
+	From     string `json:"from,omitempty"`
+	FromName string `json:"from_name,omitempty"`
+	// UnsubscribeUrl is sent as an RFC 8058 one-click List-Unsubscribe header
+	UnsubscribeUrl string `json:"unsubscribe_url,omitempty"`
+}
+
+type OutboxTelegramMessage struct {
+	Text    string             `json:"text"`
+	Buttons [][]TelegramButton `json:"buttons,omitempty"`
+	// FileName sends the stored file of Email in AppName as a photo or document instead of the text. It is read when
+	// sent, so queued messages stay small.
+	FileName string `json:"file_name,omitempty"`
+	Email    string `json:"email,omitempty"`
+	AppName  string `json:"app_name,omitempty"`
+}
+
+type TelegramButton struct {
+	Text         string `json:"text"`
+	Url          string `json:"url,omitempty"`
+	CallbackData string `json:"callback_data,omitempty"`
+}
+
+// MessengerCallback is the action behind a Telegram inline button or a WhatsApp reply button.
+type MessengerCallback struct {
+	Action   string `json:"action"`
+	LinkIDs  []int  `json:"link_ids,omitempty"`
+	Message  string `json:"message,omitempty"`
+	AppName  string `json:"app_name,omitempty"`
+	FileName string `json:"file_name,omitempty"`
+}
+
+type MirrorRule struct {
+	ID        int       `sql:"id" json:"id"`
+	Email     string    `sql:"email" json:"-"`
+	Source    string    `sql:"source" json:"source"`
+	Target    string    `sql:"target" json:"target"`
+	CreatedAt time.Time `sql:"created_at,default:now()" json:"created_at"`
+}
+
+type WhatsappSentMessage struct {
+	MessageID string `json:"message_id"`
+}
+
+type WhatsappMessageStatus struct {
+	MessageID      string    `sql:"message_id,pk" json:"message_id"`
+	WhatsappNumber string    `sql:"whatsapp_number" json:"-"`
+	Status         string    `sql:"status" json:"status"`
+	ErrorCode      int       `sql:"error_code" json:"error_code,omitempty"`
+	ErrorTitle     string    `sql:"error_title" json:"error_title,omitempty"`
+	CreatedAt      time.Time `sql:"created_at,default:now()" json:"created_at"`
+	UpdatedAt      time.Time `sql:"updated_at,default:now()" json:"updated_at"`
+}
+
+type LinkedAccount struct {
+	ID       string `json:"id"`
+	Channel  string `json:"channel"`
+	Identity string `json:"identity"`
+	Name     string `json:"name,omitempty"`
+	Address  string `json:"-"`
+}
+
+// ChannelIdentity links an account on a generic messenger channel to a Get-Link email. Like telegram_emails,
+// each link is stored twice: keyed by the encrypted identity, and keyed by the encrypted email.
+type ChannelIdentity struct {
+	Channel   string    `sql:"channel,pk" json:"channel"`
+	Identity  string    `sql:"identity,pk" json:"identity"`
+	Email     string    `sql:"email,pk" json:"email"`
+	CreatedAt time.Time `sql:"created_at,default:now()" json:"created_at"`
+}
+
+type ChannelVerificationClaims struct {
+	Email    string `json:"email,omitempty"`
+	Channel  string `json:"channel,omitempty"`
+	Identity string `json:"identity,omitempty"`
+	jwt.RegisteredClaims
+}
+
+// InboundMessage is a message received on any channel, already stripped of the channel's wire format.
+type InboundMessage struct {
+	Identity string
+	Name     string
+	Text     string
+	Files    []InboundFile
+}
+
+type OutboxTextMessage struct {
+	Text string `json:"text"`
+}
+
+type InboundFile struct {
+	Name string
+	Data []byte
+}
+
+type SlackCfg struct {
+	BaseUrl       string `env:"BASE_URL"`
+	BotToken      string `env:"SLACK_BOT_TOKEN"`
+	SigningSecret string `env:"SLACK_SIGNING_SECRET"`
+	// ApiUrl can point at a local fake server in development.
+	ApiUrl        string        `env:"SLACK_API_URL" envDefault:"https://slack.com/api"`
+	WebhookMaxAge time.Duration `env:"SLACK_WEBHOOK_MAX_AGE" envDefault:"5m"`
+	// EventDedupTTL is how long event ids are remembered, Slack retries an event for up to about an hour.
+	EventDedupTTL time.Duration `env:"SLACK_EVENT_DEDUP_TTL" envDefault:"2h"`
+}
+
+type SlackEventEnvelope struct {
+	Type      string     `json:"type"`
+	Challenge string     `json:"challenge,omitempty"`
+	TeamID    string     `json:"team_id,omitempty"`
+	EventID   string     `json:"event_id,omitempty"`
+	Event     SlackEvent `json:"event"`
+}
+
+type SlackEvent struct {
+	Type        string      `json:"type"`
+	Subtype     string      `json:"subtype,omitempty"`
+	User        string      `json:"user,omitempty"`
+	BotID       string      `json:"bot_id,omitempty"`
+	Text        string      `json:"text,omitempty"`
+	Channel     string      `json:"channel,omitempty"`
+	ChannelType string      `json:"channel_type,omitempty"`
+	Files       []SlackFile `json:"files,omitempty"`
+}
+
+type SlackFile struct {
+	ID                 string `json:"id"`
+	Name               string `json:"name"`
+	Mimetype           string `json:"mimetype"`
+	Size               int64  `json:"size"`
+	UrlPrivateDownload string `json:"url_private_download"`
+}
+
+type SlackSlashCommand struct {
+	Command   string
+	Text      string
+	UserID    string
+	UserName  string
+	ChannelID string
+}
+
+type SlackCommandResponse struct {
+	ResponseType string `json:"response_type"`
+	Text         string `json:"text"`
+}
+
+type SlackApiResponse struct {
+	Ok        bool   `json:"ok"`
+	Error     string `json:"error,omitempty"`
+	UploadUrl string `json:"upload_url,omitempty"`
+	FileID    string `json:"file_id,omitempty"`
+	Channel   struct {
+		ID string `json:"id"`
+	} `json:"channel,omitempty"`
+	User struct {
+		RealName string `json:"real_name"`
+	} `json:"user,omitempty"`
+}
+
+type DiscordCfg struct {
+	BotToken      string `env:"DISCORD_BOT_TOKEN"`
+	ApplicationID string `env:"DISCORD_APPLICATION_ID"`
+	// PublicKey is the hex encoded Ed25519 key used to sign interaction requests.
+	PublicKey string `env:"DISCORD_PUBLIC_KEY"`
+	// ApiUrl can point at a local fake server in development.
+	ApiUrl        string        `env:"DISCORD_API_URL" envDefault:"https://discord.com/api/v10"`
+	BaseUrl       string        `env:"BASE_URL"`
+	WebhookMaxAge time.Duration `env:"DISCORD_WEBHOOK_MAX_AGE" envDefault:"5m"`
+}
+
+type DiscordInteraction struct {
+	ID        string                 `json:"id"`
+	Type      int                    `json:"type"`
+	Token     string                 `json:"token"`
+	ChannelID string                 `json:"channel_id,omitempty"`
+	Data      DiscordInteractionData `json:"data"`
+	Member    *DiscordMember         `json:"member,omitempty"`
+	User      *DiscordUser           `json:"user,omitempty"`
+}
+
+type DiscordInteractionData struct {
+	Name     string                 `json:"name"`
+	Options  []DiscordCommandOption `json:"options,omitempty"`
+	Resolved struct {
+		Attachments map[string]DiscordAttachment `json:"attachments,omitempty"`
+	} `json:"resolved"`
+}
+
+type DiscordCommandOption struct {
+	Name  string `json:"name"`
+	Type  int    `json:"type"`
+	Value string `json:"value"`
+}
+
+type DiscordMember struct {
+	User DiscordUser `json:"user"`
+}
+
+type DiscordUser struct {
+	ID         string `json:"id"`
+	Username   string `json:"username"`
+	GlobalName string `json:"global_name,omitempty"`
+}
+
+type DiscordAttachment struct {
+	ID          string `json:"id"`
+	Filename    string `json:"filename"`
+	ContentType string `json:"content_type,omitempty"`
+	Size        int64  `json:"size"`
+	URL         string `json:"url"`
+}
+
+type DiscordInteractionResponse struct {
+	Type int             `json:"type"`
+	Data *DiscordMessage `json:"data,omitempty"`
+}
+
+type DiscordMessage struct {
+	Content string `json:"content"`
+	Flags   int    `json:"flags,omitempty"`
+}
+
+type DiscordApplicationCommand struct {
+	Name        string                    `json:"name"`
+	Description string                    `json:"description"`
+	Options     []DiscordCommandOptionDef `json:"options,omitempty"`
+}
+
+type DiscordCommandOptionDef struct {
+	Type        int    `json:"type"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Required    bool   `json:"required,omitempty"`
+}
+
+type SmsCfg struct {
+	BaseUrl    string `env:"BASE_URL"`
+	AccountSid string `env:"TWILIO_ACCOUNT_SID"`
+	AuthToken  string `env:"TWILIO_AUTH_TOKEN"`
+	FromNumber string `env:"TWILIO_FROM_NUMBER"`
+	// ApiUrl can point at any Twilio-compatible API, such as a local mock in development.
+	ApiUrl string `env:"TWILIO_API_URL" envDefault:"https://api.twilio.com"`
+	// UnlinkedReplyLimit caps the replies per hour to a number no account is linked to, since anyone can spoof a sender.
+	UnlinkedReplyLimit int `env:"SMS_UNLINKED_REPLY_LIMIT" envDefault:"3"`
+}
+
+// SmsMessage is the part of a Twilio inbound message webhook Get-Link uses.
+type SmsMessage struct {
+	MessageSid string
+	From       string
+	Body       string
+	Media      []SmsMedia
+}
+
+type SmsMedia struct {
+	Url         string
+	ContentType string
+}
+
+type SmsSendResponse struct {
+	Sid     string `json:"sid"`
+	Status  string `json:"status"`
+	Message string `json:"message,omitempty"`
+}
+
+type DiscordChannel struct {
+	ID string `json:"id"`
+}
+
+type WebPushCfg struct {
+	// Subject is the contact push services use to reach the sender, a mailto: or https: url.
+	Subject string `env:"WEB_PUSH_SUBJECT" envDefault:"mailto:contact.shyptsolution@gmail.com"`
+	// VapidPrivateKey is the base64url encoded P-256 scalar. When unset, a key is generated and kept in Postgres.
+	VapidPrivateKey string        `env:"VAPID_PRIVATE_KEY"`
+	TTL             time.Duration `env:"WEB_PUSH_TTL" envDefault:"24h"`
+	EncryptionKey   string        `env:"ENCRYPTION_KEY"`
+	// EndpointHosts are the push services subscriptions may point at, matching subdomains too, so the server only
+	// posts to them.
+	EndpointHosts []string `env:"WEB_PUSH_ENDPOINT_HOSTS" envDefault:"fcm.googleapis.com,updates.push.services.mozilla.com,push.apple.com,notify.windows.com"`
+}
+
+type VapidKey struct {
+	ID         int       `sql:"id,pk"`
+	PublicKey  string    `sql:"public_key"`
+	PrivateKey string    `sql:"private_key"`
+	CreatedAt  time.Time `sql:"created_at,default:now()"`
+}
+
+type PushSubscription struct {
+	ID        int       `sql:"id" json:"id"`
+	Email     string    `sql:"email" json:"-"`
+	UUID      string    `sql:"uuid" json:"uuid"`
+	Endpoint  string    `sql:"endpoint" json:"-"`
+	P256dh    string    `sql:"p256dh" json:"-"`
+	Auth      string    `sql:"auth" json:"-"`
+	CreatedAt time.Time `sql:"created_at,default:now()" json:"created_at"`
+}
+
+// PushSubscriptionRequest is the browser's PushSubscription.toJSON().
+type PushSubscriptionRequest struct {
+	Endpoint string `json:"endpoint"`
+	Keys     struct {
+		P256dh string `json:"p256dh"`
+		Auth   string `json:"auth"`
+	} `json:"keys"`
+}
+
+type WebPushPayload struct {
+	SubscriptionID int                 `json:"subscription_id"`
+	Notification   WebPushNotification `json:"notification"`
+}
+
+// WebPushNotification is the decrypted message the service worker receives.
+type WebPushNotification struct {
+	Event   string `json:"event"`
+	ID      int    `json:"id,omitempty"`
+	Title   string `json:"title,omitempty"`
+	Message string `json:"message"`
+	UUID    string `json:"uuid,omitempty"`
+	App     string `json:"app,omitempty"`
+}
+
+type FcmCfg struct {
+	// CredentialsFile is the Firebase service account json. ProjectID overrides its project_id.
+	CredentialsFile string `env:"FCM_CREDENTIALS_FILE"`
+	ProjectID       string `env:"FCM_PROJECT_ID"`
+	// ApiUrl and TokenUrl can point at a local mock. AccessToken, when set, is used instead of the service account.
+	ApiUrl      string        `env:"FCM_API_URL" envDefault:"https://fcm.googleapis.com"`
+	TokenUrl    string        `env:"FCM_TOKEN_URL"`
+	AccessToken string        `env:"FCM_ACCESS_TOKEN"`
+	TTL         time.Duration `env:"FCM_TTL" envDefault:"24h"`
+}
+
+type FcmServiceAccount struct {
+	ProjectID   string `json:"project_id"`
+	PrivateKey  string `json:"private_key"`
+	ClientEmail string `json:"client_email"`
+	TokenUri    string `json:"token_uri"`
+}
+
+type FcmToken struct {
+	ID        int       `sql:"id" json:"id"`
+	Email     string    `sql:"email" json:"-"`
+	UUID      string    `sql:"uuid" json:"uuid"`
+	Token     string    `sql:"token" json:"-"`
+	Platform  string    `sql:"platform" json:"platform"`
+	CreatedAt time.Time `sql:"created_at,default:now()" json:"created_at"`
+	UpdatedAt time.Time `sql:"updated_at,default:now()" json:"updated_at"`
+}
+
+type FcmTokenRequest struct {
+	Token    string `json:"token"`
+	Platform string `json:"platform"`
+}
+
+type FcmPayload struct {
+	TokenID     int               `json:"token_id"`
+	CollapseKey string            `json:"collapse_key"`
+	Title       string            `json:"title"`
+	Body        string            `json:"body"`
+	Data        map[string]string `json:"data"`
+}
+
+type FcmSendRequest struct {
+	Message FcmMessage `json:"message"`
+}
+
+type FcmMessage struct {
+	Token        string            `json:"token"`
+	Data         map[string]string `json:"data,omitempty"`
+	Notification *FcmNotification  `json:"notification,omitempty"`
+	Android      *FcmAndroidConfig `json:"android,omitempty"`
+	Apns         *FcmApnsConfig    `json:"apns,omitempty"`
+}
+
+type FcmNotification struct {
+	Title string `json:"title,omitempty"`
+	Body  string `json:"body,omitempty"`
+}
+
+type FcmAndroidConfig struct {
+	CollapseKey  string                  `json:"collapse_key,omitempty"`
+	Priority     string                  `json:"priority,omitempty"`
+	Ttl          string                  `json:"ttl,omitempty"`
+	Notification *FcmAndroidNotification `json:"notification,omitempty"`
+}
+
+type FcmAndroidNotification struct {
+	Tag string `json:"tag,omitempty"`
+}
+
+type FcmApnsConfig struct {
+	Headers map[string]string `json:"headers,omitempty"`
+}
+
+type FcmErrorResponse struct {
+	Error struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+		Status  string `json:"status"`
+		Details []struct {
+			Type      string `json:"@type"`
+			ErrorCode string `json:"errorCode"`
+		} `json:"details"`
+	} `json:"error"`
+}
+
+type OAuthTokenResponse struct {
+	AccessToken string `json:"access_token"`
+	ExpiresIn   int    `json:"expires_in"`
+	TokenType   string `json:"token_type"`
+}
+
+type NotificationCfg struct {
+	// DefaultMaxPerHour caps notifications per channel for users who have not set their own cap.
+	DefaultMaxPerHour int `env:"NOTIFICATION_MAX_PER_HOUR" envDefault:"30"`
+}
+
+type MessagesCfg struct {
+	// Dir holds operator overrides, one <locale>.tmpl file per language, e.g. en.tmpl to rebrand the replies.
+	Dir           string `env:"MESSAGES_DIR"`
+	DefaultLocale string `env:"DEFAULT_LOCALE" envDefault:"en"`
+}
+
+type NotificationSettings struct {
+	Email      string    `sql:"email,pk" json:"-"`
+	Timezone   string    `sql:"timezone" json:"timezone"`
+	QuietStart string    `sql:"quiet_start" json:"quiet_start"`
+	QuietEnd   string    `sql:"quiet_end" json:"quiet_end"`
+	Locale     string    `sql:"locale" json:"locale"`
+	UpdatedAt  time.Time `sql:"updated_at,default:now()" json:"-"`
+}
+
+type NotificationPreference struct {
+	Email   string   `sql:"email,pk" json:"-"`
+	Channel string   `sql:"channel,pk" json:"channel"`
+	Events  []string `sql:"events,array" json:"events"`
+	// MaxPerHour of 0 means no cap.
+	MaxPerHour int       `sql:"max_per_hour,notnull" json:"max_per_hour"`
+	UpdatedAt  time.Time `sql:"updated_at,default:now()" json:"-"`
+}
+
+type NotificationPreferences struct {
+	Timezone   string `json:"timezone"`
+	QuietStart string `json:"quiet_start"`
+	QuietEnd   string `json:"quiet_end"`
+	// Locale is the language of bot replies. Empty means detect it, e.g. from the Telegram app language.
+	Locale   string                   `json:"locale"`
+	Channels []NotificationPreference `json:"channels"`
+}
+
+// Notification is an event sent through every channel the user allows for it.
+type Notification struct {
+	Title     string `json:"title"`
+	Message   string `json:"message"`
+	ActionUrl string `json:"action_url,omitempty"`
+}
+
+type TenantCfg struct {
+	// File is a JSON list of tenants. Without it a single tenant is built from the WhatsApp, Telegram and mail env.
+	File string `env:"TENANTS_FILE"`
+	// CacheTTL is how long the tenant of a WhatsApp number or Telegram chat is cached in Redis.
+	CacheTTL time.Duration `env:"TENANT_CACHE_TTL" envDefault:"24h"`
+	// Signature, FeedbackUrl and ChromeExtensionID brand the tenant built from env.
+	Signature         []string `env:"TENANT_SIGNATURE" envDefault:"Raunit Verma,Shypt Solution"`
+	FeedbackUrl       string   `env:"TENANT_FEEDBACK_URL" envDefault:"https://codingkaro.in"`
+	ChromeExtensionID string   `env:"CHROME_EXTENSION_ID" envDefault:"pcphjmlofajahcidbgfgphicmmdfkdif"`
+}
+
+// Tenant is a white-label deployment with its own WhatsApp number, Telegram bot, branding and mail sender.
+type Tenant struct {
+	ID              string `json:"id"`
+	Name            string `json:"name"`
+	WhatsappPhoneID string `json:"whatsapp_phone_id"`
+	// WhatsappAccessToken calls the Cloud API for the number and WhatsappAppSecret signs its webhooks.
+	WhatsappAccessToken string `json:"whatsapp_access_token"`
+	WhatsappAppSecret   string `json:"whatsapp_app_secret"`
+	TelegramToken       string `json:"telegram_token"`
+	MailFrom            string `json:"mail_from"`
+	MailFromName        string `json:"mail_from_name"`
+	// MessagesDir holds <locale>.tmpl overrides of the bot replies for this tenant, parsed after MESSAGES_DIR.
+	MessagesDir    string       `json:"messages_dir"`
+	AllowedOrigins []string     `json:"allowed_origins"`
+	Quotas         TenantQuotas `json:"quotas"`
+	// Signature closes bot replies and mails, one line each. Without it the tenant name is used.
+	Signature []string `json:"signature"`
+	// FeedbackUrl is where replies and mails send users with feedback. Without it the feedback line is left out.
+	FeedbackUrl string `json:"feedback_url"`
+	// ChromeExtensionID is the tenant's browser extension, whose origin is allowed along with AllowedOrigins.
+	ChromeExtensionID string `json:"chrome_extension_id"`
+}
+
+// ChannelTenant records the tenant an identity last wrote to, keyed by the identity encrypted with itself.
+type ChannelTenant struct {
+	Channel   string    `sql:"channel,pk" json:"channel"`
+	Identity  string    `sql:"identity,pk" json:"identity"`
+	TenantID  string    `sql:"tenant_id" json:"tenant_id"`
+	UpdatedAt time.Time `sql:"updated_at,default:now()" json:"updated_at"`
+}
+
+// TenantQuotas caps the WhatsApp and Telegram folders of users who write to the tenant. Zero keeps the default.
+type TenantQuotas struct {
+	FreeFileLimitMB    int `json:"free_file_limit_mb"`
+	PremiumFileLimitMB int `json:"premium_file_limit_mb"`
+}
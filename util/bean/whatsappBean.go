@@ -20,6 +20,22 @@ type WhatsAppBusinessValue struct {
 	Metadata         WhatsAppBusinessMetadata      `json:"metadata,omitempty"`
 	Contacts         []WhatsAppBusinessContact     `json:"contacts,omitempty"`
 	Messages         []WhatsAppBusinessMessageData `json:"messages,omitempty"`
+	Statuses         []WhatsAppBusinessStatus      `json:"statuses,omitempty"`
+}
+
+type WhatsAppBusinessStatus struct {
+	ID          string                        `json:"id"`
+	Status      string                        `json:"status"`
+	Timestamp   string                        `json:"timestamp"`
+	RecipientID string                        `json:"recipient_id"`
+	Errors      []WhatsAppBusinessStatusError `json:"errors,omitempty"`
+	// BizOpaqueCallbackData echoes the tracking id of a message sent from the website
+	BizOpaqueCallbackData string `json:"biz_opaque_callback_data,omitempty"`
+}
+
+type WhatsAppBusinessStatusError struct {
+	Code  int    `json:"code"`
+	Title string `json:"title"`
 }
 
 type WhatsAppBusinessMetadata struct {
@@ -37,14 +53,20 @@ type WhatsAppBusinessProfile struct {
 }
 
 type WhatsAppBusinessMessageData struct {
-	From      string                       `json:"from"`
-	ID        string                       `json:"id"`
-	Timestamp string                       `json:"timestamp"`
-	Text      WhatsAppBusinessTextData     `json:"text,omitempty"`
-	Image     WhatsAppBusinessImageData    `json:"image,omitempty"`
-	Document  WhatsAppBusinessDocumentData `json:"document,omitempty"`
-	Video     WhatsAppBusinessVideoData    `json:"video,omitempty"`
-	Type      string                       `json:"type"`
+	From        string                           `json:"from"`
+	ID          string                           `json:"id"`
+	Timestamp   string                           `json:"timestamp"`
+	Text        WhatsAppBusinessTextData         `json:"text,omitempty"`
+	Image       WhatsAppBusinessImageData        `json:"image,omitempty"`
+	Document    WhatsAppBusinessDocumentData     `json:"document,omitempty"`
+	Video       WhatsAppBusinessVideoData        `json:"video,omitempty"`
+	Audio       WhatsAppBusinessAudioData        `json:"audio,omitempty"`
+	Sticker     WhatsAppBusinessStickerData      `json:"sticker,omitempty"`
+	Location    WhatsAppBusinessLocationData     `json:"location,omitempty"`
+	Contacts    []WhatsAppBusinessSharedContact  `json:"contacts,omitempty"`
+	Interactive WhatsAppBusinessInteractiveReply `json:"interactive,omitempty"`
+	Button      WhatsAppBusinessButtonReply      `json:"button,omitempty"`
+	Type        string                           `json:"type"`
 }
 
 type WhatsAppBusinessTextData struct {
@@ -55,12 +77,14 @@ type WhatsAppBusinessImageData struct {
 	MimeType string `json:"mime_type,omitempty"`
 	Sha256   string `json:"sha256,omitempty"`
 	ID       string `json:"id,omitempty"`
+	Caption  string `json:"caption,omitempty"`
 }
 
 type WhatsAppBusinessVideoData struct {
 	MimeType string `json:"mime_type,omitempty"`
 	Sha256   string `json:"sha256,omitempty"`
 	ID       string `json:"id,omitempty"`
+	Caption  string `json:"caption,omitempty"`
 }
 
 type WhatsAppBusinessDocumentData struct {
@@ -68,13 +92,71 @@ type WhatsAppBusinessDocumentData struct {
 	MimeType string `json:"mime_type"`
 	Sha256   string `json:"sha256"`
 	ID       string `json:"id"`
+	Caption  string `json:"caption,omitempty"`
+}
+
+type WhatsAppBusinessAudioData struct {
+	MimeType string `json:"mime_type,omitempty"`
+	Sha256   string `json:"sha256,omitempty"`
+	ID       string `json:"id,omitempty"`
+	Voice    bool   `json:"voice,omitempty"`
+}
+
+type WhatsAppBusinessStickerData struct {
+	MimeType string `json:"mime_type,omitempty"`
+	Sha256   string `json:"sha256,omitempty"`
+	ID       string `json:"id,omitempty"`
+	Animated bool   `json:"animated,omitempty"`
+}
+
+type WhatsAppBusinessLocationData struct {
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+	Name      string  `json:"name,omitempty"`
+	Address   string  `json:"address,omitempty"`
+}
+
+type WhatsAppBusinessSharedContact struct {
+	Name   WhatsAppBusinessContactName    `json:"name"`
+	Phones []WhatsAppBusinessContactPhone `json:"phones,omitempty"`
+	Emails []WhatsAppBusinessContactEmail `json:"emails,omitempty"`
+	Org    WhatsAppBusinessContactOrg     `json:"org,omitempty"`
+	Urls   []WhatsAppBusinessContactUrl   `json:"urls,omitempty"`
+}
+
+type WhatsAppBusinessContactName struct {
+	FormattedName string `json:"formatted_name"`
+	FirstName     string `json:"first_name,omitempty"`
+	LastName      string `json:"last_name,omitempty"`
+}
+
+type WhatsAppBusinessContactPhone struct {
+	Phone string `json:"phone"`
+	Type  string `json:"type,omitempty"`
+	WaID  string `json:"wa_id,omitempty"`
+}
+
+type WhatsAppBusinessContactEmail struct {
+	Email string `json:"email"`
+	Type  string `json:"type,omitempty"`
+}
+
+type WhatsAppBusinessContactOrg struct {
+	Company string `json:"company,omitempty"`
+	Title   string `json:"title,omitempty"`
+}
+
+type WhatsAppBusinessContactUrl struct {
+	Url  string `json:"url"`
+	Type string `json:"type,omitempty"`
 }
 
 type WhatsAppBusinessSendTextMessage struct {
-	MessagingProduct string                   `json:"messaging_product"`
-	To               string                   `json:"to"`
-	Type             string                   `json:"type"`
-	Text             WhatsAppBusinessTextData `json:"text"`
+	MessagingProduct      string                   `json:"messaging_product"`
+	To                    string                   `json:"to"`
+	Type                  string                   `json:"type"`
+	Text                  WhatsAppBusinessTextData `json:"text"`
+	BizOpaqueCallbackData string                   `json:"biz_opaque_callback_data,omitempty"`
 }
 
 type WhatsappMedia struct {
@@ -85,3 +167,107 @@ type WhatsappMedia struct {
 	Id               string `json:"id"`
 	MessagingProduct string `json:"messaging_product"`
 }
+
+type WhatsAppBusinessSendMediaMessage struct {
+	MessagingProduct string                     `json:"messaging_product"`
+	To               string                     `json:"to"`
+	Type             string                     `json:"type"`
+	Image            *WhatsAppBusinessMediaData `json:"image,omitempty"`
+	Video            *WhatsAppBusinessMediaData `json:"video,omitempty"`
+	Audio            *WhatsAppBusinessMediaData `json:"audio,omitempty"`
+	Document         *WhatsAppBusinessMediaData `json:"document,omitempty"`
+}
+
+type WhatsAppBusinessMediaData struct {
+	ID       string `json:"id"`
+	Filename string `json:"filename,omitempty"`
+}
+
+type WhatsAppBusinessUploadResponse struct {
+	ID string `json:"id"`
+}
+
+type WhatsAppBusinessSendInteractiveMessage struct {
+	MessagingProduct string                      `json:"messaging_product"`
+	To               string                      `json:"to"`
+	Type             string                      `json:"type"`
+	Interactive      WhatsAppBusinessInteractive `json:"interactive"`
+}
+
+type WhatsAppBusinessInteractive struct {
+	Type   string                            `json:"type"`
+	Body   WhatsAppBusinessTextData          `json:"body"`
+	Footer *WhatsAppBusinessTextData         `json:"footer,omitempty"`
+	Action WhatsAppBusinessInteractiveAction `json:"action"`
+}
+
+type WhatsAppBusinessInteractiveAction struct {
+	Button   string                        `json:"button,omitempty"`
+	Buttons  []WhatsAppBusinessReplyButton `json:"buttons,omitempty"`
+	Sections []WhatsAppBusinessListSection `json:"sections,omitempty"`
+}
+
+type WhatsAppBusinessReplyButton struct {
+	Type  string                `json:"type"`
+	Reply WhatsAppBusinessReply `json:"reply"`
+}
+
+type WhatsAppBusinessReply struct {
+	ID          string `json:"id"`
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
+}
+
+type WhatsAppBusinessListSection struct {
+	Title string                  `json:"title,omitempty"`
+	Rows  []WhatsAppBusinessReply `json:"rows"`
+}
+
+type WhatsAppBusinessInteractiveReply struct {
+	Type        string                `json:"type,omitempty"`
+	ButtonReply WhatsAppBusinessReply `json:"button_reply,omitempty"`
+	ListReply   WhatsAppBusinessReply `json:"list_reply,omitempty"`
+}
+
+type WhatsAppBusinessButtonReply struct {
+	Payload string `json:"payload,omitempty"`
+	Text    string `json:"text,omitempty"`
+}
+
+type WhatsAppBusinessSendTemplateMessage struct {
+	MessagingProduct      string                   `json:"messaging_product"`
+	To                    string                   `json:"to"`
+	Type                  string                   `json:"type"`
+	Template              WhatsAppBusinessTemplate `json:"template"`
+	BizOpaqueCallbackData string                   `json:"biz_opaque_callback_data,omitempty"`
+}
+
+type WhatsAppBusinessTemplate struct {
+	Name       string                              `json:"name"`
+	Language   WhatsAppBusinessTemplateLanguage    `json:"language"`
+	Components []WhatsAppBusinessTemplateComponent `json:"components,omitempty"`
+}
+
+type WhatsAppBusinessTemplateLanguage struct {
+	Code string `json:"code"`
+}
+
+type WhatsAppBusinessTemplateComponent struct {
+	Type       string                              `json:"type"`
+	Parameters []WhatsAppBusinessTemplateParameter `json:"parameters"`
+}
+
+type WhatsAppBusinessTemplateParameter struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+// WhatsAppBusinessOutgoingMessage reads the text out of any queued outbound message.
+type WhatsAppBusinessOutgoingMessage struct {
+	Type        string                   `json:"type"`
+	Text        WhatsAppBusinessTextData `json:"text"`
+	Interactive struct {
+		Body WhatsAppBusinessTextData `json:"body"`
+	} `json:"interactive"`
+	BizOpaqueCallbackData string `json:"biz_opaque_callback_data,omitempty"`
+}
@@ -5,22 +5,68 @@ const (
 	DEVELOPMENT                     = "development"
 	WhatsappCloudApiSendMessage     = `https://graph.facebook.com/v19.0/%s/messages`
 	WhatsappCloudApiGetMediaDataUrl = `https://graph.facebook.com/v20.0/%s`
-	ChromeExtensionUrl              = "chrome-extension://pcphjmlofajahcidbgfgphicmmdfkdif"
+	WhatsappCloudApiUploadMedia     = `https://graph.facebook.com/v19.0/%s/media`
+	ChromeExtensionOrigin           = "chrome-extension://%s"
 	PathToFiles                     = "/tmp/data/%s/%s"
 	FreeWhatsappFileLimitSizeMB     = 500
 	FreeTelegramFileLimitSizeMB     = 500
 	PremiumWhatsappFileLimitSizeMB  = 100
 	PremiumTelegramFileLimitSizeMB  = 100
+	FreeEmailFileLimitSizeMB        = 100
+	PremiumEmailFileLimitSizeMB     = 500
+	FreeChannelFileLimitSizeMB      = 100
+	PremiumChannelFileLimitSizeMB   = 500
 	FreeGetLinkFileLimitSizeMB      = 1000
 	PremiumGetLinkFileLimitSizeMB   = 2000
 )
 
+// DefaultAllowedOrigins are the CORS origins of the default tenant, besides its Chrome extension.
+var DefaultAllowedOrigins = []string{
+	"https://codingkaro.in",
+	"https://www.codingkaro.in",
+	"https://getlink.codingkaro.in",
+	"https://getlink.shyptsolution.com",
+	"http://codingkaro.in",
+	"http://getlink.codingkaro.in",
+	"https://shyptsolution.com",
+	"https://www.shyptsolution.com",
+}
+
 // routes
 
 const (
 	WhatsappWebhook     = "/whatsapp-webhook"
 	VerifyWhatsappEmail = "/verify-whatsapp-email"
 	VerifyTelegramEmail = "/verify-telegram-email"
+	InboundEmailWebhook = "/email-webhook"
+	VerifyInboundEmail  = "/verify-inbound-email"
+	DigestUnsubscribe   = "/digest/unsubscribe"
+	DigestMarkRead      = "/digest/mark-read"
+	TelegramWebhook     = "/telegram-webhook"
+	VerifyChannelEmail  = "/verify-channel-email"
+	SlackEventsWebhook  = "/slack/events"
+	SlackCommandWebhook = "/slack/commands"
+	DiscordWebhook      = "/discord/interactions"
+	SmsWebhook          = "/sms-webhook"
+)
+
+const (
+	MailTemplateVerification  = "verification"
+	MailTemplateDigest        = "digest"
+	MailTemplateNotification  = "notification"
+	MailSecurityOpportunistic = "opportunistic"
+	MailSecurityStartTLS      = "starttls"
+	MailSecurityTLS           = "tls"
+	MailSecurityNone          = "none"
+)
+
+const (
+	DigestDaily             = "daily"
+	DigestWeekly            = "weekly"
+	DigestActionUnsubscribe = "unsubscribe"
+	DigestActionMarkRead    = "mark-read"
+	// DigestAudience marks digest action tokens so they cannot be used as session tokens
+	DigestAudience = "digest"
 )
 
 const (
@@ -31,4 +77,42 @@ const (
 	WHATSAPP      = "whatsapp"
 	TELEGRAM      = "telegram"
 	GETLINK       = "getlink"
+	MAIL          = "mail"
+	SLACK         = "slack"
+	DISCORD       = "discord"
+	SMS           = "sms"
+	WEBPUSH       = "webpush"
+	FCM           = "fcm"
+	WEBSOCKET     = "websocket"
+)
+
+const (
+	MirrorAnySource = "*"
+)
+
+const (
+	TelegramModePolling = "polling"
+	TelegramModeWebhook = "webhook"
+)
+
+const (
+	WhatsappStatusAccepted  = "accepted"
+	WhatsappStatusSent      = "sent"
+	WhatsappStatusDelivered = "delivered"
+	WhatsappStatusRead      = "read"
+	WhatsappStatusFailed    = "failed"
+	EventWhatsappStatus     = "whatsapp-status"
+)
+
+const (
+	OutboxPending = "pending"
+	OutboxSending = "sending"
+	OutboxSent    = "sent"
+	OutboxFailed  = "failed"
+)
+
+const (
+	EventLinkReceived    = "link_received"
+	EventFileReceived    = "file_received"
+	EventShareDownloaded = "share_downloaded"
 )
@@ -0,0 +1,18 @@
+package util
+
+import "expvar"
+
+// webhookMetrics counts webhook outcomes per channel, e.g. "whatsapp.rejected_signature".
+var webhookMetrics = expvar.NewMap("webhooks")
+
+const (
+	WebhookAccepted          = "accepted"
+	WebhookRejectedUnsigned  = "rejected_unsigned"
+	WebhookRejectedSignature = "rejected_signature"
+	WebhookRejectedStale     = "rejected_stale"
+	WebhookRejectedTenant    = "rejected_tenant"
+)
+
+func CountWebhook(channel, outcome string) {
+	webhookMetrics.Add(channel+"."+outcome, 1)
+}
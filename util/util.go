@@ -9,12 +9,28 @@ import (
 	"time"
 )
 
+// fallbackExtensions covers messenger media types missing from minimal mime.types tables.
+var fallbackExtensions = map[string]string{
+	"audio/aac":  ".aac",
+	"audio/amr":  ".amr",
+	"audio/mp4":  ".m4a",
+	"audio/mpeg": ".mp3",
+	"audio/ogg":  ".ogg",
+	"image/jpeg": ".jpg",
+	"image/webp": ".webp",
+	"video/3gpp": ".3gp",
+	"video/mp4":  ".mp4",
+}
+
 func GetFileExtension(mimeType string) (string, error) {
 	extensions, err := mime.ExtensionsByType(mimeType)
 	if err != nil {
 		return "", err
 	}
 	if len(extensions) == 0 {
+		if mediaType, _, err := mime.ParseMediaType(mimeType); err == nil && fallbackExtensions[mediaType] != "" {
+			return fallbackExtensions[mediaType], nil
+		}
 		return "", fmt.Errorf("no extensions found for MIME type %s", mimeType)
 	}
 	return extensions[0], nil
@@ -50,3 +66,13 @@ func SanitizeFilename(filename string) string {
 func GetFileNameFromType(fileType, mimeType string) string {
 	return SanitizeFilename(fmt.Sprintf("%s_%s_From-Get-Link", fileType, time.Now().UTC().Format(time.RFC1123)))
 }
+
+func MaskIdentity(identity string) string {
+	if at := strings.Index(identity, "@"); at > 0 {
+		return identity[:1] + strings.Repeat("*", at-1) + identity[at:]
+	}
+	if len(identity) <= 4 {
+		return strings.Repeat("*", len(identity))
+	}
+	return strings.Repeat("*", len(identity)-4) + identity[len(identity)-4:]
+}